@@ -0,0 +1,45 @@
+package command
+
+import (
+	"fmt"
+	"time"
+)
+
+// accountBucket is the bucket of the state database of the owner of the output dir, at key accountOwnerKey.
+const (
+	accountBucket   = "account"
+	accountOwnerKey = "owner"
+)
+
+// accountOwner is the account whose library is in an output dir, recorded on its first run,
+// so another account isn't synced into it by mistake, like a wrong --username or config profile.
+type accountOwner struct {
+	AppleID   string    `json:"apple_id"`
+	DSID      string    `json:"dsid"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// checkAccountOwner records the account as the owner of dir in the state database, or fails when another account owns it,
+// force gives dir to the account. The accounts are told apart by dsid, an apple id can have many emails.
+func checkAccountOwner(db *stateDB, dir, appleID, dsid string, force bool) error {
+	if dsid == "" {
+		return nil
+	}
+	owner := new(accountOwner)
+	if ok, err := db.Get(accountBucket, accountOwnerKey, owner); err != nil {
+		return err
+	} else if ok {
+		if owner.DSID == dsid {
+			return nil
+		}
+		if !force {
+			return fmt.Errorf("output dir %s has the library of %s, not %s, use another output dir, or --force to sync %s into it", dir, owner.AppleID, appleID, appleID)
+		}
+		fmt.Printf("output dir %s had the library of %s, now %s\n", dir, owner.AppleID, appleID)
+	}
+
+	if err := db.Put(accountBucket, accountOwnerKey, &accountOwner{AppleID: appleID, DSID: dsid, CreatedAt: time.Now()}); err != nil {
+		return err
+	}
+	return db.Sync()
+}
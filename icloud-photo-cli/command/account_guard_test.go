@@ -0,0 +1,59 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// Another account isn't synced into the output dir without --force.
+func TestAccountOwner(t *testing.T) {
+	server := newMockServer(t)
+	server.AddAsset(&icloudmock.Asset{Filename: "IMG_0001.JPG", Data: []byte("photo")})
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+	db, err := openStateDB(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put(accountBucket, accountOwnerKey, &accountOwner{AppleID: "other@example.com", DSID: "2000000002"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = runDownload(t, cookieDir, outputDir)
+	if err == nil || !strings.Contains(err.Error(), "has the library of other@example.com") {
+		t.Fatalf("download into the output dir of another account: %v", err)
+	}
+	if owner := outputDirOwner(t, outputDir); owner.DSID != "2000000002" {
+		t.Errorf("owner %s, want 2000000002", owner.DSID)
+	}
+
+	if err := runDownload(t, cookieDir, outputDir, "--force"); err != nil {
+		t.Fatal(err)
+	}
+	if owner := outputDirOwner(t, outputDir); owner.DSID != icloudmock.Dsid || owner.AppleID != icloudmock.AppleID {
+		t.Errorf("owner %s %s, want %s %s", owner.AppleID, owner.DSID, icloudmock.AppleID, icloudmock.Dsid)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "IMG_0001.JPG")); err != nil {
+		t.Errorf("IMG_0001.JPG not downloaded: %s", err)
+	}
+}
+
+func outputDirOwner(t *testing.T, outputDir string) *accountOwner {
+	t.Helper()
+	db, err := openStateDB(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	owner := new(accountOwner)
+	if _, err := db.Get(accountBucket, accountOwnerKey, owner); err != nil {
+		t.Fatal(err)
+	}
+	return owner
+}
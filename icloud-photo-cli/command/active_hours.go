@@ -0,0 +1,109 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// activeHours is the --active-hours windows transfers are allowed in, like "22:00-07:00,12:00-13:00",
+// a window ending before it starts wraps over midnight.
+type activeHours struct {
+	windows []activeWindow
+	lock    sync.Mutex
+	waiting bool
+}
+
+type activeWindow struct {
+	start, end time.Duration // since midnight
+}
+
+func parseActiveHours(s string) (*activeHours, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	r := new(activeHours)
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(item), "-")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid --active-hours %q, want like 22:00-07:00", item)
+		}
+		start, err1 := parseClock(parts[0])
+		end, err2 := parseClock(parts[1])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid --active-hours %q, want like 22:00-07:00", item)
+		}
+		r.windows = append(r.windows, activeWindow{start: start, end: end})
+	}
+	return r, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// untilActive returns how long until now is in a window, 0 if it's already in one.
+func (r *activeHours) untilActive(now time.Time) time.Duration {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	clock := now.Sub(midnight)
+
+	wait := 24 * time.Hour
+	for _, w := range r.windows {
+		in := clock >= w.start && clock < w.end
+		if w.end <= w.start {
+			in = clock >= w.start || clock < w.end
+		}
+		if in {
+			return 0
+		}
+		d := w.start - clock
+		if d < 0 {
+			d += 24 * time.Hour
+		}
+		if d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// Wait blocks until now is in an active window, or ctx is done, a nil activeHours never blocks.
+func (r *activeHours) Wait(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	for {
+		wait := r.untilActive(time.Now())
+		if wait == 0 {
+			r.lock.Lock()
+			r.waiting = false
+			r.lock.Unlock()
+			return nil
+		}
+
+		r.lock.Lock()
+		if !r.waiting {
+			r.waiting = true
+			fmt.Printf("outside --active-hours, pause transfers for %s\n", wait.Round(time.Second))
+		}
+		r.lock.Unlock()
+
+		// recheck at least every minute, in case the clock jumps
+		if wait > time.Minute {
+			wait = time.Minute
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
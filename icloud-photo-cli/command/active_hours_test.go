@@ -0,0 +1,53 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestActiveHoursUntilActive(t *testing.T) {
+	hours, err := parseActiveHours("22:00-07:00,12:00-13:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	for _, tc := range []struct {
+		now  time.Time
+		want time.Duration
+	}{
+		{now: at(23, 0), want: 0},
+		{now: at(6, 59), want: 0},
+		{now: at(12, 30), want: 0},
+		{now: at(7, 0), want: 5 * time.Hour},
+		{now: at(13, 0), want: 9 * time.Hour},
+		{now: at(21, 30), want: 30 * time.Minute},
+	} {
+		if got := hours.untilActive(tc.now); got != tc.want {
+			t.Errorf("untilActive(%s) = %s, want %s", tc.now.Format("15:04"), got, tc.want)
+		}
+	}
+}
+
+func TestActiveHoursWaitContext(t *testing.T) {
+	now := time.Now()
+	start := now.Add(2 * time.Hour)
+	// a window starting in 2 hours, for a minute
+	hours, err := parseActiveHours(start.Format("15:04") + "-" + start.Add(time.Minute).Format("15:04"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := hours.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait should return when ctx is done, got %v", err)
+	}
+
+	var none *activeHours
+	if err := none.Wait(ctx); err != nil {
+		t.Errorf("nil activeHours should not block, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package command
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// adjustmentSidecarPath returns the .AAE path of the photo at path, like IMG_0001.AAE for IMG_0001.HEIC,
+// which is where Photos keeps the edits of an exported original.
+func adjustmentSidecarPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".AAE"
+}
+
+// writeAdjustmentSidecar writes the edit recipe of an edited photo as an .AAE plist next to the original,
+// photos without edits are skipped.
+func writeAdjustmentSidecar(photo *icloudgo.PhotoAsset, path string) error {
+	adjustment, ok := photo.Adjustment()
+	if !ok {
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	buf.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	if len(adjustment.Data) > 0 {
+		fmt.Fprintf(buf, "\t<key>adjustmentData</key>\n\t<data>%s</data>\n", base64.StdEncoding.EncodeToString(adjustment.Data))
+	}
+	for _, kv := range [][2]string{
+		{"adjustmentEditorBundleID", adjustment.CreatorCode},
+		{"adjustmentFormatIdentifier", adjustment.FormatIdentifier},
+		{"adjustmentFormatVersion", adjustment.FormatVersion},
+	} {
+		if kv[1] == "" {
+			continue
+		}
+		fmt.Fprintf(buf, "\t<key>%s</key>\n\t<string>", kv[0])
+		_ = xml.EscapeText(buf, []byte(kv[1]))
+		buf.WriteString("</string>\n")
+	}
+	if !adjustment.Timestamp.IsZero() {
+		fmt.Fprintf(buf, "\t<key>adjustmentTimestamp</key>\n\t<date>%s</date>\n", adjustment.Timestamp.UTC().Format(time.RFC3339))
+	}
+	buf.WriteString("</dict>\n</plist>\n")
+
+	return os.WriteFile(adjustmentSidecarPath(path), buf.Bytes(), 0o644)
+}
@@ -0,0 +1,46 @@
+package command
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// albumFolders lays out the albums in the folders of the album hierarchy of the Photos app, with --preserve-folders,
+// like <output>/Trips/2023/Japan for the album Japan of the folder 2023 of the folder Trips.
+type albumFolders struct {
+	albums []*icloudgo.PhotoAlbum      // every album of the tree, in the order of the Photos app
+	dirs   map[icloudgo.AlbumID]string // album id -> dir relative to the output dir
+}
+
+func loadAlbumFolders(ctx context.Context, photoCli *icloudgo.PhotoService) (*albumFolders, error) {
+	tree, err := photoCli.AlbumTreeContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := &albumFolders{dirs: map[icloudgo.AlbumID]string{}}
+	tree.Walk(func(node *icloudgo.AlbumNode, folders []string) {
+		if node.IsFolder() {
+			return
+		}
+		parts := make([]string, 0, len(folders)+1)
+		for _, name := range append(folders, node.Name) {
+			parts = append(parts, sharedAlbumDirname(name))
+		}
+		res.albums = append(res.albums, node.Album)
+		res.dirs[node.ID] = filepath.Join(parts...)
+	})
+	return res, nil
+}
+
+// Dir returns the dir of album in outputDir, outputDir for the albums out of the tree, like the smart albums.
+func (r *albumFolders) Dir(outputDir string, album *icloudgo.PhotoAlbum) string {
+	if r == nil {
+		return outputDir
+	}
+	if dir, ok := r.dirs[album.ID()]; ok {
+		return filepath.Join(outputDir, dir)
+	}
+	return outputDir
+}
@@ -0,0 +1,211 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// albumsBucket is the bucket of the state database of the albums, by album name.
+const albumsBucket = "albums"
+
+// albumState keeps the high-water marks of each album synced to the output dir in the state database,
+// and reports how many photos each album got in this run.
+//
+// The marks are the dates of the newest photo of the album in the output dir, they bound the next run of --since-last-sync,
+// the marks of this run are only kept once the album is synced without failure, so a failed photo is retried,
+// and never when the run is filtered, so a photo it skipped is not left behind the marks.
+type albumState struct {
+	db       *stateDB
+	lock     sync.Mutex
+	Albums   map[string]*albumStateEntry
+	deltas   map[string]int
+	marks    map[string]*albumStateEntry // the marks of this run
+	filtered bool                        // the run skips photos of the albums, its marks are not kept
+}
+
+type albumStateEntry struct {
+	LastAssetDate time.Time `json:"last_asset_date"`
+	LastAddedDate time.Time `json:"last_added_date"`
+	LastSync      time.Time `json:"last_sync"`
+	Count         int       `json:"count"`
+}
+
+// loadAlbumState loads the albums of the state database.
+func loadAlbumState(db *stateDB) (*albumState, error) {
+	r := &albumState{
+		db:     db,
+		Albums: map[string]*albumStateEntry{},
+		deltas: map[string]int{},
+		marks:  map[string]*albumStateEntry{},
+	}
+	for _, name := range db.Keys(albumsBucket) {
+		entry := new(albumStateEntry)
+		if _, err := db.Get(albumsBucket, name, entry); err != nil {
+			return nil, err
+		}
+		r.Albums[name] = entry
+	}
+	return r, nil
+}
+
+func (r *albumState) entry(album *icloudgo.PhotoAlbum) *albumStateEntry {
+	entry := r.Albums[album.Name]
+	if entry == nil {
+		entry = new(albumStateEntry)
+		r.Albums[album.Name] = entry
+	}
+	return entry
+}
+
+// Add records the photo of album is downloaded in this run.
+func (r *albumState) Add(album *icloudgo.PhotoAlbum, photo *icloudgo.PhotoAsset) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.entry(album).Count++
+	r.mark(album, photo)
+	r.deltas[album.Name]++
+}
+
+// Seen records the photo of album is in the output dir already.
+func (r *albumState) Seen(album *icloudgo.PhotoAlbum, photo *icloudgo.PhotoAsset) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.mark(album, photo)
+}
+
+func (r *albumState) mark(album *icloudgo.PhotoAlbum, photo *icloudgo.PhotoAsset) {
+	marks := r.marks[album.Name]
+	if marks == nil {
+		marks = new(albumStateEntry)
+		r.marks[album.Name] = marks
+	}
+	if d := photo.AssetDate(); d.After(marks.LastAssetDate) {
+		marks.LastAssetDate = d
+	}
+	if d := photo.AddedDate(); d.After(marks.LastAddedDate) {
+		marks.LastAddedDate = d
+	}
+}
+
+// Synced records album is iterated without failure in this run, its marks move to the photos of this run.
+func (r *albumState) Synced(album *icloudgo.PhotoAlbum) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entry := r.entry(album)
+	entry.LastSync = time.Now()
+	if marks := r.marks[album.Name]; marks != nil && !r.filtered {
+		if marks.LastAssetDate.After(entry.LastAssetDate) {
+			entry.LastAssetDate = marks.LastAssetDate
+		}
+		if marks.LastAddedDate.After(entry.LastAddedDate) {
+			entry.LastAddedDate = marks.LastAddedDate
+		}
+	}
+	if _, ok := r.deltas[album.Name]; !ok {
+		r.deltas[album.Name] = 0
+	}
+}
+
+// SinceLastSync returns iter ending at the first photo older than the newest photo of album the last sync had,
+// iter as is when album was never synced, or its photos are not listed newest first.
+//
+// The lists by added date, like All Photos, end at the added date, the others at the date the photo was taken,
+// so a photo filed into an album after a newer one was synced is not listed there.
+func (r *albumState) SinceLastSync(album *icloudgo.PhotoAlbum, iter icloudgo.AssetIterator) icloudgo.AssetIterator {
+	if r == nil || album.Direction != "ASCENDING" {
+		return iter
+	}
+	r.lock.Lock()
+	entry := r.Albums[album.Name]
+	r.lock.Unlock()
+	if entry == nil || entry.LastSync.IsZero() {
+		return iter
+	}
+
+	var stop func(photo *icloudgo.PhotoAsset) bool
+	switch {
+	case strings.Contains(album.ListType, "ByAddedDate") && !entry.LastAddedDate.IsZero():
+		stop = func(photo *icloudgo.PhotoAsset) bool { return photo.AddedDate().Before(entry.LastAddedDate) }
+	case strings.Contains(album.ListType, "ByAssetDate") && !entry.LastAssetDate.IsZero():
+		stop = func(photo *icloudgo.PhotoAsset) bool { return photo.AssetDate().Before(entry.LastAssetDate) }
+	default:
+		return iter
+	}
+	return &stopIter{iter: iter, stop: stop}
+}
+
+// stopIter ends iter at the first photo stop returns true for.
+type stopIter struct {
+	iter icloudgo.AssetIterator
+	stop func(photo *icloudgo.PhotoAsset) bool
+	lock sync.Mutex
+	end  bool
+}
+
+func (r *stopIter) Next() (*icloudgo.PhotoAsset, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.end {
+		return nil, icloudgo.ErrPhotosIterateEnd
+	}
+	photo, err := r.iter.Next()
+	if err != nil {
+		return nil, err
+	}
+	if r.stop(photo) {
+		r.end = true
+		return nil, icloudgo.ErrPhotosIterateEnd
+	}
+	return photo, nil
+}
+
+// Report returns the per-album deltas of this run, like "Favorites: +12, Screenshots: +340".
+func (r *albumState) Report() string {
+	if r == nil {
+		return ""
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	names := make([]string, 0, len(r.deltas))
+	for name := range r.deltas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	items := make([]string, 0, len(names))
+	for _, name := range names {
+		items = append(items, fmt.Sprintf("%s: +%d", name, r.deltas[name]))
+	}
+	return strings.Join(items, ", ")
+}
+
+// Write puts the albums into the state database, only the changed ones are written.
+func (r *albumState) Write() error {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for name, entry := range r.Albums {
+		if err := r.db.Put(albumsBucket, name, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
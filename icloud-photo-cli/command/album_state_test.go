@@ -0,0 +1,94 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+func TestSinceLastSync(t *testing.T) {
+	server := newMockServer(t)
+	added := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	addAsset := func(name string) *icloudmock.Asset {
+		added = added.Add(time.Hour)
+		return server.AddAsset(&icloudmock.Asset{Filename: name, Data: []byte(name), AssetDate: added, AddedDate: added})
+	}
+	old := addAsset("old.jpg")
+	addAsset("synced.jpg")
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+	download := func(args ...string) error {
+		return runDownload(t, cookieDir, outputDir, append([]string{"--since-last-sync", "--thread-num", "1"}, args...)...)
+	}
+
+	if err := download(); err != nil {
+		t.Fatal(err)
+	}
+	// the photos older than the last sync are not listed again
+	if err := os.Remove(filepath.Join(outputDir, old.Filename)); err != nil {
+		t.Fatal(err)
+	}
+
+	// a run with a failed photo doesn't move the marks, or the failed photo would be older than them
+	failed, newer := addAsset("failed.jpg"), addAsset("newer.jpg")
+	if err := os.MkdirAll(filepath.Join(outputDir, failed.Filename, "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	_ = download()
+	if err := os.RemoveAll(filepath.Join(outputDir, failed.Filename)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := download(); err != nil {
+		t.Fatal(err)
+	}
+	for _, asset := range []*icloudmock.Asset{failed, newer} {
+		if _, err := os.Stat(filepath.Join(outputDir, asset.Filename)); err != nil {
+			t.Errorf("%s not downloaded: %s", asset.Filename, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, old.Filename)); !os.IsNotExist(err) {
+		t.Errorf("%s older than the last sync downloaded again: %v", old.Filename, err)
+	}
+
+	// without the flag the whole album is listed
+	if err := runDownload(t, cookieDir, outputDir, "--stop-found-num", "100"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, old.Filename)); err != nil {
+		t.Errorf("%s not downloaded without --since-last-sync: %s", old.Filename, err)
+	}
+}
+
+// A filtered run doesn't move the marks past the photos it skipped.
+func TestSinceLastSyncAfterFilteredRun(t *testing.T) {
+	server := newMockServer(t)
+	added := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	addAsset := func(name string) *icloudmock.Asset {
+		added = added.Add(time.Hour)
+		return server.AddAsset(&icloudmock.Asset{Filename: name, Data: []byte(name), AssetDate: added, AddedDate: added})
+	}
+	addAsset("synced.jpg")
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+	if err := runDownload(t, cookieDir, outputDir, "--since-last-sync"); err != nil {
+		t.Fatal(err)
+	}
+
+	skipped := addAsset("skipped.jpg")
+	addAsset("newer.jpg")
+	if err := runDownload(t, cookieDir, outputDir, "--exclude", skipped.Filename); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDownload(t, cookieDir, outputDir, "--since-last-sync"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, skipped.Filename)); err != nil {
+		t.Errorf("%s skipped by the filtered run not downloaded: %s", skipped.Filename, err)
+	}
+
+	if err := runDownload(t, cookieDir, outputDir, "--since-last-sync", "--exclude", skipped.Filename); err == nil {
+		t.Errorf("--since-last-sync with --exclude accepted")
+	}
+}
@@ -0,0 +1,113 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// maxAssetRetryBackoff caps the pause between two attempts of a photo.
+const maxAssetRetryBackoff = 5 * time.Minute
+
+// assetRetry is the retry budget of each photo, --asset-retries, on top of the retries of a download failing midway:
+// a photo failing as a whole, like its lookup, a sidecar or a download out of retries, is tried again after a pause
+// doubled on each attempt, with jitter, so the workers failing together don't retry together.
+// A nil assetRetry tries each photo once.
+type assetRetry struct {
+	retries int
+	backoff time.Duration
+}
+
+func newAssetRetry(retries int, backoff time.Duration) *assetRetry {
+	if retries <= 0 {
+		return nil
+	}
+	return &assetRetry{retries: retries, backoff: backoff}
+}
+
+// Do runs fn until it succeeds, fails with an error a retry can't fix, or the photo is out of retries,
+// the error of a photo tried more than once is an *assetRetryError with the attempts.
+func (r *assetRetry) Do(ctx context.Context, photo *icloudgo.PhotoAsset, fn func() (bool, error)) (bool, error) {
+	if r == nil {
+		return fn()
+	}
+	for attempt := 1; ; attempt++ {
+		skipped, err := fn()
+		if err == nil {
+			return skipped, nil
+		}
+		if attempt > r.retries || !isRetryableAssetError(ctx, err) {
+			if attempt == 1 {
+				return skipped, err
+			}
+			return skipped, &assetRetryError{err: err, attempts: attempt}
+		}
+
+		delay := r.delay(attempt)
+		fmt.Printf("%s failed, retry %d/%d in %s: %s\n", photo.Filename(), attempt, r.retries, delay.Round(time.Millisecond), err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return skipped, err
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns the pause after the attempt-th attempt, from 1: the backoff doubled on each attempt,
+// up to maxAssetRetryBackoff, of which the second half is random.
+func (r *assetRetry) delay(attempt int) time.Duration {
+	delay := r.backoff
+	for i := 1; i < attempt && delay < maxAssetRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxAssetRetryBackoff {
+		delay = maxAssetRetryBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// isRetryableAssetError reports whether trying the photo again can fix the error,
+// not when the login, the 2fa or the disk is the problem, which every photo fails with.
+func isRetryableAssetError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, icloudgo.ErrSessionExpired) {
+		return false
+	}
+	switch ExitCode(err) {
+	case ExitAuthFailed, ExitTwoFARequired, ExitDiskFull:
+		return false
+	default:
+		return true
+	}
+}
+
+// assetRetryError is the last error of a photo tried more than once.
+type assetRetryError struct {
+	err      error
+	attempts int
+}
+
+func (r *assetRetryError) Error() string {
+	return fmt.Sprintf("%s, after %d attempts", r.err, r.attempts)
+}
+
+func (r *assetRetryError) Unwrap() error {
+	return r.err
+}
+
+// attemptsOf returns how many times the photo which failed with err was tried.
+func attemptsOf(err error) int {
+	var retryErr *assetRetryError
+	if errors.As(err, &retryErr) {
+		return retryErr.attempts
+	}
+	return 1
+}
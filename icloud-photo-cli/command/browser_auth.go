@@ -0,0 +1,184 @@
+package command
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+	"github.com/urfave/cli/v2"
+)
+
+// authenticate logs in with the password, or with a browser session when --browser-auth is set,
+// a failed login is an authError, for the exit code.
+func authenticate(ctx context.Context, c *cli.Context, cli *icloudgo.Client) error {
+	if err := login(ctx, c, cli); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return &authError{err}
+	}
+	return nil
+}
+
+func login(ctx context.Context, c *cli.Context, cli *icloudgo.Client) error {
+	if !c.Bool("browser-auth") {
+		return cli.AuthenticateContext(ctx, false, nil)
+	}
+
+	// reuse the saved browser session
+	if err := cli.AuthenticateWithCookies(""); err == nil {
+		return nil
+	}
+
+	// the handoff waits for the user to paste the cookie, a cron job or a container would wait for nothing
+	if c.Bool("non-interactive") || !isTerminal(os.Stdin) {
+		return fmt.Errorf("--browser-auth needs the user to paste a new browser session, it can't be used non-interactively: %w", icloudgo.ErrSessionExpired)
+	}
+	cookie, err := browserHandoff(c.String("domain"))
+	if err != nil {
+		return err
+	}
+	return cli.AuthenticateWithCookies(cookie)
+}
+
+// startKeepAlive renews the session in the background when --keep-alive is set, call the returned function to stop it.
+func startKeepAlive(c *cli.Context, cli *icloudgo.Client) func() {
+	if minutes := c.Int("keep-alive"); minutes > 0 {
+		return cli.KeepAlive(time.Duration(minutes) * time.Minute)
+	}
+	return func() {}
+}
+
+// browserHandoff serves a local page with the steps to log in to icloud.com in the browser, including 2fa,
+// and a form the user pastes the Cookie request header of icloud.com into, from the developer tools,
+// it doesn't read the cookies of the browser itself. The page and the form only accept the secret token of the run,
+// given in the url of the page, and the form only accepts posts from the page itself.
+func browserHandoff(domain string) (string, error) {
+	homeURL := "https://www.icloud.com"
+	if domain == "cn" {
+		homeURL = "https://www.icloud.com.cn"
+	}
+
+	token, err := newBrowserAuthToken()
+	if err != nil {
+		return "", err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("listen local callback server failed, err: %w", err)
+	}
+	host := listener.Addr().String()
+
+	cookieCh := make(chan string, 1)
+	server := &http.Server{Handler: newBrowserAuthHandler(host, homeURL, token, cookieCh), ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+
+	pageURL := fmt.Sprintf("http://%s/?token=%s", host, token)
+	fmt.Printf("Opening %s in the browser to log in, open it manually if the browser does not start\n", pageURL)
+	if err := openBrowser(pageURL); err != nil {
+		fmt.Printf("open browser failed: %s\n", err)
+	}
+
+	select {
+	case cookie := <-cookieCh:
+		return cookie, nil
+	case <-time.After(10 * time.Minute):
+		return "", fmt.Errorf("browser login timed out")
+	}
+}
+
+// newBrowserAuthHandler serves the page of browserHandoff on host, and sends the cookie posted by its form to cookieCh.
+func newBrowserAuthHandler(host, homeURL, token string, cookieCh chan<- string) http.Handler {
+	origin := "http://" + host
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// the Host check keeps out the pages of another site resolving their domain to 127.0.0.1
+		if r.Host != host || !validBrowserAuthToken(r.URL.Query().Get("token"), token) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		_ = browserAuthPage.Execute(w, map[string]string{"HomeURL": homeURL, "Token": token})
+	})
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Host != host || r.Header.Get("Origin") != origin || !validBrowserAuthToken(r.PostFormValue("token"), token) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		cookie := r.PostFormValue("cookie")
+		if cookie == "" {
+			http.Error(w, "cookie is empty", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<p>Session received, you can close this page.</p>")
+		select {
+		case cookieCh <- cookie:
+		default:
+		}
+	})
+	return mux
+}
+
+// newBrowserAuthToken returns the secret of a browser handoff, only the page opened by the run knows it.
+func newBrowserAuthToken() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", fmt.Errorf("generate browser login token failed, err: %w", err)
+	}
+	return hex.EncodeToString(bs), nil
+}
+
+func validBrowserAuthToken(got, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+var browserAuthPage = template.Must(template.New("browser-auth").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>icloudgo login</title></head>
+<body>
+<h1>icloudgo login</h1>
+<ol>
+<li>Open <a href="{{.HomeURL}}" target="_blank" rel="noopener noreferrer">{{.HomeURL}}</a> and log in, including the 2fa code. Check "Keep me signed in".</li>
+<li>Open the developer tools, select the Network tab and reload the page.</li>
+<li>Select a request to setup.icloud.com, copy the value of the Cookie request header and paste it below.</li>
+</ol>
+<form method="post" action="/callback">
+<input type="hidden" name="token" value="{{.Token}}">
+<textarea name="cookie" rows="8" cols="100"></textarea><br>
+<button type="submit">Send to icloudgo</button>
+</form>
+</body>
+</html>
+`))
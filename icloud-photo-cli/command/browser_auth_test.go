@@ -0,0 +1,66 @@
+package command
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBrowserAuthHandler(t *testing.T) {
+	const host, token = "127.0.0.1:8080", "secret"
+	cookieCh := make(chan string, 1)
+	handler := newBrowserAuthHandler(host, "https://www.icloud.com", token, cookieCh)
+
+	page := func(rawURL string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, rawURL, nil))
+		return w
+	}
+	if w := page("http://" + host + "/"); w.Code != http.StatusForbidden {
+		t.Errorf("page without token: %d, want 403", w.Code)
+	}
+	if w := page("http://evil.example/?token=" + token); w.Code != http.StatusForbidden {
+		t.Errorf("page of another host: %d, want 403", w.Code)
+	}
+	w := page("http://" + host + "/?token=" + token)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `value="secret"`) {
+		t.Errorf("page with token: %d, %s", w.Code, w.Body.String())
+	}
+
+	post := func(origin, formToken string) int {
+		form := url.Values{"token": {formToken}, "cookie": {"X-APPLE-WEBAUTH-TOKEN=1"}}
+		req := httptest.NewRequest(http.MethodPost, "http://"+host+"/callback", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	for _, tc := range []struct {
+		name, origin, token string
+	}{
+		{name: "no origin", origin: "", token: token},
+		{name: "another origin", origin: "https://evil.example", token: token},
+		{name: "wrong token", origin: "http://" + host, token: "guess"},
+	} {
+		if code := post(tc.origin, tc.token); code != http.StatusForbidden {
+			t.Errorf("post with %s: %d, want 403", tc.name, code)
+		}
+	}
+	select {
+	case cookie := <-cookieCh:
+		t.Fatalf("forbidden posts should not hand the cookie over, got %s", cookie)
+	default:
+	}
+
+	if code := post("http://"+host, token); code != http.StatusOK {
+		t.Fatalf("post from the page: %d, want 200", code)
+	}
+	if cookie := <-cookieCh; cookie != "X-APPLE-WEBAUTH-TOKEN=1" {
+		t.Errorf("cookie = %s", cookie)
+	}
+}
@@ -0,0 +1,161 @@
+package command
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math/bits"
+	"os"
+	"strings"
+)
+
+// checksumAlgorithm is a hash of the checksum manifest, picked with --checksum-algorithm,
+// to match what the backup tooling next to the archive validates.
+type checksumAlgorithm struct {
+	name     string // like sha256, the value of --checksum-algorithm and the field of manifest.json
+	sumsFile string // like SHA256SUMS, read by sha256sum -c, sha1sum -c and xxhsum -c
+	new      func() hash.Hash
+}
+
+// checksumAlgorithms are the algorithms of the manifests, the strongest first.
+var checksumAlgorithms = []*checksumAlgorithm{
+	{name: "sha256", sumsFile: "SHA256SUMS", new: sha256.New},
+	{name: "sha1", sumsFile: "SHA1SUMS", new: sha1.New},
+	{name: "xxh64", sumsFile: "XXH64SUMS", new: newXXH64},
+}
+
+func getChecksumAlgorithm(name string) (*checksumAlgorithm, error) {
+	name = strings.ToLower(name)
+	if name == "xxhash" {
+		name = "xxh64"
+	}
+	var names []string
+	for _, v := range checksumAlgorithms {
+		if v.name == name {
+			return v, nil
+		}
+		names = append(names, v.name)
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q, valid: %s", name, strings.Join(names, ", "))
+}
+
+// sumFile returns the hex checksum of the file at path.
+func (r *checksumAlgorithm) sumFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := r.new()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+const (
+	xxh64Prime1 uint64 = 11400714785074694791
+	xxh64Prime2 uint64 = 14029467366897019727
+	xxh64Prime3 uint64 = 1609587929392839161
+	xxh64Prime4 uint64 = 9650029242287828579
+	xxh64Prime5 uint64 = 2870177450012600261
+)
+
+// xxh64 is the XXH64 hash with seed 0, its sum is big endian, like the output of xxhsum.
+type xxh64 struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int
+}
+
+func newXXH64() hash.Hash {
+	r := new(xxh64)
+	r.Reset()
+	return r
+}
+
+func (r *xxh64) Reset() {
+	// the accumulators wrap around, which the constants can't
+	prime1, prime2 := xxh64Prime1, xxh64Prime2
+	r.v = [4]uint64{prime1 + prime2, prime2, 0, -prime1}
+	r.total, r.n = 0, 0
+}
+
+func (r *xxh64) Size() int      { return 8 }
+func (r *xxh64) BlockSize() int { return 32 }
+
+func (r *xxh64) Write(p []byte) (int, error) {
+	n := len(p)
+	r.total += uint64(n)
+	if r.n+len(p) < 32 {
+		r.n += copy(r.mem[r.n:], p)
+		return n, nil
+	}
+	if r.n > 0 {
+		c := copy(r.mem[r.n:], p)
+		r.block(r.mem[:])
+		p = p[c:]
+		r.n = 0
+	}
+	for ; len(p) >= 32; p = p[32:] {
+		r.block(p)
+	}
+	r.n = copy(r.mem[:], p)
+	return n, nil
+}
+
+func (r *xxh64) block(p []byte) {
+	for i := range r.v {
+		r.v[i] = xxh64Round(r.v[i], binary.LittleEndian.Uint64(p[i*8:]))
+	}
+}
+
+func (r *xxh64) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, r.Sum64())
+}
+
+func (r *xxh64) Sum64() uint64 {
+	var h uint64
+	if r.total >= 32 {
+		h = bits.RotateLeft64(r.v[0], 1) + bits.RotateLeft64(r.v[1], 7) + bits.RotateLeft64(r.v[2], 12) + bits.RotateLeft64(r.v[3], 18)
+		for _, v := range r.v {
+			h = (h^xxh64Round(0, v))*xxh64Prime1 + xxh64Prime4
+		}
+	} else {
+		h = r.v[2] + xxh64Prime5
+	}
+	h += r.total
+
+	p := r.mem[:r.n]
+	for ; len(p) >= 8; p = p[8:] {
+		h ^= xxh64Round(0, binary.LittleEndian.Uint64(p))
+		h = bits.RotateLeft64(h, 27)*xxh64Prime1 + xxh64Prime4
+	}
+	if len(p) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(p)) * xxh64Prime1
+		h = bits.RotateLeft64(h, 23)*xxh64Prime2 + xxh64Prime3
+		p = p[4:]
+	}
+	for _, c := range p {
+		h ^= uint64(c) * xxh64Prime5
+		h = bits.RotateLeft64(h, 11) * xxh64Prime1
+	}
+
+	h ^= h >> 33
+	h *= xxh64Prime2
+	h ^= h >> 29
+	h *= xxh64Prime3
+	h ^= h >> 32
+	return h
+}
+
+func xxh64Round(acc, input uint64) uint64 {
+	acc += input * xxh64Prime2
+	return bits.RotateLeft64(acc, 31) * xxh64Prime1
+}
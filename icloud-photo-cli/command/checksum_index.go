@@ -0,0 +1,137 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// checksumsBucket is the bucket of the state database of the checksum index, by path relative to the output dir.
+const checksumsBucket = "checksums"
+
+// checksumIndex is the local index of --verify-checksum, --on-conflict newer and --purge-deleted-verified, it keeps the iCloud checksum
+// and the modification date of the photo each file was downloaded from, and the SHA-256, size and modification time of the file,
+// so a file is only taken as downloaded when it's the same photo, not any photo of the same size,
+// and the files unchanged since the last run are not hashed again.
+type checksumIndex struct {
+	db        *stateDB
+	outputDir string
+}
+
+type checksumIndexEntry struct {
+	Checksum      string    `json:"checksum"`       // the iCloud checksum of the photo
+	PhotoModified time.Time `json:"photo_modified"` // the modification date of the record of the photo
+	SHA256        string    `json:"sha256"`
+	Size          int64     `json:"size"`
+	Modified      time.Time `json:"modified"`
+}
+
+// loadChecksumIndex loads the checksum index of the files of the output dir from the state database, it returns nil unless enabled.
+// The database is in the parent of the output dir with --snapshot, so the snapshots share the index.
+func loadChecksumIndex(enabled bool, db *stateDB, outputDir string) (*checksumIndex, error) {
+	if !enabled {
+		return nil, nil
+	}
+	return &checksumIndex{db: db, outputDir: outputDir}, nil
+}
+
+// entry returns the entry of the file at path, nil when the index doesn't know the file.
+func (r *checksumIndex) entry(path string) *checksumIndexEntry {
+	entry := new(checksumIndexEntry)
+	if ok, err := r.db.Get(checksumsBucket, r.rel(path), entry); err != nil || !ok {
+		return nil
+	}
+	return entry
+}
+
+// Downloaded reports whether the file f at path is the photo, by size without the index.
+//
+// With the index, the file must be recorded as downloaded from a photo with the same iCloud checksum,
+// and be unchanged since, by size and modification time, or else by SHA-256.
+// A file without an entry is not the photo, whatever its size, the index only records the files downloaded from the photos,
+// so it's downloaded again, or handled by --on-conflict.
+func (r *checksumIndex) Downloaded(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, path string, f os.FileInfo) bool {
+	if int64(versionSize(photo, version)) != f.Size() {
+		return false
+	}
+	if r == nil {
+		return true
+	}
+
+	entry := r.entry(path)
+	if entry == nil || entry.Checksum != versionChecksum(photo, version) {
+		return false
+	}
+	if entry.Size == f.Size() && entry.Modified.Equal(f.ModTime()) {
+		return true
+	}
+	if sum, err := sha256File(path); err != nil || sum != entry.SHA256 {
+		return false
+	}
+	entry.Size, entry.Modified = f.Size(), f.ModTime()
+	return r.db.Put(checksumsBucket, r.rel(path), entry) == nil
+}
+
+// Verified reports whether the file at path was downloaded from the photo, and its content is unchanged since,
+// by SHA-256 whatever its modification time, unlike Downloaded, a file the index doesn't know is not the photo.
+func (r *checksumIndex) Verified(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, path string) bool {
+	if r == nil {
+		return false
+	}
+	entry := r.entry(path)
+	if entry == nil || entry.Checksum != versionChecksum(photo, version) {
+		return false
+	}
+	f, err := os.Stat(path)
+	if err != nil || f.Size() != entry.Size {
+		return false
+	}
+	sum, err := sha256File(path)
+	return err == nil && sum == entry.SHA256
+}
+
+// PhotoModified returns the modification date of the record of the photo the file at path was downloaded from,
+// false when the index doesn't know the file.
+func (r *checksumIndex) PhotoModified(path string) (time.Time, bool) {
+	if r == nil {
+		return time.Time{}, false
+	}
+	entry := r.entry(path)
+	if entry == nil || entry.PhotoModified.IsZero() {
+		return time.Time{}, false
+	}
+	return entry.PhotoModified, true
+}
+
+// Add hashes the file at path, downloaded from the photo, and records it.
+func (r *checksumIndex) Add(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, path string) error {
+	if r == nil {
+		return nil
+	}
+	f, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	sum, err := sha256File(path)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Put(checksumsBucket, r.rel(path), &checksumIndexEntry{
+		Checksum:      versionChecksum(photo, version),
+		PhotoModified: photo.Modified(),
+		SHA256:        sum,
+		Size:          f.Size(),
+		Modified:      f.ModTime(),
+	})
+}
+
+func (r *checksumIndex) rel(path string) string {
+	rel, err := filepath.Rel(r.outputDir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,55 @@
+package command
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// partialFileMaxAge is how long the .part file of an interrupted download is kept for the next run to resume it.
+const partialFileMaxAge = 7 * 24 * time.Hour
+
+// cleanupPartialFiles removes partial downloads left by crashed runs, the .part and .parallel.part files,
+// the ones younger than partialFileMaxAge are kept, the download resumes from them,
+// it must be called with the output dir lock held.
+func cleanupPartialFiles(outputDir string) error {
+	var count, reclaimed int
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isPartialFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < partialFileMaxAge {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		count++
+		reclaimed += int(info.Size())
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cleanup partial files failed: %w", err)
+	}
+	if count > 0 {
+		fmt.Printf("cleanup %d partial files, reclaimed %s\n", count, icloudgo.FormatSize(reclaimed))
+	}
+	return nil
+}
+
+// isPartialFile reports whether name is the .part or .parallel.part file of a download.
+func isPartialFile(name string) bool {
+	return strings.HasSuffix(name, icloudgo.PartialFileSuffix)
+}
@@ -0,0 +1,242 @@
+package command
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// NewAlbumCommands returns the subcommands of the album command, which organize the user albums of the library.
+func NewAlbumCommands() []*cli.Command {
+	albumFlag := &cli.StringFlag{
+		Name:     "album",
+		Usage:    "album name",
+		Required: true,
+		Aliases:  []string{"a"},
+		EnvVars:  []string{"ICLOUD_ALBUM"},
+	}
+	nameFlag := &cli.StringFlag{
+		Name:     "name",
+		Usage:    "new album name",
+		Required: true,
+		EnvVars:  []string{"ICLOUD_ALBUM_NAME"},
+	}
+	idFlag := &cli.StringSliceFlag{
+		Name:     "id",
+		Usage:    "asset record id, like the PhotoID of an upload, can be repeated",
+		Required: true,
+		EnvVars:  []string{"ICLOUD_ASSET_ID"},
+	}
+	return []*cli.Command{
+		{
+			Name:        "create",
+			Usage:       "create a top level album named --name",
+			Description: "create a top level album named --name",
+			Flags:       append(append([]cli.Flag{}, commonFlag...), nameFlag),
+			Before:      LoadProfile,
+			Action:      AlbumCreate,
+		},
+		{
+			Name:        "rename",
+			Usage:       "rename --album to --name",
+			Description: "rename --album to --name",
+			Flags:       append(append([]cli.Flag{}, commonFlag...), albumFlag, nameFlag),
+			Before:      LoadProfile,
+			Action:      AlbumRename,
+		},
+		{
+			Name:        "delete",
+			Usage:       "delete --album, its photos stay in the library",
+			Description: "delete --album, its photos stay in the library",
+			Flags:       append(append([]cli.Flag{}, commonFlag...), albumFlag),
+			Before:      LoadProfile,
+			Action:      AlbumDelete,
+		},
+		{
+			Name:        "add",
+			Usage:       "add the assets of --id to --album",
+			Description: "add the assets of --id to --album",
+			Flags:       append(append([]cli.Flag{}, commonFlag...), albumFlag, idFlag),
+			Before:      LoadProfile,
+			Action:      AlbumAdd,
+		},
+		{
+			Name:        "remove",
+			Usage:       "take the assets of --id out of --album, they stay in the library",
+			Description: "take the assets of --id out of --album, they stay in the library",
+			Flags:       append(append([]cli.Flag{}, commonFlag...), albumFlag, idFlag),
+			Before:      LoadProfile,
+			Action:      AlbumRemove,
+		},
+		{
+			Name:        "export",
+			Usage:       "write the albums of every photo to a file",
+			Description: "write the albums of every photo to a file, so a flat archive can be sorted into albums later, or imported elsewhere with them",
+			Flags: append(append([]cli.Flag{}, commonFlag...),
+				&cli.StringFlag{
+					Name:     "output",
+					Usage:    "file to write, - for stdout",
+					Required: false,
+					Value:    "albums.json",
+					Aliases:  []string{"o"},
+					EnvVars:  []string{"ICLOUD_ALBUMS_OUTPUT"},
+				},
+				&cli.StringFlag{
+					Name:     "format",
+					Usage:    "json: an object of the photos by id, csv: a row per photo and album, with id, filename and album",
+					Required: false,
+					Value:    "json",
+					EnvVars:  []string{"ICLOUD_ALBUMS_FORMAT"},
+					Action: func(context *cli.Context, s string) error {
+						if s != "json" && s != "csv" {
+							return fmt.Errorf("format must be json or csv")
+						}
+						return nil
+					},
+				},
+			),
+			Before: LoadProfile,
+			Action: AlbumExport,
+		},
+	}
+}
+
+func AlbumCreate(c *cli.Context) error {
+	return withPhotoCli(c, func(ctx context.Context, photoCli *icloudgo.PhotoService) error {
+		if _, err := photoCli.CreateAlbumContext(ctx, c.String("name")); err != nil {
+			return err
+		}
+		fmt.Printf("created album %s\n", c.String("name"))
+		return nil
+	})
+}
+
+func AlbumRename(c *cli.Context) error {
+	return withAlbum(c, func(ctx context.Context, album *icloudgo.PhotoAlbum) error {
+		oldName := album.Name
+		if err := album.RenameContext(ctx, c.String("name")); err != nil {
+			return err
+		}
+		fmt.Printf("renamed album %s to %s\n", oldName, album.Name)
+		return nil
+	})
+}
+
+func AlbumDelete(c *cli.Context) error {
+	return withAlbum(c, func(ctx context.Context, album *icloudgo.PhotoAlbum) error {
+		if err := album.DeleteContext(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("deleted album %s\n", album.Name)
+		return nil
+	})
+}
+
+func AlbumAdd(c *cli.Context) error {
+	return withAlbum(c, func(ctx context.Context, album *icloudgo.PhotoAlbum) error {
+		ids := c.StringSlice("id")
+		if err := album.AddAssetIDs(ids...); err != nil {
+			return err
+		}
+		fmt.Printf("added %d assets to album %s\n", len(ids), album.Name)
+		return nil
+	})
+}
+
+func AlbumRemove(c *cli.Context) error {
+	return withAlbum(c, func(ctx context.Context, album *icloudgo.PhotoAlbum) error {
+		ids := c.StringSlice("id")
+		if err := album.RemoveAssetIDs(ids...); err != nil {
+			return err
+		}
+		fmt.Printf("removed %d assets from album %s\n", len(ids), album.Name)
+		return nil
+	})
+}
+
+func AlbumExport(c *cli.Context) error {
+	return withPhotoCli(c, func(ctx context.Context, photoCli *icloudgo.PhotoService) error {
+		memberships, err := photoCli.AlbumMembershipsContext(ctx)
+		if err != nil {
+			return err
+		}
+		write := func(w io.Writer) error {
+			return writeAlbumMemberships(w, memberships, c.String("format"))
+		}
+
+		output := c.String("output")
+		if output == "-" {
+			return write(os.Stdout)
+		}
+		if err := writeFileVia(output, write); err != nil {
+			return err
+		}
+		fmt.Printf("exported the albums of %d photos to %s\n", len(memberships), output)
+		return nil
+	})
+}
+
+func writeAlbumMemberships(w io.Writer, memberships map[string]*icloudgo.AlbumMembership, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(memberships)
+	}
+
+	ids := make([]string, 0, len(memberships))
+	for id := range memberships {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "filename", "album"})
+	for _, id := range ids {
+		for _, album := range memberships[id].Albums {
+			_ = cw.Write([]string{id, memberships[id].Filename, album})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// withAlbum logs in, and runs fn with the album of --album.
+func withAlbum(c *cli.Context, fn func(ctx context.Context, album *icloudgo.PhotoAlbum) error) error {
+	return withPhotoCli(c, func(ctx context.Context, photoCli *icloudgo.PhotoService) error {
+		album, err := photoCli.GetAlbumContext(ctx, c.String("album"))
+		if err != nil {
+			return err
+		}
+		return fn(ctx, album)
+	})
+}
+
+// withPhotoCli logs in, and runs fn with the photo library of the account.
+func withPhotoCli(c *cli.Context, fn func(ctx context.Context, photoCli *icloudgo.PhotoService) error) error {
+	cli, err := icloudgo.New(newClientOption(c))
+	if err != nil {
+		return err
+	}
+
+	defer cli.Close()
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	if err := authenticate(ctx, c, cli); err != nil {
+		return err
+	}
+
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		return err
+	}
+	return fn(ctx, photoCli)
+}
@@ -0,0 +1,319 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+	"github.com/chyroc/icloudgo/internal/pool"
+)
+
+func NewArchiveFlag() []cli.Flag {
+	var res []cli.Flag
+	res = append(res, commonFlag...)
+	res = append(res,
+		&cli.StringFlag{
+			Name:     "output",
+			Usage:    "downloaded photos dir",
+			Required: false,
+			Value:    "./iCloudPhotos",
+			Aliases:  []string{"o"},
+			EnvVars:  []string{"ICLOUD_OUTPUT"},
+		},
+		&cli.StringFlag{
+			Name:     "album",
+			Usage:    "album name, if not set, all photos",
+			Required: false,
+			Aliases:  []string{"a"},
+			EnvVars:  []string{"ICLOUD_ALBUM"},
+		},
+		&cli.BoolFlag{
+			Name:     "offline",
+			Usage:    "read the photos from the <filename>.json sidecars of --write-metadata in the output dir, without logging in",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_OFFLINE"},
+		},
+	)
+	return res
+}
+
+// NewVerifyFlag is NewArchiveFlag, and the workers checking the photos.
+func NewVerifyFlag() []cli.Flag {
+	return append(NewArchiveFlag(), &cli.IntFlag{
+		Name:     "thread-num",
+		Usage:    "thread num, if not set, means 1",
+		Required: false,
+		Aliases:  []string{"t"},
+		Value:    1,
+		EnvVars:  []string{"ICLOUD_THREAD_NUM"},
+	})
+}
+
+// archiveEntry is a photo of the archive, listed by iCloud, or by its metadata sidecar with --offline.
+type archiveEntry struct {
+	ID       string
+	Filename string
+	Path     string
+	Size     int
+	Created  time.Time
+	Albums   []string
+}
+
+// List prints the photos of the archive, and where they are in the output dir.
+func List(c *cli.Context) error {
+	entries, err := loadArchiveEntries(c)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		fmt.Printf("%s  %10s  %s  %s\n", entry.Created.Local().Format("2006-01-02 15:04"), icloudgo.FormatSize(entry.Size), entry.ID, entry.Path)
+	}
+	return nil
+}
+
+// Stats prints the photo count and size of the archive, by album and by year.
+func Stats(c *cli.Context) error {
+	entries, err := loadArchiveEntries(c)
+	if err != nil {
+		return err
+	}
+
+	type bucket struct{ count, size int }
+	var total bucket
+	byAlbum, byYear := map[string]*bucket{}, map[string]*bucket{}
+	add := func(m map[string]*bucket, key string, entry *archiveEntry) {
+		if m[key] == nil {
+			m[key] = new(bucket)
+		}
+		m[key].count++
+		m[key].size += entry.Size
+	}
+	for _, entry := range entries {
+		total.count++
+		total.size += entry.Size
+		for _, album := range entry.Albums {
+			add(byAlbum, album, entry)
+		}
+		add(byYear, entry.Created.Local().Format("2006"), entry)
+	}
+
+	printBuckets := func(title string, m map[string]*bucket) {
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Printf("%s:\n", title)
+		for _, key := range keys {
+			fmt.Printf("  %s: %d photos, %s\n", key, m[key].count, icloudgo.FormatSize(m[key].size))
+		}
+	}
+	fmt.Printf("total: %d photos, %s\n", total.count, icloudgo.FormatSize(total.size))
+	printBuckets("albums", byAlbum)
+	printBuckets("years", byYear)
+	return nil
+}
+
+// Verify checks every photo of the archive is in the output dir with the expected size,
+// and matches the checksum manifest of --manifest if there is one.
+func Verify(c *cli.Context) error {
+	output := c.String("output")
+	entries, err := loadArchiveEntries(c)
+	if err != nil {
+		return err
+	}
+	manifest, err := loadExistingManifest(output)
+	if err != nil {
+		return err
+	}
+
+	var missing, sizeMismatch, checksumMismatch int64
+	workers := pool.New(c.Context, &pool.Option{Workers: c.Int("thread-num")})
+	for _, entry := range entries {
+		entry := entry
+		err := workers.Submit(func(_ context.Context, _ int) error {
+			f, err := os.Stat(entry.Path)
+			if err != nil {
+				fmt.Printf("missing: %s (%s)\n", entry.Path, entry.ID)
+				atomic.AddInt64(&missing, 1)
+				return nil
+			}
+			if entry.Size > 0 && f.Size() != int64(entry.Size) {
+				fmt.Printf("size mismatch: %s, want %d, got %d\n", entry.Path, entry.Size, f.Size())
+				atomic.AddInt64(&sizeMismatch, 1)
+				return nil
+			}
+			if manifest == nil {
+				return nil
+			}
+			rel, err := filepath.Rel(output, entry.Path)
+			if err != nil {
+				return err
+			}
+			if algorithm, want, ok := manifest.checksum(filepath.ToSlash(rel)); ok {
+				got, err := algorithm.sumFile(entry.Path)
+				if err != nil {
+					return err
+				}
+				if got != want {
+					fmt.Printf("checksum mismatch: %s\n", entry.Path)
+					atomic.AddInt64(&checksumMismatch, 1)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			break
+		}
+	}
+	if err := workers.Wait(); err != nil {
+		return err
+	}
+
+	fmt.Printf("verified %d photos, %d missing, %d size mismatch, %d checksum mismatch\n", len(entries), missing, sizeMismatch, checksumMismatch)
+	if missing+sizeMismatch+checksumMismatch > 0 {
+		return fmt.Errorf("verify %s failed", output)
+	}
+	return nil
+}
+
+// loadExistingManifest loads the checksum manifest of the output dir, nil if there is none.
+func loadExistingManifest(outputDir string) (*checksumManifest, error) {
+	manifests := []*checksumManifest{{format: manifestFormatJSON, algorithm: checksumAlgorithms[0]}}
+	for _, algorithm := range checksumAlgorithms {
+		manifests = append(manifests, &checksumManifest{format: manifestFormatSums, algorithm: algorithm})
+	}
+	for _, manifest := range manifests {
+		manifest.outputDir, manifest.entries = outputDir, map[string]map[string]string{}
+		if _, err := os.Stat(manifest.path()); err != nil {
+			continue
+		}
+		if err := manifest.load(); err != nil {
+			return nil, err
+		}
+		return manifest, nil
+	}
+	return nil, nil
+}
+
+func loadArchiveEntries(c *cli.Context) ([]*archiveEntry, error) {
+	var entries []*archiveEntry
+	var err error
+	if c.Bool("offline") {
+		entries, err = loadOfflineArchiveEntries(c.String("output"), c.String("album"))
+	} else {
+		entries, err = loadOnlineArchiveEntries(c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Created.Before(entries[j].Created) })
+	return entries, nil
+}
+
+// loadOfflineArchiveEntries reads the metadata sidecars of the output dir, no session is needed.
+func loadOfflineArchiveEntries(outputDir, albumName string) ([]*archiveEntry, error) {
+	var entries []*archiveEntry
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != outputDir && d.Name()[0] == '.' {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".json") || d.Name()[0] == '.' {
+			return nil
+		}
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sidecar := new(metadataSidecar)
+		if json.Unmarshal(bs, sidecar) != nil || sidecar.ID == "" || sidecar.Filename == "" {
+			return nil
+		}
+		if albumName != "" && !containsString(sidecar.Albums, albumName) {
+			return nil
+		}
+		entries = append(entries, &archiveEntry{
+			ID:       sidecar.ID,
+			Filename: sidecar.Filename,
+			Path:     strings.TrimSuffix(path, ".json"),
+			Size:     sidecar.Size,
+			Created:  sidecar.Created,
+			Albums:   sidecar.Albums,
+		})
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no metadata sidecar found in %s, --offline needs photos downloaded with --write-metadata", outputDir)
+	}
+	return entries, nil
+}
+
+// loadOnlineArchiveEntries lists the photos of the album from iCloud.
+func loadOnlineArchiveEntries(c *cli.Context) ([]*archiveEntry, error) {
+	cli, err := icloudgo.New(newClientOption(c))
+	if err != nil {
+		return nil, err
+	}
+
+	defer cli.Close()
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	if err := authenticate(ctx, c, cli); err != nil {
+		return nil, err
+	}
+
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		return nil, err
+	}
+	album, err := photoCli.GetAlbumContext(ctx, c.String("album"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*archiveEntry
+	iter := album.PhotosIterWithOption(&icloudgo.PhotosIterOption{Context: ctx})
+	for {
+		photo, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, icloudgo.ErrPhotosIterateEnd) {
+				return entries, nil
+			}
+			return nil, err
+		}
+		entry := &archiveEntry{
+			ID:       photo.ID(),
+			Filename: photo.Filename(),
+			Path:     photo.LocalPath(c.String("output"), icloudgo.PhotoVersionOriginal),
+			Size:     photo.Size(),
+			Created:  photo.Created(),
+			Albums:   []string{},
+		}
+		if album.ID() != icloudgo.AlbumIDAll {
+			entry.Albums = append(entry.Albums, album.Name)
+		}
+		entries = append(entries, entry)
+	}
+}
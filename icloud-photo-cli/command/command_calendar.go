@@ -0,0 +1,178 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// NewCalendarCommands returns the subcommands of the calendar command, which back up the calendars of the account.
+func NewCalendarCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:        "list",
+			Usage:       "list the calendars",
+			Description: "list the calendars",
+			Flags:       append([]cli.Flag{}, commonFlag...),
+			Before:      LoadProfile,
+			Action:      CalendarList,
+		},
+		{
+			Name:        "export",
+			Usage:       "export the events of the calendars to ics files",
+			Description: "export the events between --from and --to to an ics file per calendar, which calendar apps import",
+			Flags: append(append([]cli.Flag{}, commonFlag...),
+				&cli.StringFlag{
+					Name:     "output",
+					Usage:    "dir to write the <calendar>.ics files to",
+					Required: false,
+					Value:    "./iCloudCalendars",
+					Aliases:  []string{"o"},
+					EnvVars:  []string{"ICLOUD_CALENDAR_OUTPUT"},
+				},
+				&cli.StringSliceFlag{
+					Name:     "calendar",
+					Usage:    "calendar name, can be repeated, if not set, all calendars",
+					Required: false,
+					EnvVars:  []string{"ICLOUD_CALENDAR"},
+				},
+				&cli.StringFlag{
+					Name:     "from",
+					Usage:    "first day of the events, like 2023-01-31, if not set, a year ago",
+					Required: false,
+					EnvVars:  []string{"ICLOUD_CALENDAR_FROM"},
+				},
+				&cli.StringFlag{
+					Name:     "to",
+					Usage:    "last day of the events, like 2023-12-31, if not set, a year from now",
+					Required: false,
+					EnvVars:  []string{"ICLOUD_CALENDAR_TO"},
+				},
+			),
+			Before: LoadProfile,
+			Action: CalendarExport,
+		},
+	}
+}
+
+func CalendarList(c *cli.Context) error {
+	return withCalendar(c, func(ctx context.Context, calendarCli *icloudgo.CalendarService) error {
+		calendars, err := calendarCli.CalendarsContext(ctx)
+		if err != nil {
+			return err
+		}
+		for _, calendar := range calendars {
+			readOnly := ""
+			if calendar.ReadOnly {
+				readOnly = ", read only"
+			}
+			fmt.Printf("%s  %s (%s%s)\n", calendar.ID, calendar.Title, calendar.Color, readOnly)
+		}
+		return nil
+	})
+}
+
+func CalendarExport(c *cli.Context) error {
+	now := time.Now()
+	from, err := parseCalendarDay(c.String("from"), now.AddDate(-1, 0, 0))
+	if err != nil {
+		return err
+	}
+	to, err := parseCalendarDay(c.String("to"), now.AddDate(1, 0, 0))
+	if err != nil {
+		return err
+	}
+	output := c.String("output")
+	if err := os.MkdirAll(output, os.ModePerm); err != nil {
+		return err
+	}
+
+	return withCalendar(c, func(ctx context.Context, calendarCli *icloudgo.CalendarService) error {
+		calendars, err := calendarCli.CalendarsContext(ctx)
+		if err != nil {
+			return err
+		}
+		if names := c.StringSlice("calendar"); len(names) > 0 {
+			selected := make([]*icloudgo.Calendar, 0, len(names))
+			for _, name := range names {
+				calendar := findCalendar(calendars, name)
+				if calendar == nil {
+					return fmt.Errorf("calendar %s not found", name)
+				}
+				selected = append(selected, calendar)
+			}
+			calendars = selected
+		}
+
+		events, err := calendarCli.EventsContext(ctx, from, to)
+		if err != nil {
+			return err
+		}
+		byCalendar := map[string][]*icloudgo.CalendarEvent{}
+		for _, event := range events {
+			byCalendar[event.CalendarID] = append(byCalendar[event.CalendarID], event)
+		}
+
+		for _, calendar := range calendars {
+			path := filepath.Join(output, calendar.Filename())
+			if err := writeFileVia(path, func(w io.Writer) error {
+				return icloudgo.WriteICS(w, calendar, byCalendar[calendar.ID])
+			}); err != nil {
+				return err
+			}
+			fmt.Printf("exported %d events of %s to %s\n", len(byCalendar[calendar.ID]), calendar.Title, path)
+		}
+		return nil
+	})
+}
+
+func findCalendar(calendars []*icloudgo.Calendar, name string) *icloudgo.Calendar {
+	for _, calendar := range calendars {
+		if calendar.Title == name || calendar.ID == name {
+			return calendar
+		}
+	}
+	return nil
+}
+
+// parseCalendarDay parses a day like 2023-01-31, as a UTC date, the dates of the calendar service are in UTC.
+func parseCalendarDay(s string, defaultValue time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Date(defaultValue.Year(), defaultValue.Month(), defaultValue.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+	day, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day: %s, like 2023-01-31", s)
+	}
+	return day, nil
+}
+
+// withCalendar logs in, and runs fn with the calendar service of the account.
+func withCalendar(c *cli.Context, fn func(ctx context.Context, calendarCli *icloudgo.CalendarService) error) error {
+	cli, err := icloudgo.New(newClientOption(c))
+	if err != nil {
+		return err
+	}
+
+	defer cli.Close()
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	if err := authenticate(ctx, c, cli); err != nil {
+		return err
+	}
+
+	calendarCli, err := cli.CalendarCli()
+	if err != nil {
+		return err
+	}
+	return fn(ctx, calendarCli)
+}
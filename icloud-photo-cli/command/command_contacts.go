@@ -0,0 +1,113 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// NewContactsCommands returns the subcommands of the contacts command, which back up the contacts of the account.
+func NewContactsCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:        "export",
+			Usage:       "export the contacts to a vcf file",
+			Description: "export the contacts to a vcf file, one vCard per contact, which address books like Contacts, Outlook or Google Contacts import",
+			Flags: append(append([]cli.Flag{}, commonFlag...),
+				&cli.StringFlag{
+					Name:     "output",
+					Usage:    "vcf file to write, - for stdout",
+					Required: false,
+					Value:    "contacts.vcf",
+					Aliases:  []string{"o"},
+					EnvVars:  []string{"ICLOUD_CONTACTS_OUTPUT"},
+				},
+				&cli.StringFlag{
+					Name:     "vcard-version",
+					Usage:    "vCard version, 3.0 or 4.0",
+					Required: false,
+					Value:    string(icloudgo.VCard30),
+					EnvVars:  []string{"ICLOUD_VCARD_VERSION"},
+					Action: func(context *cli.Context, s string) error {
+						_, err := icloudgo.ParseVCardVersion(s)
+						return err
+					},
+				},
+			),
+			Before: LoadProfile,
+			Action: ContactsExport,
+		},
+	}
+}
+
+func ContactsExport(c *cli.Context) error {
+	version, err := icloudgo.ParseVCardVersion(c.String("vcard-version"))
+	if err != nil {
+		return err
+	}
+	return withContacts(c, func(ctx context.Context, contactsCli *icloudgo.ContactsService) error {
+		contacts, err := contactsCli.ContactsContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		output := c.String("output")
+		if output == "-" {
+			return icloudgo.WriteVCards(os.Stdout, contacts, version)
+		}
+		// write next to the output, so a failed export keeps the previous file
+		if err := writeFileVia(output, func(w io.Writer) error {
+			return icloudgo.WriteVCards(w, contacts, version)
+		}); err != nil {
+			return err
+		}
+		fmt.Printf("exported %d contacts to %s\n", len(contacts), output)
+		return nil
+	})
+}
+
+// writeFileVia writes path with fn through a temporary file, renamed to path once fn succeeded.
+func writeFileVia(path string, fn func(w io.Writer) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = fn(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// withContacts logs in, and runs fn with the contacts service of the account.
+func withContacts(c *cli.Context, fn func(ctx context.Context, contactsCli *icloudgo.ContactsService) error) error {
+	cli, err := icloudgo.New(newClientOption(c))
+	if err != nil {
+		return err
+	}
+
+	defer cli.Close()
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	if err := authenticate(ctx, c, cli); err != nil {
+		return err
+	}
+
+	contactsCli, err := cli.ContactsCli()
+	if err != nil {
+		return err
+	}
+	return fn(ctx, contactsCli)
+}
@@ -0,0 +1,134 @@
+package command
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+)
+
+func NewDedupeFlag() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "output",
+			Usage:    "downloaded photos dir",
+			Required: false,
+			Value:    "./iCloudPhotos",
+			Aliases:  []string{"o"},
+			EnvVars:  []string{"ICLOUD_OUTPUT"},
+		},
+		&cli.BoolFlag{
+			Name:     "perceptual",
+			Usage:    "also group near-duplicate images (edited copies, resized exports) by perceptual hash",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_DEDUPE_PERCEPTUAL"},
+		},
+		&cli.IntFlag{
+			Name:     "threshold",
+			Usage:    "max hamming distance of perceptual hashes to treat images as near-duplicates",
+			Required: false,
+			Value:    6,
+			EnvVars:  []string{"ICLOUD_DEDUPE_THRESHOLD"},
+		},
+	}
+}
+
+// Dedupe reports groups of duplicate files in the downloaded photos dir, it never deletes anything.
+func Dedupe(c *cli.Context) error {
+	output := c.String("output")
+	perceptual := c.Bool("perceptual")
+	threshold := c.Int("threshold")
+
+	var paths []string
+	err := filepath.WalkDir(output, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != output && d.Name()[0] == '.' {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isPartialFile(d.Name()) || d.Name()[0] == '.' {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	// exact duplicates
+	byChecksum := map[string][]string{}
+	var checksums []string
+	for _, path := range paths {
+		sum, err := sha256File(path)
+		if err != nil {
+			return err
+		}
+		if len(byChecksum[sum]) == 0 {
+			checksums = append(checksums, sum)
+		}
+		byChecksum[sum] = append(byChecksum[sum], path)
+	}
+	exactGroups := 0
+	for _, sum := range checksums {
+		if group := byChecksum[sum]; len(group) > 1 {
+			exactGroups++
+			fmt.Printf("exact duplicate, sha256=%s\n", sum)
+			for _, path := range group {
+				fmt.Printf("  %s\n", path)
+			}
+		}
+	}
+	fmt.Printf("found %d exact duplicate groups in %d files\n", exactGroups, len(paths))
+
+	if !perceptual {
+		return nil
+	}
+
+	// near duplicates, one representative per exact group
+	type hashedFile struct {
+		path string
+		hash uint64
+	}
+	var hashed []*hashedFile
+	for _, sum := range checksums {
+		path := byChecksum[sum][0]
+		hash, err := perceptualHash(path)
+		if err != nil {
+			continue // not an image, or a format the go stdlib can't decode (heic)
+		}
+		hashed = append(hashed, &hashedFile{path: path, hash: hash})
+	}
+
+	grouped := map[int]bool{}
+	nearGroups := 0
+	for i, a := range hashed {
+		if grouped[i] {
+			continue
+		}
+		group := []string{a.path}
+		for j := i + 1; j < len(hashed); j++ {
+			if !grouped[j] && hammingDistance(a.hash, hashed[j].hash) <= threshold {
+				grouped[j] = true
+				group = append(group, hashed[j].path)
+			}
+		}
+		if len(group) > 1 {
+			nearGroups++
+			fmt.Printf("near duplicate, phash=%016x\n", a.hash)
+			for _, path := range group {
+				fmt.Printf("  %s\n", path)
+			}
+		}
+	}
+	fmt.Printf("found %d near duplicate groups in %d images\n", nearGroups, len(hashed))
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+func NewDeleteFlag() []cli.Flag {
+	return append(append([]cli.Flag{}, commonFlag...),
+		&cli.StringSliceFlag{
+			Name:     "id",
+			Usage:    "asset record id, like the PhotoID of an upload, can be repeated",
+			Required: true,
+			EnvVars:  []string{"ICLOUD_ASSET_ID"},
+		},
+		&cli.BoolFlag{
+			Name:     "dry-run",
+			Usage:    "only print the photos, without changing them",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_DRY_RUN"},
+		},
+	)
+}
+
+// Delete moves the photos of --id to the Recently Deleted album of iCloud, where they can be restored for 30 days.
+func Delete(c *cli.Context) error {
+	return withAssets(c, func(ctx context.Context, photo *icloudgo.PhotoAsset) error {
+		if photo.IsDeleted() {
+			fmt.Printf("%s (%s) is deleted already, skip.\n", photo.Filename(), photo.ID())
+			return nil
+		}
+		if c.Bool("dry-run") {
+			fmt.Printf("would delete %s (%s)\n", photo.Filename(), photo.ID())
+			return nil
+		}
+		if err := photo.DeleteContext(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("deleted %s (%s)\n", photo.Filename(), photo.ID())
+		return nil
+	})
+}
+
+// Restore moves the photos of --id out of the Recently Deleted album of iCloud.
+func Restore(c *cli.Context) error {
+	return withAssets(c, func(ctx context.Context, photo *icloudgo.PhotoAsset) error {
+		if !photo.IsDeleted() {
+			fmt.Printf("%s (%s) is not deleted, skip.\n", photo.Filename(), photo.ID())
+			return nil
+		}
+		if c.Bool("dry-run") {
+			fmt.Printf("would restore %s (%s)\n", photo.Filename(), photo.ID())
+			return nil
+		}
+		if err := photo.RestoreContext(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("restored %s (%s)\n", photo.Filename(), photo.ID())
+		return nil
+	})
+}
+
+// withAssets logs in, and runs fn with each asset of --id, the assets are all looked up first,
+// so a wrong id changes nothing.
+func withAssets(c *cli.Context, fn func(ctx context.Context, photo *icloudgo.PhotoAsset) error) error {
+	return withPhotoCli(c, func(ctx context.Context, photoCli *icloudgo.PhotoService) error {
+		var photos []*icloudgo.PhotoAsset
+		for _, id := range c.StringSlice("id") {
+			photo, err := photoCli.GetAssetByIDContext(ctx, id)
+			if err != nil {
+				return err
+			}
+			photos = append(photos, photo)
+		}
+		for _, photo := range photos {
+			if err := fn(ctx, photo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
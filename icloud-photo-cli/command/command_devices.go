@@ -0,0 +1,209 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// NewDevicesCommands returns the subcommands of the devices command, which locate and ring the Find My devices.
+func NewDevicesCommands() []*cli.Command {
+	deviceFlag := &cli.StringFlag{
+		Name:     "device",
+		Usage:    "device id or name, like \"John's iPhone\", see devices list",
+		Required: true,
+		EnvVars:  []string{"ICLOUD_DEVICE"},
+	}
+	messageFlag := &cli.StringFlag{
+		Name:     "message",
+		Usage:    "message shown on the device",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_DEVICE_MESSAGE"},
+	}
+	return []*cli.Command{
+		{
+			Name:        "list",
+			Usage:       "list the devices, with their battery and location",
+			Description: "list the devices, with their battery and location",
+			Flags: append(append([]cli.Flag{}, commonFlag...), &cli.BoolFlag{
+				Name:     "json",
+				Usage:    "print the devices as JSON, for scripts",
+				Required: false,
+				EnvVars:  []string{"ICLOUD_DEVICES_JSON"},
+			}),
+			Before: LoadProfile,
+			Action: DevicesList,
+		},
+		{
+			Name:        "sound",
+			Usage:       "play a sound on --device",
+			Description: "play a sound on --device",
+			Flags: append(append([]cli.Flag{}, commonFlag...), deviceFlag, &cli.StringFlag{
+				Name:     "subject",
+				Usage:    "title of the alert shown with the sound",
+				Required: false,
+				EnvVars:  []string{"ICLOUD_DEVICE_SUBJECT"},
+			}),
+			Before: LoadProfile,
+			Action: DevicesSound,
+		},
+		{
+			Name:        "message",
+			Usage:       "show --message on --device",
+			Description: "show --message on --device",
+			Flags: append(append([]cli.Flag{}, commonFlag...), deviceFlag, messageFlag, &cli.BoolFlag{
+				Name:     "sound",
+				Usage:    "play a sound with the message",
+				Required: false,
+				EnvVars:  []string{"ICLOUD_DEVICE_SOUND"},
+			}),
+			Before: LoadProfile,
+			Action: DevicesMessage,
+		},
+		{
+			Name:        "lost",
+			Usage:       "put --device in Lost Mode, locked, showing --message and --phone",
+			Description: "put --device in Lost Mode, locked, showing --message and --phone",
+			Flags: append(append([]cli.Flag{}, commonFlag...), deviceFlag, messageFlag, &cli.StringFlag{
+				Name:     "phone",
+				Usage:    "phone number to reach the owner, shown on the device",
+				Required: false,
+				EnvVars:  []string{"ICLOUD_DEVICE_PHONE"},
+			}, &cli.StringFlag{
+				Name:     "passcode",
+				Usage:    "passcode to lock the device with, if it has none",
+				Required: false,
+				EnvVars:  []string{"ICLOUD_DEVICE_PASSCODE"},
+			}),
+			Before: LoadProfile,
+			Action: DevicesLost,
+		},
+	}
+}
+
+// deviceJSON is a device of devices list --json.
+type deviceJSON struct {
+	ID            string              `json:"id"`
+	Name          string              `json:"name"`
+	DisplayName   string              `json:"display_name"`
+	Class         string              `json:"class"`
+	Status        string              `json:"status"`
+	BatteryLevel  float64             `json:"battery_level"`
+	BatteryStatus string              `json:"battery_status"`
+	Location      *deviceLocationJSON `json:"location,omitempty"`
+}
+
+type deviceLocationJSON struct {
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Accuracy  float64   `json:"accuracy"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func DevicesList(c *cli.Context) error {
+	return withFindMy(c, func(ctx context.Context, findMy *icloudgo.FindMyService) error {
+		devices, err := findMy.DevicesContext(ctx)
+		if err != nil {
+			return err
+		}
+		if c.Bool("json") {
+			res := make([]*deviceJSON, 0, len(devices))
+			for _, device := range devices {
+				v := &deviceJSON{
+					ID:            device.ID,
+					Name:          device.Name,
+					DisplayName:   device.DisplayName,
+					Class:         device.Class,
+					Status:        device.Status,
+					BatteryLevel:  device.BatteryLevel,
+					BatteryStatus: device.BatteryStatus,
+				}
+				if location := device.Location; location != nil {
+					v.Location = &deviceLocationJSON{Latitude: location.Latitude, Longitude: location.Longitude, Accuracy: location.HorizontalAccuracy, Timestamp: location.Timestamp}
+				}
+				res = append(res, v)
+			}
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(res)
+		}
+		for _, device := range devices {
+			location := "unknown location"
+			if v := device.Location; v != nil {
+				location = fmt.Sprintf("%.6f,%.6f ±%.0fm at %s", v.Latitude, v.Longitude, v.HorizontalAccuracy, v.Timestamp.Local().Format("2006-01-02 15:04"))
+			}
+			fmt.Printf("%s  %s (%s), battery %.0f%% %s, %s\n", device.ID, device.Name, device.DisplayName, device.BatteryLevel*100, device.BatteryStatus, location)
+		}
+		return nil
+	})
+}
+
+func DevicesSound(c *cli.Context) error {
+	return withFindMyDevice(c, func(ctx context.Context, device *icloudgo.FindMyDevice) error {
+		if err := device.PlaySoundContext(ctx, c.String("subject")); err != nil {
+			return err
+		}
+		fmt.Printf("playing a sound on %s\n", device.Name)
+		return nil
+	})
+}
+
+func DevicesMessage(c *cli.Context) error {
+	return withFindMyDevice(c, func(ctx context.Context, device *icloudgo.FindMyDevice) error {
+		if err := device.DisplayMessageContext(ctx, c.String("subject"), c.String("message"), c.Bool("sound")); err != nil {
+			return err
+		}
+		fmt.Printf("message shown on %s\n", device.Name)
+		return nil
+	})
+}
+
+func DevicesLost(c *cli.Context) error {
+	return withFindMyDevice(c, func(ctx context.Context, device *icloudgo.FindMyDevice) error {
+		if err := device.LostModeContext(ctx, c.String("phone"), c.String("message"), c.String("passcode")); err != nil {
+			return err
+		}
+		fmt.Printf("%s is in lost mode\n", device.Name)
+		return nil
+	})
+}
+
+// withFindMyDevice logs in, and runs fn with the Find My device of --device.
+func withFindMyDevice(c *cli.Context, fn func(ctx context.Context, device *icloudgo.FindMyDevice) error) error {
+	return withFindMy(c, func(ctx context.Context, findMy *icloudgo.FindMyService) error {
+		device, err := findMy.GetDeviceContext(ctx, c.String("device"))
+		if err != nil {
+			return err
+		}
+		return fn(ctx, device)
+	})
+}
+
+// withFindMy logs in, and runs fn with the Find My service of the account.
+func withFindMy(c *cli.Context, fn func(ctx context.Context, findMy *icloudgo.FindMyService) error) error {
+	cli, err := icloudgo.New(newClientOption(c))
+	if err != nil {
+		return err
+	}
+
+	defer cli.Close()
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	if err := authenticate(ctx, c, cli); err != nil {
+		return err
+	}
+
+	findMy, err := cli.FindMyCli()
+	if err != nil {
+		return err
+	}
+	return fn(ctx, findMy)
+}
@@ -1,15 +1,20 @@
 package command
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"os"
-	"sync"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/urfave/cli/v2"
 
 	"github.com/chyroc/icloudgo"
+	"github.com/chyroc/icloudgo/internal/pool"
 )
 
 func NewDownloadFlag() []cli.Flag {
@@ -24,13 +29,55 @@ func NewDownloadFlag() []cli.Flag {
 			Aliases:  []string{"o"},
 			EnvVars:  []string{"ICLOUD_OUTPUT"},
 		},
-		&cli.StringFlag{
+		&cli.StringSliceFlag{
 			Name:     "album",
-			Usage:    "album name, if not set, download all albums",
+			Usage:    "album name or smart album id (e.g. favorites), repeat it to download many albums concurrently, if not set, download all albums",
 			Required: false,
 			Aliases:  []string{"a"},
 			EnvVars:  []string{"ICLOUD_ALBUM"},
 		},
+		&cli.BoolFlag{
+			Name:     "favorites",
+			Usage:    "only download favorites, same as --album favorites",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_FAVORITES"},
+		},
+		&cli.BoolFlag{
+			Name:     "favorites-first",
+			Usage:    "when downloading all photos, download favorites before everything else",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_FAVORITES_FIRST"},
+		},
+		&cli.BoolFlag{
+			Name:     "shared-albums",
+			Usage:    "also download the shared albums the account owns or subscribes to, to the \"Shared Albums\" dir of the output dir",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_SHARED_ALBUMS"},
+		},
+		&cli.BoolFlag{
+			Name:     "incremental",
+			Usage:    "keep a state database in the output dir, and only fetch the photos changed since the last full run, the first run still goes over the whole library",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_INCREMENTAL"},
+		},
+		&cli.BoolFlag{
+			Name:     "since-last-sync",
+			Usage:    "only list the photos of each album newer than the newest one of its last sync without failure, kept in the output dir, instead of going down to --stop-found-num photos found",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_SINCE_LAST_SYNC"},
+		},
+		&cli.BoolFlag{
+			Name:     "snapshot",
+			Usage:    "download to a dated dir in the output dir on each run, with the files unchanged since the previous snapshot hardlinked from it, like rsync --link-dest",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_SNAPSHOT"},
+		},
+		&cli.StringFlag{
+			Name:     "zone",
+			Usage:    "photo library zone, like a SharedSync-* shared library, if not set, use the primary library",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_ZONE"},
+		},
 		&cli.Int64Flag{
 			Name:     "recent",
 			Usage:    "download recent photos, if not set, means all",
@@ -38,9 +85,94 @@ func NewDownloadFlag() []cli.Flag {
 			Aliases:  []string{"r"},
 			EnvVars:  []string{"ICLOUD_RECENT"},
 		},
+		&cli.IntFlag{
+			Name:     "recent-days",
+			Usage:    "download photos taken in the last `N` days",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_RECENT_DAYS"},
+		},
+		&cli.IntFlag{
+			Name:     "recent-hours",
+			Usage:    "download photos taken in the last `N` hours",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_RECENT_HOURS"},
+		},
+		&cli.StringFlag{
+			Name:     "from",
+			Usage:    "only download photos taken at or after the day, like 2023-01-01, or the time, like 2023-01-01T08:00:00Z",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_FROM"},
+		},
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "only download photos taken until the day, included, like 2023-01-31, or before the time, like 2023-01-31T08:00:00Z",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_TO"},
+		},
+		&cli.StringFlag{
+			Name:     "match",
+			Usage:    "only download photos whose filename matches the glob, like \"IMG_*.HEIC\", case insensitive",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_MATCH"},
+		},
+		&cli.StringFlag{
+			Name:     "filter",
+			Usage:    "only download photos matching the expression, like \"type==video && size>500MB && date>=2023-01-01\", fields: type, size, date, added, favorite, hidden, name, ext, source",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_FILTER"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "include",
+			Usage:    "only download the photos whose filename matches the glob, like \"*.HEIC\", rsync style, tried before --exclude, can be repeated",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_INCLUDE"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "exclude",
+			Usage:    "skip the photos whose filename matches the glob, like \"IMG_E*\" for the edited duplicates, rsync style, can be repeated",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_EXCLUDE"},
+		},
+		&cli.StringSliceFlag{
+			Name:     "exclude-source",
+			Usage:    "skip the photos saved by the app, a glob of its bundle id or name, like \"net.whatsapp.*\", case insensitive, can be repeated",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_EXCLUDE_SOURCE"},
+		},
+		&cli.BoolFlag{
+			Name:     "skip-screen-recordings",
+			Usage:    "skip the screen recordings",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_SKIP_SCREEN_RECORDINGS"},
+		},
+		&cli.StringFlag{
+			Name:     "near",
+			Usage:    "only download photos taken within the circle, like \"48.8584,2.2945,5km\"",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_NEAR"},
+		},
+		&cli.StringFlag{
+			Name:     "country",
+			Usage:    "only download photos taken in the country, name or code like FR, looked up by --geocoder",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_COUNTRY"},
+		},
+		&cli.StringFlag{
+			Name:     "city",
+			Usage:    "only download photos taken in the city, looked up by --geocoder",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_CITY"},
+		},
+		&cli.StringFlag{
+			Name:     "geocoder",
+			Usage:    "nominatim compatible reverse geocoding endpoint, used by --country and --city, the location of the photos is sent to it",
+			Required: false,
+			Value:    defaultGeocoder,
+			EnvVars:  []string{"ICLOUD_GEOCODER"},
+		},
 		&cli.Int64Flag{
 			Name:     "stop-found-num",
-			Usage:    "stop download when found `stop-found-num` photos have been downloaded",
+			Usage:    "stop download when found `stop-found-num` photos have been downloaded, not applied to the changes of --incremental",
 			Required: false,
 			Value:    50,
 			Aliases:  []string{"s"},
@@ -54,6 +186,72 @@ func NewDownloadFlag() []cli.Flag {
 			Value:    1,
 			EnvVars:  []string{"ICLOUD_THREAD_NUM"},
 		},
+		&cli.IntFlag{
+			Name:     "page-size",
+			Usage:    "list the photos `N` records per request, bigger pages take fewer requests",
+			Required: false,
+			Value:    200,
+			EnvVars:  []string{"ICLOUD_PAGE_SIZE"},
+			Action: func(c *cli.Context, v int) error {
+				if v <= 0 {
+					return fmt.Errorf("--page-size must be positive, got %d", v)
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:     "max-failures",
+			Usage:    "go on when a photo fails, and abort the run after `N` failed photos, the progress is saved to resume",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_MAX_FAILURES"},
+		},
+		&cli.StringFlag{
+			Name:     "max-failure-rate",
+			Usage:    "go on when a photo fails, and abort the run when the failure rate is over the `rate`, like 5%",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_MAX_FAILURE_RATE"},
+		},
+		&cli.IntFlag{
+			Name:     "asset-retries",
+			Usage:    "try a failed photo again up to `N` times, with a backoff doubled on each attempt, the photos out of retries are listed in --report and don't stop the run",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_ASSET_RETRIES"},
+		},
+		&cli.DurationFlag{
+			Name:     "asset-retry-backoff",
+			Usage:    "pause before the first retry of a photo, doubled on each next retry up to 5m, with jitter",
+			Required: false,
+			Value:    10 * time.Second,
+			EnvVars:  []string{"ICLOUD_ASSET_RETRY_BACKOFF"},
+		},
+		&cli.IntFlag{
+			Name:     "download-retries",
+			Usage:    "retry a download failing midway up to `N` times, resuming it from its .part file",
+			Required: false,
+			Value:    2,
+			EnvVars:  []string{"ICLOUD_DOWNLOAD_RETRIES"},
+		},
+		&cli.IntFlag{
+			Name:     "download-retry-backoff",
+			Usage:    "pause `seconds` before the first retry of a download, doubled before each next retry, up to a minute",
+			Required: false,
+			Value:    2,
+			EnvVars:  []string{"ICLOUD_DOWNLOAD_RETRY_BACKOFF"},
+		},
+		&cli.IntFlag{
+			Name:     "parallel-chunks",
+			Usage:    "download a large video or photo in `N` parallel ranges, for a CDN slow per connection, 1 downloads it in one request",
+			Required: false,
+			Value:    1,
+			EnvVars:  []string{"ICLOUD_PARALLEL_CHUNKS"},
+		},
+		&cli.StringFlag{
+			Name:     "chunk-size",
+			Usage:    "the size of a range of --parallel-chunks, like 16MB, the files smaller than two ranges are downloaded in one request",
+			Required: false,
+			Value:    "16MB",
+			EnvVars:  []string{"ICLOUD_CHUNK_SIZE"},
+		},
 		&cli.BoolFlag{
 			Name:     "auto-delete",
 			Usage:    "auto delete photos after download",
@@ -61,190 +259,1284 @@ func NewDownloadFlag() []cli.Flag {
 			Aliases:  []string{"ad"},
 			EnvVars:  []string{"ICLOUD_AUTO_DELETE"},
 		},
+		&cli.BoolFlag{
+			Name:     "trash",
+			Usage:    "with --auto-delete, move local copies into <output>/.trash instead of deleting them",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_TRASH"},
+		},
+		&cli.IntFlag{
+			Name:     "trash-retention-days",
+			Usage:    "days photos are kept in <output>/.trash before they are deleted",
+			Required: false,
+			Value:    30,
+			EnvVars:  []string{"ICLOUD_TRASH_RETENTION_DAYS"},
+		},
+		&cli.IntFlag{
+			Name:     "purge-deleted-days",
+			Usage:    "permanently delete photos in Recently Deleted for at least `N` days",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_PURGE_DELETED_DAYS"},
+		},
+		&cli.BoolFlag{
+			Name:     "purge-deleted-verified",
+			Usage:    "permanently delete photos in Recently Deleted once the checksum index of --verify-checksum has their file in the output dir, unchanged",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_PURGE_DELETED_VERIFIED"},
+		},
+		&cli.BoolFlag{
+			Name:     "dry-run",
+			Usage:    "with --purge-deleted-days or --purge-deleted-verified, only print the photos which would be purged",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_DRY_RUN"},
+		},
+		&cli.StringFlag{
+			Name:     "on-conflict",
+			Usage:    "what to do when a downloaded file exists but differs: skip, overwrite, rename, newer: overwrite when the photo changed on iCloud since it was downloaded, by the checksum index of --verify-checksum",
+			Required: false,
+			Value:    conflictOverwrite,
+			EnvVars:  []string{"ICLOUD_ON_CONFLICT"},
+			Action: func(context *cli.Context, s string) error {
+				if !isValidConflictPolicy(s) {
+					return fmt.Errorf("on-conflict must be skip, overwrite, rename or newer")
+				}
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:     "file-template",
+			Usage:    "lay out the photos in the output dir with the Go template, like '{{.Date.Format \"2006/01\"}}/{{.ID}}_{{.Filename}}', fields: ID, Filename, OriginalFilename, Name, Ext, Album, Date, CreatedAt, AddedAt, Favorite, Video, Source",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_FILE_TEMPLATE"},
+		},
+		&cli.StringFlag{
+			Name:     "group-by",
+			Usage:    "put the photos in folders of the output dir: none, year, month, day, or moment, like the events of the Photos app, by time and place gaps",
+			Required: false,
+			Value:    groupByNone,
+			EnvVars:  []string{"ICLOUD_GROUP_BY"},
+			Action: func(context *cli.Context, s string) error {
+				if !isValidGroupBy(s) {
+					return fmt.Errorf("group-by must be none, year, month, day or moment")
+				}
+				return nil
+			},
+		},
+		&cli.StringSliceFlag{
+			Name:     "mirror",
+			Usage:    "also write every photo to this dir, or to s3://bucket/prefix or webdav://user@host/dir like --output-backend, from the same download, repeat it for more",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_MIRROR"},
+		},
+		&cli.BoolFlag{
+			Name:     "verify-checksum",
+			Usage:    "only skip a file of the same size as the photo when it was downloaded from the same photo, by the iCloud checksum and a SHA-256 index of the output dir",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_VERIFY_CHECKSUM"},
+		},
+		&cli.StringFlag{
+			Name:     "manifest",
+			Usage:    "write a checksum manifest of downloaded files to the output dir: sums (like SHA256SUMS), json",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_MANIFEST"},
+		},
+		&cli.StringFlag{
+			Name:     "checksum-algorithm",
+			Usage:    "the checksum algorithm of --manifest: sha256, sha1 or xxh64, to match what the backup tooling validates",
+			Required: false,
+			Value:    "sha256",
+			EnvVars:  []string{"ICLOUD_CHECKSUM_ALGORITHM"},
+			Action: func(c *cli.Context, v string) error {
+				_, err := getChecksumAlgorithm(v)
+				return err
+			},
+		},
+		&cli.StringFlag{
+			Name:     "target",
+			Usage:    "upload photos to another service instead of the output dir, e.g. immich://host, nextcloud://user@host/dir",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_TARGET"},
+		},
+		&cli.StringFlag{
+			Name:     "immich-api-key",
+			Usage:    "immich api key, used with --target immich://host",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_IMMICH_API_KEY"},
+		},
+		&cli.StringFlag{
+			Name:     "nextcloud-password",
+			Usage:    "nextcloud app password, used with --target nextcloud://user@host/dir",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_NEXTCLOUD_PASSWORD"},
+		},
+		&cli.BoolFlag{
+			Name:     "nextcloud-tag-album",
+			Usage:    "tag files uploaded to nextcloud with the album name",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_NEXTCLOUD_TAG_ALBUM"},
+		},
+		&cli.StringFlag{
+			Name:     "output-backend",
+			Usage:    "stream the photos to s3://bucket/prefix or webdav://user@host/dir instead of the output dir, which keeps the state of the runs",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_OUTPUT_BACKEND"},
+		},
+		&cli.StringFlag{
+			Name:     "s3-endpoint",
+			Usage:    "url of a S3 compatible server, like http://minio:9000, used with --output-backend s3://bucket/prefix, the credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_S3_ENDPOINT", "AWS_ENDPOINT_URL"},
+		},
+		&cli.StringFlag{
+			Name:     "s3-region",
+			Usage:    "region of the bucket, used with --output-backend s3://bucket/prefix",
+			Required: false,
+			Value:    "us-east-1",
+			EnvVars:  []string{"ICLOUD_S3_REGION", "AWS_REGION"},
+		},
+		&cli.StringFlag{
+			Name:     "webdav-password",
+			Usage:    "webdav password, used with --output-backend webdav://user@host/dir",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_WEBDAV_PASSWORD"},
+		},
+		&cli.BoolFlag{
+			Name:     "preserve-folders",
+			Usage:    "download every album into the folders it's in in the Photos app, like <output>/Trips/2023/Japan, or only the --album ones",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_PRESERVE_FOLDERS"},
+		},
+		&cli.BoolFlag{
+			Name:     "photoprism",
+			Usage:    "write photos in PhotoPrism import layout, one folder per album with YAML sidecars",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_PHOTOPRISM"},
+		},
+		&cli.BoolFlag{
+			Name:     "previews-only",
+			Usage:    "only download the medium preview of each photo into <output>-previews, and record which originals are not fetched",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_PREVIEWS_ONLY"},
+		},
+		&cli.BoolFlag{
+			Name:     "original-filename",
+			Usage:    "name files by the filename the photo was imported with, instead of the current (edited) filename",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_ORIGINAL_FILENAME"},
+		},
+		&cli.StringFlag{
+			Name:     "version",
+			Usage:    "the version of the photos to download: original, adjusted (the edited rendering), alternative (like the RAW of a RAW+JPEG photo), medium or thumb, adjusted and alternative fall back to the original",
+			Required: false,
+			Value:    string(icloudgo.PhotoVersionOriginal),
+			EnvVars:  []string{"ICLOUD_VERSION"},
+			Action: func(c *cli.Context, v string) error {
+				return validatePhotoVersion(v)
+			},
+		},
+		&cli.StringFlag{
+			Name:     "active-hours",
+			Usage:    "only transfer photos in these local time windows, like \"22:00-07:00\", listing photos is always allowed",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_ACTIVE_HOURS"},
+		},
+		&cli.StringFlag{
+			Name:     "min-free-space",
+			Usage:    "stop cleanly when the free space of the output dir falls below this size, like 10G",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_MIN_FREE_SPACE"},
+		},
+		&cli.BoolFlag{
+			Name:     "estimate",
+			Usage:    "print the total size and estimated time of the selected photos before downloading, it goes over the whole selection first",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_ESTIMATE"},
+		},
+		&cli.BoolFlag{
+			Name:     "estimate-only",
+			Usage:    "print the total size and estimated time of the selected photos, then exit without downloading",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_ESTIMATE_ONLY"},
+		},
+		&cli.BoolFlag{
+			Name:     "gallery",
+			Usage:    "write a browsable index.html with thumbnails, album pages and date navigation to the output dir",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_GALLERY"},
+		},
+		&cli.BoolFlag{
+			Name:     "write-metadata",
+			Usage:    "write a <filename>.json next to each photo with its id, dates, checksum, caption, location, favorite flag and albums, and the <filename>.xmp of --write-xmp",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_WRITE_METADATA"},
+		},
+		&cli.BoolFlag{
+			Name:     "write-xmp",
+			Usage:    "write a <filename>.xmp next to each photo, with the caption, date, location, and the rating and keywords of --rating-map and --keyword-map",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_WRITE_XMP"},
+		},
+		&cli.StringFlag{
+			Name:     "rating-map",
+			Usage:    "map photo flags to the sidecar rating, the first match wins, like favorite=5,edited=3,default=0",
+			Required: false,
+			Value:    defaultRatingMap,
+			EnvVars:  []string{"ICLOUD_RATING_MAP"},
+		},
+		&cli.StringFlag{
+			Name:     "keyword-map",
+			Usage:    "rename albums when used as sidecar keywords, like Favorites=Best,Recents=, an empty name drops the album",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_KEYWORD_MAP"},
+		},
+		&cli.BoolFlag{
+			Name:     "write-adjustments",
+			Usage:    "write the edit recipe of each edited photo as a <name>.AAE next to the original",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_WRITE_ADJUSTMENTS"},
+		},
+		&cli.BoolFlag{
+			Name:     "live-photo-mov",
+			Usage:    "also download the video of each live photo, as a <name>.MOV next to the photo",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_LIVE_PHOTO_MOV"},
+		},
+		&cli.StringFlag{
+			Name:     "progress",
+			Usage:    "how the progress is shown, bar: a progress bar with the count, throughput and ETA, redrawn in place, lines: a line per photo, auto: bar when stderr is a terminal",
+			Required: false,
+			Value:    "auto",
+			EnvVars:  []string{"ICLOUD_PROGRESS"},
+		},
+		&cli.BoolFlag{
+			Name:     "video-poster",
+			Usage:    "also download the poster frame of each video, as a <name>_poster.JPG next to the video",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_VIDEO_POSTER"},
+		},
+		&cli.StringFlag{
+			Name:     "exec",
+			Usage:    "run the shell command after each photo is downloaded, with the path, photo id and album in $ICLOUD_FILE, $ICLOUD_PHOTO_ID and $ICLOUD_ALBUM",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_EXEC"},
+		},
+		&cli.StringFlag{
+			Name:     "report",
+			Usage:    "write a JSON report of the run to the path, with the config, counts, failures and timings",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_REPORT"},
+		},
+		&cli.BoolFlag{
+			Name:     "force",
+			Usage:    "break the lock of another run on the output dir, or sync another account into it",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_FORCE"},
+		},
+		&cli.BoolFlag{
+			Name:     "include-hidden",
+			Usage:    "also download hidden photos when downloading all photos",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_INCLUDE_HIDDEN"},
+		},
+		&cli.BoolFlag{
+			Name:     "include-recently-deleted",
+			Usage:    "also download recently deleted photos when downloading all photos",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_INCLUDE_RECENTLY_DELETED"},
+		},
 	)
 	return res
 }
 
-func Download(c *cli.Context) error {
-	username := c.String("username")
-	password := c.String("password")
-	cookieDir := c.String("cookie-dir")
-	domain := c.String("domain")
-	output := c.String("output")
-	recent := c.Int64("recent")
-	stopNum := c.Int64("stop-found-num")
-	album := c.String("album")
-	threadNum := c.Int("thread-num")
-	autoDelete := c.Bool("auto-delete")
+// filterFlags are the flags which skip photos of the albums, a run with them doesn't go over the whole library.
+var filterFlags = []string{
+	"recent-days", "recent-hours", "from", "to", "match", "filter", "include", "exclude", "exclude-source",
+	"skip-screen-recordings", "near", "country", "city",
+}
+
+type downloadOption struct {
+	ctx              context.Context
+	output           string
+	albums           []string
+	zone             string
+	favoritesFirst   bool
+	favoritesPassed  bool
+	sinceLastSync    bool
+	estimate         bool
+	estimateOnly     bool
+	minFreeSpace     uint64
+	activeHours      *activeHours
+	recent           int
+	stopNum          int64
+	threadNum        int
+	autoDelete       bool
+	trash            bool
+	trashRetention   time.Duration
+	purgeDays        int
+	purgeVerified    bool
+	purgeDryRun      bool
+	force            bool
+	onConflict       string
+	originalFilename bool
+	grouping         *grouping
+	fileTemplate     *fileTemplate
+	folders          *albumFolders
+	iterOption       *icloudgo.PhotosIterOption
+	storage          icloudgo.Storage
+	mirrors          []*mirrorDestination
+	progress         *runProgress
+	bar              *progressBar
+	failureBudget    *failureBudget
+	assetRetry       *assetRetry
+	report           *runReport
+	manifest         *checksumManifest
+	checksums        *checksumIndex
+	state            *stateDB
+	albumState       *albumState
+	syncState        *syncState
+	snapshot         *snapshot
+	gallery          *gallery
+	immich           *immichTarget
+	nextcloud        *nextcloudTarget
+	photoprism       bool
+	writeMetadata    bool
+	writeXMP         bool
+	curation         *curationMap
+	writeAdjustments bool
+	livePhotoMov     bool
+	videoPoster      bool
+	sharedAlbums     bool
+	execHook         string
+	version          icloudgo.PhotoVersion
+	parallel         *icloudgo.ParallelDownloadOption
+
+	previewsOnly bool
+	pending      *pendingOriginals
+}
+
+func Download(c *cli.Context) (finalErr error) {
+	option := &downloadOption{
+		output:           c.String("output"),
+		albums:           c.StringSlice("album"),
+		zone:             c.String("zone"),
+		favoritesFirst:   c.Bool("favorites-first"),
+		sinceLastSync:    c.Bool("since-last-sync"),
+		recent:           int(c.Int64("recent")),
+		stopNum:          c.Int64("stop-found-num"),
+		threadNum:        c.Int("thread-num"),
+		autoDelete:       c.Bool("auto-delete"),
+		trash:            c.Bool("trash"),
+		trashRetention:   time.Duration(c.Int("trash-retention-days")) * 24 * time.Hour,
+		purgeDays:        c.Int("purge-deleted-days"),
+		purgeVerified:    c.Bool("purge-deleted-verified"),
+		purgeDryRun:      c.Bool("dry-run"),
+		force:            c.Bool("force"),
+		onConflict:       c.String("on-conflict"),
+		originalFilename: c.Bool("original-filename"),
+		grouping:         newGrouping(c.String("group-by")),
+		photoprism:       c.Bool("photoprism"),
+		writeMetadata:    c.Bool("write-metadata"),
+		writeXMP:         c.Bool("write-xmp"),
+		writeAdjustments: c.Bool("write-adjustments"),
+		livePhotoMov:     c.Bool("live-photo-mov"),
+		videoPoster:      c.Bool("video-poster"),
+		sharedAlbums:     c.Bool("shared-albums"),
+		execHook:         c.String("exec"),
+		version:          icloudgo.PhotoVersion(c.String("version")),
+		estimate:         c.Bool("estimate"),
+		estimateOnly:     c.Bool("estimate-only"),
+
+		previewsOnly: c.Bool("previews-only"),
+		storage:      icloudgo.NewFileStorage(),
+		progress:     newRunProgress(),
+		iterOption: &icloudgo.PhotosIterOption{
+			IncludeHidden:          c.Bool("include-hidden"),
+			IncludeRecentlyDeleted: c.Bool("include-recently-deleted"),
+			PageSize:               c.Int("page-size"),
+		},
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+	option.ctx = ctx
+	option.iterOption.Context = ctx
+
+	bar, err := newProgressBar(c.String("progress"), option.progress)
+	if err != nil {
+		return err
+	}
+	option.bar = bar
+	option.bar.apply(option)
+
+	option.report = newRunReport(c, c.String("report"))
+	defer func() {
+		if err := option.report.Write(option.progress, finalErr); err != nil && finalErr == nil {
+			finalErr = err
+		}
+	}()
+
+	if n := c.Int("parallel-chunks"); n > 1 {
+		chunkSize, err := parseSize(c.String("chunk-size"))
+		if err != nil {
+			return err
+		}
+		option.parallel = &icloudgo.ParallelDownloadOption{Concurrency: n, ChunkSize: int64(chunkSize)}
+	}
+
+	if v := c.String("min-free-space"); v != "" {
+		minFreeSpace, err := parseSize(v)
+		if err != nil {
+			return err
+		}
+		option.minFreeSpace = minFreeSpace
+	}
+
+	curation, err := parseCurationMap(c.String("rating-map"), c.String("keyword-map"))
+	if err != nil {
+		return err
+	}
+	option.curation = curation
+
+	budget, err := newFailureBudget(c.Int("max-failures"), c.String("max-failure-rate"))
+	if err != nil {
+		return err
+	}
+	option.failureBudget = budget
+	option.assetRetry = newAssetRetry(c.Int("asset-retries"), c.Duration("asset-retry-backoff"))
+	if option.assetRetry != nil && option.failureBudget == nil {
+		// a photo out of retries is recorded in the report, it doesn't stop the run
+		option.failureBudget = &failureBudget{}
+	}
+
+	option.mirrors, err = newMirrorDestinations(c)
+	if err != nil {
+		return err
+	}
+
+	activeHours, err := parseActiveHours(c.String("active-hours"))
+	if err != nil {
+		return err
+	}
+	option.activeHours = activeHours
+
+	if days, hours := c.Int("recent-days"), c.Int("recent-hours"); days > 0 || hours > 0 {
+		window := time.Duration(days)*24*time.Hour + time.Duration(hours)*time.Hour
+		option.iterOption.Since = time.Now().Add(-window)
+	}
+
+	photosFilter, err := parsePhotosFilter(c.String("from"), c.String("to"), c.String("match"))
+	if err != nil {
+		return err
+	}
+	option.iterOption = photosFilter.IterOption(option.iterOption)
+
+	if expr := c.String("filter"); expr != "" {
+		filter, err := parseAssetFilter(expr)
+		if err != nil {
+			return err
+		}
+		filter.apply(option)
+	}
+
+	rules, err := parseFilenameRules(c.StringSlice("include"), c.StringSlice("exclude"))
+	if err != nil {
+		return err
+	}
+	rules.apply(option)
+
+	for _, glob := range c.StringSlice("exclude-source") {
+		match, err := sourceMatcher(glob)
+		if err != nil {
+			return fmt.Errorf("invalid --exclude-source %q: %w", glob, err)
+		}
+		option.addFilter(func(photo *icloudgo.PhotoAsset) bool { return !match(photo) })
+	}
+
+	if c.Bool("skip-screen-recordings") {
+		option.addFilter(func(photo *icloudgo.PhotoAsset) bool { return !photo.IsScreenRecording() })
+	}
+
+	if near := c.String("near"); near != "" {
+		fence, err := parseGeoFence(near)
+		if err != nil {
+			return err
+		}
+		option.addFilter(fence.Contains)
+	}
+
+	if country, city := c.String("country"), c.String("city"); country != "" || city != "" {
+		if c.String("geocoder") == defaultGeocoder {
+			fmt.Printf("warning: --country and --city send the location of the photos to %s, set --geocoder to use your own server\n", defaultGeocoder)
+		}
+		option.addFilter(placeFilter(newReverseGeocoder(c.String("geocoder")), country, city))
+	}
+
+	// the photos are downloaded all the same, only the purge is dry
+	if option.purgeDryRun && option.purgeDays <= 0 && !option.purgeVerified {
+		return fmt.Errorf("--dry-run needs --purge-deleted-days or --purge-deleted-verified")
+	}
+	if c.Bool("incremental") {
+		if len(option.albums) > 0 {
+			return fmt.Errorf("--incremental can't be used with --album")
+		}
+		// the sync token moves past the changes the filters skip, the next runs with other filters would never see them
+		for _, name := range filterFlags {
+			if c.IsSet(name) {
+				return fmt.Errorf("--incremental can't be used with --%s", name)
+			}
+		}
+	}
+
+	if c.Bool("since-last-sync") {
+		// the marks of a filtered run would be past the photos it skipped, the next runs would stop before them
+		for _, name := range filterFlags {
+			if c.IsSet(name) {
+				return fmt.Errorf("--since-last-sync can't be used with --%s", name)
+			}
+		}
+	}
+
+	if c.Bool("snapshot") {
+		// a snapshot starts empty, and keeps the photos deleted since the previous one in the previous one,
+		// it's of the whole library, the photos hardlinked from the previous one are found, and must not stop the run
+		for _, name := range append([]string{"incremental", "since-last-sync", "auto-delete", "trash", "target", "previews-only", "recent", "stop-found-num"}, filterFlags...) {
+			if c.IsSet(name) {
+				return fmt.Errorf("--snapshot can't be used with --%s", name)
+			}
+		}
+		option.stopNum = math.MaxInt64
+	}
+
+	if c.IsSet("version") {
+		// the previews and the targets are of the originals
+		for _, name := range []string{"previews-only", "target"} {
+			if c.IsSet(name) {
+				return fmt.Errorf("--version can't be used with --%s", name)
+			}
+		}
+	}
 
-	cli, err := icloudgo.New(&icloudgo.ClientOption{
-		AppID:           username,
-		CookieDir:       cookieDir,
-		PasswordGetter:  getTextInput("apple id password", password),
-		TwoFACodeGetter: getTextInput("2fa code", ""),
-		Domain:          domain,
-	})
+	option.fileTemplate, err = newFileTemplate(c.String("file-template"))
 	if err != nil {
 		return err
 	}
+	if option.fileTemplate != nil {
+		for _, name := range []string{"original-filename", "photoprism"} {
+			if c.IsSet(name) {
+				return fmt.Errorf("--file-template can't be used with --%s", name)
+			}
+		}
+	}
 
-	defer cli.Close()
+	if option.grouping != nil {
+		if option.photoprism {
+			return fmt.Errorf("--group-by can't be used with --photoprism")
+		}
+		// the moment of a photo is only known from the photos next to it, not from the changes or the deleted photos alone
+		if c.String("group-by") == groupByMoment {
+			for _, name := range []string{"incremental", "auto-delete", "purge-deleted-verified"} {
+				if c.IsSet(name) {
+					return fmt.Errorf("--group-by moment can't be used with --%s", name)
+				}
+			}
+		}
+	}
+
+	if c.Bool("preserve-folders") {
+		// the photos are laid out by the albums they are downloaded from, and cleaned up from the output dir
+		for _, name := range []string{"photoprism", "favorites", "favorites-first", "incremental", "auto-delete", "purge-deleted-verified"} {
+			if c.IsSet(name) {
+				return fmt.Errorf("--preserve-folders can't be used with --%s", name)
+			}
+		}
+	}
 
-	if err := cli.Authenticate(false, nil); err != nil {
+	if c.Bool("favorites") {
+		if len(option.albums) > 0 {
+			return fmt.Errorf("--favorites can't be used with --album")
+		}
+		option.albums = []string{string(icloudgo.AlbumIDFavorites)}
+	}
+
+	if target := c.String("target"); strings.HasPrefix(target, "immich") {
+		immich, err := newImmichTarget(target, c.String("immich-api-key"))
+		if err != nil {
+			return err
+		}
+		option.immich = immich
+	} else if strings.HasPrefix(target, "nextcloud") {
+		nextcloud, err := newNextcloudTarget(target, c.String("nextcloud-password"), c.Bool("nextcloud-tag-album"))
+		if err != nil {
+			return err
+		}
+		option.nextcloud = nextcloud
+	} else if target != "" {
+		return fmt.Errorf("unsupported target: %s", target)
+	}
+
+	backend, err := newOutputBackend(c, option.output)
+	if err != nil {
 		return err
 	}
+	if backend != nil {
+		// the backend has no local file to link, move, read or hand to a command
+		for _, name := range []string{"target", "mirror", "snapshot", "trash", "previews-only", "photoprism", "gallery", "manifest", "verify-checksum",
+			"write-metadata", "write-xmp", "write-adjustments", "exec"} {
+			if c.IsSet(name) {
+				return fmt.Errorf("--output-backend can't be used with --%s", name)
+			}
+		}
+		// newer and the verified purge need the checksum index, which hashes the local files
+		if option.onConflict == conflictNewer {
+			return fmt.Errorf("--output-backend can't be used with --on-conflict newer")
+		}
+		if option.purgeVerified {
+			return fmt.Errorf("--output-backend can't be used with --purge-deleted-verified")
+		}
+		option.storage = backend
+	}
 
-	photoCli, err := cli.PhotoCli()
+	cli, err := icloudgo.New(newClientOption(c))
 	if err != nil {
 		return err
 	}
 
-	if err := downloadPhoto(photoCli, output, album, int(recent), stopNum, threadNum); err != nil {
+	defer cli.Close()
+
+	unlock, err := acquireLock(option.ctx, option.output, option.force)
+	if err != nil {
 		return err
 	}
+	defer unlock()
 
-	if autoDelete {
-		if err := autoDeletePhoto(photoCli, output, threadNum); err != nil {
+	if c.Bool("snapshot") {
+		option.snapshot, err = newSnapshot(option.output, time.Now())
+		if err != nil {
 			return err
 		}
+		option.output = option.snapshot.dir
 	}
 
-	return nil
-}
+	if err := cleanupPartialFiles(option.output); err != nil {
+		return err
+	}
 
-func downloadPhoto(photoCli *icloudgo.PhotoService, outputDir, albumName string, recent int, stopNum int64, threadNum int) error {
-	if f, _ := os.Stat(outputDir); f == nil {
-		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+	// rootDir is the output dir the user gave, the snapshot dir is in it, and so is the state database shared by the snapshots
+	rootDir := option.output
+	if option.snapshot != nil {
+		rootDir = filepath.Dir(option.output)
+	}
+	option.state, err = openStateDB(rootDir)
+	if err != nil {
+		return err
+	}
+	defer option.state.Close()
+
+	option.manifest, err = newChecksumManifest(c.String("manifest"), c.String("checksum-algorithm"), option.output)
+	if err != nil {
+		return err
+	}
+
+	option.checksums, err = loadChecksumIndex(c.Bool("verify-checksum") || option.onConflict == conflictNewer || option.purgeVerified, option.state, option.output)
+	if err != nil {
+		return err
+	}
+
+	option.pending, err = loadPendingOriginals(option.output)
+	if err != nil {
+		return err
+	}
+
+	option.albumState, err = loadAlbumState(option.state)
+	if err != nil {
+		return err
+	}
+	for _, name := range filterFlags {
+		option.albumState.filtered = option.albumState.filtered || c.IsSet(name)
+	}
+
+	option.gallery, err = newGallery(c.Bool("gallery"), option.output)
+	if err != nil {
+		return err
+	}
+
+	option.syncState, err = loadSyncState(c.Bool("incremental"), option.state)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err := authenticate(option.ctx, c, cli); err != nil {
+		return err
+	}
+	defer startKeepAlive(c, cli)()
+	if err := checkAccountOwner(option.state, rootDir, c.String("username"), cli.DSID(), option.force); err != nil {
+		return err
+	}
+	if warning := warnStorageFull(option.ctx, cli); warning != "" {
+		option.report.AddWarning(warning)
+	}
+
+	photoCli, err := getPhotoCli(cli, option.zone)
+	if err != nil {
+		return err
+	}
+	option.report.Stage("auth", start)
+	photoCli.SetParallelDownload(option.parallel)
+	if c.Bool("preserve-folders") {
+		if option.folders, err = loadAlbumFolders(option.ctx, photoCli); err != nil {
+			return err
+		}
+	}
+	if option.syncState != nil {
+		option.syncState.service = photoCli
+	}
+
+	// the estimate goes over the whole selection, so it's only done when asked for
+	if option.estimate || option.estimateOnly {
+		start = time.Now()
+		estimate, err := estimateAlbums(photoCli, option)
+		if err != nil {
 			return err
 		}
+		fmt.Println(estimate)
+		option.report.Stage("estimate", start)
+		if option.estimateOnly {
+			return nil
+		}
 	}
 
-	album, err := photoCli.GetAlbum(albumName)
+	start = time.Now()
+	stopDump := dumpProgressOnSignal(option.progress)
+	option.bar.Start()
+	err = downloadAlbums(photoCli, option)
+	if err == nil && option.sharedAlbums {
+		err = downloadSharedAlbums(photoCli, option)
+	}
+	option.bar.Stop()
+	stopDump()
+	option.report.Stage("download", start)
+	if manifestErr := option.manifest.Write(); manifestErr != nil && err == nil {
+		err = manifestErr
+	}
+	if pendingErr := option.pending.Write(); pendingErr != nil && err == nil {
+		err = pendingErr
+	}
+	if galleryErr := option.gallery.Write(); galleryErr != nil && err == nil {
+		err = galleryErr
+	}
+	for _, mirror := range option.mirrors {
+		fmt.Println(mirror)
+	}
+	if report := option.albumState.Report(); report != "" {
+		fmt.Printf("albums: %s\n", report)
+	}
+	if stateErr := option.albumState.Write(); stateErr != nil && err == nil {
+		err = stateErr
+	}
+	if report := option.syncState.Report(); report != "" {
+		fmt.Printf("incremental: %s\n", report)
+	}
+	if option.snapshot != nil {
+		fmt.Println(option.snapshot)
+	}
+	if stateErr := option.state.Close(); stateErr != nil && err == nil {
+		err = stateErr
+	}
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("album: %s, total: %d, target: %s, thread-num: %d\n", album.Name, album.Size(), outputDir, threadNum)
+	start = time.Now()
+	if option.autoDelete {
+		if err := autoDeletePhoto(photoCli, option); err != nil {
+			return err
+		}
+	}
+
+	if option.purgeDays > 0 || option.purgeVerified {
+		if err := purgeDeletedPhoto(photoCli, option); err != nil {
+			return err
+		}
+	}
+	option.report.Stage("cleanup", start)
+
+	// a snapshot with failed photos is incomplete, latest keeps pointing to the last complete one
+	if option.failureBudget.Failed() == 0 {
+		if err := option.snapshot.Finish(); err != nil {
+			return err
+		}
+	}
+	return option.failureBudget.Err()
+}
+
+// downloadAlbums downloads the selected albums, with --favorites-first,
+// a full-library run downloads favorites first, so the most valued photos are safe first.
+func downloadAlbums(photoCli icloudgo.AlbumLister, option *downloadOption) error {
+	if option.favoritesFirst && len(option.albums) == 0 {
+		// each pass has its own --stop-found-num counter, the favorites of the first pass are on disk
+		// by the second pass, they don't count as found there, or it would stop before the new photos
+		option.favoritesPassed = false
+		for _, albumName := range option.albumNames() {
+			albums, err := getAlbums(photoCli, []string{albumName})
+			if err != nil {
+				return err
+			}
+			if err := downloadPhoto(option, albums); err != nil {
+				return err
+			}
+			option.favoritesPassed = true
+		}
+		return nil
+	}
+	if option.folders != nil && len(option.albums) == 0 {
+		return downloadPhoto(option, option.folders.albums)
+	}
+	albums, err := getAlbums(photoCli, option.albumNames())
+	if err != nil {
+		return err
+	}
+	return downloadPhoto(option, albums)
+}
 
-	if recent == 0 {
-		recent, err = album.GetSize()
+func getAlbums(photoCli icloudgo.AlbumLister, albumNames []string) ([]*icloudgo.PhotoAlbum, error) {
+	albums := make([]*icloudgo.PhotoAlbum, 0, len(albumNames))
+	for _, albumName := range albumNames {
+		album, err := photoCli.GetAlbum(albumName)
 		if err != nil {
+			return nil, err
+		}
+		albums = append(albums, album)
+	}
+	return albums, nil
+}
+
+// downloadPhoto downloads the albums concurrently, all albums share the --thread-num workers.
+func downloadPhoto(option *downloadOption, albums []*icloudgo.PhotoAlbum) error {
+	outputDir := option.output
+	if f, _ := os.Stat(outputDir); f == nil {
+		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
 			return err
 		}
 	}
 
-	photoIter := album.PhotosIter()
-	wait := new(sync.WaitGroup)
-	foundDownloadedNum := int64(0)
-	var downloaded int32
-	var finalErr error
-	for threadIndex := 0; threadIndex < threadNum; threadIndex++ {
-		wait.Add(1)
-		go func(threadIndex int) {
-			defer wait.Done()
-
-			for {
-				if atomic.LoadInt32(&downloaded) >= int32(recent) {
-					return
-				}
-				if atomic.LoadInt64(&foundDownloadedNum) >= stopNum {
-					return
-				}
+	var jobs []*albumJob
+	for _, album := range albums {
+		var err error
+		option.bar.Printf("album: %s, total: %d, target: %s, thread-num: %d\n", album.Name, album.Size(), outputDir, option.threadNum)
 
-				photoAsset, err := photoIter.Next()
-				if err != nil {
-					if errors.Is(err, icloudgo.ErrPhotosIterateEnd) {
-						return
-					}
-					if finalErr != nil {
-						finalErr = err
-					}
-					return
-				}
+		var iter icloudgo.AssetIterator = album.PhotosIterWithOption(option.iterOption)
+		if option.sinceLastSync {
+			iter = option.albumState.SinceLastSync(album, iter)
+		}
+		recent := option.recent
+		stopNum := option.stopNum
+		if option.syncState != nil && album.ID() == icloudgo.AlbumIDAll {
+			iter = option.syncState.Iter(option)
+			if recent == 0 {
+				recent = math.MaxInt32
+			}
+			// the sync token is only saved once the changes are iterated to the end,
+			// and the changes already downloaded are the ones of a previous run stopped before saving it
+			stopNum = math.MaxInt64
+		}
+		iter = option.grouping.Iter(iter)
+		if recent == 0 {
+			recent, err = album.GetSize()
+			if err != nil {
+				return err
+			}
+		}
+		option.bar.AddTotal(int(math.Min(float64(recent), float64(album.Size()))))
+		jobs = append(jobs, &albumJob{
+			album:   album,
+			iter:    iter,
+			recent:  int32(recent),
+			stopNum: stopNum,
+		})
+	}
 
-				if isDownloaded, err := downloadPhotoAsset(photoAsset, outputDir, threadIndex); err != nil {
-					if finalErr != nil {
-						finalErr = err
-					}
-					return
-				} else if isDownloaded {
-					atomic.AddInt64(&foundDownloadedNum, 1)
-					if foundDownloadedNum >= stopNum {
-						return
-					}
-				} else {
-					atomic.AddInt32(&downloaded, 1)
+	if err := option.checkFreeSpace(); err != nil {
+		return err
+	}
+
+	queue := newAlbumQueue(jobs)
+	workers := pool.New(option.ctx, &pool.Option{Workers: option.threadNum, OnError: option.recordFailure})
+	for workers.Err() == nil {
+		if err := option.checkFreeSpace(); err != nil {
+			workers.Stop(err)
+			break
+		}
+		job := queue.Next()
+		if job == nil {
+			break
+		}
+		// the next page is not fetched outside --active-hours, its download urls would expire while waiting
+		if err := option.activeHours.Wait(option.ctx); err != nil {
+			workers.Stop(err)
+			break
+		}
+
+		photoAsset, err := job.iter.Next()
+		if err != nil {
+			if errors.Is(err, icloudgo.ErrPhotosIterateEnd) {
+				queue.Finish(job)
+				continue
+			}
+			workers.Stop(err)
+			break
+		}
+
+		atomic.AddInt64(&job.iterated, 1)
+		_ = workers.Submit(func(ctx context.Context, threadIndex int) error {
+			if job.isDone() {
+				// --recent or --stop-found-num was reached while the photo was queued
+				return nil
+			}
+			// the photos queued before the window closed wait too
+			if err := option.activeHours.Wait(ctx); err != nil {
+				return err
+			}
+			option.progress.Start(threadIndex, photoAsset)
+			isDownloaded, err := option.assetRetry.Do(option.ctx, photoAsset, func() (bool, error) {
+				return downloadPhotoAsset(photoAsset, job.album, option, threadIndex)
+			})
+			option.progress.Done(threadIndex, photoAsset, isDownloaded, err)
+			if err != nil {
+				return err
+			}
+			// the successes are the base of --max-failure-rate
+			_ = option.failureBudget.Record(nil)
+			if isDownloaded {
+				if !option.favoritesPassed || !photoAsset.IsFavorite() {
+					atomic.AddInt64(&job.found, 1)
 				}
+				option.albumState.Seen(job.album, photoAsset)
+			} else {
+				atomic.AddInt32(&job.downloaded, 1)
+				option.albumState.Add(job.album, photoAsset)
 			}
-		}(threadIndex)
+			return option.syncState.Add(photoAsset)
+		})
 	}
-	wait.Wait()
+	finalErr := workers.Wait()
 
+	if finalErr == nil && option.failureBudget.Failed() == 0 {
+		for _, job := range jobs {
+			option.albumState.Synced(job.album)
+		}
+		finalErr = option.syncState.Synced()
+	}
+	if finalErr == nil {
+		for _, drift := range checkDrift(jobs, option) {
+			option.bar.Printf("%s\n", drift)
+			option.report.AddDrift(drift)
+		}
+	}
 	return finalErr
 }
 
-func downloadPhotoAsset(photo *icloudgo.PhotoAsset, outputDir string, threadIndex int) (bool, error) {
+// recordFailure counts a failed photo against the failure budget, with --asset-retries,
+// the errors a retry can't fix, like an expired login or a full disk, abort the run at once.
+func (r *downloadOption) recordFailure(err error) error {
+	if r.assetRetry != nil && !isRetryableAssetError(r.ctx, err) {
+		return err
+	}
+	return r.failureBudget.Record(err)
+}
+
+func downloadPhotoAsset(photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, option *downloadOption, threadIndex int) (bool, error) {
 	filename := photo.Filename()
-	path := photo.LocalPath(outputDir, icloudgo.PhotoVersionOriginal)
-	fmt.Printf("start %v, %v, %v, thread=%d\n", photo.ID(), filename, photo.FormatSize(), threadIndex)
+	outputDir := option.albumDir(album)
+	if outputDir != option.output {
+		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+			return false, err
+		}
+	}
+	version := option.photoVersion(photo)
+	path := option.localPath(photo, album, outputDir, version)
+	option.bar.Logf("start %v, %v, %v, thread=%d\n", photo.ID(), filename, photo.FormatSize(), threadIndex)
+
+	if option.immich != nil {
+		return uploadPhotoAssetToImmich(photo, album, option.immich)
+	}
+	if option.nextcloud != nil {
+		return uploadPhotoAssetToNextcloud(photo, album, option.nextcloud, option.output, path)
+	}
+	if option.previewsOnly {
+		return downloadPreview(photo, path, option)
+	}
+
+	if option.trash && restoreFromTrash(option.output, photo, version, path) {
+		return true, nil
+	}
+	option.snapshot.link(path, int64(versionSize(photo, version)))
+	target, skip := resolveConflict(option.storage, photo, version, path, option.onConflict, option.checksums)
+	if skip {
+		option.pending.Remove(photo)
+		option.bar.Logf("file '%s' exist, skip.\n", path)
+		copyToMirrors(photo, version, option, target)
+		if err := writeSidecars(photo, album, path, option); err != nil {
+			return true, err
+		}
+		if option.writeAdjustments {
+			if err := writeAdjustmentSidecar(photo, path); err != nil {
+				return true, err
+			}
+		}
+		if err := downloadLivePhotoMov(photo, path, option); err != nil {
+			return true, err
+		}
+		if err := downloadVideoPoster(photo, path, option); err != nil {
+			return true, err
+		}
+		return true, option.gallery.Add(photo, album, path)
+	}
+	if err := downloadWithMirrors(photo, version, option, target); err != nil {
+		return false, err
+	}
+	option.pending.Remove(photo)
+	if option.photoprism {
+		if err := writePhotoprismSidecar(photo, album, target); err != nil {
+			return false, err
+		}
+	}
+	if err := writeSidecars(photo, album, target, option); err != nil {
+		return false, err
+	}
+	if option.writeAdjustments {
+		if err := writeAdjustmentSidecar(photo, target); err != nil {
+			return false, err
+		}
+	}
+	if err := downloadLivePhotoMov(photo, target, option); err != nil {
+		return false, err
+	}
+	if err := downloadVideoPoster(photo, target, option); err != nil {
+		return false, err
+	}
+	if err := option.gallery.Add(photo, album, target); err != nil {
+		return false, err
+	}
+	if err := option.checksums.Add(photo, version, target); err != nil {
+		return false, err
+	}
+	runExecHook(option, photo, album, target)
+	return false, option.manifest.Add(target)
+}
+
+func uploadPhotoAssetToImmich(photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, immich *immichTarget) (bool, error) {
+	if exist, err := immich.Exists(photo); err != nil {
+		return false, err
+	} else if exist {
+		fmt.Printf("asset '%s' exist in immich, skip.\n", photo.Filename())
+		return true, nil
+	}
+
+	albumName := ""
+	if album.ID() != icloudgo.AlbumIDAll {
+		albumName = album.Name
+	}
+	return false, immich.Upload(photo, icloudgo.PhotoVersionOriginal, albumName)
+}
+
+func uploadPhotoAssetToNextcloud(photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, nextcloud *nextcloudTarget, outputDir, path string) (bool, error) {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		return false, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	if exist, err := nextcloud.Exists(photo, rel); err != nil {
+		return false, err
+	} else if exist {
+		fmt.Printf("file '%s' exist in nextcloud, skip.\n", rel)
+		return true, nil
+	}
+
+	albumName := ""
+	if album.ID() != icloudgo.AlbumIDAll {
+		albumName = album.Name
+	}
+	return false, nextcloud.Upload(photo, icloudgo.PhotoVersionOriginal, rel, albumName)
+}
+
+// addFilter makes the run skip photos filter returns false for, on top of the existing filter.
+func (r *downloadOption) addFilter(filter func(photo *icloudgo.PhotoAsset) bool) {
+	prev := r.iterOption.Filter
+	r.iterOption.Filter = func(photo *icloudgo.PhotoAsset) bool {
+		return (prev == nil || prev(photo)) && filter(photo)
+	}
+}
+
+// albumNames returns the albums the run downloads, in order, "" means all photos.
+func (r *downloadOption) albumNames() []string {
+	if r.favoritesFirst && len(r.albums) == 0 {
+		return []string{string(icloudgo.AlbumIDFavorites), ""}
+	}
+	if len(r.albums) == 0 {
+		return []string{""}
+	}
+	return r.albums
+}
+
+var errFreeSpaceLow = errors.New("free space below --min-free-space")
+
+// checkFreeSpace stops the run before the output dir filesystem is full,
+// files already downloaded are kept, and the next run continues from them.
+func (r *downloadOption) checkFreeSpace() error {
+	if r.minFreeSpace == 0 || r.immich != nil || r.nextcloud != nil {
+		return nil
+	}
+	free, err := diskFree(r.output)
+	if err != nil {
+		return fmt.Errorf("check free space of %s failed: %w", r.output, err)
+	}
+	if free < r.minFreeSpace {
+		return fmt.Errorf("%w: %s free on %s, stop downloading", errFreeSpaceLow, icloudgo.FormatSize(int(free)), r.output)
+	}
+	return nil
+}
+
+// albumDir returns the dir the photos of album are downloaded to, the output dir unless --photoprism or --preserve-folders.
+func (r *downloadOption) albumDir(album *icloudgo.PhotoAlbum) string {
+	outputDir := r.output
+	if r.photoprism {
+		outputDir = photoprismDir(outputDir, album)
+	}
+	return r.folders.Dir(outputDir, album)
+}
+
+// localPath returns the path of the version of the photo of album in outputDir, album is nil when it's unknown,
+// a --file-template failing on the photo falls back to the filename.
+func (r *downloadOption) localPath(photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, outputDir string, version icloudgo.PhotoVersion) string {
+	if dir := r.grouping.Dir(photo); dir != "" {
+		outputDir = filepath.Join(outputDir, dir)
+	}
+	if r.fileTemplate != nil {
+		if path, err := r.fileTemplate.Path(photo, album, outputDir, version); err == nil {
+			return path
+		}
+	}
+	if r.originalFilename {
+		return photo.LocalPathWithFilename(outputDir, version, photo.OriginalFilename())
+	}
+	return photo.LocalPath(outputDir, version)
+}
+
+// downloadedAlbums returns the albums downloadAlbums goes over, the ones the photos are laid out by.
+func downloadedAlbums(photoCli icloudgo.AlbumLister, option *downloadOption) ([]*icloudgo.PhotoAlbum, error) {
+	if option.folders != nil && len(option.albums) == 0 {
+		return option.folders.albums, nil
+	}
+	return getAlbums(photoCli, option.albumNames())
+}
 
-	if f, _ := os.Stat(path); f != nil {
-		if photo.Size() != int(f.Size()) {
-			return false, photo.DownloadTo(icloudgo.PhotoVersionOriginal, path)
-		} else {
-			fmt.Printf("file '%s' exist, skip.\n", path)
-			return true, nil
+// deletedPhotoPaths returns the files of the deleted photo, at the paths downloadPhotoAsset downloads it to
+// for each album of the run, a file which isn't the photo, by the checksum index or else by size, is kept.
+func deletedPhotoPaths(photo *icloudgo.PhotoAsset, albums []*icloudgo.PhotoAlbum, option *downloadOption) []string {
+	version := option.photoVersion(photo)
+	var paths []string
+	seen := map[string]bool{}
+	for _, album := range albums {
+		path := option.localPath(photo, album, option.albumDir(album), version)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if f, _ := option.storage.Stat(path); f != nil && option.checksums.Downloaded(photo, version, path, f) {
+			paths = append(paths, path)
 		}
-	} else {
-		return false, photo.DownloadTo(icloudgo.PhotoVersionOriginal, path)
 	}
+	return paths
 }
 
-func autoDeletePhoto(photoCli *icloudgo.PhotoService, outputDir string, threadNum int) error {
-	album, err := photoCli.GetAlbum(icloudgo.AlbumNameRecentlyDeleted)
+func autoDeletePhoto(photoCli icloudgo.AlbumLister, option *downloadOption) error {
+	outputDir, threadNum := option.output, option.threadNum
+	album, err := photoCli.GetAlbumByID(icloudgo.AlbumIDRecentlyDeleted)
+	if err != nil {
+		return err
+	}
+	albums, err := downloadedAlbums(photoCli, option)
 	if err != nil {
 		return err
 	}
 
 	fmt.Printf("auto delete album: %s, total: %d\n", album.Name, album.Size())
 
-	photoIter := album.PhotosIter()
-	wait := new(sync.WaitGroup)
-	var finalErr error
-	for threadIndex := 0; threadIndex < threadNum; threadIndex++ {
-		wait.Add(1)
-		go func(threadIndex int) {
-			defer wait.Done()
-
-			for {
-				photoAsset, err := photoIter.Next()
-				if err != nil {
-					if errors.Is(err, icloudgo.ErrPhotosIterateEnd) {
-						return
-					}
-					if finalErr == nil {
-						finalErr = err
-					}
-					return
-				}
+	remove := option.storage.Remove
+	if option.trash {
+		remove = func(path string) error { return moveToTrash(outputDir, path) }
+	}
 
-				path := photoAsset.LocalPath(outputDir, icloudgo.PhotoVersionOriginal)
+	photoIter := album.PhotosIter()
+	workers := pool.New(option.ctx, &pool.Option{Workers: threadNum})
+	for workers.Err() == nil {
+		photoAsset, err := photoIter.Next()
+		if err != nil {
+			if !errors.Is(err, icloudgo.ErrPhotosIterateEnd) {
+				workers.Stop(err)
+			}
+			break
+		}
 
-				if err := os.Remove(path); err != nil {
+		_ = workers.Submit(func(_ context.Context, threadIndex int) error {
+			for _, path := range deletedPhotoPaths(photoAsset, albums, option) {
+				if err := remove(path); err != nil {
 					if errors.Is(err, os.ErrNotExist) {
 						continue
 					}
-					if finalErr != nil {
-						finalErr = err
+					return err
+				}
+				fmt.Printf("delete %v, %v, %v, thread=%d\n", photoAsset.ID(), path, photoAsset.FormatSize(), threadIndex)
+				if option.livePhotoMov {
+					if err := remove(livePhotoMovPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+						fmt.Printf("delete %s failed, err: %s\n", livePhotoMovPath(path), err)
+					}
+				}
+				if option.videoPoster {
+					if err := remove(videoPosterPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+						fmt.Printf("delete %s failed, err: %s\n", videoPosterPath(path), err)
 					}
-					return
-				} else {
-					fmt.Printf("delete %v, %v, %v, thread=%d\n", photoAsset.ID(), photoAsset.Filename(), photoAsset.FormatSize(), threadIndex)
 				}
 			}
-		}(threadIndex)
+			return nil
+		})
 	}
-	wait.Wait()
+	finalErr := workers.Wait()
 
+	if finalErr == nil && option.trash {
+		return purgeTrash(outputDir, option.trashRetention)
+	}
 	return finalErr
 }
+
+// purgeDeletedPhoto expunges photos of Recently Deleted, which are old enough or already in the output dir.
+func purgeDeletedPhoto(photoCli *icloudgo.PhotoService, option *downloadOption) error {
+	purgeOption := &icloudgo.PurgeOption{
+		OlderThan: time.Duration(option.purgeDays) * 24 * time.Hour,
+		DryRun:    option.purgeDryRun,
+		OnPurge: func(photo *icloudgo.PhotoAsset) {
+			if option.purgeDryRun {
+				fmt.Printf("would purge %v, %v, %v\n", photo.ID(), photo.Filename(), photo.FormatSize())
+				return
+			}
+			fmt.Printf("purge %v, %v, %v\n", photo.ID(), photo.Filename(), photo.FormatSize())
+		},
+	}
+	if option.purgeVerified {
+		// an expunged photo is gone for good, the file must be the one downloaded from it, not any file of its size
+		purgeOption.Verified = func(photo *icloudgo.PhotoAsset) bool {
+			version := option.photoVersion(photo)
+			return option.checksums.Verified(photo, version, option.localPath(photo, nil, option.output, version))
+		}
+	}
+
+	count, err := photoCli.PurgeRecentlyDeleted(purgeOption)
+	if option.purgeDryRun {
+		fmt.Printf("would purge %d photos from recently deleted\n", count)
+		return err
+	}
+	fmt.Printf("purged %d photos from recently deleted\n", count)
+	return err
+}
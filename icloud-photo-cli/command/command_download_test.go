@@ -0,0 +1,100 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// A deleted photo is only purged when its file is the one downloaded from it, a file of the same size isn't enough.
+func TestPurgeDeletedVerified(t *testing.T) {
+	server := newMockServer(t)
+	archived := server.AddAsset(&icloudmock.Asset{Filename: "IMG_0001.JPG", Data: []byte("archived")})
+	replaced := server.AddAsset(&icloudmock.Asset{Filename: "IMG_0002.JPG", Data: []byte("replaced")})
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+
+	if err := runDownload(t, cookieDir, outputDir, "--verify-checksum"); err != nil {
+		t.Fatal(err)
+	}
+	// another photo of the same size at the path
+	if err := os.WriteFile(filepath.Join(outputDir, replaced.Filename), []byte("REPLACED"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	notArchived := server.AddAsset(&icloudmock.Asset{Filename: "IMG_0003.JPG", Data: []byte("not archived")})
+	for _, asset := range []*icloudmock.Asset{archived, replaced, notArchived} {
+		server.UpdateAsset(asset.ID, func(asset *icloudmock.Asset) { asset.Deleted = true })
+	}
+
+	if err := runDownload(t, cookieDir, outputDir, "--purge-deleted-verified", "--dry-run"); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.Requests("modify"); n != 0 {
+		t.Fatalf("%d modify requests with --dry-run, want none", n)
+	}
+
+	if err := runDownload(t, cookieDir, outputDir, "--purge-deleted-verified"); err != nil {
+		t.Fatal(err)
+	}
+	if server.Asset(archived.ID) != nil {
+		t.Errorf("%s not purged", archived.Filename)
+	}
+	for _, asset := range []*icloudmock.Asset{replaced, notArchived} {
+		if server.Asset(asset.ID) == nil {
+			t.Errorf("%s purged, its file is not the photo", asset.Filename)
+		}
+	}
+}
+
+func TestDryRunNeedsPurge(t *testing.T) {
+	newMockServer(t)
+	if err := runDownload(t, t.TempDir(), t.TempDir(), "--dry-run"); err == nil {
+		t.Errorf("download --dry-run without a purge flag succeeded")
+	}
+}
+
+// Auto delete removes the file of a deleted photo where it was downloaded to, by the layout of the run,
+// and keeps another photo's file at the path.
+func TestAutoDeleteLayout(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		path string
+	}{
+		{args: []string{"--album", "Trips", "--file-template", `{{.Album}}/{{.Date.Format "2006"}}/{{.Filename}}`}, path: "Trips/2023/IMG_0001.JPG"},
+		{args: []string{"--album", "Trips", "--photoprism"}, path: "Trips/IMG_0001.JPG"},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			server := newMockServer(t)
+			album := server.AddAlbum("Trips")
+			created := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			deleted := server.AddAsset(&icloudmock.Asset{
+				Filename:  "IMG_0001.JPG",
+				Data:      []byte("deleted"),
+				AssetDate: created,
+				AddedDate: created,
+				Albums:    []string{album.ID},
+			})
+			cookieDir, outputDir := t.TempDir(), t.TempDir()
+			if err := runDownload(t, cookieDir, outputDir, tt.args...); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(outputDir, tt.path)
+			assertFile(t, path, deleted.Data)
+			other := filepath.Join(outputDir, deleted.Filename)
+			if err := os.WriteFile(other, []byte("another photo"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			server.UpdateAsset(deleted.ID, func(asset *icloudmock.Asset) { asset.Deleted = true })
+			if err := runDownload(t, cookieDir, outputDir, append(tt.args, "--auto-delete")...); err != nil {
+				t.Fatal(err)
+			}
+			if f, _ := os.Stat(path); f != nil {
+				t.Errorf("%s not deleted", path)
+			}
+			assertFile(t, other, []byte("another photo"))
+		})
+	}
+}
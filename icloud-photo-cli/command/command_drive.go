@@ -0,0 +1,232 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// NewDriveCommands returns the subcommands of the drive command, which manage iCloud Drive files.
+func NewDriveCommands() []*cli.Command {
+	pathFlag := &cli.StringFlag{
+		Name:     "path",
+		Usage:    "slash separated path in iCloud Drive, like Documents/notes.txt, empty is the root",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_DRIVE_PATH"},
+	}
+	return []*cli.Command{
+		{
+			Name:        "ls",
+			Usage:       "list a folder",
+			Description: "list a folder",
+			Flags: append(append([]cli.Flag{}, commonFlag...), pathFlag, &cli.BoolFlag{
+				Name:     "recursive",
+				Usage:    "list the whole tree below the folder",
+				Required: false,
+				Aliases:  []string{"r"},
+				EnvVars:  []string{"ICLOUD_DRIVE_RECURSIVE"},
+			}),
+			Before: LoadProfile,
+			Action: DriveList,
+		},
+		{
+			Name:        "download",
+			Usage:       "download a file, or a folder with everything in it",
+			Description: "download a file, or a folder with everything in it",
+			Flags: append(append([]cli.Flag{}, commonFlag...), pathFlag, &cli.StringFlag{
+				Name:     "output",
+				Usage:    "output dir",
+				Required: false,
+				Value:    "./iCloudDrive",
+				Aliases:  []string{"o"},
+				EnvVars:  []string{"ICLOUD_OUTPUT"},
+			}),
+			Before: LoadProfile,
+			Action: DriveDownload,
+		},
+		{
+			Name:        "upload",
+			Usage:       "upload a file into a folder",
+			Description: "upload a file into a folder",
+			Flags: append(append([]cli.Flag{}, commonFlag...), pathFlag, &cli.StringFlag{
+				Name:     "file",
+				Usage:    "file path",
+				Required: true,
+				Aliases:  []string{"f"},
+				EnvVars:  []string{"ICLOUD_FILE"},
+			}),
+			Before: LoadProfile,
+			Action: DriveUpload,
+		},
+		{
+			Name:        "mkdir",
+			Usage:       "create the folder at --path, its parent must exist",
+			Description: "create the folder at --path, its parent must exist",
+			Flags:       append(append([]cli.Flag{}, commonFlag...), pathFlag),
+			Before:      LoadProfile,
+			Action:      DriveMkdir,
+		},
+		{
+			Name:        "rm",
+			Usage:       "move the file or folder at --path to Recently Deleted",
+			Description: "move the file or folder at --path to Recently Deleted",
+			Flags:       append(append([]cli.Flag{}, commonFlag...), pathFlag),
+			Before:      LoadProfile,
+			Action:      DriveRemove,
+		},
+	}
+}
+
+func DriveList(c *cli.Context) error {
+	return withDrive(c, func(ctx context.Context, drive *icloudgo.DriveService) error {
+		node, err := drive.GetNodeContext(ctx, c.String("path"))
+		if err != nil {
+			return err
+		}
+		if !c.Bool("recursive") {
+			children, err := node.ChildrenContext(ctx)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				fmt.Println(formatDriveNode(child.Name, child))
+			}
+			return nil
+		}
+		return node.WalkContext(ctx, func(nodePath string, node *icloudgo.DriveNode) error {
+			if nodePath != "" {
+				fmt.Println(formatDriveNode(nodePath, node))
+			}
+			return nil
+		})
+	})
+}
+
+func DriveDownload(c *cli.Context) error {
+	output := c.String("output")
+	return withDrive(c, func(ctx context.Context, drive *icloudgo.DriveService) error {
+		node, err := drive.GetNodeContext(ctx, c.String("path"))
+		if err != nil {
+			return err
+		}
+		return node.WalkContext(ctx, func(nodePath string, node *icloudgo.DriveNode) error {
+			if node.IsDir() {
+				return nil
+			}
+			if nodePath == "" {
+				nodePath = node.Name
+			}
+			target := filepath.Join(output, filepath.FromSlash(nodePath))
+			if f, _ := os.Stat(target); f != nil && f.Size() == node.Size {
+				fmt.Printf("file '%s' exist, skip.\n", target)
+				return nil
+			}
+			fmt.Printf("start %s, %s\n", nodePath, icloudgo.FormatSize(int(node.Size)))
+			return node.DownloadToContext(ctx, target)
+		})
+	})
+}
+
+func DriveUpload(c *cli.Context) error {
+	file := c.String("file")
+	return withDrive(c, func(ctx context.Context, drive *icloudgo.DriveService) error {
+		folder, err := drive.GetNodeContext(ctx, c.String("path"))
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if err := folder.UploadContext(ctx, filepath.Base(file), f, info.Size()); err != nil {
+			return err
+		}
+		fmt.Printf("uploaded %s to /%s\n", file, c.String("path"))
+		return nil
+	})
+}
+
+func DriveMkdir(c *cli.Context) error {
+	drivePath := path.Clean("/" + c.String("path"))
+	if drivePath == "/" {
+		return fmt.Errorf("--path is required")
+	}
+	return withDrive(c, func(ctx context.Context, drive *icloudgo.DriveService) error {
+		parent, err := drive.GetNodeContext(ctx, path.Dir(drivePath))
+		if err != nil {
+			return err
+		}
+		if _, err := parent.MkdirContext(ctx, path.Base(drivePath)); err != nil {
+			return err
+		}
+		fmt.Printf("created %s\n", drivePath)
+		return nil
+	})
+}
+
+func DriveRemove(c *cli.Context) error {
+	drivePath := path.Clean("/" + c.String("path"))
+	if drivePath == "/" {
+		return fmt.Errorf("--path is required, the root can't be removed")
+	}
+	return withDrive(c, func(ctx context.Context, drive *icloudgo.DriveService) error {
+		node, err := drive.GetNodeContext(ctx, drivePath)
+		if err != nil {
+			return err
+		}
+		if err := node.TrashContext(ctx); err != nil {
+			return err
+		}
+		fmt.Printf("moved %s to Recently Deleted\n", drivePath)
+		return nil
+	})
+}
+
+// withDrive logs in, and runs fn with the iCloud Drive of the account.
+func withDrive(c *cli.Context, fn func(ctx context.Context, drive *icloudgo.DriveService) error) error {
+	cli, err := icloudgo.New(newClientOption(c))
+	if err != nil {
+		return err
+	}
+
+	defer cli.Close()
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	if err := authenticate(ctx, c, cli); err != nil {
+		return err
+	}
+	defer startKeepAlive(c, cli)()
+
+	drive, err := cli.DriveCli()
+	if err != nil {
+		return err
+	}
+	return fn(ctx, drive)
+}
+
+func formatDriveNode(name string, node *icloudgo.DriveNode) string {
+	if node.IsDir() {
+		return fmt.Sprintf("%-10s %s  %s/", "-", formatDriveTime(node), name)
+	}
+	return fmt.Sprintf("%-10s %s  %s", icloudgo.FormatSize(int(node.Size)), formatDriveTime(node), name)
+}
+
+func formatDriveTime(node *icloudgo.DriveNode) string {
+	if node.Modified.IsZero() {
+		return "                "
+	}
+	return node.Modified.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,122 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// NewSessionCommands returns the subcommands of the session command, which move a logged in session between machines.
+func NewSessionCommands() []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:        "export",
+			Usage:       "write the session to a file",
+			Description: "log in, and write the session, its cookies and tokens, to a file, for session import on another machine, keep it secret, it's as good as the password and the 2fa code",
+			Flags: append(append([]cli.Flag{}, commonFlag...),
+				&cli.StringFlag{
+					Name:     "output",
+					Usage:    "session file to write, - for stdout",
+					Required: false,
+					Value:    "icloud-session.json",
+					Aliases:  []string{"o"},
+					EnvVars:  []string{"ICLOUD_SESSION_OUTPUT"},
+				},
+			),
+			Before: LoadProfile,
+			Action: SessionExport,
+		},
+		{
+			Name:        "import",
+			Usage:       "use the session of a file",
+			Description: "save the session of a session export file in the cookie dir, and check it's logged in, the next runs use it without the password or a 2fa code",
+			Flags: append(append([]cli.Flag{}, commonFlag...),
+				&cli.StringFlag{
+					Name:     "input",
+					Usage:    "session file to read, - for stdin",
+					Required: false,
+					Value:    "icloud-session.json",
+					Aliases:  []string{"i"},
+					EnvVars:  []string{"ICLOUD_SESSION_INPUT"},
+				},
+				&cli.StringFlag{
+					Name:     "pyicloud-dir",
+					Usage:    "import the session pyicloud or icloudpd saved in the dir for --username instead, like ~/.pyicloud",
+					Required: false,
+					EnvVars:  []string{"ICLOUD_PYICLOUD_DIR"},
+				},
+			),
+			Before: LoadProfile,
+			Action: SessionImport,
+		},
+	}
+}
+
+func SessionExport(c *cli.Context) error {
+	cli, err := icloudgo.New(newClientOption(c))
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	if err := authenticate(ctx, c, cli); err != nil {
+		return err
+	}
+	bs, err := cli.ExportSession()
+	if err != nil {
+		return err
+	}
+
+	output := c.String("output")
+	if output == "-" {
+		_, err := os.Stdout.Write(append(bs, '\n'))
+		return err
+	}
+	if err := os.WriteFile(output, bs, 0o600); err != nil {
+		return err
+	}
+	fmt.Printf("exported the session to %s\n", output)
+	return nil
+}
+
+func SessionImport(c *cli.Context) error {
+	cli, err := icloudgo.New(newClientOption(c))
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	if dir := c.String("pyicloud-dir"); dir != "" {
+		if err := cli.ImportPyiCloudSession(dir); err != nil {
+			return err
+		}
+	} else {
+		var bs []byte
+		if input := c.String("input"); input == "-" {
+			bs, err = io.ReadAll(os.Stdin)
+		} else {
+			bs, err = os.ReadFile(input)
+		}
+		if err != nil {
+			return err
+		}
+		if err := cli.ImportSession(bs); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+	if err := authenticate(ctx, c, cli); err != nil {
+		return err
+	}
+	fmt.Println("imported the session, the runs with the same --cookie-dir use it")
+	return nil
+}
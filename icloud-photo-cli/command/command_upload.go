@@ -1,13 +1,18 @@
 package command
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/urfave/cli/v2"
 
 	"github.com/chyroc/icloudgo"
+	"github.com/chyroc/icloudgo/internal/pool"
 )
 
 func NewUploadFlag() []cli.Flag {
@@ -16,57 +21,152 @@ func NewUploadFlag() []cli.Flag {
 	res = append(res,
 		&cli.StringFlag{
 			Name:     "file",
-			Usage:    "file path",
+			Usage:    "file path, or a dir to upload every file in it",
 			Required: true,
 			Aliases:  []string{"f"},
 			EnvVars:  []string{"ICLOUD_FILE"},
 		},
+		&cli.StringFlag{
+			Name:     "album",
+			Usage:    "file every uploaded photo into the album, it's created if missing",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_ALBUM"},
+		},
+		&cli.BoolFlag{
+			Name:     "map-folders-to-albums",
+			Usage:    "when uploading a dir, file each photo into the album named after its folder, albums are created if missing",
+			Required: false,
+			EnvVars:  []string{"ICLOUD_MAP_FOLDERS_TO_ALBUMS"},
+		},
+		&cli.IntFlag{
+			Name:     "thread-num",
+			Usage:    "thread num, if not set, means 1",
+			Required: false,
+			Aliases:  []string{"t"},
+			Value:    1,
+			EnvVars:  []string{"ICLOUD_THREAD_NUM"},
+		},
 	)
 	return res
 }
 
 func Upload(c *cli.Context) error {
-	username := c.String("username")
-	password := c.String("password")
-	cookieDir := c.String("cookie-dir")
-	domain := c.String("domain")
 	file := c.String("file")
 
-	cli, err := icloudgo.New(&icloudgo.ClientOption{
-		AppID:           username,
-		CookieDir:       cookieDir,
-		PasswordGetter:  getTextInput("apple id password", password),
-		TwoFACodeGetter: getTextInput("2fa code", ""),
-		Domain:          domain,
-	})
+	cli, err := icloudgo.New(newClientOption(c))
 	if err != nil {
 		return err
 	}
 
 	defer cli.Close()
 
-	if err := cli.Authenticate(false, nil); err != nil {
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	if err := authenticate(ctx, c, cli); err != nil {
 		return err
 	}
+	defer startKeepAlive(c, cli)()
 
 	photoCli, err := cli.PhotoCli()
 	if err != nil {
 		return err
 	}
 
-	basename := filepath.Base(file)
-	f, err := os.Open(file)
+	uploader := &folderUploader{ctx: ctx, photoCli: photoCli, album: c.String("album"), mapFoldersToAlbums: c.Bool("map-folders-to-albums")}
+	if f, err := os.Stat(file); err != nil {
+		return err
+	} else if !f.IsDir() {
+		return uploader.upload(file, "")
+	}
+
+	// a failed upload is tried again, iCloud tells a photo already uploaded by its checksum
+	workers := pool.New(ctx, &pool.Option{Workers: c.Int("thread-num"), Retries: 2, RetryDelay: 5 * time.Second})
+	walkErr := filepath.WalkDir(file, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != file && d.Name()[0] == '.' {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name()[0] == '.' {
+			return nil
+		}
+		albumName := ""
+		if dir := filepath.Dir(path); dir != filepath.Clean(file) {
+			albumName = filepath.Base(dir)
+		}
+		return workers.Submit(func(_ context.Context, _ int) error {
+			return uploader.upload(path, albumName)
+		})
+	})
+	if walkErr != nil {
+		workers.Stop(walkErr)
+	}
+	err = workers.Wait()
+	fmt.Printf("upload: %s\n", workers.Metrics())
+	return err
+}
+
+// folderUploader uploads files, and files them into album, or with mapFoldersToAlbums, into the album of their folder.
+type folderUploader struct {
+	ctx                context.Context
+	photoCli           *icloudgo.PhotoService
+	album              string
+	mapFoldersToAlbums bool
+
+	// albumLock keeps concurrent uploads from creating the same album twice
+	albumLock sync.Mutex
+}
+
+func (r *folderUploader) upload(path, folderName string) error {
+	basename := filepath.Base(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	isDuplicate, err := photoCli.Upload(basename, f)
+	res, err := r.photoCli.UploadWithResultContext(r.ctx, basename, f)
 	if err != nil {
 		return err
 	}
-	if isDuplicate {
+	if res.IsDuplicate {
 		fmt.Printf("file %s is duplicate\n", basename)
 	}
+	albumName := r.album
+	if r.mapFoldersToAlbums && folderName != "" {
+		albumName = folderName
+	}
+	if albumName == "" || res.PhotoID == "" {
+		return nil
+	}
+
+	album, err := r.getOrCreateAlbum(albumName)
+	if err != nil {
+		return err
+	}
+	if err := album.AddAssetIDs(res.PhotoID); err != nil {
+		return err
+	}
+	fmt.Printf("file %s added to album %s\n", basename, albumName)
 	return nil
 }
+
+func (r *folderUploader) getOrCreateAlbum(name string) (*icloudgo.PhotoAlbum, error) {
+	r.albumLock.Lock()
+	defer r.albumLock.Unlock()
+
+	albums, err := r.photoCli.AlbumsContext(r.ctx)
+	if err != nil {
+		return nil, err
+	}
+	if album, ok := albums[name]; ok {
+		return album, nil
+	}
+	fmt.Printf("create album %s\n", name)
+	return r.photoCli.CreateAlbumContext(r.ctx, name)
+}
@@ -0,0 +1,141 @@
+package command
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// maxWatchBackoff caps the wait of watch after failed syncs, unless --interval is longer.
+const maxWatchBackoff = time.Hour
+
+func NewWatchFlag() []cli.Flag {
+	return append(NewDownloadFlag(),
+		&cli.DurationFlag{
+			Name:     "interval",
+			Usage:    "time between two polls of the changes, doubled after a failed sync up to 1h, or the Retry-After of a rate limit",
+			Required: false,
+			Value:    5 * time.Minute,
+			EnvVars:  []string{"ICLOUD_WATCH_INTERVAL"},
+			Action: func(context *cli.Context, v time.Duration) error {
+				if v <= 0 {
+					return fmt.Errorf("--interval must be positive")
+				}
+				return nil
+			},
+		},
+	)
+}
+
+// Watch polls the changes of the library every --interval with the Watcher of the library, until it's interrupted,
+// and syncs them with a download --incremental once some are not in the output dir yet.
+// A failed sync is retried later, with the same changes, but a failure which needs the user,
+// like a new 2fa code or a full disk, stops it.
+func Watch(c *cli.Context) error {
+	for _, name := range []string{"album", "snapshot", "estimate", "estimate-only"} {
+		if c.IsSet(name) {
+			return fmt.Errorf("watch can't be used with --%s", name)
+		}
+	}
+	if err := c.Set("incremental", "true"); err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	// the syncs run with the lock of watch, no other run writes the output dir or its state between them
+	output := c.String("output")
+	unlock, err := acquireLock(ctx, output, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	c.Context = withHeldLock(c.Context, output)
+
+	cli, err := icloudgo.New(newClientOption(c))
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+	if err := authenticate(ctx, c, cli); err != nil {
+		return err
+	}
+	photoCli, err := getPhotoCli(cli, c.String("zone"))
+	if err != nil {
+		return err
+	}
+
+	token, err := watchSyncToken(output)
+	if err != nil {
+		return err
+	}
+	interval := c.Duration("interval")
+	maxWait := maxWatchBackoff
+	if maxWait < interval {
+		maxWait = interval
+	}
+	watcher := photoCli.NewWatcher(&icloudgo.WatchOption{
+		SyncToken:   token,
+		Interval:    interval,
+		MaxInterval: maxWait,
+		Retry: func(err error) bool {
+			switch ExitCode(err) {
+			case ExitAuthFailed, ExitTwoFARequired, ExitDiskFull:
+				return false
+			}
+			fmt.Printf("sync failed, retry later: %s\n", err)
+			return true
+		},
+	})
+
+	// the session is renewed while watch waits, the syncs renew it while they run
+	stopKeepAlive := startKeepAlive(c, cli)
+	err = watcher.Watch(ctx, func(changes *icloudgo.SyncChanges) error {
+		pending, err := watchPending(output, changes)
+		if err != nil || !pending {
+			return err
+		}
+		// the sync loads the renewed cookies, the two clients never write the cookie dir at once
+		stopKeepAlive()
+		defer func() { stopKeepAlive = startKeepAlive(c, cli) }()
+		return Download(c)
+	})
+	stopKeepAlive()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// watchSyncToken returns the sync token of the last download --incremental to output, the watch starts from there.
+func watchSyncToken(output string) (string, error) {
+	db, err := openStateDB(output)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	state, err := loadSyncState(true, db)
+	if err != nil {
+		return "", err
+	}
+	return state.Token(), nil
+}
+
+// watchPending reports whether the changes have something the output dir doesn't have yet,
+// the first poll of a watch without a sync token hands the whole library, mostly downloaded already.
+func watchPending(output string, changes *icloudgo.SyncChanges) (bool, error) {
+	db, err := openStateDB(output)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+	state, err := loadSyncState(true, db)
+	if err != nil {
+		return false, err
+	}
+	return state.Pending(changes)
+}
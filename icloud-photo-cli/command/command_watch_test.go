@@ -0,0 +1,64 @@
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// watch downloads the library, then the photos added since, polling the changes,
+// and only runs a sync when the changes have a photo not downloaded yet.
+func TestWatch(t *testing.T) {
+	server := newMockServer(t)
+	first := server.AddAsset(&icloudmock.Asset{Filename: "first.jpg", Data: []byte("first")})
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- runCommandContext(ctx, t, cookieDir, "watch", NewWatchFlag(), Watch, "--output", outputDir, "--interval", "20ms")
+	}()
+
+	waitFile(t, filepath.Join(outputDir, first.Filename))
+	// another run can't write the output dir while watch waits
+	if err := runDownload(t, t.TempDir(), outputDir); err == nil || !strings.Contains(err.Error(), "locked by another run") {
+		t.Errorf("download while watching = %v, want the error of the lock", err)
+	}
+	// a sync signs in, the polls without changes don't sync
+	syncs := server.Requests("validate")
+	time.Sleep(100 * time.Millisecond)
+	if n := server.Requests("validate") - syncs; n != 0 {
+		t.Errorf("%d syncs without changes", n)
+	}
+
+	second := server.AddAsset(&icloudmock.Asset{Filename: "second.jpg", Data: []byte("second")})
+	waitFile(t, filepath.Join(outputDir, second.Filename))
+	if server.Requests("validate") == syncs {
+		t.Errorf("no sync of the changes")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("watch = %s, want nil once interrupted", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("watch not stopped")
+	}
+}
+
+func waitFile(t *testing.T, path string) {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+	}
+	t.Fatalf("%s not downloaded", path)
+}
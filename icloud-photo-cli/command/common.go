@@ -4,13 +4,27 @@ import (
 	"fmt"
 
 	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
 )
 
 var commonFlag = []cli.Flag{
+	&cli.StringFlag{
+		Name:     "config",
+		Usage:    "config file path, default is $XDG_CONFIG_HOME/icloudgo/config.json",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_CONFIG"},
+	},
+	&cli.StringFlag{
+		Name:     "profile",
+		Usage:    "profile name in the config file",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_PROFILE"},
+	},
 	&cli.StringFlag{
 		Name:     "username",
 		Usage:    "apple id username",
-		Required: true,
+		Required: false,
 		Aliases:  []string{"u"},
 		EnvVars:  []string{"ICLOUD_USERNAME"},
 	},
@@ -21,6 +35,54 @@ var commonFlag = []cli.Flag{
 		Aliases:  []string{"p"},
 		EnvVars:  []string{"ICLOUD_PASSWORD"},
 	},
+	&cli.StringFlag{
+		Name:     "password-file",
+		Usage:    "read the apple id password from the file, like a docker secret",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_PASSWORD_FILE"},
+	},
+	&cli.StringFlag{
+		Name:     "2fa-code",
+		Usage:    "2fa code, for logins without a terminal",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_2FA_CODE"},
+	},
+	&cli.StringFlag{
+		Name:     "2fa-code-file",
+		Usage:    "read the 2fa code from the file",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_2FA_CODE_FILE"},
+	},
+	&cli.StringFlag{
+		Name:     "2fa-phone",
+		Usage:    "send the 2fa code by SMS to the trusted phone number with the id or the last digits, instead of the trusted Apple devices, ask: pick one on stdin",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_2FA_PHONE"},
+	},
+	&cli.BoolFlag{
+		Name:     "non-interactive",
+		Usage:    "never prompt on stdin, fail fast when the password or 2fa code is needed but not provided",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_NON_INTERACTIVE"},
+	},
+	&cli.BoolFlag{
+		Name:     "accept-terms",
+		Usage:    "accept the iCloud terms and the consent steps a sign-in asks for, like for a managed or new Apple ID, without prompting",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_ACCEPT_TERMS"},
+	},
+	&cli.BoolFlag{
+		Name:     "browser-auth",
+		Usage:    "log in to icloud.com in the system browser, and paste its Cookie header into a local page, instead of the password",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_BROWSER_AUTH"},
+	},
+	&cli.IntFlag{
+		Name:     "keep-alive",
+		Usage:    "validate the session every `N` minutes while running, to renew the session cookies before they expire, 0 is disabled",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_KEEP_ALIVE"},
+	},
 	&cli.StringFlag{
 		Name:     "cookie-dir",
 		Usage:    "cookie dir",
@@ -28,6 +90,42 @@ var commonFlag = []cli.Flag{
 		Aliases:  []string{"c"},
 		EnvVars:  []string{"ICLOUD_COOKIE_DIR"},
 	},
+	&cli.Float64Flag{
+		Name:     "max-rps",
+		Usage:    "send at most `N` requests a second to iCloud, the downloads included, shared by all the threads, 0 is unlimited",
+		Required: false,
+		EnvVars:  []string{"ICLOUD_MAX_RPS"},
+		Action: func(context *cli.Context, f float64) error {
+			if f < 0 {
+				return fmt.Errorf("--max-rps must not be negative")
+			}
+			return nil
+		},
+	},
+	&cli.StringFlag{
+		Name:     "log-level",
+		Usage:    "log level of the library, like the login steps and the retries, debug, info, warn or error",
+		Required: false,
+		Value:    "info",
+		EnvVars:  []string{"ICLOUD_LOG_LEVEL"},
+		Action: func(context *cli.Context, s string) error {
+			_, err := icloudgo.ParseLogLevel(s)
+			return err
+		},
+	},
+	&cli.StringFlag{
+		Name:     "log-format",
+		Usage:    "log format of the library, text: lines on stdout, json: a JSON object per line on stderr",
+		Required: false,
+		Value:    "text",
+		EnvVars:  []string{"ICLOUD_LOG_FORMAT"},
+		Action: func(context *cli.Context, s string) error {
+			if s != "text" && s != "json" {
+				return fmt.Errorf("log format must be text or json")
+			}
+			return nil
+		},
+	},
 	&cli.StringFlag{
 		Name:        "domain",
 		Usage:       "icloud domain(com,cn)",
@@ -0,0 +1,82 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+)
+
+// config is the cli config file, like:
+//
+//	{
+//	  "profiles": {
+//	    "alice": {"username": "alice@icloud.com", "output": "/photos/alice", "album": "favorites"},
+//	    "bob":   {"username": "bob@icloud.com", "output": "/photos/bob", "thread-num": 4}
+//	  }
+//	}
+//
+// each profile maps flag names to values, flags given on the command line take precedence.
+type config struct {
+	Profiles map[string]map[string]any `json:"profiles"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "icloudgo", "config.json")
+}
+
+func loadConfig(path string) (*config, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config %s failed: %w", path, err)
+	}
+	conf := new(config)
+	if err := json.Unmarshal(bs, conf); err != nil {
+		return nil, fmt.Errorf("parse config %s failed: %w", path, err)
+	}
+	return conf, nil
+}
+
+// LoadProfile applies the selected --profile of the config file to the flags not set on the command line.
+func LoadProfile(c *cli.Context) error {
+	if profileName := c.String("profile"); profileName != "" {
+		configPath := c.String("config")
+		if configPath == "" {
+			configPath = defaultConfigPath()
+		}
+		conf, err := loadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		profile, ok := conf.Profiles[profileName]
+		if !ok {
+			return fmt.Errorf("profile %s not found in %s", profileName, configPath)
+		}
+		for name, value := range profile {
+			if c.IsSet(name) {
+				continue
+			}
+			values, ok := value.([]any)
+			if !ok {
+				values = []any{value}
+			}
+			for _, v := range values {
+				if err := c.Set(name, fmt.Sprint(v)); err != nil {
+					return fmt.Errorf("profile %s: set %s failed: %w", profileName, name, err)
+				}
+			}
+		}
+	}
+
+	// --offline commands work from the output dir alone, without logging in
+	if c.String("username") == "" && !c.Bool("offline") {
+		return fmt.Errorf("username is required, set --username or use a --profile")
+	}
+	return nil
+}
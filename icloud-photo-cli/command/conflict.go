@@ -0,0 +1,63 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictRename    = "rename"
+	conflictNewer     = "newer"
+)
+
+func isValidConflictPolicy(s string) bool {
+	switch s {
+	case conflictSkip, conflictOverwrite, conflictRename, conflictNewer:
+		return true
+	}
+	return false
+}
+
+// resolveConflict decides where the photo should be downloaded to, when a file already exists at path.
+//
+// A file with the same size as the photo is always treated as downloaded,
+// with --verify-checksum, only when the checksum index tells it's the same photo.
+// With newer, the file is replaced when the photo was modified on iCloud since the file was downloaded from it,
+// by the modification date of the record the index keeps, not the file's, which is the creation date of the photo,
+// a file the index doesn't know is kept.
+func resolveConflict(storage icloudgo.Storage, photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, path, onConflict string, checksums *checksumIndex) (string, bool) {
+	f, _ := storage.Stat(path)
+	if f == nil {
+		return path, false
+	}
+	if checksums.Downloaded(photo, version, path, f) {
+		return path, true
+	}
+
+	switch onConflict {
+	case conflictSkip:
+		return path, true
+	case conflictRename:
+		ext := filepath.Ext(path)
+		base := path[:len(path)-len(ext)]
+		for i := 1; ; i++ {
+			candidate := fmt.Sprintf("%s_%d%s", base, i, ext)
+			f, _ := storage.Stat(candidate)
+			if f == nil {
+				return candidate, false
+			}
+			if checksums.Downloaded(photo, version, candidate, f) {
+				return candidate, true
+			}
+		}
+	case conflictNewer:
+		modified, ok := checksums.PhotoModified(path)
+		return path, !ok || !photo.Modified().After(modified)
+	default:
+		return path, false
+	}
+}
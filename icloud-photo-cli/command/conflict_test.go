@@ -0,0 +1,119 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// A photo edited on iCloud after it was created has a modification date after the one of its file,
+// which is the creation date, so newer compares with the date of the photo the file was downloaded from.
+func TestConflictNewer(t *testing.T) {
+	server := newMockServer(t)
+	created := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	asset := server.AddAsset(&icloudmock.Asset{
+		Filename:  "IMG_0001.JPG",
+		Data:      []byte("edited on iCloud before the download"),
+		AssetDate: created,
+		AddedDate: created,
+		Modified:  created.AddDate(1, 0, 0),
+	})
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+
+	if err := runDownload(t, cookieDir, outputDir, "--on-conflict", "newer"); err != nil {
+		t.Fatal(err)
+	}
+	path := downloadedFile(t, outputDir)
+	assertFile(t, path, asset.Data)
+
+	// an edit which keeps the modification time, like exiftool -P
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := []byte("edited locally")
+	if err := os.WriteFile(path, local, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDownload(t, cookieDir, outputDir, "--on-conflict", "newer"); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, path, local)
+
+	// an edit on iCloud since the download replaces the file
+	edited := []byte("edited on iCloud after the download")
+	server.UpdateAsset(asset.ID, func(asset *icloudmock.Asset) { asset.Data = edited })
+	if err := runDownload(t, cookieDir, outputDir, "--on-conflict", "newer"); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, path, edited)
+}
+
+// downloadedFile returns the only photo of the output dir.
+func downloadedFile(t *testing.T, outputDir string) string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != outputDir && info.Name()[0] == '.' {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name()[0] != '.' {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("downloaded %v, want one photo", files)
+	}
+	return files[0]
+}
+
+func assertFile(t *testing.T, path string, want []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is %q, want %q", path, got, want)
+	}
+}
+
+// A file of the same size as the photo which the index didn't download is not taken as the photo,
+// nor recorded as it, so the verified purge doesn't trust it either.
+func TestVerifyChecksumUnindexedFile(t *testing.T) {
+	server := newMockServer(t)
+	asset := server.AddAsset(&icloudmock.Asset{Filename: "IMG_0001.JPG", Data: []byte("photo")})
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+
+	// the path the photo is downloaded to, found by a first run without the index
+	if err := runDownload(t, cookieDir, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	path := downloadedFile(t, outputDir)
+	if err := os.WriteFile(path, []byte("other"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runDownload(t, cookieDir, outputDir, "--verify-checksum", "--on-conflict", "skip"); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, path, []byte("other"))
+	if err := runDownload(t, cookieDir, outputDir, "--verify-checksum"); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, path, asset.Data)
+}
@@ -0,0 +1,103 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const defaultRatingMap = "favorite=5"
+
+// curationMap maps the iCloud curation of a photo to the xmp:Rating and dc:subject of its sidecars,
+// so imports into Lightroom or digiKam keep it.
+//
+// --rating-map is a list of `flag=rating`, the first matching flag wins, like `favorite=5,edited=3,default=0`,
+// the flags are those of the --filter type field, and favorite, hidden, edited.
+// --keyword-map renames albums when used as keywords, like `Favorites=Best,Recents=`, an empty name drops the album.
+type curationMap struct {
+	ratings       []*ratingRule
+	defaultRating int
+	keywords      map[string]string
+}
+
+type ratingRule struct {
+	is     func(photo *icloudgo.PhotoAsset) bool
+	rating int
+}
+
+var curationFlags = map[string]func(photo *icloudgo.PhotoAsset) bool{
+	"favorite": (*icloudgo.PhotoAsset).IsFavorite,
+	"hidden":   (*icloudgo.PhotoAsset).IsHidden,
+	"edited":   (*icloudgo.PhotoAsset).IsEdited,
+}
+
+func parseCurationMap(ratingMap, keywordMap string) (*curationMap, error) {
+	r := &curationMap{keywords: map[string]string{}}
+	for _, item := range splitMapItems(ratingMap) {
+		flag, value, _ := strings.Cut(item, "=")
+		rating, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || rating < -1 || rating > 5 {
+			return nil, fmt.Errorf("invalid rating map item %q, the rating must be -1 to 5", item)
+		}
+		flag = strings.ToLower(strings.TrimSpace(flag))
+		if flag == "default" {
+			r.defaultRating = rating
+			continue
+		}
+		is, ok := curationFlags[flag]
+		if !ok {
+			is, ok = filterAssetTypes[flag]
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid rating map item %q, unknown flag %s", item, flag)
+		}
+		r.ratings = append(r.ratings, &ratingRule{is: is, rating: rating})
+	}
+	for _, item := range splitMapItems(keywordMap) {
+		album, keyword, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid keyword map item %q, want `album=keyword`", item)
+		}
+		r.keywords[strings.TrimSpace(album)] = strings.TrimSpace(keyword)
+	}
+	return r, nil
+}
+
+func splitMapItems(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// Rating returns the xmp:Rating of the photo, 0 is unrated and -1 is rejected.
+func (r *curationMap) Rating(photo *icloudgo.PhotoAsset) int {
+	for _, rule := range r.ratings {
+		if rule.is(photo) {
+			return rule.rating
+		}
+	}
+	return r.defaultRating
+}
+
+// Keywords returns the dc:subject keywords of a photo in albums.
+func (r *curationMap) Keywords(albums []string) []string {
+	keywords := []string{}
+	for _, album := range albums {
+		keyword, ok := r.keywords[album]
+		if !ok {
+			keyword = album
+		}
+		if keyword != "" && !containsString(keywords, keyword) {
+			keywords = append(keywords, keyword)
+		}
+	}
+	sort.Strings(keywords)
+	return keywords
+}
@@ -0,0 +1,60 @@
+package command
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// albumDrift compares a full pass over an album with the album size iCloud reports,
+// a gap means the iteration was silently truncated, or photos are missing locally.
+type albumDrift struct {
+	Album    string `json:"album"`
+	Expected int    `json:"expected"`
+	Iterated int    `json:"iterated"`
+	Local    int    `json:"local"`
+}
+
+func (r *albumDrift) String() string {
+	res := fmt.Sprintf("drift: album %s, icloud has %d photos, iterated %d, local %d", r.Album, r.Expected, r.Iterated, r.Local)
+	if missing := r.Expected - r.Iterated; missing > 0 {
+		res += fmt.Sprintf(", %d missing from the iteration", missing)
+	} else if missing < 0 {
+		res += fmt.Sprintf(", %d extra in the iteration", -missing)
+	}
+	if missing := r.Iterated - r.Local; missing > 0 {
+		res += fmt.Sprintf(", %d not saved locally", missing)
+	}
+	return res
+}
+
+// checkDrift returns the drift of each album fully iterated in this run.
+//
+// Albums stopped early by --recent or --stop-found-num are skipped,
+// and so is the whole run when filters, --incremental or --since-last-sync make the counts incomparable.
+func checkDrift(jobs []*albumJob, option *downloadOption) []*albumDrift {
+	iterOption := option.iterOption
+	if option.syncState != nil || option.sinceLastSync || !iterOption.Since.IsZero() || !iterOption.Until.IsZero() || iterOption.Filter != nil || iterOption.IncludeHidden || iterOption.IncludeRecentlyDeleted {
+		return nil
+	}
+
+	var res []*albumDrift
+	for _, job := range jobs {
+		if atomic.LoadInt32(&job.done) != 1 {
+			continue
+		}
+		expected, err := job.album.GetSize()
+		if err != nil {
+			continue
+		}
+		drift := &albumDrift{
+			Album:    job.album.Name,
+			Expected: expected,
+			Iterated: int(atomic.LoadInt64(&job.iterated)),
+			Local:    int(atomic.LoadInt32(&job.downloaded)) + int(atomic.LoadInt64(&job.found)),
+		}
+		if drift.Expected != drift.Iterated || drift.Iterated != drift.Local {
+			res = append(res, drift)
+		}
+	}
+	return res
+}
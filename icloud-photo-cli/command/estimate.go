@@ -0,0 +1,59 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// estimateThroughput is the assumed download speed, in bytes per second, used to estimate the run time.
+const estimateThroughput = 10 << 20
+
+type downloadEstimate struct {
+	count         int
+	size          int
+	missingCount  int
+	missingSize   int
+	estimatedTime time.Duration
+}
+
+// estimateAlbums sums the size of the assets the run would select,
+// and the part of them not already in the output dir.
+func estimateAlbums(photoCli icloudgo.AlbumLister, option *downloadOption) (*downloadEstimate, error) {
+	res := new(downloadEstimate)
+	for _, albumName := range option.albumNames() {
+		album, err := photoCli.GetAlbum(albumName)
+		if err != nil {
+			return nil, err
+		}
+
+		iter := option.grouping.Iter(album.PhotosIterWithOption(option.iterOption))
+		for option.recent == 0 || res.count < option.recent {
+			photo, err := iter.Next()
+			if err != nil {
+				if errors.Is(err, icloudgo.ErrPhotosIterateEnd) {
+					break
+				}
+				return nil, err
+			}
+
+			res.count++
+			version := option.photoVersion(photo)
+			res.size += versionSize(photo, version)
+			if _, err := os.Stat(option.localPath(photo, album, option.output, version)); err != nil {
+				res.missingCount++
+				res.missingSize += versionSize(photo, version)
+			}
+		}
+	}
+	res.estimatedTime = time.Duration(float64(res.missingSize) / estimateThroughput * float64(time.Second)).Round(time.Second)
+	return res, nil
+}
+
+func (r *downloadEstimate) String() string {
+	return fmt.Sprintf("estimate: %d photos, %s, to download: %d photos, %s, about %s at %s/s",
+		r.count, icloudgo.FormatSize(r.size), r.missingCount, icloudgo.FormatSize(r.missingSize), r.estimatedTime, icloudgo.FormatSize(estimateThroughput))
+}
@@ -0,0 +1,44 @@
+package command
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// runExecHook runs --exec for the photo downloaded to path, a failed hook is reported,
+// but doesn't fail the photo, it's downloaded already, and wouldn't be downloaded again to retry the hook.
+func runExecHook(option *downloadOption, photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, path string) {
+	if option.execHook == "" {
+		return
+	}
+	albumName := ""
+	if album != nil && album.ID() != icloudgo.AlbumIDAll {
+		albumName = album.Name
+	}
+
+	cmd := shellCommand(option.ctx, option.execHook)
+	cmd.Env = append(os.Environ(), "ICLOUD_FILE="+path, "ICLOUD_PHOTO_ID="+photo.ID(), "ICLOUD_ALBUM="+albumName)
+	out, err := cmd.CombinedOutput()
+	if len(out) > 0 {
+		option.bar.Printf("%s", out)
+	}
+	if err != nil {
+		option.bar.Printf("exec for '%s' failed: %s\n", path, err)
+	}
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	default:
+		return exec.CommandContext(ctx, "sh", "-c", command)
+	}
+}
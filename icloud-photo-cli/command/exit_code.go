@@ -0,0 +1,79 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// The exit codes of the cli, so wrapper scripts and systemd units can tell the failures apart,
+// like retrying later on a rate limit, but paging someone when the session needs a new 2fa code.
+const (
+	ExitOK             = 0
+	ExitError          = 1
+	ExitAuthFailed     = 2
+	ExitTwoFARequired  = 3
+	ExitPartialFailure = 4
+	ExitRateLimited    = 5
+	ExitDiskFull       = 6
+)
+
+var exitCodeUsages = []struct {
+	code  int
+	usage string
+}{
+	{ExitOK, "success"},
+	{ExitError, "any other error"},
+	{ExitAuthFailed, "login failed, like a wrong password, no password to use, terms not accepted, or iCloud web access disabled"},
+	{ExitTwoFARequired, "the session needs a 2fa code, two-step authentication, or security keys, and there is no terminal to ask for it"},
+	{ExitPartialFailure, "the run finished, or was aborted by --max-failures or --max-failure-rate, with some photos failed"},
+	{ExitRateLimited, "iCloud kept rate limiting the requests, try again later"},
+	{ExitDiskFull, "the output dir is full, or below --min-free-space"},
+}
+
+// ExitCodeUsage returns the exit codes and their meaning, for the help output.
+func ExitCodeUsage() string {
+	lines := []string{"EXIT CODES:"}
+	for _, v := range exitCodeUsages {
+		lines = append(lines, fmt.Sprintf("   %d  %s", v.code, v.usage))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ExitCode returns the exit code of the error a command returned,
+// the more actionable failure wins when the error chain has several, like a rate limit during login.
+func ExitCode(err error) int {
+	var authErr *authError
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, errFreeSpaceLow), errors.Is(err, syscall.ENOSPC):
+		return ExitDiskFull
+	case errors.Is(err, icloudgo.ErrRateLimited):
+		return ExitRateLimited
+	case errors.Is(err, icloudgo.ErrTwoFACodeRequired), errors.Is(err, icloudgo.ErrTwoStepRequired), errors.Is(err, icloudgo.ErrSecurityKeyRequired):
+		return ExitTwoFARequired
+	case errors.As(err, &authErr), errors.Is(err, icloudgo.ErrWebAccessDisabled):
+		return ExitAuthFailed
+	case errors.Is(err, errPartialFailure):
+		return ExitPartialFailure
+	default:
+		return ExitError
+	}
+}
+
+// authError is a failed login, whatever the reason is.
+type authError struct {
+	err error
+}
+
+func (r *authError) Error() string {
+	return r.err.Error()
+}
+
+func (r *authError) Unwrap() error {
+	return r.err
+}
@@ -0,0 +1,108 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// errPartialFailure is returned by a run which finished with some photos failed, within the failure budget.
+var errPartialFailure = errors.New("partial failure")
+
+// failureBudgetMinSamples is how many photos are tried before --max-failure-rate is checked,
+// so the first failed photo is not a 100% failure rate.
+const failureBudgetMinSamples = 20
+
+// failureBudget lets a run go on when some photos fail, and aborts it when something systemic is wrong,
+// like expired auth or throttling. A nil budget aborts on the first failure.
+type failureBudget struct {
+	lock        sync.Mutex
+	maxFailures int
+	maxRate     float64
+	tried       int
+	failed      int
+}
+
+// newFailureBudget parses --max-failures and --max-failure-rate, like `5%` or `0.05`,
+// it returns nil when both are unset.
+func newFailureBudget(maxFailures int, maxRate string) (*failureBudget, error) {
+	rate := 0.0
+	if maxRate != "" {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(maxRate, "%"), 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid max failure rate: %s", maxRate)
+		}
+		if strings.HasSuffix(maxRate, "%") {
+			v /= 100
+		}
+		if v > 1 {
+			return nil, fmt.Errorf("invalid max failure rate: %s, must be at most 100%%", maxRate)
+		}
+		rate = v
+	}
+	if maxFailures <= 0 && rate == 0 {
+		return nil, nil
+	}
+	return &failureBudget{maxFailures: maxFailures, maxRate: rate}, nil
+}
+
+// Record counts the result of a photo, and returns an error when the run should abort.
+func (r *failureBudget) Record(err error) error {
+	if r == nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.tried++
+	if err == nil {
+		return nil
+	}
+	r.failed++
+	fmt.Printf("download failed (%d of %d photos), continue: %s\n", r.failed, r.tried, err)
+
+	if r.maxFailures > 0 && r.failed >= r.maxFailures {
+		return &budgetError{fmt.Errorf("abort: %d photos failed, reached --max-failures, last err: %w", r.failed, err)}
+	}
+	if r.maxRate > 0 && r.tried >= failureBudgetMinSamples && float64(r.failed)/float64(r.tried) > r.maxRate {
+		return &budgetError{fmt.Errorf("abort: %d of %d photos failed, over --max-failure-rate %.2f%%, last err: %w", r.failed, r.tried, r.maxRate*100, err)}
+	}
+	return nil
+}
+
+// Failed returns how many photos failed, the albums are not marked synced when some photos failed.
+func (r *failureBudget) Failed() int {
+	if r == nil {
+		return 0
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.failed
+}
+
+// Err returns an error matching errPartialFailure when some photos failed, nil when none did.
+func (r *failureBudget) Err() error {
+	if failed := r.Failed(); failed > 0 {
+		return fmt.Errorf("%w: %d photos failed", errPartialFailure, failed)
+	}
+	return nil
+}
+
+// budgetError is the abort of a run over its failure budget, it matches errPartialFailure,
+// and unwraps to the error of the last failed photo.
+type budgetError struct {
+	err error
+}
+
+func (r *budgetError) Error() string {
+	return r.err.Error()
+}
+
+func (r *budgetError) Unwrap() error {
+	return r.err
+}
+
+func (r *budgetError) Is(target error) bool {
+	return target == errPartialFailure
+}
@@ -0,0 +1,119 @@
+package command
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewFailureBudget(t *testing.T) {
+	for _, tc := range []struct {
+		maxFailures int
+		maxRate     string
+		wantRate    float64
+		wantNil     bool
+		wantErr     bool
+	}{
+		{maxFailures: 0, maxRate: "", wantNil: true},
+		{maxFailures: 3, maxRate: "", wantRate: 0},
+		{maxFailures: 0, maxRate: "5%", wantRate: 0.05},
+		{maxFailures: 0, maxRate: "0.05", wantRate: 0.05},
+		{maxFailures: 0, maxRate: "150%", wantErr: true},
+		{maxFailures: 0, maxRate: "-1", wantErr: true},
+		{maxFailures: 0, maxRate: "abc", wantErr: true},
+	} {
+		budget, err := newFailureBudget(tc.maxFailures, tc.maxRate)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("newFailureBudget(%d, %q) should fail", tc.maxFailures, tc.maxRate)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("newFailureBudget(%d, %q) failed: %s", tc.maxFailures, tc.maxRate, err)
+			continue
+		}
+		if tc.wantNil {
+			if budget != nil {
+				t.Errorf("newFailureBudget(%d, %q) should be nil", tc.maxFailures, tc.maxRate)
+			}
+			continue
+		}
+		if budget.maxRate != tc.wantRate {
+			t.Errorf("newFailureBudget(%d, %q) rate = %v, want %v", tc.maxFailures, tc.maxRate, budget.maxRate, tc.wantRate)
+		}
+	}
+}
+
+func TestFailureBudgetRate(t *testing.T) {
+	budget, err := newFailureBudget(0, "5%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := errors.New("download failed")
+
+	// one failure in the first photos is under the min samples
+	if err := budget.Record(failure); err != nil {
+		t.Fatalf("first failure should not abort: %s", err)
+	}
+	for i := 0; i < 19; i++ {
+		if err := budget.Record(nil); err != nil {
+			t.Fatalf("success should not abort: %s", err)
+		}
+	}
+	// 2 of 21 is over 5%
+	err = budget.Record(failure)
+	if err == nil {
+		t.Fatal("2 of 21 failed should abort")
+	}
+	if !errors.Is(err, errPartialFailure) || !errors.Is(err, failure) {
+		t.Errorf("abort should match errPartialFailure and the last failure: %s", err)
+	}
+	if budget.Failed() != 2 || budget.tried != 21 {
+		t.Errorf("failed %d of %d, want 2 of 21", budget.Failed(), budget.tried)
+	}
+}
+
+func TestFailureBudgetRateWithinBudget(t *testing.T) {
+	budget, _ := newFailureBudget(0, "10%")
+	failure := errors.New("download failed")
+	for i := 0; i < 100; i++ {
+		var err error
+		if i%20 == 0 {
+			err = failure
+		}
+		if err := budget.Record(err); err != nil {
+			t.Fatalf("5%% failures should not abort a 10%% budget: %s", err)
+		}
+	}
+	if !errors.Is(budget.Err(), errPartialFailure) {
+		t.Errorf("Err() should match errPartialFailure: %v", budget.Err())
+	}
+}
+
+func TestFailureBudgetMaxFailures(t *testing.T) {
+	budget, _ := newFailureBudget(2, "")
+	failure := errors.New("download failed")
+	if err := budget.Record(failure); err != nil {
+		t.Fatalf("first failure should not abort: %s", err)
+	}
+	if err := budget.Record(nil); err != nil {
+		t.Fatalf("success should not abort: %s", err)
+	}
+	if err := budget.Record(failure); err == nil {
+		t.Fatal("second failure should abort")
+	}
+}
+
+func TestFailureBudgetNil(t *testing.T) {
+	var budget *failureBudget
+	failure := errors.New("download failed")
+	if err := budget.Record(nil); err != nil {
+		t.Errorf("nil budget success: %s", err)
+	}
+	if err := budget.Record(failure); err != failure {
+		t.Errorf("nil budget should abort on the first failure, got %v", err)
+	}
+	if budget.Err() != nil {
+		t.Errorf("nil budget Err() = %v", budget.Err())
+	}
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// fileTemplate lays out the photos with --file-template, the template can give distinct photos the same path,
+// like a template without the filename, the photos listed after the first one get a _<n> suffix instead.
+type fileTemplate struct {
+	tmpl *icloudgo.FilenameTemplate
+
+	lock   sync.Mutex
+	claims map[string]string // relative path -> photo id
+	paths  map[string]string // photo id + album -> relative path
+}
+
+func newFileTemplate(text string) (*fileTemplate, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := icloudgo.ParseFilenameTemplate(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --file-template: %w", err)
+	}
+	return &fileTemplate{tmpl: tmpl, claims: map[string]string{}, paths: map[string]string{}}, nil
+}
+
+// Path returns the path of the version of the photo of album in outputDir, album is nil when it's unknown.
+func (r *fileTemplate) Path(photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, outputDir string, version icloudgo.PhotoVersion) (string, error) {
+	albumName := ""
+	if album != nil && album.ID() != icloudgo.AlbumIDAll {
+		albumName = album.Name
+	}
+	rel, err := r.claim(photo, albumName)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.FromSlash(rel)
+	return photo.LocalPathWithFilename(filepath.Join(outputDir, filepath.Dir(rel)), version, filepath.Base(rel)), nil
+}
+
+func (r *fileTemplate) claim(photo *icloudgo.PhotoAsset, albumName string) (string, error) {
+	key := photo.ID() + "/" + albumName
+	r.lock.Lock()
+	rel, ok := r.paths[key]
+	r.lock.Unlock()
+	if ok {
+		return rel, nil
+	}
+
+	rel, err := r.tmpl.Path(photo, albumName)
+	if err != nil {
+		return "", err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ext := path.Ext(rel)
+	base := rel[:len(rel)-len(ext)]
+	for i := 1; ; i++ {
+		if id, ok := r.claims[rel]; !ok || id == photo.ID() {
+			break
+		}
+		rel = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+	r.claims[rel] = photo.ID()
+	r.paths[key] = rel
+	return rel, nil
+}
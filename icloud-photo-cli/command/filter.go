@@ -0,0 +1,230 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// assetFilter is a parsed --filter expression, like `type==video && size>500MB && date>=2023-01-01`.
+//
+// Clauses are joined by &&, each is `field op value`, with the fields:
+//
+//	type     photo, video, live, screenshot, screenrecording, panorama, slomo, burst (==, !=)
+//	size     like 500MB (==, !=, >, >=, <, <=)
+//	date     when the photo was taken, like 2023-01-01 or 2023-01-01T08:00:00Z (==, !=, >, >=, <, <=)
+//	added    when the photo was added to the library, same as date
+//	favorite true or false (==, !=)
+//	hidden   true or false (==, !=)
+//	name     filename glob, like IMG_*.HEIC, case insensitive (==, !=)
+//	ext      file extension, like heic, case insensitive (==, !=)
+//	source   the app which saved the photo, a glob of its bundle id or name, like net.whatsapp.*, case insensitive (==, !=)
+//
+// A lower bound of date is sent to the server, the rest is checked locally.
+type assetFilter struct {
+	since      time.Time
+	predicates []func(photo *icloudgo.PhotoAsset) bool
+}
+
+var filterClauseRegexp = regexp.MustCompile(`^\s*([a-z]+)\s*(==|!=|>=|<=|>|<)\s*(.+?)\s*$`)
+
+func parseAssetFilter(expr string) (*assetFilter, error) {
+	r := new(assetFilter)
+	for _, clause := range strings.Split(expr, "&&") {
+		match := filterClauseRegexp.FindStringSubmatch(clause)
+		if match == nil {
+			return nil, fmt.Errorf("invalid filter clause %q, want `field op value`", strings.TrimSpace(clause))
+		}
+		field, op, value := match[1], match[2], strings.Trim(match[3], `"'`)
+		predicate, err := r.parseClause(field, op, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter clause %q: %w", strings.TrimSpace(clause), err)
+		}
+		r.predicates = append(r.predicates, predicate)
+	}
+	return r, nil
+}
+
+func (r *assetFilter) parseClause(field, op, value string) (func(photo *icloudgo.PhotoAsset) bool, error) {
+	switch field {
+	case "type":
+		is, ok := filterAssetTypes[strings.ToLower(value)]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q", value)
+		}
+		return equalPredicate(op, is)
+	case "size":
+		size, err := parseSize(value)
+		if err != nil {
+			return nil, err
+		}
+		return comparePredicate(op, func(photo *icloudgo.PhotoAsset) int {
+			return compareInt(int64(photo.Size()), int64(size))
+		})
+	case "date", "added":
+		t, err := parseFilterTime(value)
+		if err != nil {
+			return nil, err
+		}
+		if field == "date" && (op == ">=" || op == ">") && t.After(r.since) {
+			r.since = t
+		}
+		get := (*icloudgo.PhotoAsset).AssetDate
+		if field == "added" {
+			get = (*icloudgo.PhotoAsset).AddedDate
+		}
+		return comparePredicate(op, func(photo *icloudgo.PhotoAsset) int {
+			return compareInt(get(photo).UnixNano(), t.UnixNano())
+		})
+	case "favorite", "hidden":
+		want, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+		get := (*icloudgo.PhotoAsset).IsFavorite
+		if field == "hidden" {
+			get = (*icloudgo.PhotoAsset).IsHidden
+		}
+		return equalPredicate(op, func(photo *icloudgo.PhotoAsset) bool { return get(photo) == want })
+	case "name":
+		pattern := strings.ToLower(value)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+		return equalPredicate(op, func(photo *icloudgo.PhotoAsset) bool {
+			ok, _ := path.Match(pattern, strings.ToLower(photo.Filename()))
+			return ok
+		})
+	case "source":
+		match, err := sourceMatcher(value)
+		if err != nil {
+			return nil, err
+		}
+		return equalPredicate(op, match)
+	case "ext":
+		ext := "." + strings.TrimPrefix(strings.ToLower(value), ".")
+		return equalPredicate(op, func(photo *icloudgo.PhotoAsset) bool {
+			return strings.ToLower(filepath.Ext(photo.Filename())) == ext
+		})
+	}
+	return nil, fmt.Errorf("unknown field %q", field)
+}
+
+// Match reports whether the photo matches every clause.
+func (r *assetFilter) Match(photo *icloudgo.PhotoAsset) bool {
+	for _, predicate := range r.predicates {
+		if !predicate(photo) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply adds the filter to the run, the server-side part is merged with the existing Since.
+func (r *assetFilter) apply(option *downloadOption) {
+	if r.since.After(option.iterOption.Since) {
+		option.iterOption.Since = r.since
+	}
+	option.addFilter(r.Match)
+}
+
+var filterAssetTypes = map[string]func(photo *icloudgo.PhotoAsset) bool{
+	"photo":           func(photo *icloudgo.PhotoAsset) bool { return !photo.IsVideo() },
+	"video":           (*icloudgo.PhotoAsset).IsVideo,
+	"live":            (*icloudgo.PhotoAsset).IsLivePhoto,
+	"screenshot":      (*icloudgo.PhotoAsset).IsScreenshot,
+	"screenrecording": (*icloudgo.PhotoAsset).IsScreenRecording,
+	"panorama":        (*icloudgo.PhotoAsset).IsPanorama,
+	"slomo":           (*icloudgo.PhotoAsset).IsSloMo,
+	"burst":           (*icloudgo.PhotoAsset).IsBurst,
+}
+
+func equalPredicate(op string, is func(photo *icloudgo.PhotoAsset) bool) (func(photo *icloudgo.PhotoAsset) bool, error) {
+	switch op {
+	case "==":
+		return is, nil
+	case "!=":
+		return func(photo *icloudgo.PhotoAsset) bool { return !is(photo) }, nil
+	}
+	return nil, fmt.Errorf("operator %s not supported, use == or !=", op)
+}
+
+func comparePredicate(op string, compare func(photo *icloudgo.PhotoAsset) int) (func(photo *icloudgo.PhotoAsset) bool, error) {
+	check := map[string]func(int) bool{
+		"==": func(c int) bool { return c == 0 },
+		"!=": func(c int) bool { return c != 0 },
+		">":  func(c int) bool { return c > 0 },
+		">=": func(c int) bool { return c >= 0 },
+		"<":  func(c int) bool { return c < 0 },
+		"<=": func(c int) bool { return c <= 0 },
+	}[op]
+	return func(photo *icloudgo.PhotoAsset) bool { return check(compare(photo)) }, nil
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func parseFilterTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+// parsePhotosFilter parses --from, --to and --match, a --to day is included.
+func parsePhotosFilter(from, to, match string) (*icloudgo.PhotosFilter, error) {
+	r := &icloudgo.PhotosFilter{Match: match}
+	if from != "" {
+		t, err := parseFilterTime(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --from %q, like 2023-01-31 or 2023-01-31T08:00:00Z", from)
+		}
+		r.From = t
+	}
+	if to != "" {
+		t, err := parseFilterTime(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --to %q, like 2023-01-31 or 2023-01-31T08:00:00Z", to)
+		}
+		if _, err := time.Parse(time.RFC3339, to); err != nil {
+			t = t.AddDate(0, 0, 1)
+		}
+		r.To = t
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && !r.From.Before(r.To) {
+		return nil, fmt.Errorf("--to %s is before --from %s", to, from)
+	}
+	if _, err := path.Match(strings.ToLower(match), ""); err != nil {
+		return nil, fmt.Errorf("invalid --match %q: %w", match, err)
+	}
+	return r, nil
+}
+
+// sourceMatcher returns whether the bundle id or the name of the app which saved a photo matches the glob, case insensitive.
+func sourceMatcher(glob string) (func(photo *icloudgo.PhotoAsset) bool, error) {
+	pattern := strings.ToLower(glob)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return func(photo *icloudgo.PhotoAsset) bool {
+		for _, v := range []string{photo.SourceBundleID(), photo.SourceAppName()} {
+			if ok, _ := path.Match(pattern, strings.ToLower(v)); ok && v != "" {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
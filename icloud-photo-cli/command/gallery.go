@@ -0,0 +1,242 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const (
+	galleryDirName   = ".gallery"
+	galleryIndexName = "index.html"
+)
+
+// gallery writes a static, browsable index.html into the output dir:
+// a thumbnail grid grouped by month, and one page per album.
+// entries are kept in .gallery/gallery.json, so incremental runs add to the gallery of previous runs.
+type gallery struct {
+	outputDir string
+	lock      sync.Mutex
+	entries   map[string]*galleryEntry
+}
+
+type galleryEntry struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"`
+	Thumb   string    `json:"thumb"`
+	Created time.Time `json:"created"`
+	Video   bool      `json:"video"`
+	Albums  []string  `json:"albums"`
+}
+
+func newGallery(enabled bool, outputDir string) (*gallery, error) {
+	if !enabled {
+		return nil, nil
+	}
+	r := &gallery{outputDir: outputDir, entries: map[string]*galleryEntry{}}
+	bs, err := os.ReadFile(r.dataPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	var entries []*galleryEntry
+	if err := json.Unmarshal(bs, &entries); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %w", r.dataPath(), err)
+	}
+	for _, v := range entries {
+		r.entries[v.ID] = v
+	}
+	return r, nil
+}
+
+func (r *gallery) dataPath() string {
+	return filepath.Join(r.outputDir, galleryDirName, "gallery.json")
+}
+
+// Add downloads the thumbnail of the photo stored at path, and adds it to the gallery.
+func (r *gallery) Add(photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, path string) error {
+	if r == nil {
+		return nil
+	}
+	rel, err := filepath.Rel(r.outputDir, path)
+	if err != nil {
+		return err
+	}
+
+	thumb := filepath.Join(galleryDirName, "thumbs", galleryThumbName(photo.ID()))
+	if f, _ := os.Stat(filepath.Join(r.outputDir, thumb)); f == nil || f.Size() == 0 {
+		if err := os.MkdirAll(filepath.Join(r.outputDir, galleryDirName, "thumbs"), os.ModePerm); err != nil {
+			return err
+		}
+		// the thumb of a video is a video too, the thumb of its poster frame is what an <img> shows
+		version := icloudgo.PhotoVersionThumb
+		if posters := photo.PosterFrameVersions(); len(posters) > 0 {
+			version = posters[len(posters)-1].Version
+		}
+		if err := photo.DownloadTo(version, filepath.Join(r.outputDir, thumb)); err != nil {
+			fmt.Printf("download thumb of %s failed, use the original in gallery, err: %s\n", photo.ID(), err)
+			thumb = rel
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entry := r.entries[photo.ID()]
+	if entry == nil {
+		entry = &galleryEntry{ID: photo.ID()}
+		r.entries[photo.ID()] = entry
+	}
+	entry.Path = filepath.ToSlash(rel)
+	entry.Thumb = filepath.ToSlash(thumb)
+	entry.Created = photo.Created()
+	entry.Video = photo.IsVideo()
+	if album.ID() != icloudgo.AlbumIDAll && !containsString(entry.Albums, album.Name) {
+		entry.Albums = append(entry.Albums, album.Name)
+		sort.Strings(entry.Albums)
+	}
+	return nil
+}
+
+// Write writes index.html, the album pages and the gallery data.
+func (r *gallery) Write() error {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entries := make([]*galleryEntry, 0, len(r.entries))
+	albums := map[string][]*galleryEntry{}
+	for _, v := range r.entries {
+		entries = append(entries, v)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Created.After(entries[j].Created) })
+	for _, v := range entries {
+		for _, album := range v.Albums {
+			albums[album] = append(albums[album], v)
+		}
+	}
+
+	albumNames := make([]string, 0, len(albums))
+	for name := range albums {
+		albumNames = append(albumNames, name)
+	}
+	sort.Strings(albumNames)
+	links := make([]galleryLink, 0, len(albumNames))
+	for i, name := range albumNames {
+		links = append(links, galleryLink{Name: fmt.Sprintf("%s (%d)", name, len(albums[name])), Href: fmt.Sprintf("%s/album-%d.html", galleryDirName, i)})
+	}
+
+	if err := os.MkdirAll(filepath.Join(r.outputDir, galleryDirName), os.ModePerm); err != nil {
+		return err
+	}
+	if err := writeGalleryPage(filepath.Join(r.outputDir, galleryIndexName), "All Photos", "", links, entries); err != nil {
+		return err
+	}
+	for i, name := range albumNames {
+		path := filepath.Join(r.outputDir, galleryDirName, fmt.Sprintf("album-%d.html", i))
+		if err := writeGalleryPage(path, name, "../", []galleryLink{{Name: "All Photos", Href: galleryIndexName}}, albums[name]); err != nil {
+			return err
+		}
+	}
+
+	bs, _ := json.MarshalIndent(entries, "", "  ")
+	if err := os.WriteFile(r.dataPath(), bs, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("gallery: %d photos, %d albums, see %s\n", len(entries), len(albumNames), filepath.Join(r.outputDir, galleryIndexName))
+	return nil
+}
+
+type galleryLink struct {
+	Name string
+	Href string
+}
+
+type galleryMonth struct {
+	Anchor  string
+	Name    string
+	Entries []*galleryEntry
+}
+
+func writeGalleryPage(path, title, root string, links []galleryLink, entries []*galleryEntry) error {
+	var months []*galleryMonth
+	for _, v := range entries {
+		name := v.Created.Local().Format("2006-01")
+		if len(months) == 0 || months[len(months)-1].Name != name {
+			months = append(months, &galleryMonth{Anchor: "m" + name, Name: name})
+		}
+		months[len(months)-1].Entries = append(months[len(months)-1].Entries, v)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return galleryTemplate.Execute(f, map[string]any{
+		"Title":  title,
+		"Root":   root,
+		"Links":  links,
+		"Months": months,
+	})
+}
+
+// galleryThumbName returns a filename safe name of the thumbnail of the record.
+func galleryThumbName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, id) + ".jpg"
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, sans-serif; margin: 0; padding: 16px; background: #111; color: #eee; }
+a { color: #9cf; }
+nav { margin-bottom: 12px; line-height: 1.8; }
+nav a { margin-right: 12px; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(160px, 1fr)); gap: 4px; }
+.grid a { position: relative; display: block; aspect-ratio: 1; overflow: hidden; background: #222; }
+.grid img { width: 100%; height: 100%; object-fit: cover; }
+.grid .video::after { content: "▶"; position: absolute; right: 6px; bottom: 4px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<nav>{{range .Links}}<a href="{{$.Root}}{{.Href}}">{{.Name}}</a>{{end}}</nav>
+<nav>{{range .Months}}<a href="#{{.Anchor}}">{{.Name}}</a>{{end}}</nav>
+{{range .Months}}
+<h2 id="{{.Anchor}}">{{.Name}}</h2>
+<div class="grid">{{range .Entries}}<a href="{{$.Root}}{{.Path}}"{{if .Video}} class="video"{{end}}><img loading="lazy" src="{{$.Root}}{{.Thumb}}" alt="{{.Path}}"></a>{{end}}</div>
+{{end}}
+</body>
+</html>
+`))
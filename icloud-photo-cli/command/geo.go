@@ -0,0 +1,183 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const defaultGeocoder = "https://nominatim.openstreetmap.org/reverse"
+
+const earthRadiusMeters = 6371000
+
+// geoFence is a circle on the earth, parsed from --near "lat,lon,radius".
+type geoFence struct {
+	latitude  float64
+	longitude float64
+	radius    float64 // meters
+}
+
+// parseGeoFence parses "lat,lon,radius", radius is like 500m or 5km, km if the unit is omitted.
+func parseGeoFence(s string) (*geoFence, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid --near %q, want lat,lon,radius", s)
+	}
+	latitude, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	longitude, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return nil, fmt.Errorf("invalid --near %q, bad lat,lon", s)
+	}
+
+	radiusText := strings.ToLower(strings.TrimSpace(parts[2]))
+	unit := 1000.0
+	if strings.HasSuffix(radiusText, "km") {
+		radiusText = strings.TrimSuffix(radiusText, "km")
+	} else if strings.HasSuffix(radiusText, "m") {
+		radiusText, unit = strings.TrimSuffix(radiusText, "m"), 1
+	}
+	radius, err := strconv.ParseFloat(radiusText, 64)
+	if err != nil || radius <= 0 {
+		return nil, fmt.Errorf("invalid --near %q, bad radius", s)
+	}
+	return &geoFence{latitude: latitude, longitude: longitude, radius: radius * unit}, nil
+}
+
+// Contains reports whether the photo was taken in the fence, photos without location are never in it.
+func (r *geoFence) Contains(photo *icloudgo.PhotoAsset) bool {
+	latitude, longitude, ok := photo.Location()
+	return ok && haversine(r.latitude, r.longitude, latitude, longitude) <= r.radius
+}
+
+// haversine returns the distance in meters between two points.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat, dLon := (lat2-lat1)*rad, (lon2-lon1)*rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
+
+// reverseGeocoder turns photo locations into country and city with a Nominatim compatible endpoint,
+// results are cached by ~1km cells, and requests are spaced 1s apart, per the Nominatim usage policy.
+type reverseGeocoder struct {
+	endpoint string
+	client   *http.Client
+	lock     sync.Mutex
+	cache    map[string]*geoPlace
+	next     time.Time // when the next request may be sent
+}
+
+type geoPlace struct {
+	Country     string
+	CountryCode string
+	City        string
+}
+
+func newReverseGeocoder(endpoint string) *reverseGeocoder {
+	if endpoint == "" {
+		endpoint = defaultGeocoder
+	}
+	return &reverseGeocoder{endpoint: endpoint, client: &http.Client{Timeout: 30 * time.Second}, cache: map[string]*geoPlace{}}
+}
+
+// Place returns where the photo was taken, nil if the photo has no location or the lookup failed,
+// a failed lookup is not cached, the next photo of the cell tries again.
+func (r *reverseGeocoder) Place(photo *icloudgo.PhotoAsset) *geoPlace {
+	latitude, longitude, ok := photo.Location()
+	if !ok {
+		return nil
+	}
+	key := fmt.Sprintf("%.2f,%.2f", latitude, longitude)
+
+	// the lock only guards the cache and the request slots, the other workers don't wait for the request
+	r.lock.Lock()
+	if place, ok := r.cache[key]; ok {
+		r.lock.Unlock()
+		return place
+	}
+	now := time.Now()
+	at := r.next
+	if at.Before(now) {
+		at = now
+	}
+	r.next = at.Add(time.Second)
+	r.lock.Unlock()
+
+	time.Sleep(time.Until(at))
+	place, err := r.lookup(latitude, longitude)
+	if err != nil {
+		fmt.Printf("reverse geocode %s failed, err: %s\n", key, err)
+		return nil
+	}
+
+	r.lock.Lock()
+	r.cache[key] = place
+	r.lock.Unlock()
+	return place
+}
+
+func (r *reverseGeocoder) lookup(latitude, longitude float64) (*geoPlace, error) {
+	query := url.Values{
+		"format": {"jsonv2"},
+		"lat":    {strconv.FormatFloat(latitude, 'f', 6, 64)},
+		"lon":    {strconv.FormatFloat(longitude, 'f', 6, 64)},
+		"zoom":   {"10"},
+	}
+	req, err := http.NewRequest(http.MethodGet, r.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "icloud-photo-cli")
+	req.Header.Set("Accept-Language", "en")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Address struct {
+			Country     string `json:"country"`
+			CountryCode string `json:"country_code"`
+			City        string `json:"city"`
+			Town        string `json:"town"`
+			Village     string `json:"village"`
+		} `json:"address"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	city := res.Address.City
+	if city == "" {
+		city = res.Address.Town
+	}
+	if city == "" {
+		city = res.Address.Village
+	}
+	return &geoPlace{Country: res.Address.Country, CountryCode: res.Address.CountryCode, City: city}, nil
+}
+
+// placeFilter matches photos taken in the country and city, empty means any.
+func placeFilter(geocoder *reverseGeocoder, country, city string) func(photo *icloudgo.PhotoAsset) bool {
+	return func(photo *icloudgo.PhotoAsset) bool {
+		place := geocoder.Place(photo)
+		if place == nil {
+			return false
+		}
+		if country != "" && !strings.EqualFold(country, place.Country) && !strings.EqualFold(country, place.CountryCode) {
+			return false
+		}
+		return city == "" || strings.EqualFold(city, place.City)
+	}
+}
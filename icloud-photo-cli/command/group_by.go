@@ -0,0 +1,109 @@
+package command
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const (
+	groupByNone   = "none"
+	groupByYear   = "year"
+	groupByMonth  = "month"
+	groupByDay    = "day"
+	groupByMoment = "moment"
+)
+
+func isValidGroupBy(s string) bool {
+	switch s {
+	case groupByNone, groupByYear, groupByMonth, groupByDay, groupByMoment:
+		return true
+	}
+	return false
+}
+
+// grouping is the folder of the output dir each photo goes to, by --group-by.
+type grouping struct {
+	by string
+
+	// dirs are the moment folders of the photos listed so far, by photo id
+	lock sync.Mutex
+	dirs map[string]string
+}
+
+func newGrouping(by string) *grouping {
+	if by == "" || by == groupByNone {
+		return nil
+	}
+	return &grouping{by: by, dirs: map[string]string{}}
+}
+
+// Dir returns the folder of the photo, relative to the output dir, empty when the photos are not grouped.
+func (r *grouping) Dir(photo *icloudgo.PhotoAsset) string {
+	if r == nil {
+		return ""
+	}
+	date := photo.AssetDateInZone()
+	switch r.by {
+	case groupByYear:
+		return date.Format("2006")
+	case groupByMonth:
+		return filepath.Join(date.Format("2006"), date.Format("01"))
+	case groupByDay:
+		return filepath.Join(date.Format("2006"), date.Format("2006-01-02"))
+	case groupByMoment:
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		return r.dirs[photo.ID()]
+	}
+	return ""
+}
+
+// Iter returns the assets of iter, grouped into moments when grouping by moment,
+// so Dir knows the moment of each asset before it's downloaded.
+func (r *grouping) Iter(iter icloudgo.AssetIterator) icloudgo.AssetIterator {
+	if r == nil || r.by != groupByMoment {
+		return iter
+	}
+	return &momentGroupIter{grouping: r, moments: icloudgo.NewMomentIter(iter, nil)}
+}
+
+// momentGroupIter lists the assets of each moment, once the moment is complete.
+type momentGroupIter struct {
+	grouping *grouping
+	moments  *icloudgo.MomentIter
+	assets   []*icloudgo.PhotoAsset
+}
+
+func (r *momentGroupIter) Next() (*icloudgo.PhotoAsset, error) {
+	for len(r.assets) == 0 {
+		moment, err := r.moments.Next()
+		if err != nil {
+			return nil, err
+		}
+		dir := momentDir(moment)
+		r.grouping.lock.Lock()
+		for _, asset := range moment.Assets {
+			r.grouping.dirs[asset.ID()] = dir
+		}
+		r.grouping.lock.Unlock()
+		r.assets = moment.Assets
+	}
+	asset := r.assets[0]
+	r.assets = r.assets[1:]
+	return asset, nil
+}
+
+// momentDir names the folder of a moment by its first photo, like 2023/2023-05-20 14.32,
+// new photos of a moment are newer, so they don't rename the folder of its older ones.
+func momentDir(moment *icloudgo.Moment) string {
+	first := moment.Assets[0]
+	for _, asset := range moment.Assets[1:] {
+		if asset.AssetDate().Before(first.AssetDate()) {
+			first = asset
+		}
+	}
+	date := first.AssetDateInZone()
+	return filepath.Join(date.Format("2006"), date.Format("2006-01-02 15.04"))
+}
@@ -0,0 +1,56 @@
+package command
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// filenameRules are the --include and --exclude globs, matched like rsync does against the filename a photo is saved as:
+// the first matching rule decides, the --include rules are tried before the --exclude rules,
+// and a photo matching no rule is downloaded, so `--include '*.HEIC' --exclude '*'` keeps only the HEIC photos.
+//
+// The globs are case sensitive, *, ? and [...] are supported, like IMG_E* for the edited duplicates.
+type filenameRules []filenameRule
+
+type filenameRule struct {
+	pattern string
+	include bool
+}
+
+func parseFilenameRules(includes, excludes []string) (filenameRules, error) {
+	var rules filenameRules
+	for _, patterns := range []struct {
+		patterns []string
+		include  bool
+	}{{includes, true}, {excludes, false}} {
+		for _, pattern := range patterns.patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			rules = append(rules, filenameRule{pattern: pattern, include: patterns.include})
+		}
+	}
+	return rules, nil
+}
+
+// Match reports whether the file named filename is downloaded.
+func (r filenameRules) Match(filename string) bool {
+	for _, rule := range r {
+		if ok, _ := path.Match(rule.pattern, filename); ok {
+			return rule.include
+		}
+	}
+	return true
+}
+
+func (r filenameRules) apply(option *downloadOption) {
+	if len(r) == 0 {
+		return
+	}
+	option.addFilter(func(photo *icloudgo.PhotoAsset) bool {
+		return r.Match(filepath.Base(option.localPath(photo, nil, "", icloudgo.PhotoVersionOriginal)))
+	})
+}
@@ -0,0 +1,29 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// downloadLivePhotoMov downloads the video of a live photo next to the photo at path, with --live-photo-mov,
+// a video already there is kept.
+func downloadLivePhotoMov(photo *icloudgo.PhotoAsset, path string, option *downloadOption) error {
+	if !option.livePhotoMov || len(photo.LiveVideoVersions()) == 0 {
+		return nil
+	}
+	target := livePhotoMovPath(path)
+	option.snapshot.link(target, 0)
+	if f, _ := option.storage.Stat(target); f != nil {
+		return nil
+	}
+	fmt.Printf("download live photo video %s\n", target)
+	return photo.DownloadToStorageContext(option.ctx, icloudgo.PhotoVersionLiveOriginal, option.storage, target)
+}
+
+// livePhotoMovPath returns the path of the video of the live photo at path, like IMG_0001.MOV for IMG_0001.HEIC.
+func livePhotoMovPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".MOV"
+}
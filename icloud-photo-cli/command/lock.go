@@ -0,0 +1,98 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const lockFilename = ".icloudgo.lock"
+
+type lockInfo struct {
+	Pid       int       `json:"pid"`
+	Hostname  string    `json:"hostname"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type heldLockKey struct{}
+
+// withHeldLock returns a context of the runs the lock of outputDir is held for, like the syncs of watch.
+func withHeldLock(ctx context.Context, outputDir string) context.Context {
+	return context.WithValue(ctx, heldLockKey{}, filepath.Clean(outputDir))
+}
+
+// acquireLock makes sure only one run works on outputDir at a time, unless ctx is of a run the lock is held for,
+// `force` breaks a lock left by another run.
+func acquireLock(ctx context.Context, outputDir string, force bool) (func(), error) {
+	if held, _ := ctx.Value(heldLockKey{}).(string); held == filepath.Clean(outputDir) {
+		return func() {}, nil
+	}
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(outputDir, lockFilename)
+
+	hostname, _ := os.Hostname()
+	bs, _ := json.Marshal(&lockInfo{Pid: os.Getpid(), Hostname: hostname, StartedAt: time.Now()})
+
+	for i := 0; i < 2; i++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = f.Write(bs)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("create lock %s failed: %w", path, err)
+		}
+
+		holder := readLockInfo(path)
+		if !force {
+			if holder != nil && holder.isStale(hostname) {
+				return nil, fmt.Errorf("output dir %s is locked by a stale run (pid %d, started at %s), use --force to break it", outputDir, holder.Pid, holder.StartedAt.Format(time.RFC3339))
+			}
+			if holder != nil {
+				return nil, fmt.Errorf("output dir %s is locked by another run (pid %d on %s, started at %s)", outputDir, holder.Pid, holder.Hostname, holder.StartedAt.Format(time.RFC3339))
+			}
+			return nil, fmt.Errorf("output dir %s is locked by another run, remove %s or use --force", outputDir, path)
+		}
+
+		fmt.Printf("break lock %s\n", path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("break lock %s failed: %w", path, err)
+		}
+	}
+	return nil, fmt.Errorf("acquire lock %s failed", path)
+}
+
+func readLockInfo(path string) *lockInfo {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	info := new(lockInfo)
+	if err := json.Unmarshal(bs, info); err != nil {
+		return nil
+	}
+	return info
+}
+
+// isStale reports whether the process holding the lock is gone, only known for locks of this host.
+func (r *lockInfo) isStale(hostname string) bool {
+	if r.Hostname != hostname || r.Pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(r.Pid)
+	if err != nil {
+		return true
+	}
+	return errors.Is(process.Signal(syscall.Signal(0)), os.ErrProcessDone)
+}
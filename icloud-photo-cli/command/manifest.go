@@ -0,0 +1,178 @@
+package command
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	manifestFormatSums = "sums"
+	// manifestFormatSHA256SUMS is the sums format of the manifests written before --checksum-algorithm
+	manifestFormatSHA256SUMS = "sha256sums"
+	manifestFormatJSON       = "json"
+)
+
+// checksumManifest records the checksum of every file downloaded by the run,
+// merged into the manifest left by previous runs, so archives can be verified with `sha256sum -c`,
+// `sha1sum -c` or `xxhsum -c`, depending on the algorithm.
+type checksumManifest struct {
+	format    string
+	algorithm *checksumAlgorithm
+	outputDir string
+	lock      sync.Mutex
+	entries   map[string]map[string]string // relative path -> algorithm -> hex checksum
+}
+
+func newChecksumManifest(format, algorithm, outputDir string) (*checksumManifest, error) {
+	if format == "" {
+		return nil, nil
+	}
+	if format == manifestFormatSHA256SUMS {
+		format = manifestFormatSums
+	}
+	if format != manifestFormatSums && format != manifestFormatJSON {
+		return nil, fmt.Errorf("manifest must be %s or %s", manifestFormatSums, manifestFormatJSON)
+	}
+	alg, err := getChecksumAlgorithm(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	r := &checksumManifest{format: format, algorithm: alg, outputDir: outputDir, entries: map[string]map[string]string{}}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *checksumManifest) path() string {
+	if r.format == manifestFormatJSON {
+		return filepath.Join(r.outputDir, "manifest.json")
+	}
+	return filepath.Join(r.outputDir, r.algorithm.sumsFile)
+}
+
+func (r *checksumManifest) load() error {
+	f, err := os.Open(r.path())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	if r.format == manifestFormatJSON {
+		// an entry is the path and the checksum of each algorithm, like {"path": "IMG_0001.HEIC", "sha256": "..."}
+		var entries []map[string]string
+		if err := json.NewDecoder(f).Decode(&entries); err != nil {
+			return fmt.Errorf("parse %s failed: %w", r.path(), err)
+		}
+		for _, v := range entries {
+			path := v["path"]
+			delete(v, "path")
+			r.entries[path] = v
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		sum, path, ok := strings.Cut(scanner.Text(), "  ")
+		if ok {
+			r.entries[path] = map[string]string{r.algorithm.name: sum}
+		}
+	}
+	return scanner.Err()
+}
+
+// Add hashes the file at path and records it.
+func (r *checksumManifest) Add(path string) error {
+	if r == nil {
+		return nil
+	}
+	sum, err := r.algorithm.sumFile(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(r.outputDir, path)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// the checksums of other algorithms are of the file before it was downloaded again
+	r.entries[filepath.ToSlash(rel)] = map[string]string{r.algorithm.name: sum}
+	return nil
+}
+
+// checksum returns the checksum of the file at rel and its algorithm, the strongest one of a json manifest,
+// ok is false when the file isn't in the manifest.
+func (r *checksumManifest) checksum(rel string) (algorithm *checksumAlgorithm, sum string, ok bool) {
+	sums := r.entries[rel]
+	for _, algorithm := range checksumAlgorithms {
+		if sum, ok := sums[algorithm.name]; ok {
+			return algorithm, sum, true
+		}
+	}
+	return nil, "", false
+}
+
+func (r *checksumManifest) Write() error {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	paths := make([]string, 0, len(r.entries))
+	for path := range r.entries {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var bs []byte
+	if r.format == manifestFormatJSON {
+		entries := make([]map[string]string, 0, len(paths))
+		for _, path := range paths {
+			entry := map[string]string{"path": path}
+			for k, v := range r.entries[path] {
+				entry[k] = v
+			}
+			entries = append(entries, entry)
+		}
+		bs, _ = json.MarshalIndent(entries, "", "  ")
+	} else {
+		var sb strings.Builder
+		for _, path := range paths {
+			if sum, ok := r.entries[path][r.algorithm.name]; ok {
+				sb.WriteString(sum + "  " + path + "\n")
+			}
+		}
+		bs = []byte(sb.String())
+	}
+	return os.WriteFile(r.path(), bs, 0o644)
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
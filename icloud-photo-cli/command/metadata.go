@@ -0,0 +1,108 @@
+package command
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// metadataSidecar is the <filename>.json written next to each asset by --write-metadata.
+type metadataSidecar struct {
+	ID               string            `json:"id"`
+	Filename         string            `json:"filename"`
+	OriginalFilename string            `json:"original_filename"`
+	Size             int               `json:"size"`
+	Checksum         string            `json:"checksum"`
+	Created          time.Time         `json:"created"`
+	Date             time.Time         `json:"date"`
+	Modified         time.Time         `json:"modified"`
+	Favorite         bool              `json:"favorite"`
+	Hidden           bool              `json:"hidden"`
+	Caption          string            `json:"caption,omitempty"`
+	Source           *metadataSource   `json:"source,omitempty"`
+	Location         *metadataLocation `json:"location,omitempty"`
+	Albums           []string          `json:"albums"`
+	Rating           int               `json:"rating"`
+	Keywords         []string          `json:"keywords"`
+}
+
+type metadataLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type metadataSource struct {
+	ImportedBy int    `json:"imported_by"`
+	BundleID   string `json:"bundle_id,omitempty"`
+	AppName    string `json:"app_name,omitempty"`
+}
+
+func metadataSidecarPath(path string) string {
+	return path + ".json"
+}
+
+// writeSidecars writes the sidecars of the photo downloaded to path,
+// the <filename>.json with --write-metadata, and the <filename>.xmp with --write-metadata or --write-xmp.
+func writeSidecars(photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, path string, option *downloadOption) error {
+	if option.writeMetadata {
+		if err := writeMetadataSidecar(photo, album, path, option.curation); err != nil {
+			return err
+		}
+	}
+	if option.writeMetadata || option.writeXMP {
+		albums := []string{}
+		if album.ID() != icloudgo.AlbumIDAll {
+			albums = append(albums, album.Name)
+		}
+		if err := writeXMPSidecar(photo, path, option.curation.Rating(photo), option.curation.Keywords(albums)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMetadataSidecar writes the metadata of the photo downloaded to path,
+// albums of the sidecar left by previous runs are kept, so a photo in many albums lists all of them.
+func writeMetadataSidecar(photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, path string, curation *curationMap) error {
+	sidecarPath := metadataSidecarPath(path)
+	albums := []string{}
+	if bs, err := os.ReadFile(sidecarPath); err == nil {
+		old := new(metadataSidecar)
+		if json.Unmarshal(bs, old) == nil && old.ID == photo.ID() {
+			albums = append(albums, old.Albums...)
+		}
+	}
+	if album.ID() != icloudgo.AlbumIDAll && !containsString(albums, album.Name) {
+		albums = append(albums, album.Name)
+		sort.Strings(albums)
+	}
+
+	sidecar := &metadataSidecar{
+		ID:               photo.ID(),
+		Filename:         photo.Filename(),
+		OriginalFilename: photo.OriginalFilename(),
+		Size:             photo.Size(),
+		Checksum:         photo.Checksum(),
+		Created:          photo.Created().UTC(),
+		Date:             photo.AssetDateInZone(),
+		Modified:         photo.Modified().UTC(),
+		Favorite:         photo.IsFavorite(),
+		Hidden:           photo.IsHidden(),
+		Caption:          photo.Caption(),
+		Albums:           albums,
+		Rating:           curation.Rating(photo),
+		Keywords:         curation.Keywords(albums),
+	}
+	if photo.ImportedBy() != 0 || photo.SourceBundleID() != "" {
+		sidecar.Source = &metadataSource{ImportedBy: photo.ImportedBy(), BundleID: photo.SourceBundleID(), AppName: photo.SourceAppName()}
+	}
+	if latitude, longitude, ok := photo.Location(); ok {
+		sidecar.Location = &metadataLocation{Latitude: latitude, Longitude: longitude}
+	}
+
+	bs, _ := json.MarshalIndent(sidecar, "", "  ")
+	return os.WriteFile(sidecarPath, bs, 0o644)
+}
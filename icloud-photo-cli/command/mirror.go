@@ -0,0 +1,121 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// mirrorDestination is an extra --mirror output dir, or S3 bucket or WebDAV dir like --output-backend,
+// every photo of the run is written to it too, from the same download stream as the output dir.
+type mirrorDestination struct {
+	name    string
+	dir     string // the local dir, empty for a S3 or WebDAV mirror, their paths are relative
+	storage icloudgo.Storage
+	done    int64
+	failed  int64
+}
+
+func newMirrorDestinations(c *cli.Context) ([]*mirrorDestination, error) {
+	mirrors := c.StringSlice("mirror")
+	res := make([]*mirrorDestination, 0, len(mirrors))
+	for _, mirror := range mirrors {
+		if !isBackendURL(mirror) {
+			res = append(res, &mirrorDestination{name: mirror, dir: mirror, storage: icloudgo.NewFileStorage()})
+			continue
+		}
+		storage, err := newBackendStorage(c, "mirror", mirror)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &mirrorDestination{name: stripURLUserinfo(mirror), storage: storage})
+	}
+	return res, nil
+}
+
+// missing returns the path of the photo in the mirror, and whether the mirror still needs it.
+func (r *mirrorDestination) missing(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, outputDir, path string) (string, bool) {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		return "", false
+	}
+	target := filepath.Join(r.dir, rel)
+	f, _ := r.storage.Stat(target)
+	return target, f == nil || int(f.Size()) != versionSize(photo, version)
+}
+
+func (r *mirrorDestination) record(path string, err error) {
+	if err != nil {
+		atomic.AddInt64(&r.failed, 1)
+		fmt.Printf("mirror %s failed, err: %s\n", path, err)
+		return
+	}
+	atomic.AddInt64(&r.done, 1)
+}
+
+func (r *mirrorDestination) String() string {
+	return fmt.Sprintf("mirror %s: %d written, %d failed", r.name, atomic.LoadInt64(&r.done), atomic.LoadInt64(&r.failed))
+}
+
+// downloadWithMirrors downloads the photo to target and to every mirror missing it, in one download.
+func downloadWithMirrors(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, option *downloadOption, target string) error {
+	targets := []*icloudgo.StorageTarget{{Storage: option.storage, Path: target}}
+	var mirrors []*mirrorDestination
+	for _, mirror := range option.mirrors {
+		if path, ok := mirror.missing(photo, version, option.output, target); ok {
+			targets = append(targets, &icloudgo.StorageTarget{Storage: mirror.storage, Path: path})
+			mirrors = append(mirrors, mirror)
+		}
+	}
+
+	errs := photo.DownloadToStoragesContext(option.ctx, version, targets)
+	for i, mirror := range mirrors {
+		mirror.record(targets[i+1].Path, errs[i+1])
+	}
+	return errs[0]
+}
+
+// copyToMirrors copies the already downloaded photo at path to every mirror missing it, without downloading it again.
+func copyToMirrors(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, option *downloadOption, path string) {
+	for _, mirror := range option.mirrors {
+		target, ok := mirror.missing(photo, version, option.output, path)
+		if !ok {
+			continue
+		}
+		mirror.record(target, copyToStorage(path, mirror.storage, target, photo))
+	}
+}
+
+func copyToStorage(path string, storage icloudgo.Storage, target string, photo *icloudgo.PhotoAsset) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := storage.Create(target + icloudgo.PartialFileSuffix)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := storage.Rename(target+icloudgo.PartialFileSuffix, target); err != nil {
+		return err
+	}
+	if chtimes, ok := storage.(icloudgo.StorageChtimes); ok {
+		created := photo.Created()
+		return chtimes.Chtimes(target, created, created)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package command
+
+import (
+	"context"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// newMockServer starts a fake iCloud the commands of runCommand sign in to.
+func newMockServer(t *testing.T) *icloudmock.Server {
+	t.Helper()
+	server := icloudmock.New()
+	t.Cleanup(server.Close)
+	t.Setenv("ICLOUD_AUTH_ENDPOINT", server.AuthEndpoint())
+	t.Setenv("ICLOUD_SETUP_ENDPOINT", server.SetupEndpoint())
+	return server
+}
+
+// runCommand runs the command name of the cli with args, signed in to the fake iCloud of newMockServer.
+func runCommand(t *testing.T, cookieDir, name string, flags []cli.Flag, action cli.ActionFunc, args ...string) error {
+	t.Helper()
+	return runCommandContext(context.Background(), t, cookieDir, name, flags, action, args...)
+}
+
+// runCommandContext is like runCommand, the command is interrupted when ctx is done.
+func runCommandContext(ctx context.Context, t *testing.T, cookieDir, name string, flags []cli.Flag, action cli.ActionFunc, args ...string) error {
+	t.Helper()
+	app := &cli.App{
+		Name:                      "icloud-photo-cli",
+		DisableSliceFlagSeparator: true,
+		Commands: []*cli.Command{{
+			Name:   name,
+			Flags:  flags,
+			Before: LoadProfile,
+			Action: action,
+		}},
+	}
+	return app.RunContext(ctx, append([]string{
+		"icloud-photo-cli", name,
+		"--username", icloudmock.AppleID, "--password", icloudmock.Password,
+		"--cookie-dir", cookieDir, "--domain", "com", "--non-interactive", "--log-level", "error",
+	}, args...))
+}
+
+// runDownload runs download with args to the output dir.
+func runDownload(t *testing.T, cookieDir, outputDir string, args ...string) error {
+	t.Helper()
+	return runCommand(t, cookieDir, "download", NewDownloadFlag(), Download, append([]string{"--output", outputDir}, args...)...)
+}
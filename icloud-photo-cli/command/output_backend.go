@@ -0,0 +1,128 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// outputBackend writes the photos to the storage of --output-backend instead of the output dir,
+// the paths of the output dir are mapped to the same relative paths in the storage,
+// and the output dir keeps the state of the runs, like the lock and the sync state.
+type outputBackend struct {
+	dir     string
+	storage icloudgo.Storage
+}
+
+// newOutputBackend parses --output-backend, nil when there is no --output-backend.
+func newOutputBackend(c *cli.Context, outputDir string) (*outputBackend, error) {
+	backend := c.String("output-backend")
+	if backend == "" {
+		return nil, nil
+	}
+	storage, err := newBackendStorage(c, "output-backend", backend)
+	if err != nil {
+		return nil, err
+	}
+	return &outputBackend{dir: outputDir, storage: storage}, nil
+}
+
+// isBackendURL reports whether s is the url of a storage newBackendStorage parses, instead of a local dir.
+func isBackendURL(s string) bool {
+	return strings.Contains(s, "://")
+}
+
+// newBackendStorage returns the storage of `s3://bucket/prefix`, `webdav://user@host/dir` (https) or `webdav+http://...`,
+// given to the flag name, the names of the storage are relative to the bucket prefix or the dir.
+func newBackendStorage(c *cli.Context, flag, backend string) (icloudgo.Storage, error) {
+	u, err := url.Parse(backend)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s %s: %w", flag, stripURLUserinfo(backend), err)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		return icloudgo.NewS3Storage(&icloudgo.S3StorageOption{
+			Endpoint:        c.String("s3-endpoint"),
+			Region:          c.String("s3-region"),
+			Bucket:          u.Host,
+			Prefix:          strings.Trim(u.Path, "/"),
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			PathStyle:       c.String("s3-endpoint") != "",
+		})
+	case "webdav", "webdav+http":
+		password := c.String("webdav-password")
+		if password == "" && u.User != nil {
+			password, _ = u.User.Password()
+		}
+		username := ""
+		if u.User != nil {
+			username = u.User.Username()
+		}
+		scheme := "https"
+		if u.Scheme == "webdav+http" {
+			scheme = "http"
+		}
+		return icloudgo.NewWebDAVStorage(&icloudgo.WebDAVStorageOption{
+			URL:      (&url.URL{Scheme: scheme, Host: u.Host, Path: u.Path}).String(),
+			Username: username,
+			Password: password,
+		})
+	default:
+		return nil, fmt.Errorf("invalid --%s %s, expect s3://bucket/prefix or webdav://user@host/dir", flag, stripURLUserinfo(backend))
+	}
+}
+
+// rel returns the path of name of the output dir in the storage.
+func (r *outputBackend) rel(name string) (string, error) {
+	rel, err := filepath.Rel(r.dir, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not in the output dir %s", name, r.dir)
+	}
+	return rel, nil
+}
+
+func (r *outputBackend) Stat(name string) (os.FileInfo, error) {
+	rel, err := r.rel(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.storage.Stat(rel)
+}
+
+func (r *outputBackend) Create(name string) (io.WriteCloser, error) {
+	rel, err := r.rel(name)
+	if err != nil {
+		return nil, err
+	}
+	return r.storage.Create(rel)
+}
+
+func (r *outputBackend) Rename(oldName, newName string) error {
+	oldRel, err := r.rel(oldName)
+	if err != nil {
+		return err
+	}
+	newRel, err := r.rel(newName)
+	if err != nil {
+		return err
+	}
+	return r.storage.Rename(oldRel, newRel)
+}
+
+func (r *outputBackend) Remove(name string) error {
+	rel, err := r.rel(name)
+	if err != nil {
+		return err
+	}
+	return r.storage.Remove(rel)
+}
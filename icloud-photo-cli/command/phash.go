@@ -0,0 +1,127 @@
+package command
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"math/bits"
+	"os"
+	"sort"
+)
+
+const phashSize = 32
+
+// perceptualHash computes the 64 bit DCT based pHash of an image file,
+// images that look alike (resized, re-encoded, lightly edited) have a small hamming distance.
+//
+// Only formats supported by the go standard library (jpeg, png, gif) can be hashed.
+func perceptualHash(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, err
+	}
+
+	pixels := grayscaleResize(img, phashSize)
+	coeffs := dct2D(pixels)
+
+	// the top-left 8x8 low frequencies, without the DC term
+	var lows []float64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if x == 0 && y == 0 {
+				continue
+			}
+			lows = append(lows, coeffs[y][x])
+		}
+	}
+	sorted := append([]float64(nil), lows...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, v := range lows {
+		if v > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash, nil
+}
+
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// grayscaleResize box-samples img into a size*size luminance matrix.
+func grayscaleResize(img image.Image, size int) [][]float64 {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	res := make([][]float64, size)
+	for y := 0; y < size; y++ {
+		res[y] = make([]float64, size)
+		y0, y1 := bounds.Min.Y+y*h/size, bounds.Min.Y+(y+1)*h/size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < size; x++ {
+			x0, x1 := bounds.Min.X+x*w/size, bounds.Min.X+(x+1)*w/size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum float64
+			var n int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					r, g, b, _ := img.At(sx, sy).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					n++
+				}
+			}
+			res[y][x] = sum / float64(n)
+		}
+	}
+	return res
+}
+
+func dct2D(in [][]float64) [][]float64 {
+	n := len(in)
+	rows := make([][]float64, n)
+	for y := 0; y < n; y++ {
+		rows[y] = dct1D(in[y])
+	}
+	out := make([][]float64, n)
+	for y := range out {
+		out[y] = make([]float64, n)
+	}
+	col := make([]float64, n)
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			col[y] = rows[y][x]
+		}
+		res := dct1D(col)
+		for y := 0; y < n; y++ {
+			out[y][x] = res[y]
+		}
+	}
+	return out
+}
+
+func dct1D(in []float64) []float64 {
+	n := len(in)
+	out := make([]float64, n)
+	for k := 0; k < n; k++ {
+		var sum float64
+		for i, v := range in {
+			sum += v * math.Cos(math.Pi/float64(n)*(float64(i)+0.5)*float64(k))
+		}
+		out[k] = sum
+	}
+	return out
+}
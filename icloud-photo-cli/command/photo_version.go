@@ -0,0 +1,56 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// photoVersions are the versions --version downloads.
+var photoVersions = []icloudgo.PhotoVersion{
+	icloudgo.PhotoVersionOriginal,
+	icloudgo.PhotoVersionAdjusted,
+	icloudgo.PhotoVersionAlternative,
+	icloudgo.PhotoVersionMedium,
+	icloudgo.PhotoVersionThumb,
+}
+
+func validatePhotoVersion(version string) error {
+	var names []string
+	for _, v := range photoVersions {
+		if string(v) == version {
+			return nil
+		}
+		names = append(names, string(v))
+	}
+	return fmt.Errorf("unsupported --version %q, valid: %s", version, strings.Join(names, ", "))
+}
+
+// photoVersion returns the version of the photo to download, the adjusted and alternative versions fall back to
+// the original for the photos without them, like a photo which is not edited.
+func (r *downloadOption) photoVersion(photo *icloudgo.PhotoAsset) icloudgo.PhotoVersion {
+	if r.version == "" {
+		return icloudgo.PhotoVersionOriginal
+	}
+	if _, ok := photo.Version(r.version); !ok {
+		return icloudgo.PhotoVersionOriginal
+	}
+	return r.version
+}
+
+// versionSize returns the size of the version of the photo, the size of the original when it has no such version.
+func versionSize(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion) int {
+	if v, ok := photo.Version(version); ok {
+		return v.Size
+	}
+	return photo.Size()
+}
+
+// versionChecksum is like versionSize, but returns the checksum.
+func versionChecksum(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion) string {
+	if v, ok := photo.Version(version); ok {
+		return v.Checksum
+	}
+	return photo.Checksum()
+}
@@ -0,0 +1,121 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const pendingOriginalsFilename = "pending_originals.json"
+
+// previewsDir is the tree --previews-only downloads to, next to the output dir.
+func previewsDir(outputDir string) string {
+	return filepath.Clean(outputDir) + "-previews"
+}
+
+// pendingOriginals records which originals are not fetched yet,
+// a previews-only run adds to it, and a normal run removes the originals it downloads.
+type pendingOriginals struct {
+	path    string
+	lock    sync.Mutex
+	entries map[string]*pendingOriginal
+	changed bool
+}
+
+type pendingOriginal struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+	Size int    `json:"size"`
+}
+
+func loadPendingOriginals(outputDir string) (*pendingOriginals, error) {
+	r := &pendingOriginals{
+		path:    filepath.Join(previewsDir(outputDir), pendingOriginalsFilename),
+		entries: map[string]*pendingOriginal{},
+	}
+	bs, err := os.ReadFile(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	var entries []*pendingOriginal
+	if err := json.Unmarshal(bs, &entries); err != nil {
+		return nil, fmt.Errorf("parse %s failed: %w", r.path, err)
+	}
+	for _, v := range entries {
+		r.entries[v.ID] = v
+	}
+	return r, nil
+}
+
+func (r *pendingOriginals) Add(photo *icloudgo.PhotoAsset, path string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.entries[photo.ID()] = &pendingOriginal{ID: photo.ID(), Path: path, Size: photo.Size()}
+	r.changed = true
+}
+
+func (r *pendingOriginals) Remove(photo *icloudgo.PhotoAsset) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.entries[photo.ID()]; ok {
+		delete(r.entries, photo.ID())
+		r.changed = true
+	}
+}
+
+func (r *pendingOriginals) Write() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.changed {
+		return nil
+	}
+
+	entries := make([]*pendingOriginal, 0, len(r.entries))
+	for _, v := range r.entries {
+		entries = append(entries, v)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	bs, _ := json.MarshalIndent(entries, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.path, bs, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("%d originals not fetched yet, see %s\n", len(entries), r.path)
+	return nil
+}
+
+// downloadPreview downloads the medium derivative of the photo into the previews tree,
+// and records the original as pending.
+func downloadPreview(photo *icloudgo.PhotoAsset, originalPath string, option *downloadOption) (bool, error) {
+	rel, err := filepath.Rel(option.output, filepath.Dir(originalPath))
+	if err != nil {
+		return false, err
+	}
+	dir := filepath.Join(previewsDir(option.output), rel)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return false, err
+	}
+	path := filepath.Join(dir, filepath.Base(option.localPath(photo, nil, "", icloudgo.PhotoVersionMedium)))
+
+	if f, _ := os.Stat(originalPath); f != nil && int(f.Size()) == photo.Size() {
+		return true, nil
+	}
+	option.pending.Add(photo, originalPath)
+
+	if f, _ := os.Stat(path); f != nil && f.Size() > 0 {
+		fmt.Printf("preview '%s' exist, skip.\n", path)
+		return true, nil
+	}
+	return false, photo.DownloadToContext(option.ctx, icloudgo.PhotoVersionMedium, path)
+}
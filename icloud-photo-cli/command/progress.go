@@ -0,0 +1,157 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const progressMaxErrors = 5
+
+// runProgress tracks what a download run is doing, it's dumped on SIGUSR1 to diagnose long runs.
+type runProgress struct {
+	lock       sync.Mutex
+	started    time.Time
+	workers    map[int]*workerProgress
+	downloaded int
+	skipped    int
+	failed     int
+	bytes      int64
+	errors     []string
+	failures   []*runFailure
+}
+
+type runFailure struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+	Attempts int    `json:"attempts"`
+}
+
+type workerProgress struct {
+	asset   string
+	size    int
+	started time.Time
+}
+
+func newRunProgress() *runProgress {
+	return &runProgress{started: time.Now(), workers: map[int]*workerProgress{}}
+}
+
+// Start records the worker begins the photo.
+func (r *runProgress) Start(threadIndex int, photo *icloudgo.PhotoAsset) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.workers[threadIndex] = &workerProgress{asset: photo.Filename(), size: photo.Size(), started: time.Now()}
+}
+
+// Done records the worker finished the photo.
+func (r *runProgress) Done(threadIndex int, photo *icloudgo.PhotoAsset, skipped bool, err error) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.workers, threadIndex)
+	switch {
+	case err != nil:
+		r.failed++
+		r.failures = append(r.failures, &runFailure{ID: photo.ID(), Filename: photo.Filename(), Error: err.Error(), Attempts: attemptsOf(err)})
+		r.errors = append(r.errors, fmt.Sprintf("%s %s: %s", time.Now().Format(time.RFC3339), photo.Filename(), err))
+		if len(r.errors) > progressMaxErrors {
+			r.errors = r.errors[len(r.errors)-progressMaxErrors:]
+		}
+	case skipped:
+		r.skipped++
+	default:
+		r.downloaded++
+		r.bytes += int64(photo.Size())
+	}
+}
+
+// progressSnapshot is the progress of a run at a point in time.
+type progressSnapshot struct {
+	Started    time.Time
+	Downloaded int
+	Skipped    int
+	Failed     int
+	Bytes      int64
+	// Current is the photos the workers are on, by thread
+	Current []string
+}
+
+// Snapshot returns the current progress.
+func (r *runProgress) Snapshot() progressSnapshot {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	snapshot := progressSnapshot{Started: r.started, Downloaded: r.downloaded, Skipped: r.skipped, Failed: r.failed, Bytes: r.bytes}
+	threads := make([]int, 0, len(r.workers))
+	for threadIndex := range r.workers {
+		threads = append(threads, threadIndex)
+	}
+	sort.Ints(threads)
+	for _, threadIndex := range threads {
+		snapshot.Current = append(snapshot.Current, r.workers[threadIndex].asset)
+	}
+	return snapshot
+}
+
+// Dump writes the current progress to w.
+func (r *runProgress) Dump(w io.Writer) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	elapsed := time.Since(r.started)
+	throughput := float64(r.bytes) / elapsed.Seconds()
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("progress: elapsed %s, downloaded %d (%s, %s/s), skipped %d, failed %d\n",
+		elapsed.Round(time.Second), r.downloaded, icloudgo.FormatSize(int(r.bytes)), icloudgo.FormatSize(int(throughput)), r.skipped, r.failed))
+
+	threads := make([]int, 0, len(r.workers))
+	for threadIndex := range r.workers {
+		threads = append(threads, threadIndex)
+	}
+	sort.Ints(threads)
+	for _, threadIndex := range threads {
+		worker := r.workers[threadIndex]
+		sb.WriteString(fmt.Sprintf("  thread=%d: %s, %s, for %s\n",
+			threadIndex, worker.asset, icloudgo.FormatSize(worker.size), time.Since(worker.started).Round(time.Second)))
+	}
+	for _, err := range r.errors {
+		sb.WriteString(fmt.Sprintf("  error: %s\n", err))
+	}
+	_, _ = io.WriteString(w, sb.String())
+}
+
+// dumpProgressOnSignal dumps the progress to stderr every time the process gets the progress signal,
+// until the returned func is called.
+func dumpProgressOnSignal(progress *runProgress) func() {
+	signals, stop := notifyProgressSignal()
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				progress.Dump(os.Stderr)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		stop()
+		close(done)
+	}
+}
@@ -0,0 +1,208 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const (
+	progressBarInterval = 200 * time.Millisecond
+	progressBarWidth    = 30
+	progressBarNameMax  = 32
+)
+
+// progressBar draws the progress of a download run on one line of stderr, redrawn in place,
+// it replaces the lines printed for each photo, see --progress.
+type progressBar struct {
+	lock     sync.Mutex
+	w        io.Writer
+	run      *runProgress
+	total    int
+	filtered map[string]int
+	inflight map[string]icloudgo.DownloadProgress
+	width    int
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+// newProgressBar returns the bar of --progress, nil when the lines of each photo are printed instead.
+func newProgressBar(mode string, run *runProgress) (*progressBar, error) {
+	switch mode {
+	case "lines":
+		return nil, nil
+	case "auto", "":
+		if !isTerminal(os.Stderr) {
+			return nil, nil
+		}
+	case "bar":
+	default:
+		return nil, fmt.Errorf("invalid --progress %q, want auto, bar or lines", mode)
+	}
+	return &progressBar{
+		w:        os.Stderr,
+		run:      run,
+		filtered: map[string]int{},
+		inflight: map[string]icloudgo.DownloadProgress{},
+	}, nil
+}
+
+// isTerminal reports whether f is a terminal, and not a pipe or a file.
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// apply feeds the bar with the progress of the downloads and of the album iterators of the run.
+func (r *progressBar) apply(option *downloadOption) {
+	if r == nil {
+		return
+	}
+	option.ctx = icloudgo.WithDownloadProgress(option.ctx, r.downloadProgress)
+	option.iterOption.OnProgress = r.iterProgress
+}
+
+func (r *progressBar) downloadProgress(progress icloudgo.DownloadProgress) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if progress.Done {
+		delete(r.inflight, progress.Target)
+	} else {
+		r.inflight[progress.Target] = progress
+	}
+}
+
+func (r *progressBar) iterProgress(progress icloudgo.IterProgress) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.filtered[progress.Album] = progress.Scanned - progress.Yielded
+}
+
+// AddTotal adds the photos of an album to the photos the run goes over.
+func (r *progressBar) AddTotal(n int) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.total += n
+}
+
+// Start redraws the bar until Stop.
+func (r *progressBar) Start() {
+	if r == nil {
+		return
+	}
+	r.stop, r.stopped = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(r.stopped)
+		ticker := time.NewTicker(progressBarInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.draw()
+			case <-r.stop:
+				r.draw()
+				r.lock.Lock()
+				_, _ = io.WriteString(r.w, "\n")
+				r.width = 0
+				r.lock.Unlock()
+				return
+			}
+		}
+	}()
+}
+
+// Stop draws the bar a last time, and leaves it on its own line.
+func (r *progressBar) Stop() {
+	if r == nil || r.stop == nil {
+		return
+	}
+	close(r.stop)
+	<-r.stopped
+	r.stop = nil
+}
+
+// Printf prints a line to stdout above the bar, nil prints it as is.
+func (r *progressBar) Printf(format string, args ...any) {
+	if r == nil {
+		fmt.Printf(format, args...)
+		return
+	}
+	r.lock.Lock()
+	r.clear()
+	fmt.Printf(format, args...)
+	r.lock.Unlock()
+	r.draw()
+}
+
+// Logf prints a line about a single photo, which the bar replaces, nil prints it as is.
+func (r *progressBar) Logf(format string, args ...any) {
+	if r == nil {
+		fmt.Printf(format, args...)
+	}
+}
+
+func (r *progressBar) clear() {
+	if r.width > 0 {
+		_, _ = io.WriteString(r.w, "\r"+strings.Repeat(" ", r.width)+"\r")
+		r.width = 0
+	}
+}
+
+func (r *progressBar) draw() {
+	snapshot := r.run.Snapshot()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	done := snapshot.Downloaded + snapshot.Skipped + snapshot.Failed
+	for _, filtered := range r.filtered {
+		done += filtered
+	}
+	bytes := snapshot.Bytes
+	for _, progress := range r.inflight {
+		bytes += progress.Written - progress.Resumed
+	}
+	elapsed := time.Since(snapshot.Started)
+
+	var ratio float64
+	if r.total > 0 {
+		ratio = float64(done) / float64(r.total)
+		if ratio > 1 {
+			ratio = 1
+		}
+	}
+	filled := int(ratio * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	line := fmt.Sprintf("[%s] %d/%d %3.0f%% %s %s/s", bar, done, r.total, ratio*100,
+		icloudgo.FormatSize(int(bytes)), icloudgo.FormatSize(int(float64(bytes)/elapsed.Seconds())))
+	if done > 0 && r.total > done {
+		eta := time.Duration(float64(elapsed) / float64(done) * float64(r.total-done))
+		line += fmt.Sprintf(" ETA %s", eta.Round(time.Second))
+	}
+	if snapshot.Failed > 0 {
+		line += fmt.Sprintf(" failed %d", snapshot.Failed)
+	}
+	if len(snapshot.Current) > 0 {
+		name := snapshot.Current[0]
+		if len(name) > progressBarNameMax {
+			name = name[:progressBarNameMax-3] + "..."
+		}
+		line += " " + name
+	}
+
+	padding := ""
+	if len(line) < r.width {
+		padding = strings.Repeat(" ", r.width-len(line))
+	}
+	_, _ = io.WriteString(r.w, "\r"+line+padding)
+	r.width = len(line)
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package command
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyProgressSignal subscribes to SIGUSR1, like `kill -USR1 <pid>`.
+func notifyProgressSignal() (<-chan os.Signal, func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	return signals, func() { signal.Stop(signals) }
+}
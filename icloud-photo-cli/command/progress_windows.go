@@ -0,0 +1,12 @@
+//go:build windows
+
+package command
+
+import (
+	"os"
+)
+
+// notifyProgressSignal never fires, windows has no SIGUSR1.
+func notifyProgressSignal() (<-chan os.Signal, func()) {
+	return make(chan os.Signal), func() {}
+}
@@ -0,0 +1,159 @@
+package command
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// secretFlags are never written to the run report.
+var secretFlags = map[string]bool{
+	"password":           true,
+	"2fa-code":           true,
+	"immich-api-key":     true,
+	"nextcloud-password": true,
+	"webdav-password":    true,
+}
+
+// runReport is the JSON report of a run written to --report,
+// with the config used, counts, failures and how long each stage took.
+type runReport struct {
+	path   string
+	lock   sync.Mutex
+	stages map[string]time.Duration
+
+	Command  string            `json:"command"`
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+	Duration string            `json:"duration"`
+	Config   map[string]any    `json:"config"`
+	Counts   reportCounts      `json:"counts"`
+	Failures []*runFailure     `json:"failures"`
+	Drift    []*albumDrift     `json:"drift,omitempty"`
+	Warnings []string          `json:"warnings,omitempty"`
+	Timings  map[string]string `json:"timings"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type reportCounts struct {
+	Downloaded int   `json:"downloaded"`
+	Skipped    int   `json:"skipped"`
+	Failed     int   `json:"failed"`
+	Bytes      int64 `json:"bytes"`
+}
+
+func newRunReport(c *cli.Context, path string) *runReport {
+	if path == "" {
+		return nil
+	}
+	r := &runReport{
+		path:     path,
+		stages:   map[string]time.Duration{},
+		Command:  c.Command.Name,
+		Started:  time.Now(),
+		Config:   map[string]any{},
+		Failures: []*runFailure{},
+		Timings:  map[string]string{},
+	}
+	for _, flag := range c.Command.Flags {
+		name := flag.Names()[0]
+		if secretFlags[name] || !c.IsSet(name) {
+			continue
+		}
+		// a webdav:// url can carry the password
+		switch name {
+		case "output-backend":
+			r.Config[name] = stripURLUserinfo(c.String(name))
+			continue
+		case "mirror":
+			var mirrors []string
+			for _, mirror := range c.StringSlice(name) {
+				if isBackendURL(mirror) {
+					mirror = stripURLUserinfo(mirror)
+				}
+				mirrors = append(mirrors, mirror)
+			}
+			r.Config[name] = mirrors
+			continue
+		}
+		r.Config[name] = c.Value(name)
+	}
+	return r
+}
+
+// stripURLUserinfo returns rawURL without the user and password.
+func stripURLUserinfo(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	return u.String()
+}
+
+// Stage records the stage took since start, like report.Stage("auth", start).
+func (r *runReport) Stage(name string, start time.Time) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stages[name] += time.Since(start)
+}
+
+// AddDrift records an album whose full pass doesn't match the album size.
+func (r *runReport) AddDrift(drift *albumDrift) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Drift = append(r.Drift, drift)
+}
+
+// AddWarning records a warning of the run, like a full iCloud storage.
+func (r *runReport) AddWarning(warning string) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Warnings = append(r.Warnings, warning)
+}
+
+// Write writes the report with the counts of progress and the error the run ended with.
+func (r *runReport) Write(progress *runProgress, err error) error {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.Finished = time.Now()
+	r.Duration = r.Finished.Sub(r.Started).Round(time.Millisecond).String()
+	for name, d := range r.stages {
+		r.Timings[name] = d.Round(time.Millisecond).String()
+	}
+	if progress != nil {
+		progress.lock.Lock()
+		r.Counts = reportCounts{Downloaded: progress.downloaded, Skipped: progress.skipped, Failed: progress.failed, Bytes: progress.bytes}
+		r.Failures = append(r.Failures, progress.failures...)
+		progress.lock.Unlock()
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	bs, _ := json.MarshalIndent(r, "", "  ")
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(r.path, bs, 0o644)
+}
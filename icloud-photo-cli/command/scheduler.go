@@ -0,0 +1,62 @@
+package command
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// albumJob is the download of one album, it's done once it reaches --recent or --stop-found-num,
+// or its iterator is exhausted.
+type albumJob struct {
+	album      *icloudgo.PhotoAlbum
+	iter       icloudgo.PhotosIterNext
+	recent     int32
+	stopNum    int64
+	downloaded int32
+	found      int64
+	iterated   int64
+	done       int32 // the iterator is exhausted, the photos already queued are still downloaded
+}
+
+// isDone reports whether the job reached --recent or --stop-found-num, the photos queued since are skipped.
+func (r *albumJob) isDone() bool {
+	return atomic.LoadInt32(&r.downloaded) >= r.recent ||
+		atomic.LoadInt64(&r.found) >= r.stopNum
+}
+
+// albumQueue hands the albums of a run out to the workers round-robin,
+// so small albums are not starved by large ones, and all workers stay busy until every album is done.
+type albumQueue struct {
+	lock  sync.Mutex
+	jobs  []*albumJob
+	index int
+}
+
+func newAlbumQueue(jobs []*albumJob) *albumQueue {
+	return &albumQueue{jobs: jobs}
+}
+
+// Next returns the next album job which is not done, nil when all jobs are done.
+func (r *albumQueue) Next() *albumJob {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for len(r.jobs) > 0 {
+		r.index %= len(r.jobs)
+		job := r.jobs[r.index]
+		if atomic.LoadInt32(&job.done) == 1 || job.isDone() {
+			r.jobs = append(r.jobs[:r.index], r.jobs[r.index+1:]...)
+			continue
+		}
+		r.index++
+		return job
+	}
+	return nil
+}
+
+// Finish marks the job as done, like when its iterator is exhausted.
+func (r *albumQueue) Finish(job *albumJob) {
+	atomic.StoreInt32(&job.done, 1)
+}
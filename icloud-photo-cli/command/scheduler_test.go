@@ -0,0 +1,47 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// The photos queued when the iterator of their album is exhausted are still downloaded.
+func TestAlbumJobFinish(t *testing.T) {
+	job := &albumJob{recent: 10, stopNum: 10}
+	queue := newAlbumQueue([]*albumJob{job})
+	if queue.Next() != job {
+		t.Fatal("the job is not handed out")
+	}
+	queue.Finish(job)
+	if job.isDone() {
+		t.Error("the photos queued before the end of the iterator are skipped")
+	}
+	if queue.Next() != nil {
+		t.Error("the job is handed out after the end of its iterator")
+	}
+
+	job.found = 10
+	if !job.isDone() {
+		t.Error("the job is not done at --stop-found-num")
+	}
+}
+
+func TestDownloadToTheLastPhoto(t *testing.T) {
+	server := newMockServer(t)
+	assets := []*icloudmock.Asset{
+		server.AddAsset(&icloudmock.Asset{Data: []byte("first")}),
+		server.AddAsset(&icloudmock.Asset{Data: []byte("last")}),
+	}
+	outputDir := t.TempDir()
+	if err := runDownload(t, t.TempDir(), outputDir, "--thread-num", "1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, asset := range assets {
+		if _, err := os.Stat(filepath.Join(outputDir, asset.Filename)); err != nil {
+			t.Errorf("%s not downloaded: %s", asset.Filename, err)
+		}
+	}
+}
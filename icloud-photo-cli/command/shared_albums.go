@@ -0,0 +1,110 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// sharedAlbumsDir is the dir of the output dir --shared-albums writes to, one sub dir per shared album.
+const sharedAlbumsDir = "Shared Albums"
+
+// sharedMetadataSidecar is the <filename>.json written next to each shared asset with --write-metadata.
+type sharedMetadataSidecar struct {
+	ID          string                   `json:"id"`
+	Album       string                   `json:"album"`
+	Owner       string                   `json:"owner"`
+	Contributor string                   `json:"contributor"`
+	Created     time.Time                `json:"created"`
+	Caption     string                   `json:"caption,omitempty"`
+	Comments    []*sharedMetadataComment `json:"comments"`
+}
+
+type sharedMetadataComment struct {
+	Author  string    `json:"author"`
+	Text    string    `json:"text"`
+	Created time.Time `json:"created"`
+}
+
+// downloadSharedAlbums downloads the shared albums of the account with --shared-albums,
+// assets already in the output dir are skipped.
+func downloadSharedAlbums(photoCli *icloudgo.PhotoService, option *downloadOption) error {
+	albums, err := photoCli.SharedAlbumsContext(option.ctx)
+	if err != nil {
+		return err
+	}
+	for _, album := range albums {
+		assets, err := album.AssetsContext(option.ctx)
+		if err != nil {
+			return err
+		}
+		outputDir := filepath.Join(option.output, sharedAlbumsDir, sharedAlbumDirname(album.Name))
+		fmt.Printf("shared album: %s, owner: %s, members: %d, total: %d, target: %s\n", album.Name, album.OwnerName, len(album.Members), len(assets), outputDir)
+
+		for _, asset := range assets {
+			if err := option.ctx.Err(); err != nil {
+				return err
+			}
+			if err := option.failureBudget.Record(downloadSharedAsset(asset, outputDir, option)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func downloadSharedAsset(asset *icloudgo.SharedAsset, outputDir string, option *downloadOption) error {
+	path := asset.LocalPath(outputDir)
+	if _, err := option.storage.Stat(path); err == nil {
+		fmt.Printf("file '%s' exist, skip.\n", path)
+	} else {
+		fmt.Printf("start %v, %v, %v\n", asset.ID, asset.Filename(), icloudgo.FormatSize(asset.Size()))
+		if err := asset.DownloadToStorageContext(option.ctx, option.storage, path); err != nil {
+			return err
+		}
+	}
+	if option.writeMetadata {
+		return writeSharedMetadataSidecar(asset, path, option)
+	}
+	return nil
+}
+
+func writeSharedMetadataSidecar(asset *icloudgo.SharedAsset, path string, option *downloadOption) error {
+	comments, err := asset.CommentsContext(option.ctx)
+	if err != nil {
+		return err
+	}
+	sidecar := &sharedMetadataSidecar{
+		ID:          asset.ID,
+		Album:       asset.Album().Name,
+		Owner:       asset.Album().OwnerName,
+		Contributor: asset.ContributorName,
+		Created:     asset.Created.UTC(),
+		Caption:     asset.Caption,
+		Comments:    []*sharedMetadataComment{},
+	}
+	for _, comment := range comments {
+		sidecar.Comments = append(sidecar.Comments, &sharedMetadataComment{
+			Author:  comment.AuthorName,
+			Text:    comment.Text,
+			Created: comment.Created.UTC(),
+		})
+	}
+
+	bs, _ := json.MarshalIndent(sidecar, "", "  ")
+	return os.WriteFile(metadataSidecarPath(path), bs, 0o644)
+}
+
+// sharedAlbumDirname returns the dir name of a shared album, a name can't escape the shared albums dir.
+func sharedAlbumDirname(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		name = "_" + name
+	}
+	return name
+}
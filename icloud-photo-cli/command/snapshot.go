@@ -0,0 +1,118 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const (
+	// snapshotLayout names the dirs of --snapshot, they sort by date
+	snapshotLayout = "2006-01-02T150405"
+	// snapshotLatest is the symlink to the last complete snapshot
+	snapshotLatest = "latest"
+)
+
+// snapshot is the dated dir a --snapshot run downloads to, inside the output dir.
+// Files already in a previous snapshot are hardlinked from it, like rsync --link-dest,
+// so every snapshot is a full view of the library, and unchanged files take no extra space.
+type snapshot struct {
+	dir string
+	// previous are the dirs of the previous snapshots, the newest first
+	previous []string
+
+	lock       sync.Mutex
+	linked     int
+	linkedSize int64
+}
+
+// newSnapshot creates the snapshot dir of now in outputDir.
+func newSnapshot(outputDir string, now time.Time) (*snapshot, error) {
+	name := now.Format(snapshotLayout)
+	entries, err := os.ReadDir(outputDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var previous []string
+	for _, entry := range entries {
+		if _, err := time.Parse(snapshotLayout, entry.Name()); err == nil && entry.IsDir() && entry.Name() < name {
+			previous = append(previous, filepath.Join(outputDir, entry.Name()))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(previous)))
+
+	r := &snapshot{dir: filepath.Join(outputDir, name), previous: previous}
+	if err := os.MkdirAll(r.dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// link hardlinks the file at path of the snapshot from the newest previous snapshot having it,
+// with size bytes, any size when size is 0, and reports whether it did.
+// It does nothing when path exists, and when hardlinks fail, like across filesystems, the file is downloaded instead.
+func (r *snapshot) link(path string, size int64) bool {
+	if r == nil {
+		return false
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return false
+	}
+	rel, err := filepath.Rel(r.dir, path)
+	if err != nil {
+		return false
+	}
+	for _, previous := range r.previous {
+		src := filepath.Join(previous, rel)
+		f, err := os.Stat(src)
+		if err != nil || !f.Mode().IsRegular() || (size > 0 && f.Size() != size) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return false
+		}
+		if err := os.Link(src, path); err != nil {
+			return false
+		}
+		r.lock.Lock()
+		r.linked++
+		r.linkedSize += f.Size()
+		r.lock.Unlock()
+		return true
+	}
+	return false
+}
+
+// Finish points the latest symlink of the output dir to the snapshot, once the run completed.
+func (r *snapshot) Finish() error {
+	if r == nil {
+		return nil
+	}
+	latest := filepath.Join(filepath.Dir(r.dir), snapshotLatest)
+	if f, err := os.Lstat(latest); err == nil {
+		if f.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("%s is not a symlink, leave it to --snapshot", latest)
+		}
+		if err := os.Remove(latest); err != nil {
+			return err
+		}
+	}
+	if err := os.Symlink(filepath.Base(r.dir), latest); err != nil {
+		return fmt.Errorf("link %s to the snapshot failed: %w", latest, err)
+	}
+	return nil
+}
+
+func (r *snapshot) String() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.previous) == 0 {
+		return fmt.Sprintf("snapshot %s, the first one", r.dir)
+	}
+	return fmt.Sprintf("snapshot %s, %d unchanged files, %s, linked from the previous snapshots", r.dir, r.linked, icloudgo.FormatSize(int(r.linkedSize)))
+}
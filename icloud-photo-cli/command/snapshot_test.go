@@ -0,0 +1,65 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// The photos of the previous snapshot hardlinked into the new one are found photos,
+// they must not stop the run at --stop-found-num, or the new snapshot would miss the older photos.
+func TestSnapshotOfTheWholeLibrary(t *testing.T) {
+	server := newMockServer(t)
+	for i := 0; i < 60; i++ {
+		server.AddAsset(&icloudmock.Asset{Data: []byte{byte(i), 1, 2, 3}})
+	}
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+
+	if err := runDownload(t, cookieDir, outputDir, "--snapshot"); err != nil {
+		t.Fatal(err)
+	}
+	// the first snapshot is moved back in time, so the next run in the same second gets its own dir
+	first := snapshotDirs(t, outputDir)
+	if len(first) != 1 {
+		t.Fatalf("snapshots %v, want one", first)
+	}
+	previous := filepath.Join(outputDir, "2000-01-01T000000")
+	if err := os.Rename(first[0], previous); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runDownload(t, cookieDir, outputDir, "--snapshot"); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range snapshotDirs(t, outputDir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 60 {
+			t.Errorf("snapshot %s has %d photos, want 60", filepath.Base(dir), len(entries))
+		}
+	}
+
+	if err := runDownload(t, cookieDir, outputDir, "--snapshot", "--stop-found-num", "10"); err == nil {
+		t.Errorf("--snapshot with --stop-found-num accepted")
+	}
+}
+
+// snapshotDirs returns the snapshot dirs of the output dir.
+func snapshotDirs(t *testing.T, outputDir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name()[0] != '.' {
+			res = append(res, filepath.Join(outputDir, entry.Name()))
+		}
+	}
+	return res
+}
@@ -0,0 +1,249 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const stateDBFilename = ".icloudgo.db"
+
+// stateDB is the local state database of an output dir, a key value store of buckets,
+// like the owner of the output dir, the albums synced, the checksum index, and the assets of --incremental.
+//
+// The changes are appended to a log, so a run writes what it changed, not the whole state,
+// a torn last line of a crashed run is ignored, and the log is compacted on open once it's mostly overwritten records.
+type stateDB struct {
+	path    string
+	lock    sync.Mutex
+	buckets map[string]map[string]json.RawMessage
+	records int // records in the log, the live ones and the overwritten ones
+	f       *os.File
+	w       *bufio.Writer
+}
+
+// stateDBRecord is a line of the log, a record without value deletes the key.
+type stateDBRecord struct {
+	Bucket string          `json:"b"`
+	Key    string          `json:"k"`
+	Value  json.RawMessage `json:"v,omitempty"`
+}
+
+// openStateDB opens the state database of dir, it's created on the first write.
+func openStateDB(dir string) (*stateDB, error) {
+	r := &stateDB{
+		path:    filepath.Join(dir, stateDBFilename),
+		buckets: map[string]map[string]json.RawMessage{},
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	if live := r.live(); r.records > 2*live+1000 {
+		if err := r.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *stateDB) load() error {
+	f, err := os.Open(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// a record is written with its newline, a line without it was torn by a crash, the next records go after it
+				return os.Truncate(r.path, offset)
+			}
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("read %s failed: %w", r.path, err)
+		}
+		record := new(stateDBRecord)
+		if err := json.Unmarshal(line, record); err != nil {
+			return fmt.Errorf("parse %s failed: %w", r.path, err)
+		}
+		r.apply(record)
+		r.records++
+		offset += int64(len(line))
+	}
+}
+
+func (r *stateDB) apply(record *stateDBRecord) {
+	bucket := r.buckets[record.Bucket]
+	if len(record.Value) == 0 {
+		delete(bucket, record.Key)
+		return
+	}
+	if bucket == nil {
+		bucket = map[string]json.RawMessage{}
+		r.buckets[record.Bucket] = bucket
+	}
+	bucket[record.Key] = record.Value
+}
+
+func (r *stateDB) live() int {
+	res := 0
+	for _, bucket := range r.buckets {
+		res += len(bucket)
+	}
+	return res
+}
+
+// compact rewrites the log with the live records only, the new log replaces the old one at once.
+func (r *stateDB) compact() error {
+	tmp := r.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	records := 0
+	for name, bucket := range r.buckets {
+		for key, value := range bucket {
+			if err := writeStateDBRecord(w, &stateDBRecord{Bucket: name, Key: key, Value: value}); err != nil {
+				f.Close()
+				return err
+			}
+			records++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return err
+	}
+	r.records = records
+	return nil
+}
+
+// Get decodes the value of key in bucket into v, false when there is no such key.
+func (r *stateDB) Get(bucket, key string, v any) (bool, error) {
+	r.lock.Lock()
+	value, ok := r.buckets[bucket][key]
+	r.lock.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		return false, fmt.Errorf("parse %s/%s of %s failed: %w", bucket, key, r.path, err)
+	}
+	return true, nil
+}
+
+// Keys returns the keys of bucket.
+func (r *stateDB) Keys(bucket string) []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	res := make([]string, 0, len(r.buckets[bucket]))
+	for key := range r.buckets[bucket] {
+		res = append(res, key)
+	}
+	return res
+}
+
+// Put sets the value of key in bucket, it's on disk after the next Sync.
+func (r *stateDB) Put(bucket, key string, v any) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if old, ok := r.buckets[bucket][key]; ok && bytes.Equal(old, value) {
+		return nil
+	}
+	return r.append(&stateDBRecord{Bucket: bucket, Key: key, Value: value})
+}
+
+// Delete removes key from bucket, it's on disk after the next Sync.
+func (r *stateDB) Delete(bucket, key string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.buckets[bucket][key]; !ok {
+		return nil
+	}
+	return r.append(&stateDBRecord{Bucket: bucket, Key: key})
+}
+
+func (r *stateDB) append(record *stateDBRecord) error {
+	if r.w == nil {
+		f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		r.f, r.w = f, bufio.NewWriter(f)
+	}
+	if err := writeStateDBRecord(r.w, record); err != nil {
+		return err
+	}
+	r.apply(record)
+	r.records++
+	return nil
+}
+
+// Sync writes the changes to disk.
+func (r *stateDB) Sync() error {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.w == nil {
+		return nil
+	}
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	return r.f.Sync()
+}
+
+// Close writes the changes to disk, and closes the log.
+func (r *stateDB) Close() error {
+	if r == nil {
+		return nil
+	}
+	err := r.Sync()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.f != nil {
+		if closeErr := r.f.Close(); err == nil {
+			err = closeErr
+		}
+		r.f, r.w = nil, nil
+	}
+	return err
+}
+
+func writeStateDBRecord(w *bufio.Writer, record *stateDBRecord) error {
+	bs, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(bs, '\n'))
+	return err
+}
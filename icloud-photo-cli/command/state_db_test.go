@@ -0,0 +1,97 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateDB(t *testing.T) {
+	dir := t.TempDir()
+	db, err := openStateDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := db.Put("bucket", key, key+"1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Put("bucket", "a", "a2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete("bucket", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash while a record is written leaves a torn line
+	path := filepath.Join(dir, stateDBFilename)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"b":"bucket","k":"d","v":`)
+	f.Close()
+
+	db, err = openStateDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put("bucket", "e", "e1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = openStateDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	want := map[string]string{"a": "a2", "c": "c1", "e": "e1"}
+	if keys := db.Keys("bucket"); len(keys) != len(want) {
+		t.Errorf("keys %v, want the ones of %v", keys, want)
+	}
+	for key, value := range want {
+		var got string
+		if ok, err := db.Get("bucket", key, &got); !ok || err != nil || got != value {
+			t.Errorf("get %s: %q %v %v, want %q", key, got, ok, err, value)
+		}
+	}
+	if ok, _ := db.Get("bucket", "b", new(string)); ok {
+		t.Errorf("deleted key b found")
+	}
+}
+
+func TestStateDBCompact(t *testing.T) {
+	dir := t.TempDir()
+	db, err := openStateDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3000; i++ {
+		if err := db.Put("bucket", "key", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = openStateDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if db.records != 1 {
+		t.Errorf("%d records after the compaction, want 1", db.records)
+	}
+	var got int
+	if ok, _ := db.Get("bucket", "key", &got); !ok || got != 2999 {
+		t.Errorf("get key: %d, want 2999", got)
+	}
+}
@@ -0,0 +1,22 @@
+package command
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// warnStorageFull warns when the iCloud storage of the account is full, the devices stop uploading then,
+// so the library downloaded can miss their newest photos. It returns the warning, empty when there is none.
+func warnStorageFull(ctx context.Context, cli *icloudgo.Client) string {
+	status, err := cli.StorageStatusContext(ctx)
+	if err != nil || !status.Full {
+		// a best effort check, the download doesn't depend on it
+		return ""
+	}
+	warning := fmt.Sprintf("iCloud storage is full (%s of %s used), the devices stopped uploading to iCloud Photos, "+
+		"the photos downloaded may miss the newest photos of the devices", icloudgo.FormatSize(int(status.Used)), icloudgo.FormatSize(int(status.Total)))
+	fmt.Printf("warning: %s\n", warning)
+	return warning
+}
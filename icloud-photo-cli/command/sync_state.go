@@ -0,0 +1,190 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// the buckets of the state database of --incremental
+const (
+	syncBucket       = "sync"
+	syncAssetsBucket = "sync_assets"
+	syncTokenKey     = "token"
+)
+
+// syncState is the state of --incremental in the state database, it keeps the sync token of the last full pass,
+// and the checksum and modification date of each downloaded asset,
+// so the next run only fetches the assets changed since.
+type syncState struct {
+	db        *stateDB
+	service   changesLister
+	lock      sync.Mutex
+	nextToken string
+	changed   int
+	deleted   int
+}
+
+type syncStateEntry struct {
+	Checksum string    `json:"checksum"`
+	Modified time.Time `json:"modified"`
+}
+
+// changesLister lists the asset changes of a library, it's implemented by *icloudgo.PhotoService.
+type changesLister interface {
+	SyncChangesContext(ctx context.Context, syncToken string) (*icloudgo.SyncChanges, error)
+}
+
+// loadSyncState loads the state of --incremental of the state database, it returns nil without --incremental.
+func loadSyncState(enabled bool, db *stateDB) (*syncState, error) {
+	if !enabled {
+		return nil, nil
+	}
+	return &syncState{db: db}, nil
+}
+
+// Iter returns the iterator of the assets changed since the last full pass,
+// the whole library on the first run.
+func (r *syncState) Iter(option *downloadOption) icloudgo.AssetIterator {
+	return &syncChangesIter{state: r, option: option, token: r.Token()}
+}
+
+// Token returns the sync token of the last full pass, empty before the first one.
+func (r *syncState) Token() string {
+	var token string
+	_, _ = r.db.Get(syncBucket, syncTokenKey, &token)
+	return token
+}
+
+// Changed reports whether the photo is new, or changed since it was downloaded.
+func (r *syncState) Changed(photo *icloudgo.PhotoAsset) bool {
+	entry := new(syncStateEntry)
+	if ok, err := r.db.Get(syncAssetsBucket, photo.ID(), entry); !ok || err != nil {
+		return true
+	}
+	return entry.Checksum != photo.Checksum() || photo.Modified().After(entry.Modified)
+}
+
+// Pending reports whether the changes have a photo the output dir doesn't have as it is now,
+// or one deleted from the library which it has, so a sync has something to do.
+func (r *syncState) Pending(changes *icloudgo.SyncChanges) (bool, error) {
+	for _, id := range changes.Deleted {
+		if ok, err := r.db.Get(syncAssetsBucket, id, new(syncStateEntry)); ok || err != nil {
+			return ok, err
+		}
+	}
+	for _, asset := range changes.Assets {
+		if r.Changed(asset) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Add records the photo is in the output dir.
+func (r *syncState) Add(photo *icloudgo.PhotoAsset) error {
+	if r == nil {
+		return nil
+	}
+	return r.db.Put(syncAssetsBucket, photo.ID(), &syncStateEntry{Checksum: photo.Checksum(), Modified: photo.Modified()})
+}
+
+func (r *syncState) remove(ids []string) error {
+	for _, id := range ids {
+		if ok, _ := r.db.Get(syncAssetsBucket, id, new(syncStateEntry)); !ok {
+			continue
+		}
+		if err := r.db.Delete(syncAssetsBucket, id); err != nil {
+			return err
+		}
+		r.lock.Lock()
+		r.deleted++
+		r.lock.Unlock()
+	}
+	return nil
+}
+
+// Synced records all the changes are downloaded, the next run starts from them.
+func (r *syncState) Synced() error {
+	if r == nil {
+		return nil
+	}
+	r.lock.Lock()
+	token := r.nextToken
+	r.lock.Unlock()
+	if token == "" {
+		return nil
+	}
+	return r.db.Put(syncBucket, syncTokenKey, token)
+}
+
+// Report returns the changes of this run, like "1200 changed, 3 deleted".
+func (r *syncState) Report() string {
+	if r == nil {
+		return ""
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return fmt.Sprintf("%d changed, %d deleted", r.changed, r.deleted)
+}
+
+// syncChangesIter yields the changed assets the run selects, batch by batch.
+type syncChangesIter struct {
+	state  *syncState
+	option *downloadOption
+	lock   sync.Mutex
+	token  string
+	assets []*icloudgo.PhotoAsset
+	end    bool
+}
+
+func (r *syncChangesIter) Next() (*icloudgo.PhotoAsset, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for len(r.assets) == 0 {
+		if r.end {
+			// all the changes are handed out, the token is saved once they are downloaded
+			r.state.lock.Lock()
+			r.state.nextToken = r.token
+			r.state.lock.Unlock()
+			return nil, icloudgo.ErrPhotosIterateEnd
+		}
+		changes, err := r.state.service.SyncChangesContext(r.option.ctx, r.token)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.state.remove(changes.Deleted); err != nil {
+			return nil, err
+		}
+		for _, asset := range changes.Assets {
+			if r.selects(asset) {
+				r.assets = append(r.assets, asset)
+			}
+		}
+		r.token, r.end = changes.SyncToken, !changes.MoreComing
+	}
+
+	asset := r.assets[0]
+	r.assets = r.assets[1:]
+	return asset, nil
+}
+
+// selects reports whether the run downloads the changed asset, like the All Photos iterator would,
+// --incremental can't be used with the filters, which would skip changes the sync token moves past.
+func (r *syncChangesIter) selects(asset *icloudgo.PhotoAsset) bool {
+	iterOption := r.option.iterOption
+	if asset.IsHidden() && !iterOption.IncludeHidden || asset.IsDeleted() && !iterOption.IncludeRecentlyDeleted {
+		return false
+	}
+	if !r.state.Changed(asset) {
+		return false
+	}
+	r.state.lock.Lock()
+	r.state.changed++
+	r.state.lock.Unlock()
+	return true
+}
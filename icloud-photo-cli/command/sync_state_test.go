@@ -0,0 +1,66 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// An incremental run over photos already downloaded by a full run goes over the whole feed,
+// --stop-found-num would stop it before the end, and the sync token would never be saved.
+func TestIncrementalSavesSyncTokenOverFoundPhotos(t *testing.T) {
+	server := newMockServer(t)
+	server.SetChangesPageSize(20)
+	for i := 0; i < 60; i++ {
+		server.AddAsset(&icloudmock.Asset{Data: []byte{byte(i), 1, 2, 3}})
+	}
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+
+	if err := runDownload(t, cookieDir, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := runDownload(t, cookieDir, outputDir, "--incremental", "--stop-found-num", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if token := syncToken(t, outputDir); token != "seq-60" {
+		t.Fatalf("sync token %q, want seq-60", token)
+	}
+
+	// the next run only asks the changes since
+	server.UpdateAsset("MASTER0001", func(asset *icloudmock.Asset) { asset.Favorite = true })
+	zones := server.Requests("zone")
+	if err := runDownload(t, cookieDir, outputDir, "--incremental", "--stop-found-num", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.Requests("zone") - zones; n != 1 {
+		t.Errorf("%d pages of changes, want 1", n)
+	}
+	if token := syncToken(t, outputDir); token != "seq-61" {
+		t.Errorf("sync token %q, want seq-61", token)
+	}
+}
+
+func syncToken(t *testing.T, outputDir string) string {
+	t.Helper()
+	db, err := openStateDB(outputDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var token string
+	if _, err := db.Get(syncBucket, syncTokenKey, &token); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// The sync token moves past the changes the filters skip, so --incremental refuses them.
+func TestIncrementalRejectsFilters(t *testing.T) {
+	newMockServer(t)
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+	for _, args := range [][]string{{"--filter", "type==video"}, {"--from", "2023-01-01"}, {"--exclude", "IMG_E*"}} {
+		if err := runDownload(t, cookieDir, outputDir, append([]string{"--incremental"}, args...)...); err == nil {
+			t.Errorf("--incremental with %s accepted", args[0])
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const immichDeviceID = "icloudgo"
+
+// immichTarget uploads each downloaded asset straight into an Immich server,
+// instead of writing it to the local output dir.
+type immichTarget struct {
+	endpoint string
+	apiKey   string
+	httpCli  *http.Client
+
+	albumLock sync.Mutex
+	albumIDs  map[string]string // album name -> immich album id
+}
+
+// newImmichTarget parses `immich://host[:port]` (https) or `immich+http://host[:port]`.
+func newImmichTarget(target, apiKey string) (*immichTarget, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %s: %w", target, err)
+	}
+	switch u.Scheme {
+	case "immich":
+		u.Scheme = "https"
+	case "immich+http":
+		u.Scheme = "http"
+	default:
+		return nil, fmt.Errorf("invalid target %s, expect immich://host", target)
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("immich api key is required")
+	}
+	return &immichTarget{
+		endpoint: strings.TrimSuffix(u.String(), "/") + "/api",
+		apiKey:   apiKey,
+		httpCli:  &http.Client{},
+		albumIDs: map[string]string{},
+	}, nil
+}
+
+// Exists reports whether the asset was already uploaded by a previous run.
+func (r *immichTarget) Exists(photo *icloudgo.PhotoAsset) (bool, error) {
+	resp := new(struct {
+		ExistingIDs []string `json:"existingIds"`
+	})
+	err := r.do(http.MethodPost, "/assets/exist", map[string]any{
+		"deviceAssetIds": []string{photo.ID()},
+		"deviceId":       immichDeviceID,
+	}, resp)
+	if err != nil {
+		return false, err
+	}
+	return len(resp.ExistingIDs) > 0, nil
+}
+
+// Upload streams the photo from iCloud into immich, and adds it to albumName when it's not empty.
+func (r *immichTarget) Upload(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, albumName string) error {
+	body, err := photo.Download(version)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		created := photo.Created().UTC().Format(time.RFC3339)
+		fields := map[string]string{
+			"deviceAssetId":  photo.ID(),
+			"deviceId":       immichDeviceID,
+			"fileCreatedAt":  created,
+			"fileModifiedAt": photo.Modified().UTC().Format(time.RFC3339),
+			"isFavorite":     strconv.FormatBool(photo.IsFavorite()),
+		}
+		for k, v := range fields {
+			if err := mw.WriteField(k, v); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		part, err := mw.CreateFormFile("assetData", photo.Filename())
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(part, body); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint+"/assets", pr)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	uploadResp := new(struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	})
+	if err := r.send(req, uploadResp); err != nil {
+		return fmt.Errorf("upload %s to immich failed: %w", photo.Filename(), err)
+	}
+
+	if albumName == "" {
+		return nil
+	}
+	albumID, err := r.getOrCreateAlbum(albumName)
+	if err != nil {
+		return err
+	}
+	return r.do(http.MethodPut, "/albums/"+albumID+"/assets", map[string]any{"ids": []string{uploadResp.ID}}, nil)
+}
+
+func (r *immichTarget) getOrCreateAlbum(name string) (string, error) {
+	r.albumLock.Lock()
+	defer r.albumLock.Unlock()
+
+	if id, ok := r.albumIDs[name]; ok {
+		return id, nil
+	}
+
+	var albums []struct {
+		ID        string `json:"id"`
+		AlbumName string `json:"albumName"`
+	}
+	if err := r.do(http.MethodGet, "/albums", nil, &albums); err != nil {
+		return "", err
+	}
+	for _, album := range albums {
+		if album.AlbumName == name {
+			r.albumIDs[name] = album.ID
+			return album.ID, nil
+		}
+	}
+
+	created := new(struct {
+		ID string `json:"id"`
+	})
+	if err := r.do(http.MethodPost, "/albums", map[string]any{"albumName": name}, created); err != nil {
+		return "", err
+	}
+	r.albumIDs[name] = created.ID
+	return created.ID, nil
+}
+
+func (r *immichTarget) do(method, path string, body, resp any) error {
+	var reader io.Reader
+	if body != nil {
+		bs, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequest(method, r.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return r.send(req, resp)
+}
+
+func (r *immichTarget) send(req *http.Request, resp any) error {
+	req.Header.Set("x-api-key", r.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	res, err := r.httpCli.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", req.Method, req.URL, err)
+	}
+	defer res.Body.Close()
+
+	bs, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", req.Method, req.URL, err)
+	}
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s %s failed, status %d, response text: %s", req.Method, req.URL, res.StatusCode, bs)
+	}
+	if resp != nil && len(bs) > 0 {
+		if err := json.Unmarshal(bs, resp); err != nil {
+			return fmt.Errorf("%s %s unmarshal failed: %w, text: %s", req.Method, req.URL, err, bs)
+		}
+	}
+	return nil
+}
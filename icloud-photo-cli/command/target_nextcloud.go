@@ -0,0 +1,251 @@
+package command
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// nextcloudTarget uploads each downloaded asset into a Nextcloud instance over WebDAV,
+// keeping the same relative path the file would have in the local output dir.
+type nextcloudTarget struct {
+	host     string // https://host
+	user     string
+	password string
+	baseDir  string // dir in the user's files, like /Photos/iCloud
+	tagAlbum bool
+	httpCli  *http.Client
+
+	lock        sync.Mutex
+	createdDirs map[string]bool
+	tagIDs      map[string]string // tag name -> system tag id
+}
+
+// newNextcloudTarget parses `nextcloud://user@host[:port]/dir` (https) or `nextcloud+http://...`.
+func newNextcloudTarget(target, password string, tagAlbum bool) (*nextcloudTarget, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %s: %w", target, err)
+	}
+	switch u.Scheme {
+	case "nextcloud":
+		u.Scheme = "https"
+	case "nextcloud+http":
+		u.Scheme = "http"
+	default:
+		return nil, fmt.Errorf("invalid target %s, expect nextcloud://user@host/dir", target)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("invalid target %s, nextcloud user is required", target)
+	}
+	if password == "" {
+		password, _ = u.User.Password()
+	}
+	if password == "" {
+		return nil, fmt.Errorf("nextcloud password is required")
+	}
+	return &nextcloudTarget{
+		host:        u.Scheme + "://" + u.Host,
+		user:        u.User.Username(),
+		password:    password,
+		baseDir:     path.Clean("/" + u.Path),
+		tagAlbum:    tagAlbum,
+		httpCli:     &http.Client{},
+		createdDirs: map[string]bool{},
+		tagIDs:      map[string]string{},
+	}, nil
+}
+
+func (r *nextcloudTarget) fileURL(p string) string {
+	u := &url.URL{Path: path.Join("/remote.php/dav/files", r.user, r.baseDir, p)}
+	return r.host + u.EscapedPath()
+}
+
+// Exists reports whether the file at relative path rel has the size of the photo.
+func (r *nextcloudTarget) Exists(photo *icloudgo.PhotoAsset, rel string) (bool, error) {
+	req, err := http.NewRequest(http.MethodHead, r.fileURL(rel), nil)
+	if err != nil {
+		return false, err
+	}
+	res, err := r.send(req, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return false, err
+	}
+	return res.StatusCode == http.StatusOK && res.ContentLength == int64(photo.Size()), nil
+}
+
+// Upload streams the photo from iCloud to relative path rel, and tags it with albumName when enabled.
+func (r *nextcloudTarget) Upload(photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, rel, albumName string) error {
+	if err := r.mkdirAll(path.Dir(rel)); err != nil {
+		return err
+	}
+
+	body, err := photo.Download(version)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	req, err := http.NewRequest(http.MethodPut, r.fileURL(rel), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-OC-MTime", fmt.Sprintf("%d", photo.Created().Unix()))
+	if _, err := r.send(req, http.StatusCreated, http.StatusNoContent); err != nil {
+		return fmt.Errorf("upload %s to nextcloud failed: %w", photo.Filename(), err)
+	}
+
+	if r.tagAlbum && albumName != "" {
+		return r.tag(rel, albumName)
+	}
+	return nil
+}
+
+func (r *nextcloudTarget) mkdirAll(dir string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cur := ""
+	for _, name := range strings.Split(path.Clean("/"+dir), "/") {
+		if name == "" {
+			continue
+		}
+		cur = path.Join(cur, name)
+		if r.createdDirs[cur] {
+			continue
+		}
+		req, err := http.NewRequest("MKCOL", r.fileURL(cur), nil)
+		if err != nil {
+			return err
+		}
+		// 405: already exists
+		if _, err := r.send(req, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+			return err
+		}
+		r.createdDirs[cur] = true
+	}
+	return nil
+}
+
+func (r *nextcloudTarget) tag(rel, tagName string) error {
+	fileID, err := r.fileID(rel)
+	if err != nil {
+		return err
+	}
+	tagID, err := r.getOrCreateTag(tagName)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/remote.php/dav/systemtags-relations/files/%s/%s", r.host, fileID, tagID), nil)
+	if err != nil {
+		return err
+	}
+	// 409: already tagged
+	_, err = r.send(req, http.StatusCreated, http.StatusConflict)
+	return err
+}
+
+func (r *nextcloudTarget) fileID(rel string) (string, error) {
+	resp := new(davMultiStatus)
+	if err := r.propfind(r.fileURL(rel), `<d:propfind xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns"><d:prop><oc:fileid/></d:prop></d:propfind>`, "0", resp); err != nil {
+		return "", err
+	}
+	if len(resp.Responses) == 0 || resp.Responses[0].FileID == "" {
+		return "", fmt.Errorf("nextcloud file id of %s not found", rel)
+	}
+	return resp.Responses[0].FileID, nil
+}
+
+func (r *nextcloudTarget) getOrCreateTag(name string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if id, ok := r.tagIDs[name]; ok {
+		return id, nil
+	}
+
+	resp := new(davMultiStatus)
+	if err := r.propfind(r.host+"/remote.php/dav/systemtags/", `<d:propfind xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns"><d:prop><oc:id/><oc:display-name/></d:prop></d:propfind>`, "1", resp); err != nil {
+		return "", err
+	}
+	for _, v := range resp.Responses {
+		if v.DisplayName == name && v.ID != "" {
+			r.tagIDs[name] = v.ID
+			return v.ID, nil
+		}
+	}
+
+	bs := fmt.Sprintf(`{"name":%q,"userVisible":true,"userAssignable":true,"canAssign":true}`, name)
+	req, err := http.NewRequest(http.MethodPost, r.host+"/remote.php/dav/systemtags/", strings.NewReader(bs))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := r.send(req, http.StatusCreated)
+	if err != nil {
+		return "", err
+	}
+	id := path.Base(res.Header.Get("Content-Location"))
+	r.tagIDs[name] = id
+	return id, nil
+}
+
+func (r *nextcloudTarget) propfind(u, body, depth string, resp *davMultiStatus) error {
+	req, err := http.NewRequest("PROPFIND", u, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml")
+	res, err := r.send(req, http.StatusMultiStatus)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(res.body, resp); err != nil {
+		return fmt.Errorf("PROPFIND %s unmarshal failed: %w", u, err)
+	}
+	return nil
+}
+
+type davResponse struct {
+	*http.Response
+	body []byte
+}
+
+func (r *nextcloudTarget) send(req *http.Request, expectStatus ...int) (*davResponse, error) {
+	req.SetBasicAuth(r.user, r.password)
+
+	res, err := r.httpCli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", req.Method, req.URL, err)
+	}
+	defer res.Body.Close()
+
+	bs, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", req.Method, req.URL, err)
+	}
+	for _, status := range expectStatus {
+		if res.StatusCode == status {
+			return &davResponse{Response: res, body: bs}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s failed, expect status %v, but got %d, response text: %s", req.Method, req.URL, expectStatus, res.StatusCode, bs)
+}
+
+type davMultiStatus struct {
+	Responses []struct {
+		Href        string `xml:"href"`
+		FileID      string `xml:"propstat>prop>fileid"`
+		ID          string `xml:"propstat>prop>id"`
+		DisplayName string `xml:"propstat>prop>display-name"`
+	} `xml:"response"`
+}
@@ -0,0 +1,43 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// photoprismDir returns the dir a photo of album is written to in PhotoPrism import layout:
+// one folder per album, which PhotoPrism turns into albums when importing with "create albums from folder names".
+func photoprismDir(outputDir string, album *icloudgo.PhotoAlbum) string {
+	if album.ID() == icloudgo.AlbumIDAll {
+		return outputDir
+	}
+	return filepath.Join(outputDir, album.Path())
+}
+
+// writePhotoprismSidecar writes the PhotoPrism YAML sidecar of the photo downloaded to path,
+// like IMG_0001.HEIC -> IMG_0001.yml, keeping favorite, caption and album.
+func writePhotoprismSidecar(photo *icloudgo.PhotoAsset, album *icloudgo.PhotoAlbum, path string) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("TakenAt: %s\n", photo.Created().UTC().Format(time.RFC3339)))
+	sb.WriteString("TakenSrc: meta\n")
+	if photo.IsFavorite() {
+		sb.WriteString("Favorite: true\n")
+	}
+	if caption := photo.Caption(); caption != "" {
+		sb.WriteString(fmt.Sprintf("Caption: %s\n", strconv.Quote(caption)))
+		sb.WriteString("CaptionSrc: meta\n")
+	}
+	if album.ID() != icloudgo.AlbumIDAll {
+		sb.WriteString("Details:\n")
+		sb.WriteString(fmt.Sprintf("  Keywords: %s\n", strconv.Quote(album.Name)))
+	}
+
+	ext := filepath.Ext(path)
+	return os.WriteFile(path[:len(path)-len(ext)]+".yml", []byte(sb.String()), 0o644)
+}
@@ -0,0 +1,119 @@
+package command
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+const trashDirName = ".trash"
+
+// trashPath returns where the file at path is kept in the local trash of outputDir.
+func trashPath(outputDir, path string) (string, error) {
+	rel, err := filepath.Rel(outputDir, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(outputDir, trashDirName, rel), nil
+}
+
+// trashedCopies returns the copies kept in the local trash at target, a copy trashed while an older one is kept
+// gets a counter suffix, like "IMG_0001_1.JPG", so the newest copy is the last.
+func trashedCopies(target string) []string {
+	var copies []string
+	ext := filepath.Ext(target)
+	base := target[:len(target)-len(ext)]
+	for i, candidate := 0, target; ; i, candidate = i+1, fmt.Sprintf("%s_%d%s", base, i+1, ext) {
+		if f, _ := os.Stat(candidate); f == nil {
+			return copies
+		}
+		copies = append(copies, candidate)
+	}
+}
+
+// moveToTrash moves the file at path into the local trash, the file time is set to now,
+// so the trash retention counts from when the photo was deleted, a copy already in the trash is kept.
+func moveToTrash(outputDir, path string) error {
+	target, err := trashPath(outputDir, path)
+	if err != nil {
+		return err
+	}
+	if copies := trashedCopies(target); len(copies) > 0 {
+		ext := filepath.Ext(target)
+		target = fmt.Sprintf("%s_%d%s", target[:len(target)-len(ext)], len(copies), ext)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(path, target); err != nil {
+		return err
+	}
+	now := time.Now()
+	return os.Chtimes(target, now, now)
+}
+
+// restoreFromTrash moves the newest trashed copy of the photo back to path, like when the photo is restored in iCloud,
+// and reports whether it's restored.
+func restoreFromTrash(outputDir string, photo *icloudgo.PhotoAsset, version icloudgo.PhotoVersion, path string) bool {
+	if f, _ := os.Stat(path); f != nil {
+		return false
+	}
+	target, err := trashPath(outputDir, path)
+	if err != nil {
+		return false
+	}
+	trashed := ""
+	for _, candidate := range trashedCopies(target) {
+		if f, _ := os.Stat(candidate); f != nil && int(f.Size()) == versionSize(photo, version) {
+			trashed = candidate
+		}
+	}
+	if trashed == "" {
+		return false
+	}
+	if err := os.Rename(trashed, path); err != nil {
+		return false
+	}
+	created := photo.Created()
+	_ = os.Chtimes(path, created, created)
+	fmt.Printf("restore %v, %v from trash\n", photo.ID(), photo.Filename())
+	return true
+}
+
+// purgeTrash removes files kept in the local trash for longer than retention, and the empty dirs left.
+func purgeTrash(outputDir string, retention time.Duration) error {
+	root := filepath.Join(outputDir, trashDirName)
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < retention {
+			return nil
+		}
+		fmt.Printf("purge %s from trash\n", path)
+		return os.Remove(path)
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i > 0; i-- {
+		_ = os.Remove(dirs[i]) // only succeeds for empty dirs
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// A photo deleted at the path of a photo already in the trash is kept next to it, and the newest is restored.
+func TestTrashKeepsCopies(t *testing.T) {
+	server := newMockServer(t)
+	cookieDir, outputDir := t.TempDir(), t.TempDir()
+
+	var trashed []string
+	for _, data := range []string{"first", "newer"} {
+		asset := server.AddAsset(&icloudmock.Asset{Filename: "IMG_0001.JPG", Data: []byte(data)})
+		if err := runDownload(t, cookieDir, outputDir); err != nil {
+			t.Fatal(err)
+		}
+		path := downloadedFile(t, outputDir)
+		server.UpdateAsset(asset.ID, func(asset *icloudmock.Asset) { asset.Deleted = true })
+		if err := runDownload(t, cookieDir, outputDir, "--auto-delete", "--trash"); err != nil {
+			t.Fatal(err)
+		}
+		if f, _ := os.Stat(path); f != nil {
+			t.Fatalf("%s not moved to the trash", path)
+		}
+		trashed = append(trashed, asset.ID)
+	}
+	target, err := trashPath(outputDir, filepath.Join(outputDir, "IMG_0001.JPG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copies := trashedCopies(target)
+	if len(copies) != 2 {
+		t.Fatalf("trash keeps %v, want two copies", copies)
+	}
+	assertFile(t, copies[0], []byte("first"))
+	assertFile(t, copies[1], []byte("newer"))
+
+	server.UpdateAsset(trashed[1], func(asset *icloudmock.Asset) { asset.Deleted = false })
+	if err := runDownload(t, cookieDir, outputDir, "--trash"); err != nil {
+		t.Fatal(err)
+	}
+	assertFile(t, downloadedFile(t, outputDir), []byte("newer"))
+	if n := server.Requests("download"); n != 2 {
+		t.Errorf("%d downloads, want the restored photo not downloaded again", n)
+	}
+}
@@ -1,6 +1,19 @@
 package command
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/chyroc/icloudgo"
+)
 
 func getTextInput(tip, defaultValue string) func(string) (string, error) {
 	return func(string2 string) (string, error) {
@@ -13,3 +26,192 @@ func getTextInput(tip, defaultValue string) func(string) (string, error) {
 		return s, err
 	}
 }
+
+// getSecretInput is like getTextInput, but can read the value from file, like a docker secret,
+// and with nonInteractive, it returns errRequired instead of reading stdin.
+func getSecretInput(tip, value, file string, nonInteractive bool, errRequired error) func(string) (string, error) {
+	return func(appleID string) (string, error) {
+		if value == "" && file != "" {
+			bs, err := os.ReadFile(file)
+			if err != nil && !(nonInteractive && os.IsNotExist(err)) {
+				return "", fmt.Errorf("read %s failed, err: %w", file, err)
+			}
+			value = strings.TrimSpace(string(bs))
+		}
+		if value == "" && nonInteractive {
+			return "", errRequired
+		}
+		return getTextInput(tip, value)(appleID)
+	}
+}
+
+// getConsent accepts the consent steps of a sign-in with acceptTerms, asks on stdin otherwise,
+// and with nonInteractive, declines them, so the sign-in fails telling what is needed.
+func getConsent(acceptTerms, nonInteractive bool) icloudgo.ConsentHandler {
+	return func(req *icloudgo.ConsentRequest) (bool, error) {
+		if acceptTerms {
+			return true, nil
+		}
+		if nonInteractive {
+			return false, nil
+		}
+		fmt.Printf("%s: %s\n", req.AppleID, req.Message)
+		if req.URL != "" {
+			fmt.Printf("see %s\n", req.URL)
+		}
+		fmt.Println("Accept? [y/N]")
+		var s string
+		_, _ = fmt.Scanln(&s)
+		return strings.EqualFold(s, "y") || strings.EqualFold(s, "yes"), nil
+	}
+}
+
+// getTrustedDevice picks the trusted phone number or device of --2fa-phone the 2fa code is sent to.
+// Without it, two-factor authentication uses the code pushed to the trusted Apple devices,
+// and two-step authentication, which has no such code, asks on stdin, unless nonInteractive.
+func getTrustedDevice(phone string, nonInteractive bool) icloudgo.TrustedDeviceSelector {
+	return func(req *icloudgo.TwoFactorRequest) (*icloudgo.TrustedDevice, error) {
+		if phone == "" && (req.HSAVersion >= 2 || nonInteractive) {
+			return nil, nil
+		}
+		if phone != "" && phone != "ask" {
+			for _, device := range req.Devices {
+				if device.ID == phone || (device.PhoneNumber != "" && strings.HasSuffix(device.PhoneNumber, phone)) {
+					return device, nil
+				}
+			}
+			return nil, fmt.Errorf("no trusted phone number matches --2fa-phone %s, the trusted ones are: %s", phone, trustedDeviceNames(req.Devices))
+		}
+		if nonInteractive {
+			return nil, fmt.Errorf("--2fa-phone ask can't be used with --non-interactive, the trusted phone numbers are: %s", trustedDeviceNames(req.Devices))
+		}
+
+		for i, device := range req.Devices {
+			fmt.Printf("%d: %s\n", i, device.Name)
+		}
+		fmt.Println("Please input the number of the device to send the 2fa code to")
+		var i int
+		if _, err := fmt.Scanln(&i); err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= len(req.Devices) {
+			return nil, fmt.Errorf("no trusted device %d", i)
+		}
+		return req.Devices[i], nil
+	}
+}
+
+func trustedDeviceNames(devices []*icloudgo.TrustedDevice) string {
+	names := make([]string, 0, len(devices))
+	for _, device := range devices {
+		names = append(names, fmt.Sprintf("%s (id %s)", device.Name, device.ID))
+	}
+	return strings.Join(names, ", ")
+}
+
+// newClientOption builds the client option from the common flags.
+func newClientOption(c *cli.Context) *icloudgo.ClientOption {
+	nonInteractive := c.Bool("non-interactive")
+	return &icloudgo.ClientOption{
+		AppID:           c.String("username"),
+		CookieDir:       c.String("cookie-dir"),
+		PasswordGetter:  getSecretInput("apple id password", c.String("password"), c.String("password-file"), nonInteractive, icloudgo.ErrPasswordRequired),
+		TwoFACodeGetter: getSecretInput("2fa code", c.String("2fa-code"), c.String("2fa-code-file"), nonInteractive, icloudgo.ErrTwoFACodeRequired),
+		ConsentHandler:  getConsent(c.Bool("accept-terms"), nonInteractive),
+
+		TrustedDeviceSelector: getTrustedDevice(c.String("2fa-phone"), nonInteractive),
+		Domain:                c.String("domain"),
+		DownloadRetry:         newDownloadRetryPolicy(c),
+		Logger:                newLogger(c),
+		MaxRequestsPerSecond:  c.Float64("max-rps"),
+	}
+}
+
+// newLogger builds the logger of --log-level and --log-format.
+func newLogger(c *cli.Context) icloudgo.Logger {
+	level, err := icloudgo.ParseLogLevel(c.String("log-level"))
+	if err != nil {
+		level = icloudgo.LogLevelInfo
+	}
+	if c.String("log-format") == "json" {
+		return icloudgo.NewJSONLogger(os.Stderr, level)
+	}
+	return icloudgo.NewTextLogger(os.Stdout, level)
+}
+
+// newDownloadRetryPolicy builds the policy of --download-retries and --download-retry-backoff,
+// nil keeps the default of the client, also for the commands without the flags.
+func newDownloadRetryPolicy(c *cli.Context) *icloudgo.DownloadRetryPolicy {
+	if !c.IsSet("download-retries") && !c.IsSet("download-retry-backoff") {
+		return nil
+	}
+	policy := *icloudgo.DefaultDownloadRetryPolicy
+	if c.IsSet("download-retries") {
+		policy.MaxAttempts = c.Int("download-retries") + 1
+	}
+	if c.IsSet("download-retry-backoff") {
+		policy.Backoff = time.Duration(c.Int("download-retry-backoff")) * time.Second
+	}
+	return &policy
+}
+
+// getPhotoCli returns the photo service of the zone, or of the primary library when zone is empty.
+func getPhotoCli(cli *icloudgo.Client, zone string) (*icloudgo.PhotoService, error) {
+	photoCli, err := cli.PhotoCli()
+	if err != nil || zone == "" {
+		return photoCli, err
+	}
+
+	zones, err := photoCli.Zones()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range zones {
+		if v.Name == zone {
+			return cli.PhotoCliWithZone(v)
+		}
+	}
+	return nil, fmt.Errorf("zone %s not found", zone)
+}
+
+var sizeUnits = map[string]uint64{
+	"":   1,
+	"B":  1,
+	"K":  1 << 10,
+	"KB": 1 << 10,
+	"M":  1 << 20,
+	"MB": 1 << 20,
+	"G":  1 << 30,
+	"GB": 1 << 30,
+	"T":  1 << 40,
+	"TB": 1 << 40,
+}
+
+// parseSize parses a human size like "10G", "500MB" or "1024".
+func parseSize(s string) (uint64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := sizeUnits[strings.TrimSpace(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	num, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return uint64(num * float64(unit)), nil
+}
+
+// signalContext is cancelled on the first SIGINT or SIGTERM, so a run stops its requests and saves its progress,
+// a second signal kills the process as usual.
+func signalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
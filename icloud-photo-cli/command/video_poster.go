@@ -0,0 +1,30 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/chyroc/icloudgo"
+)
+
+// downloadVideoPoster downloads the poster frame of a video next to the video at path, with --video-poster,
+// a poster frame already there is kept.
+func downloadVideoPoster(photo *icloudgo.PhotoAsset, path string, option *downloadOption) error {
+	versions := photo.PosterFrameVersions()
+	if !option.videoPoster || len(versions) == 0 {
+		return nil
+	}
+	target := videoPosterPath(path)
+	option.snapshot.link(target, 0)
+	if f, _ := option.storage.Stat(target); f != nil {
+		return nil
+	}
+	fmt.Printf("download video poster %s\n", target)
+	return photo.DownloadToStorageContext(option.ctx, versions[0].Version, option.storage, target)
+}
+
+// videoPosterPath returns the path of the poster frame of the video at path, like IMG_0001_poster.JPG for IMG_0001.MOV.
+func videoPosterPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "_" + string(icloudgo.PhotoVersionPoster) + ".JPG"
+}
@@ -0,0 +1,115 @@
+package command
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+)
+
+func xmpSidecarPath(path string) string {
+	return path + ".xmp"
+}
+
+// xmpSidecar is the <filename>.xmp written next to each photo by --write-xmp and --write-metadata, read by Lightroom and digiKam.
+//
+// It carries the metadata iCloud keeps out of the file: the caption, the date as adjusted in Photos,
+// the location, and the rating, which is 5 for favorites with the default --rating-map.
+type xmpSidecar struct {
+	Rating   int
+	Keywords []string
+	Caption  string
+	Date     time.Time
+	Location *metadataLocation
+}
+
+// xmpSubjects reads the dc:subject keywords of an existing sidecar.
+type xmpSubjects struct {
+	Subjects []string `xml:"RDF>Description>subject>Bag>li"`
+}
+
+// writeXMPSidecar writes the xmp sidecar of the photo downloaded to path,
+// keywords of the sidecar left by previous runs are kept, so a photo in many albums gets all of them.
+func writeXMPSidecar(photo *icloudgo.PhotoAsset, path string, rating int, keywords []string) error {
+	sidecarPath := xmpSidecarPath(path)
+	sidecar := &xmpSidecar{
+		Rating:   rating,
+		Keywords: append([]string{}, keywords...),
+		Caption:  photo.Caption(),
+		Date:     photo.AssetDateInZone(),
+	}
+	if latitude, longitude, ok := photo.Location(); ok {
+		sidecar.Location = &metadataLocation{Latitude: latitude, Longitude: longitude}
+	}
+	if bs, err := os.ReadFile(sidecarPath); err == nil {
+		old := new(xmpSubjects)
+		if xml.Unmarshal(bs, old) == nil {
+			for _, keyword := range old.Subjects {
+				if !containsString(sidecar.Keywords, keyword) {
+					sidecar.Keywords = append(sidecar.Keywords, keyword)
+				}
+			}
+		}
+	}
+	sort.Strings(sidecar.Keywords)
+
+	return os.WriteFile(sidecarPath, sidecar.Marshal(), 0o644)
+}
+
+func (r *xmpSidecar) Marshal() []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	buf.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	buf.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	buf.WriteString("  <rdf:Description rdf:about=\"\"\n")
+	buf.WriteString("    xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\"\n")
+	buf.WriteString("    xmlns:dc=\"http://purl.org/dc/elements/1.1/\"\n")
+	buf.WriteString("    xmlns:photoshop=\"http://ns.adobe.com/photoshop/1.0/\"\n")
+	buf.WriteString("    xmlns:exif=\"http://ns.adobe.com/exif/1.0/\"\n")
+	if !r.Date.IsZero() {
+		date := r.Date.Format("2006-01-02T15:04:05-07:00")
+		fmt.Fprintf(buf, "    xmp:CreateDate=\"%s\"\n", date)
+		fmt.Fprintf(buf, "    photoshop:DateCreated=\"%s\"\n", date)
+		fmt.Fprintf(buf, "    exif:DateTimeOriginal=\"%s\"\n", date)
+	}
+	if r.Location != nil {
+		buf.WriteString("    exif:GPSVersionID=\"2.2.0.0\"\n")
+		fmt.Fprintf(buf, "    exif:GPSLatitude=\"%s\"\n", xmpGPSCoordinate(r.Location.Latitude, "N", "S"))
+		fmt.Fprintf(buf, "    exif:GPSLongitude=\"%s\"\n", xmpGPSCoordinate(r.Location.Longitude, "E", "W"))
+	}
+	fmt.Fprintf(buf, "    xmp:Rating=\"%d\">\n", r.Rating)
+	if r.Caption != "" {
+		buf.WriteString("   <dc:description>\n    <rdf:Alt>\n     <rdf:li xml:lang=\"x-default\">")
+		_ = xml.EscapeText(buf, []byte(r.Caption))
+		buf.WriteString("</rdf:li>\n    </rdf:Alt>\n   </dc:description>\n")
+	}
+	if len(r.Keywords) > 0 {
+		buf.WriteString("   <dc:subject>\n    <rdf:Bag>\n")
+		for _, keyword := range r.Keywords {
+			buf.WriteString("     <rdf:li>")
+			_ = xml.EscapeText(buf, []byte(keyword))
+			buf.WriteString("</rdf:li>\n")
+		}
+		buf.WriteString("    </rdf:Bag>\n   </dc:subject>\n")
+	}
+	buf.WriteString("  </rdf:Description>\n")
+	buf.WriteString(" </rdf:RDF>\n")
+	buf.WriteString("</x:xmpmeta>\n")
+	buf.WriteString("<?xpacket end=\"w\"?>\n")
+	return buf.Bytes()
+}
+
+// xmpGPSCoordinate formats a coordinate the XMP exif way, degrees and decimal minutes, like 37,46.494000N.
+func xmpGPSCoordinate(value float64, positive, negative string) string {
+	ref := positive
+	if value < 0 {
+		ref, value = negative, -value
+	}
+	degrees := math.Floor(value)
+	return fmt.Sprintf("%d,%.6f%s", int(degrees), (value-degrees)*60, ref)
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"log"
+	"fmt"
 	"os"
 
 	"github.com/urfave/cli/v2"
@@ -11,26 +11,110 @@ import (
 
 func main() {
 	app := &cli.App{
-		Name:  "icloud-photo-cli",
-		Usage: "icloud photo cli",
+		Name:                  "icloud-photo-cli",
+		Usage:                 "icloud photo cli",
+		CustomAppHelpTemplate: cli.AppHelpTemplate + "\n" + command.ExitCodeUsage() + "\n",
+		// album names can have commas, a repeatable flag takes one value per flag, env var or profile value
+		DisableSliceFlagSeparator: true,
 		Commands: []*cli.Command{
 			{
 				Name:        "download",
 				Aliases:     []string{"d"},
 				Description: "download photos",
 				Flags:       command.NewDownloadFlag(),
+				Before:      command.LoadProfile,
 				Action:      command.Download,
 			},
+			{
+				Name:        "watch",
+				Description: "download the new photos every --interval, until it's interrupted",
+				Flags:       command.NewWatchFlag(),
+				Before:      command.LoadProfile,
+				Action:      command.Watch,
+			},
 			{
 				Name:        "upload",
 				Aliases:     []string{"u"},
 				Description: "upload photos",
 				Flags:       command.NewUploadFlag(),
+				Before:      command.LoadProfile,
 				Action:      command.Upload,
 			},
+			{
+				Name:        "delete",
+				Description: "move photos to the Recently Deleted album of iCloud",
+				Flags:       command.NewDeleteFlag(),
+				Before:      command.LoadProfile,
+				Action:      command.Delete,
+			},
+			{
+				Name:        "restore",
+				Description: "move photos out of the Recently Deleted album of iCloud",
+				Flags:       command.NewDeleteFlag(),
+				Before:      command.LoadProfile,
+				Action:      command.Restore,
+			},
+			{
+				Name:        "list",
+				Description: "list the photos of the archive, with --offline from the metadata sidecars",
+				Flags:       command.NewArchiveFlag(),
+				Before:      command.LoadProfile,
+				Action:      command.List,
+			},
+			{
+				Name:        "verify",
+				Description: "check the photos of the archive are in the output dir, with --offline from the metadata sidecars",
+				Flags:       command.NewVerifyFlag(),
+				Before:      command.LoadProfile,
+				Action:      command.Verify,
+			},
+			{
+				Name:        "stats",
+				Description: "print the photo count and size of the archive, with --offline from the metadata sidecars",
+				Flags:       command.NewArchiveFlag(),
+				Before:      command.LoadProfile,
+				Action:      command.Stats,
+			},
+			{
+				Name:        "album",
+				Description: "create, rename and delete albums, and add or remove their photos",
+				Subcommands: command.NewAlbumCommands(),
+			},
+			{
+				Name:        "session",
+				Description: "export the logged in session, and import it on another machine",
+				Subcommands: command.NewSessionCommands(),
+			},
+			{
+				Name:        "drive",
+				Description: "manage iCloud Drive files",
+				Subcommands: command.NewDriveCommands(),
+			},
+			{
+				Name:        "devices",
+				Description: "locate the Find My devices, play a sound on them or put them in Lost Mode",
+				Subcommands: command.NewDevicesCommands(),
+			},
+			{
+				Name:        "contacts",
+				Description: "export the contacts to vCard",
+				Subcommands: command.NewContactsCommands(),
+			},
+			{
+				Name:        "calendar",
+				Description: "list the calendars, and export their events to ics",
+				Subcommands: command.NewCalendarCommands(),
+			},
+			{
+				Name:        "dedupe",
+				Description: "report duplicate photos in the downloaded dir",
+				Flags:       command.NewDedupeFlag(),
+				Action:      command.Dedupe,
+			},
 		},
 	}
 	if err := app.Run(os.Args); err != nil {
-		log.Fatalln(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(command.ExitCode(err))
 	}
 }
@@ -1,6 +1,10 @@
 package icloudgo
 
 import (
+	"context"
+	"io"
+	"time"
+
 	"github.com/chyroc/icloudgo/internal"
 )
 
@@ -12,15 +16,168 @@ type (
 	TextGetter   func(appleID string) (string, error)
 	Client       = internal.Client
 	ClientOption = internal.ClientOption
+	Endpoints    = internal.Endpoints
 	Error        = internal.Error
 	PhotoAlbum   = internal.PhotoAlbum
 	PhotoAsset   = internal.PhotoAsset
 	PhotoService = internal.PhotoService
+	DriveService = internal.DriveService
+	DriveNode    = internal.DriveNode
+	WebService   = internal.WebService
+	PhotoZone    = internal.PhotoZone
+	Storage      = internal.Storage
+	FileStorage  = internal.FileStorage
+	Adjustment   = internal.Adjustment
+	SyncChanges  = internal.SyncChanges
+	Watcher      = internal.Watcher
+	WatchOption  = internal.WatchOption
+	SharedAlbum  = internal.SharedAlbum
+	SharedAsset  = internal.SharedAsset
+
+	FindMyService  = internal.FindMyService
+	FindMyDevice   = internal.FindMyDevice
+	FindMyLocation = internal.FindMyLocation
+
+	ContactsService = internal.ContactsService
+	Contact         = internal.Contact
+	ContactField    = internal.ContactField
+	ContactAddress  = internal.ContactAddress
+	VCardVersion    = internal.VCardVersion
+
+	CalendarService = internal.CalendarService
+	Calendar        = internal.Calendar
+	CalendarEvent   = internal.CalendarEvent
+
+	AssetVersion       = internal.AssetVersion
+	LiveVideoVersion   = internal.LiveVideoVersion
+	PosterFrameVersion = internal.PosterFrameVersion
+	WebAccessError     = internal.WebAccessError
+
+	ConsentKind          = internal.ConsentKind
+	ConsentRequest       = internal.ConsentRequest
+	ConsentHandler       = internal.ConsentHandler
+	ConsentRequiredError = internal.ConsentRequiredError
+
+	TrustedDevice         = internal.TrustedDevice
+	TwoFactorRequest      = internal.TwoFactorRequest
+	TrustedDeviceSelector = internal.TrustedDeviceSelector
+
+	EventSink    = internal.EventSink
+	NopEventSink = internal.NopEventSink
+
+	AlbumMembership = internal.AlbumMembership
+
+	StorageStatus = internal.StorageStatus
+	StorageUsage  = internal.StorageUsage
+
+	SharedAlbumMember  = internal.SharedAlbumMember
+	SharedAssetComment = internal.SharedAssetComment
+
+	StorageChtimes = internal.StorageChtimes
+	StorageAppend  = internal.StorageAppend
+	StorageWriteAt = internal.StorageWriteAt
+	WriteAtCloser  = internal.WriteAtCloser
+	StorageTarget  = internal.StorageTarget
+
+	S3Storage           = internal.S3Storage
+	S3StorageOption     = internal.S3StorageOption
+	WebDAVStorage       = internal.WebDAVStorage
+	WebDAVStorageOption = internal.WebDAVStorageOption
+
+	DownloadRetryPolicy    = internal.DownloadRetryPolicy
+	ParallelDownloadOption = internal.ParallelDownloadOption
+
+	AlbumNode = internal.AlbumNode
+
+	Moment       = internal.Moment
+	MomentOption = internal.MomentOption
+	MomentIter   = internal.MomentIter
+
+	Logger   = internal.Logger
+	LogLevel = internal.LogLevel
+
+	DownloadProgress     = internal.DownloadProgress
+	DownloadProgressFunc = internal.DownloadProgressFunc
+	IterProgress         = internal.IterProgress
+
+	PhotosIterOption = internal.PhotosIterOption
+	PhotosFilter     = internal.PhotosFilter
+	MediaType        = internal.MediaType
+	PurgeOption      = internal.PurgeOption
+	UploadOption     = internal.UploadOption
+	UploadResult     = internal.UploadResult
+	PhotosIterNext   = internal.PhotosIterNext
+
+	FilenameTemplate     = internal.FilenameTemplate
+	FilenameTemplateData = internal.FilenameTemplateData
+
+	RecordsQueryRequest  = internal.RecordsQueryRequest
+	RecordsQueryResponse = internal.RecordsQueryResponse
+	QueryCursor          = internal.QueryCursor
+	QueryFilter          = internal.QueryFilter
+	QuerySort            = internal.QuerySort
+	Record               = internal.Record
+	RecordField          = internal.RecordField
+	RecordTimestamp      = internal.RecordTimestamp
+
+	AlbumLister   = internal.AlbumLister
+	AssetIterator = internal.AssetIterator
+	Downloader    = internal.Downloader
+)
+
+var PhotoZonePrimary = internal.PhotoZonePrimary
+
+const (
+	ConsentTerms  = internal.ConsentTerms
+	ConsentRepair = internal.ConsentRepair
+)
+
+const (
+	MediaTypeAny   = internal.MediaTypeAny
+	MediaTypePhoto = internal.MediaTypePhoto
+	MediaTypeVideo = internal.MediaTypeVideo
+	MediaTypeLive  = internal.MediaTypeLive
+)
+
+const (
+	VCard30 = internal.VCard30
+	VCard40 = internal.VCard40
+)
+
+var DefaultDownloadRetryPolicy = internal.DefaultDownloadRetryPolicy
+
+var DefaultMomentOption = internal.DefaultMomentOption
+
+var (
+	DefaultLogger = internal.DefaultLogger
+	NopLogger     = internal.NopLogger
+)
+
+const (
+	LogLevelDebug = internal.LogLevelDebug
+	LogLevelInfo  = internal.LogLevelInfo
+	LogLevelWarn  = internal.LogLevelWarn
+	LogLevelError = internal.LogLevelError
 )
 
 var (
 	ErrValidateCodeWrong = internal.ErrValidateCodeWrong
 	ErrPhotosIterateEnd  = internal.ErrPhotosIterateEnd
+	ErrRateLimited       = internal.ErrRateLimited
+
+	ErrDownloadURLExpired = internal.ErrDownloadURLExpired
+	ErrWebAccessDisabled  = internal.ErrWebAccessDisabled
+
+	ErrPasswordRequired  = internal.ErrPasswordRequired
+	ErrTwoFACodeRequired = internal.ErrTwoFACodeRequired
+	ErrTwoStepRequired   = internal.ErrTwoStepRequired
+	ErrConsentRequired   = internal.ErrConsentRequired
+
+	ErrSecurityKeyRequired = internal.ErrSecurityKeyRequired
+	ErrSessionExpired      = internal.ErrSessionExpired
+
+	ErrInvalidQueryIdentifier = internal.ErrInvalidQueryIdentifier
+	ErrInvalidQueryFilter     = internal.ErrInvalidQueryFilter
 )
 
 const (
@@ -37,10 +194,107 @@ const (
 	AlbumNameHidden          = internal.AlbumNameHidden
 )
 
+type AlbumID = internal.AlbumID
+
+const (
+	AlbumIDAll             = internal.AlbumIDAll
+	AlbumIDTimeLapse       = internal.AlbumIDTimeLapse
+	AlbumIDVideos          = internal.AlbumIDVideos
+	AlbumIDSloMo           = internal.AlbumIDSloMo
+	AlbumIDBursts          = internal.AlbumIDBursts
+	AlbumIDFavorites       = internal.AlbumIDFavorites
+	AlbumIDPanoramas       = internal.AlbumIDPanoramas
+	AlbumIDScreenshots     = internal.AlbumIDScreenshots
+	AlbumIDLive            = internal.AlbumIDLive
+	AlbumIDRecentlyDeleted = internal.AlbumIDRecentlyDeleted
+	AlbumIDHidden          = internal.AlbumIDHidden
+)
+
+func NewFileStorage() *FileStorage {
+	return internal.NewFileStorage()
+}
+
+func NewS3Storage(option *S3StorageOption) (*S3Storage, error) {
+	return internal.NewS3Storage(option)
+}
+
+func NewWebDAVStorage(option *WebDAVStorageOption) (*WebDAVStorage, error) {
+	return internal.NewWebDAVStorage(option)
+}
+
+func FormatSize(size int) string {
+	return internal.FormatSize(size)
+}
+
+func AlbumDisplayName(id AlbumID, locale string) string {
+	return internal.AlbumDisplayName(id, locale)
+}
+
+func NewTextLogger(w io.Writer, level LogLevel) Logger {
+	return internal.NewTextLogger(w, level)
+}
+
+func NewJSONLogger(w io.Writer, level LogLevel) Logger {
+	return internal.NewJSONLogger(w, level)
+}
+
+func ParseLogLevel(s string) (LogLevel, error) {
+	return internal.ParseLogLevel(s)
+}
+
+func ParseVCardVersion(s string) (VCardVersion, error) {
+	return internal.ParseVCardVersion(s)
+}
+
+func WriteVCards(w io.Writer, contacts []*Contact, version VCardVersion) error {
+	return internal.WriteVCards(w, contacts, version)
+}
+
+func WriteICS(w io.Writer, calendar *Calendar, events []*CalendarEvent) error {
+	return internal.WriteICS(w, calendar, events)
+}
+
+func WithDownloadProgress(ctx context.Context, fn DownloadProgressFunc) context.Context {
+	return internal.WithDownloadProgress(ctx, fn)
+}
+
+func DownloadProgressChan(ch chan<- DownloadProgress) DownloadProgressFunc {
+	return internal.DownloadProgressChan(ch)
+}
+
 type PhotoVersion = internal.PhotoVersion
 
+const PartialFileSuffix = internal.PartialFileSuffix
+
 const (
 	PhotoVersionOriginal = internal.PhotoVersionOriginal
 	PhotoVersionMedium   = internal.PhotoVersionMedium
 	PhotoVersionThumb    = internal.PhotoVersionThumb
+
+	PhotoVersionAdjusted    = internal.PhotoVersionAdjusted
+	PhotoVersionAlternative = internal.PhotoVersionAlternative
+
+	PhotoVersionLiveOriginal = internal.PhotoVersionLiveOriginal
+	PhotoVersionLiveMedium   = internal.PhotoVersionLiveMedium
+	PhotoVersionLiveThumb    = internal.PhotoVersionLiveThumb
+	PhotoVersionPoster       = internal.PhotoVersionPoster
+	PhotoVersionPosterThumb  = internal.PhotoVersionPosterThumb
 )
+
+const (
+	DriveNodeTypeFolder     = internal.DriveNodeTypeFolder
+	DriveNodeTypeFile       = internal.DriveNodeTypeFile
+	DriveNodeTypeAppLibrary = internal.DriveNodeTypeAppLibrary
+)
+
+func NewMomentIter(iter AssetIterator, option *MomentOption) *MomentIter {
+	return internal.NewMomentIter(iter, option)
+}
+
+func ParseFilenameTemplate(text string) (*FilenameTemplate, error) {
+	return internal.ParseFilenameTemplate(text)
+}
+
+func RetryAfter(err error) time.Duration {
+	return internal.RetryAfter(err)
+}
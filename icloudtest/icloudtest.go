@@ -0,0 +1,52 @@
+// Package icloudtest fakes iCloud, for the tests of programs using icloudgo without a real account.
+//
+// The Server holds an in-memory photo library, the Client NewClient returns is signed in to it,
+// so the albums, iterators and assets the code under test gets are the real ones of icloudgo,
+// listing, downloading, uploading and deleting the assets of the library.
+package icloudtest
+
+import (
+	"github.com/chyroc/icloudgo"
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+type (
+	// Server is the fake iCloud, see its AddAsset, AddAlbum, Asset and Fail.
+	Server = icloudmock.Server
+	// Asset is a photo of the library of the Server.
+	Asset = icloudmock.Asset
+	// Album is a user album of the library of the Server.
+	Album = icloudmock.Album
+)
+
+// AppleID and Password are the credentials the Server accepts.
+const (
+	AppleID  = icloudmock.AppleID
+	Password = icloudmock.Password
+)
+
+// NewServer starts a fake iCloud with an empty library, Close it when done.
+func NewServer() *Server {
+	return icloudmock.New()
+}
+
+// NewClient returns a client of the server, signed in, which keeps its session files in cookieDir, like t.TempDir().
+func NewClient(server *Server, cookieDir string) (*icloudgo.Client, error) {
+	cli, err := icloudgo.New(&icloudgo.ClientOption{
+		AppID:     AppleID,
+		CookieDir: cookieDir,
+		PasswordGetter: func(appleID string) (string, error) {
+			return Password, nil
+		},
+		Domain:    "com",
+		Endpoints: &icloudgo.Endpoints{Auth: server.AuthEndpoint(), Setup: server.SetupEndpoint()},
+		Logger:    icloudgo.NopLogger,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cli.Authenticate(false, nil); err != nil {
+		return nil, err
+	}
+	return cli, nil
+}
@@ -0,0 +1,94 @@
+package icloudtest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/chyroc/icloudgo"
+	"github.com/chyroc/icloudgo/icloudtest"
+)
+
+// countFavorites is code under test, it takes an AlbumLister, so it runs on the fake library as on iCloud.
+func countFavorites(photos icloudgo.AlbumLister) (int, error) {
+	album, err := photos.GetAlbumByID(icloudgo.AlbumIDFavorites)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	iter := album.PhotosIter()
+	for {
+		_, err := iter.Next()
+		if errors.Is(err, icloudgo.ErrPhotosIterateEnd) {
+			return count, nil
+		} else if err != nil {
+			return 0, err
+		}
+		count++
+	}
+}
+
+func ExampleNewServer() {
+	server := icloudtest.NewServer()
+	defer server.Close()
+	server.AddAsset(&icloudtest.Asset{Filename: "beach.jpg", Data: []byte("beach"), Favorite: true})
+	server.AddAsset(&icloudtest.Asset{Filename: "receipt.jpg", Data: []byte("receipt")})
+
+	cookieDir, _ := os.MkdirTemp("", "icloudtest")
+	defer os.RemoveAll(cookieDir)
+	cli, err := icloudtest.NewClient(server, cookieDir)
+	if err != nil {
+		panic(err)
+	}
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(countFavorites(photoCli))
+	// Output: 1 <nil>
+}
+
+func TestFakeLibrary(t *testing.T) {
+	server := icloudtest.NewServer()
+	defer server.Close()
+	server.AddAsset(&icloudtest.Asset{Filename: "beach.jpg", Data: []byte("beach")})
+
+	cli, err := icloudtest.NewClient(server, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded, err := photoCli.UploadAsset(context.Background(), bytes.NewReader([]byte("mountain")), "mountain.jpg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.Filename() != "mountain.jpg" {
+		t.Errorf("uploaded %s, want mountain.jpg", uploaded.Filename())
+	}
+
+	var downloader icloudgo.Downloader = uploaded
+	body, err := downloader.Download(icloudgo.PhotoVersionOriginal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(data) != "mountain" {
+		t.Errorf("downloaded %q, %v, want mountain", data, err)
+	}
+
+	if err := uploaded.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if asset := server.Asset(uploaded.ID()); asset == nil || !asset.Deleted {
+		t.Errorf("the asset is not deleted in the library: %+v", asset)
+	}
+}
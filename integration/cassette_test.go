@@ -0,0 +1,440 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// serverPlaceholder stands for the origin of every url of a cassette, it's replaced by the url of the replay server.
+const serverPlaceholder = "{{server}}"
+
+// cassette is the sanitized traffic of a test, in the order it was sent.
+type cassette struct {
+	// Fake reports whether the cassette is recorded from the fake iCloud, its replay doesn't cover the iCloud API then
+	Fake         bool           `json:"fake,omitempty"`
+	Interactions []*interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Query       string          `json:"query,omitempty"`
+	RequestBody json.RawMessage `json:"requestBody,omitempty"`
+	// RequestSize is the size of a request body which is not JSON, like an upload, it's not kept
+	RequestSize int               `json:"requestSize,omitempty"`
+	Status      int               `json:"status"`
+	Header      map[string]string `json:"header,omitempty"`
+	Body        json.RawMessage   `json:"body,omitempty"`
+	// BodySize is the size of a response body which is not JSON, like a download, it's replayed as zeros
+	BodySize int `json:"bodySize,omitempty"`
+
+	used bool
+}
+
+func loadCassette(file string) (*cassette, error) {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	res := new(cassette)
+	if err := json.Unmarshal(bs, res); err != nil {
+		return nil, fmt.Errorf("parse cassette %s failed, err: %w", file, err)
+	}
+	return res, nil
+}
+
+func (r *cassette) save(file string) error {
+	bs, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(bs, '\n'), 0o644)
+}
+
+// recorder is the transport of the recorded tests, it keeps the raw traffic, which is sanitized when saved.
+type recorder struct {
+	next http.RoundTripper
+
+	lock      sync.Mutex
+	exchanges []*exchange
+}
+
+type exchange struct {
+	method  string
+	url     *url.URL
+	reqBody []byte
+	status  int
+	header  http.Header
+	body    []byte
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.exchanges = append(r.exchanges, &exchange{
+		method: req.Method, url: req.URL, reqBody: reqBody,
+		status: resp.StatusCode, header: resp.Header.Clone(), body: body,
+	})
+	return resp, nil
+}
+
+// cassette returns the sanitized traffic recorded, secrets are the values to replace, like the apple id, by their placeholder.
+func (r *recorder) cassette(secrets map[string]string) *cassette {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s := newSanitizer(secrets)
+	res := new(cassette)
+	for _, v := range r.exchanges {
+		res.Interactions = append(res.Interactions, s.interaction(v))
+	}
+	return res
+}
+
+// sessionHeaders are the response headers of the session, they are kept with a placeholder,
+// keptHeaders are kept as is, the others are dropped.
+var sessionHeaders = map[string]bool{
+	"X-Apple-Session-Token":        true,
+	"X-Apple-Id-Session-Id":        true,
+	"Scnt":                         true,
+	"X-Apple-Twosv-Trust-Token":    true,
+	"X-Apple-Repair-Session-Token": true,
+}
+
+var keptHeaders = map[string]bool{
+	"Content-Type":               true,
+	"X-Apple-Id-Account-Country": true,
+}
+
+// maskedKeys are the JSON fields of the account and the session, their values are replaced by a placeholder,
+// droppedKeys the ones of the photos which are not needed to replay, like the location.
+var (
+	maskedKeys = newKeySet(
+		"accountName", "password", "dsWebAuthToken", "trustToken", "trustTokens",
+		"appleId", "appleIdAlias", "appleIdAliases", "primaryEmail", "fullName", "firstName", "lastName",
+		"aDsID", "notificationId", "userRecordName", "deviceID", "ownerRecordName",
+		"wrappingKey", "referenceChecksum", "syncToken", "continuationMarker",
+	)
+	droppedKeys = newKeySet(
+		"locationEnc", "locationV2Enc", "locationLatitude", "locationLongitude", "captionEnc",
+		"adjustmentSimpleDataEnc", "importedByDisplayNameEnc", "importedByBundleIdentifierEnc",
+	)
+	filenameKeys = newKeySet("filenameEnc", "originalFilenameEnc")
+)
+
+var originRegexp = regexp.MustCompile(`https?://[^/\s"'?#]+`)
+
+// sanitizer replaces the secrets, the filenames and the urls of the traffic,
+// the same value gets the same replacement in every interaction, so the cassette stays consistent.
+type sanitizer struct {
+	secrets   *strings.Replacer
+	filenames map[string]string
+	downloads map[string]string // path and query of a download url -> path of the replay server
+}
+
+func newSanitizer(secrets map[string]string) *sanitizer {
+	var pairs []string
+	for secret, placeholder := range secrets {
+		if secret != "" {
+			pairs = append(pairs, secret, placeholder)
+		}
+	}
+	return &sanitizer{
+		secrets:   strings.NewReplacer(pairs...),
+		filenames: map[string]string{},
+		downloads: map[string]string{},
+	}
+}
+
+func (r *sanitizer) interaction(v *exchange) *interaction {
+	res := &interaction{
+		Method: v.method,
+		Path:   r.text(v.url.Path),
+		Query:  r.text(v.url.RawQuery),
+		Status: v.status,
+	}
+	if download, ok := r.downloads[downloadKey(v.url)]; ok {
+		res.Path, res.Query = download, ""
+	}
+	if len(v.reqBody) > 0 {
+		if body, ok := r.sanitizeJSON(v.reqBody); ok {
+			res.RequestBody = body
+		} else {
+			res.RequestSize = len(v.reqBody)
+		}
+	}
+	for key := range v.header {
+		key = http.CanonicalHeaderKey(key)
+		if sessionHeaders[key] {
+			setHeader(&res.Header, key, "REDACTED")
+		} else if keptHeaders[key] {
+			setHeader(&res.Header, key, v.header.Get(key))
+		}
+	}
+	if len(v.body) > 0 {
+		if body, ok := r.sanitizeJSON(v.body); ok {
+			res.Body = body
+		} else {
+			res.BodySize = len(v.body)
+		}
+	}
+	return res
+}
+
+// sanitizeJSON returns the sanitized JSON of bs, false if it's not JSON.
+func (r *sanitizer) sanitizeJSON(bs []byte) (json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	res, err := json.Marshal(r.value("", v))
+	if err != nil {
+		return nil, false
+	}
+	return res, true
+}
+
+func (r *sanitizer) value(key string, v any) any {
+	switch {
+	case maskedKeys[key]:
+		return mask(v)
+	case filenameKeys[key]:
+		if field, ok := v.(map[string]any); ok {
+			if s, ok := field["value"].(string); ok {
+				field["value"] = r.filename(s)
+			}
+			return field
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if droppedKeys[k] {
+				delete(v, k)
+				continue
+			}
+			v[k] = r.value(k, child)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = r.value(key, child)
+		}
+		return v
+	case string:
+		if key == "downloadURL" {
+			return r.download(v)
+		}
+		return r.text(v)
+	}
+	return v
+}
+
+// text replaces the secrets and the origins of the urls of s.
+func (r *sanitizer) text(s string) string {
+	return originRegexp.ReplaceAllString(r.secrets.Replace(s), serverPlaceholder)
+}
+
+// filename replaces a base64 filename by IMG_<n>, with its extension.
+func (r *sanitizer) filename(enc string) string {
+	if res, ok := r.filenames[enc]; ok {
+		return res
+	}
+	bs, _ := base64.StdEncoding.DecodeString(enc)
+	res := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("IMG_%04d%s", len(r.filenames)+1, path.Ext(string(bs)))))
+	r.filenames[enc] = res
+	return res
+}
+
+// download replaces a signed download url by a /download/<n> url of the replay server.
+func (r *sanitizer) download(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return r.text(rawURL)
+	}
+	key := downloadKey(u)
+	if _, ok := r.downloads[key]; !ok {
+		r.downloads[key] = fmt.Sprintf("/download/%d", len(r.downloads)+1)
+	}
+	return serverPlaceholder + r.downloads[key]
+}
+
+func downloadKey(u *url.URL) string {
+	return u.EscapedPath() + "?" + u.RawQuery
+}
+
+func mask(v any) any {
+	switch v := v.(type) {
+	case string:
+		return "REDACTED"
+	case []any:
+		return []any{}
+	case map[string]any:
+		for k, child := range v {
+			v[k] = mask(child)
+		}
+		return v
+	}
+	return v
+}
+
+func newKeySet(keys ...string) map[string]bool {
+	res := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		res[key] = true
+	}
+	return res
+}
+
+func setHeader(header *map[string]string, key, value string) {
+	if *header == nil {
+		*header = map[string]string{}
+	}
+	(*header)[key] = value
+}
+
+// player replays a cassette, a request gets the first unused interaction of its method and path,
+// the one with the same sanitized JSON body first.
+type player struct {
+	t   *testing.T
+	url string
+
+	lock     sync.Mutex
+	cassette *cassette
+}
+
+func (r *player) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	it := r.match(req.Method, req.URL.Path, body)
+	if it == nil {
+		r.t.Errorf("no interaction recorded for %s %s", req.Method, req.URL.Path)
+		http.Error(w, "not recorded", http.StatusNotFound)
+		return
+	}
+	for k, v := range it.Header {
+		w.Header().Set(k, v)
+	}
+	w.WriteHeader(it.Status)
+	if len(it.Body) > 0 {
+		_, _ = w.Write(bytes.ReplaceAll(it.Body, []byte(serverPlaceholder), []byte(r.url)))
+	} else if it.BodySize > 0 {
+		_, _ = w.Write(make([]byte, it.BodySize))
+	}
+}
+
+func (r *player) match(method, urlPath string, body []byte) *interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sanitized, _ := newSanitizer(nil).sanitizeJSON(body)
+	var res *interaction
+	for _, it := range r.cassette.Interactions {
+		if it.used || it.Method != method || it.Path != urlPath {
+			continue
+		}
+		if res == nil {
+			res = it
+		}
+		if sameJSON(it.RequestBody, sanitized) {
+			res = it
+			break
+		}
+	}
+	if res != nil {
+		res.used = true
+	}
+	return res
+}
+
+// unused returns the interactions not replayed yet.
+func (r *player) unused() []*interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var res []*interaction
+	for _, it := range r.cassette.Interactions {
+		if !it.used {
+			res = append(res, it)
+		}
+	}
+	return res
+}
+
+func sameJSON(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestSanitizer(t *testing.T) {
+	u, _ := url.Parse("https://p01-ckdatabasews.icloud.com/database/1/query?dsid=42")
+	rec := &recorder{exchanges: []*exchange{{
+		method:  http.MethodPost,
+		url:     u,
+		reqBody: []byte(`{"accountName":"someone@icloud.com","password":"hunter2"}`),
+		status:  http.StatusOK,
+		header:  http.Header{"X-Apple-Session-Token": {"token"}, "Set-Cookie": {"X-APPLE-WEBAUTH-TOKEN=token"}},
+		body: []byte(`{"dsid":"42","fields":{"filenameEnc":{"value":"` + base64.StdEncoding.EncodeToString([]byte("Paris.HEIC")) + `"},` +
+			`"locationEnc":{"value":"x"},"resOriginalRes":{"value":{"downloadURL":"https://cvws.icloud-content.com/B/abc?o=1"}}}}`),
+	}}}
+	it := rec.cassette(map[string]string{"42": "1"}).Interactions[0]
+
+	bs, _ := json.Marshal(it)
+	for _, secret := range []string{"someone", "hunter2", "token", "Paris", "42", "locationEnc", "icloud.com", "icloud-content.com"} {
+		if bytes.Contains(bs, []byte(secret)) {
+			t.Errorf("%q recorded: %s", secret, bs)
+		}
+	}
+	if want := "dsid=1"; it.Query != want {
+		t.Errorf("query %q, want %q", it.Query, want)
+	}
+	if want := base64.StdEncoding.EncodeToString([]byte("IMG_0001.HEIC")); !bytes.Contains(it.Body, []byte(want)) {
+		t.Errorf("filename not replaced by %s: %s", want, it.Body)
+	}
+	if want := serverPlaceholder + "/download/1"; !bytes.Contains(it.Body, []byte(want)) {
+		t.Errorf("download url not replaced by %s: %s", want, it.Body)
+	}
+}
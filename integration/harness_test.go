@@ -0,0 +1,144 @@
+// Package integration runs the library end to end: against a throwaway iCloud account when its credentials are given,
+// else by replaying the sanitized cassettes of testdata.
+//
+// The committed cassettes are recorded from the fake iCloud of icloudmock, not from an account,
+// so their replay only checks the client still makes the requests it made to the fake, it's not a test of the iCloud API,
+// which only a run against an account, or cassettes recorded from one, covers.
+//
+//	ICLOUD_TEST_USERNAME, ICLOUD_TEST_PASSWORD  the account, the tests upload and delete photos, never use a real library
+//	ICLOUD_TEST_2FA_CODE                        the 2fa code, when the cookie dir has no trusted session
+//	ICLOUD_TEST_COOKIE_DIR                      keeps the session between the runs, so the 2fa code is asked once
+//	ICLOUD_TEST_DOMAIN                          com or cn, com by default
+//	ICLOUD_TEST_FAKE=1                          runs against the fake iCloud of icloudtest instead of an account
+//	ICLOUD_TEST_RECORD=1                        records the traffic of the account, or of the fake, to the cassettes
+//
+// The apple id, the password, the dsid, the session tokens, the names and the locations are not recorded,
+// the filenames are replaced by IMG_<n>, the signed download urls by /download/<n>, and the downloads by their size.
+package integration
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chyroc/icloudgo"
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// harness is the client of a test, and how it reaches iCloud.
+type harness struct {
+	client *icloudgo.Client
+	// replaying reports whether the responses come from a cassette, the downloads are zeros then
+	replaying bool
+}
+
+// newHarness returns the harness of t, seed fills the library of the fake iCloud of ICLOUD_TEST_FAKE.
+func newHarness(t *testing.T, seed func(server *icloudmock.Server)) *harness {
+	t.Helper()
+	cassetteFile := filepath.Join("testdata", t.Name()+".json")
+	username, password := os.Getenv("ICLOUD_TEST_USERNAME"), os.Getenv("ICLOUD_TEST_PASSWORD")
+	option := &icloudgo.ClientOption{
+		CookieDir: os.Getenv("ICLOUD_TEST_COOKIE_DIR"),
+		Domain:    os.Getenv("ICLOUD_TEST_DOMAIN"),
+		TwoFACodeGetter: func(appleID string) (string, error) {
+			if code := os.Getenv("ICLOUD_TEST_2FA_CODE"); code != "" {
+				return code, nil
+			}
+			return "", errors.New("2fa code required, set ICLOUD_TEST_2FA_CODE")
+		},
+		Logger: icloudgo.NopLogger,
+	}
+	if option.CookieDir == "" {
+		option.CookieDir = t.TempDir()
+	}
+	if option.Domain == "" {
+		option.Domain = "com"
+	}
+
+	res := new(harness)
+	live := username != "" && password != ""
+	fake := false
+	switch {
+	case live:
+		t.Logf("running against the account %s", username)
+	case os.Getenv("ICLOUD_TEST_FAKE") == "1":
+		server := icloudmock.New()
+		t.Cleanup(server.Close)
+		if seed != nil {
+			seed(server)
+		}
+		username, password = icloudmock.AppleID, icloudmock.Password
+		option.Endpoints = &icloudgo.Endpoints{Auth: server.AuthEndpoint(), Setup: server.SetupEndpoint()}
+		live, fake = true, true
+	default:
+		recorded, err := loadCassette(cassetteFile)
+		if errors.Is(err, os.ErrNotExist) {
+			t.Skipf("no cassette %s, record it with ICLOUD_TEST_RECORD=1 and a throwaway account", cassetteFile)
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if recorded.Fake {
+			t.Logf("replaying %s, recorded from the fake iCloud, not from an account", cassetteFile)
+		}
+		p := &player{t: t, cassette: recorded}
+		server := httptest.NewServer(p)
+		t.Cleanup(server.Close)
+		p.url = server.URL
+		t.Cleanup(func() {
+			// the client doesn't send the requests of the cassette anymore, it must be recorded again
+			for _, it := range p.unused() {
+				t.Errorf("interaction %s %s recorded but not replayed", it.Method, it.Path)
+			}
+		})
+		username, password = icloudmock.AppleID, icloudmock.Password
+		option.CookieDir = t.TempDir()
+		option.Endpoints = &icloudgo.Endpoints{Auth: server.URL + "/appleauth/auth", Setup: server.URL + "/setup/ws/1"}
+		res.replaying = true
+	}
+	option.AppID = username
+	option.PasswordGetter = func(appleID string) (string, error) {
+		return password, nil
+	}
+
+	var rec *recorder
+	if live && os.Getenv("ICLOUD_TEST_RECORD") == "1" {
+		// the client sends its requests with the default transport
+		rec = &recorder{next: http.DefaultTransport}
+		http.DefaultTransport = rec
+		t.Cleanup(func() {
+			http.DefaultTransport = rec.next
+		})
+	}
+
+	cli, err := icloudgo.New(option)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Authenticate(false, nil); err != nil {
+		t.Fatal(err)
+	}
+	res.client = cli
+
+	if rec != nil {
+		t.Cleanup(func() {
+			if t.Failed() {
+				t.Logf("not saving the cassette %s of a failed test", cassetteFile)
+				return
+			}
+			secrets := map[string]string{
+				username:   icloudmock.AppleID,
+				password:   icloudmock.Password,
+				cli.DSID(): icloudmock.Dsid,
+			}
+			recorded := rec.cassette(secrets)
+			recorded.Fake = fake
+			if err := recorded.save(cassetteFile); err != nil {
+				t.Errorf("save cassette %s failed: %s", cassetteFile, err)
+			}
+		})
+	}
+	return res
+}
@@ -0,0 +1,127 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chyroc/icloudgo"
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// TestPhotos uploads a photo, finds it in the albums, downloads it, and deletes it.
+func TestPhotos(t *testing.T) {
+	h := newHarness(t, func(server *icloudmock.Server) {
+		for i := 0; i < 3; i++ {
+			server.AddAsset(&icloudmock.Asset{Data: newJPEG(t)})
+		}
+		server.AddAlbum("Trips")
+	})
+
+	photoCli, err := h.client.PhotoCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := newJPEG(t)
+	uploaded, err := photoCli.UploadAsset(context.Background(), bytes.NewReader(data), "icloudgo-integration.jpg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext := path.Ext(uploaded.Filename()); !strings.EqualFold(ext, ".jpg") {
+		t.Errorf("uploaded %s, want a .jpg", uploaded.Filename())
+	}
+	if !h.replaying && uploaded.Size() != len(data) {
+		t.Errorf("uploaded %d bytes, want %d", uploaded.Size(), len(data))
+	}
+
+	albums, err := photoCli.Albums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := albums[icloudgo.AlbumNameAll]
+	if all == nil {
+		t.Fatalf("album %s not found", icloudgo.AlbumNameAll)
+	}
+
+	// the newest photos are listed first, so the upload is on the first page
+	listed := findAsset(t, all.PhotosIterWithOption(&icloudgo.PhotosIterOption{PageSize: 10}), uploaded.ID(), 10)
+	if listed == nil {
+		t.Fatalf("uploaded %s not found in %s", uploaded.ID(), icloudgo.AlbumNameAll)
+	}
+
+	body, err := listed.Download(icloudgo.PhotoVersionOriginal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.replaying {
+		// the cassette keeps the size of the downloads, not the photos, which are not the ones of this run
+		if len(downloaded) != listed.Size() {
+			t.Errorf("downloaded %d bytes, want %d", len(downloaded), listed.Size())
+		}
+	} else if !bytes.Equal(downloaded, data) {
+		t.Errorf("downloaded %d bytes which are not the uploaded photo", len(downloaded))
+	}
+
+	if err := listed.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := photoCli.GetAlbum(icloudgo.AlbumNameRecentlyDeleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trashed := findAsset(t, deleted.PhotosIterWithOption(&icloudgo.PhotosIterOption{PageSize: 10}), uploaded.ID(), 10)
+	if trashed == nil {
+		t.Fatalf("deleted %s not found in %s", uploaded.ID(), icloudgo.AlbumNameRecentlyDeleted)
+	}
+	// leave the account as it was
+	if err := trashed.Expunge(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// findAsset returns the asset id among the first limit assets of iter, nil if it's not there.
+func findAsset(t *testing.T, iter icloudgo.AssetIterator, id string, limit int) *icloudgo.PhotoAsset {
+	t.Helper()
+	for i := 0; i < limit; i++ {
+		asset, err := iter.Next()
+		if errors.Is(err, icloudgo.ErrPhotosIterateEnd) {
+			return nil
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if asset.ID() == id {
+			return asset
+		}
+	}
+	return nil
+}
+
+// newJPEG returns a small JPEG no other run uploaded, iCloud keeps one copy of the same photo.
+func newJPEG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	seed := time.Now().UnixNano()
+	for i := range img.Pix {
+		img.Pix[i] = byte(seed >> (8 * (i % 8)))
+	}
+	img.Set(0, 0, color.RGBA{R: byte(seed), A: 255})
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
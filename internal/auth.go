@@ -1,11 +1,20 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
 
-func (r *Client) Authenticate(forceRefresh bool, service *string) (finalErr error) {
+func (r *Client) Authenticate(forceRefresh bool, service *string) error {
+	return r.AuthenticateContext(context.Background(), forceRefresh, service)
+}
+
+// AuthenticateContext is like Authenticate, the login is given up when ctx is done.
+func (r *Client) AuthenticateContext(ctx context.Context, forceRefresh bool, service *string) (finalErr error) {
+	// one login at a time, goroutines sharing the client wait for it and reuse the session
+	r.authLock.Lock()
+	defer r.authLock.Unlock()
 	defer func() {
 		if finalErr == nil {
 			r.flush()
@@ -13,13 +22,14 @@ func (r *Client) Authenticate(forceRefresh bool, service *string) (finalErr erro
 	}()
 
 	var errs []string
-	if r.sessionData.SessionToken != "" && !forceRefresh {
-		fmt.Printf("Checking session token validity")
-		if err := r.validateToken(); err == nil {
-			return nil
+	var lastErr error
+	if r.session().SessionToken != "" && !forceRefresh {
+		if err := r.validateToken(ctx); err == nil {
+			return r.acceptPendingTerms(ctx)
 		} else {
 			errs = append(errs, err.Error())
-			fmt.Printf("Invalid session token. Attempting brand new login.\n")
+			lastErr = err
+			r.log(LogLevelInfo, "Invalid session token. Attempting brand new login.", "err", err)
 		}
 	}
 
@@ -29,12 +39,13 @@ func (r *Client) Authenticate(forceRefresh bool, service *string) (finalErr erro
 	}
 
 	if service != nil {
-		if r.Data != nil && len(r.Data.Apps) > 0 && r.Data.Apps[*service] != nil && r.Data.Apps[*service].CanLaunchWithOneFactor {
+		if data := r.data(); data != nil && len(data.Apps) > 0 && data.Apps[*service] != nil && data.Apps[*service].CanLaunchWithOneFactor {
 
-			fmt.Printf("Authenticating as %s for %s\n", r.appleID, *service)
-			if err := r.authWithCredentialsService(*service, password); err != nil {
+			r.log(LogLevelInfo, "Authenticating for service", "apple_id", r.appleID, "service", *service)
+			if err := r.authWithCredentialsService(ctx, *service, password); err != nil {
 				errs = append(errs, err.Error())
-				fmt.Printf("Could not log into service. Attempting brand new login.\n")
+				lastErr = err
+				r.log(LogLevelInfo, "Could not log into service. Attempting brand new login.", "service", *service, "err", err)
 			} else {
 				return nil
 			}
@@ -43,20 +54,25 @@ func (r *Client) Authenticate(forceRefresh bool, service *string) (finalErr erro
 
 	// default, login to icloud.com[.cn]
 	{
-		fmt.Printf("Authenticating as %s\n", r.appleID)
-		err := r.signIn(password)
+		r.log(LogLevelInfo, "Authenticating", "apple_id", r.appleID)
+		err := r.signIn(ctx, password)
 		if err == nil {
-			err = r.verify2Fa()
+			err = r.verify2Fa(ctx)
+			if err == nil {
+				err = r.acceptPendingTerms(ctx)
+			}
 			if err == nil {
 				return nil
 			}
 		}
 		// self._webservices = self.data["webservices"]
 		errs = append(errs, err.Error())
-		fmt.Printf("Login failed\n")
+		lastErr = err
+		r.log(LogLevelError, "Login failed", "apple_id", r.appleID, "err", err)
 	}
 
-	return fmt.Errorf("login failed: %s", strings.Join(errs, "; "))
+	// wrap the last error, so callers can check typed errors like ErrTwoFACodeRequired
+	return fmt.Errorf("login failed: %s%w", strings.Join(append(errs[:len(errs)-1], ""), "; "), lastErr)
 }
 
 func getPassword(appleID string, passwordGetter TextGetter) (string, error) {
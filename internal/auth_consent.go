@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ConsentKind is the kind of step iCloud asks for before the account can use the web.
+type ConsentKind string
+
+const (
+	// ConsentTerms is new iCloud terms and conditions to accept
+	ConsentTerms ConsentKind = "terms"
+	// ConsentRepair is the account setup iCloud asks to complete, like the first sign-in of a managed Apple ID,
+	// or the privacy consent of a new account
+	ConsentRepair ConsentKind = "repair"
+)
+
+// ConsentRequest is a step the sign-in can't go on without, it's given to the ConsentHandler of the client.
+type ConsentRequest struct {
+	Kind    ConsentKind
+	AppleID string
+	// Message tells what is asked, to show to the user
+	Message string
+	// URL is where the terms can be read, or the step completed on the web, it may be empty
+	URL string
+	// TermsVersion is the version of the terms to accept, for ConsentTerms
+	TermsVersion int
+}
+
+// ConsentHandler is asked for the consent or terms steps of a sign-in, it returns true to accept,
+// false makes the sign-in fail with a ConsentRequiredError.
+type ConsentHandler func(req *ConsentRequest) (bool, error)
+
+// ErrConsentRequired is the error ConsentRequiredError matches with errors.Is.
+var ErrConsentRequired = NewError("consent_required", "the account needs a consent step to sign in")
+
+// ConsentRequiredError is returned by Authenticate when a consent step is declined, or there is no ConsentHandler to ask.
+type ConsentRequiredError struct {
+	Request *ConsentRequest
+}
+
+func (e *ConsentRequiredError) Error() string {
+	msg := fmt.Sprintf("%s: %s", ErrConsentRequired.Code, e.Request.Message)
+	if e.Request.URL != "" {
+		msg += ", see " + e.Request.URL
+	}
+	return msg
+}
+
+func (e *ConsentRequiredError) Is(target error) bool {
+	return target == ErrConsentRequired
+}
+
+// askConsent asks the ConsentHandler of the client, and returns a ConsentRequiredError when it declines.
+func (r *Client) askConsent(req *ConsentRequest) error {
+	req.AppleID = r.appleID
+	r.log(LogLevelInfo, "Consent required", "apple_id", r.appleID, "kind", string(req.Kind))
+	if r.consentHandler == nil {
+		return &ConsentRequiredError{Request: req}
+	}
+	accepted, err := r.consentHandler(req)
+	if err != nil {
+		return fmt.Errorf("ask consent failed, err: %w", err)
+	}
+	if !accepted {
+		return &ConsentRequiredError{Request: req}
+	}
+	return nil
+}
+
+// completeRepair completes the account setup signin answered with 412 for,
+// the repair session token of the signin response is sent back.
+func (r *Client) completeRepair(ctx context.Context, repairSessionToken string) error {
+	if err := r.askConsent(&ConsentRequest{
+		Kind:    ConsentRepair,
+		Message: "the account needs its setup completed before signing in to iCloud.com, like accepting the privacy notice of a managed or new Apple ID",
+		URL:     r.homeEndpoint,
+	}); err != nil {
+		return err
+	}
+
+	session := r.session()
+	headers := r.getAuthHeaders(map[string]string{})
+	headers = setIfNotEmpty(headers, "scnt", session.Scnt)
+	headers = setIfNotEmpty(headers, "X-Apple-ID-Session-Id", session.SessionID)
+	headers = setIfNotEmpty(headers, "X-Apple-Repair-Session-Token", repairSessionToken)
+	_, err := r.request(&rawReq{
+		Context:      ctx,
+		Method:       http.MethodPost,
+		URL:          r.authEndpoint + "/repair/complete",
+		Headers:      headers,
+		Body:         map[string]any{},
+		ExpectStatus: newSet(http.StatusOK, http.StatusNoContent),
+	})
+	if err != nil {
+		return fmt.Errorf("complete account repair failed, err: %w", err)
+	}
+	return nil
+}
+
+// acceptPendingTerms accepts the iCloud terms the account login reported as updated,
+// and logs in again with the session token, so the services show up.
+func (r *Client) acceptPendingTerms(ctx context.Context) error {
+	data := r.data()
+	if data == nil {
+		return nil
+	}
+	if data.IsRepairNeeded {
+		return r.askConsentOnWeb(data)
+	}
+	if !data.TermsUpdateNeeded {
+		return nil
+	}
+
+	locale := "en_US"
+	if data.DsInfo != nil && data.DsInfo.Locale != "" {
+		locale = data.DsInfo.Locale
+	}
+	text, err := r.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.setupEndpoint + "/getTerms",
+		Headers: r.getCommonHeaders(map[string]string{}),
+		Body:    map[string]any{"locale": locale},
+	})
+	if err != nil {
+		return fmt.Errorf("get terms failed, err: %w", err)
+	}
+	terms := new(getTermsResp)
+	if err = json.Unmarshal([]byte(text), terms); err != nil {
+		return fmt.Errorf("get terms unmarshal failed, err: %w, text: %s", err, text)
+	}
+	termsURL := terms.ICloudTerms.URL
+	if termsURL == "" {
+		termsURL = data.ConfigBag.Urls.DownloadICloudTerms
+	}
+
+	if err := r.askConsent(&ConsentRequest{
+		Kind:         ConsentTerms,
+		Message:      "the iCloud terms and conditions were updated, and must be accepted to use iCloud.com",
+		URL:          termsURL,
+		TermsVersion: terms.ICloudTerms.Version,
+	}); err != nil {
+		return err
+	}
+
+	if _, err = r.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.setupEndpoint + "/acceptTerms",
+		Headers: r.getCommonHeaders(map[string]string{}),
+		Body:    map[string]any{"acceptedICloudTerms": terms.ICloudTerms.Version},
+	}); err != nil {
+		return fmt.Errorf("accept terms failed, err: %w", err)
+	}
+	r.log(LogLevelInfo, "Accepted iCloud terms", "apple_id", r.appleID, "version", terms.ICloudTerms.Version)
+	return r.authWithToken(ctx)
+}
+
+// askConsentOnWeb fails with the repair step the account login reported, it can only be completed on the web,
+// after signin the repair session token is gone.
+func (r *Client) askConsentOnWeb(data *ValidateData) error {
+	url := data.ConfigBag.Urls.AccountRepairUI
+	if url == "" {
+		url = r.homeEndpoint
+	}
+	return &ConsentRequiredError{Request: &ConsentRequest{
+		Kind:    ConsentRepair,
+		AppleID: r.appleID,
+		Message: "the account needs its setup completed on iCloud.com before it can be used, sign in there once",
+		URL:     url,
+	}}
+}
+
+type getTermsResp struct {
+	ICloudTerms struct {
+		Version int    `json:"version"`
+		URL     string `json:"url"`
+	} `json:"iCloudTerms"`
+}
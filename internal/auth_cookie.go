@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthenticateWithCookies logs in with the cookies of a browser session on icloud.com[.cn],
+// like the Cookie header copied from the browser developer tools.
+//
+// The cookies are stored in the cookie jar, so later runs can call it with an empty header
+// to check that the saved browser session is still valid.
+func (r *Client) AuthenticateWithCookies(cookieHeader string) error {
+	r.authLock.Lock()
+	defer r.authLock.Unlock()
+
+	cookies := parseCookieHeader(cookieHeader)
+	if len(cookies) > 0 {
+		if err := r.setSessionCookies(cookies); err != nil {
+			return err
+		}
+	}
+
+	if err := r.validateToken(context.Background()); err != nil {
+		return fmt.Errorf("authenticate with cookies failed, err: %w", err)
+	}
+	if data := r.data(); data == nil || data.DsInfo == nil {
+		return fmt.Errorf("authenticate with cookies failed, the browser session is not logged in")
+	}
+	return r.flush()
+}
+
+func (r *Client) setSessionCookies(cookies []*http.Cookie) error {
+	setupURL, err := url.Parse(r.setupEndpoint)
+	if err != nil {
+		return fmt.Errorf("parse setup endpoint failed, err: %w", err)
+	}
+	// the cookies of www.icloud.com are shared with setup.icloud.com, ckdatabasews and so on
+	domain := strings.TrimPrefix(setupURL.Hostname(), "setup.")
+	for _, v := range cookies {
+		v.Domain = domain
+		v.Path = "/"
+		v.Secure = true
+	}
+
+	jar := r.httpCli.Jar()
+	for _, u := range []string{r.setupEndpoint, r.homeEndpoint} {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("parse endpoint %s failed, err: %w", u, err)
+		}
+		jar.SetCookies(parsed, cookies)
+	}
+	return nil
+}
+
+// parseCookieHeader parses "a=1; b=2", a leading "Cookie:" is allowed.
+func parseCookieHeader(header string) []*http.Cookie {
+	header = strings.TrimSpace(header)
+	if len(header) >= len("cookie:") && strings.EqualFold(header[:len("cookie:")], "cookie:") {
+		header = strings.TrimSpace(header[len("cookie:"):])
+	}
+	if header == "" {
+		return nil
+	}
+	req := http.Request{Header: http.Header{"Cookie": []string{header}}}
+	return req.Cookies()
+}
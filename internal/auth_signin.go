@@ -1,36 +1,45 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
 
-func (r *Client) signIn(password string) error {
+func (r *Client) signIn(ctx context.Context, password string) error {
+	session := r.session()
 	body := map[string]any{
 		"accountName": r.appleID,
 		"password":    password,
 		"rememberMe":  true,
 		"trustTokens": []string{},
 	}
-	if r.sessionData.TrustToken != "" {
-		body["trustTokens"] = []string{r.sessionData.TrustToken}
+	if session.TrustToken != "" {
+		body["trustTokens"] = []string{session.TrustToken}
 	}
 
 	headers := r.getAuthHeaders(map[string]string{})
-	headers = setIfNotEmpty(headers, "scnt", r.sessionData.Scnt)
-	headers = setIfNotEmpty(headers, "X-Apple-ID-Session-Id", r.sessionData.SessionID)
+	headers = setIfNotEmpty(headers, "scnt", session.Scnt)
+	headers = setIfNotEmpty(headers, "X-Apple-ID-Session-Id", session.SessionID)
 
-	_, err := r.request(&rawReq{
+	_, _, status, err := r.doRequest(&rawReq{
+		Context:      ctx,
 		Method:       http.MethodPost,
 		URL:          r.authEndpoint + "/signin",
 		Headers:      headers,
 		Querys:       map[string]string{"isRememberMeEnabled": "true"},
 		Body:         body,
-		ExpectStatus: newSet[int](http.StatusOK),
+		ExpectStatus: newSet[int](http.StatusOK, http.StatusPreconditionFailed),
 	})
 	if err != nil {
 		return fmt.Errorf("signin failed: %w", err)
 	}
+	if status == http.StatusPreconditionFailed {
+		// the account needs a consent step, like a managed Apple ID signing in for the first time
+		if err := r.completeRepair(ctx, r.session().RepairSessionToken); err != nil {
+			return fmt.Errorf("signin failed: %w", err)
+		}
+	}
 
-	return r.authWithToken()
+	return r.authWithToken(ctx)
 }
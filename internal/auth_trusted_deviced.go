@@ -1,14 +1,55 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 )
 
+// TrustedDevice is a device or phone number the code of a sign-in can be sent to.
+type TrustedDevice struct {
+	ID          string
+	Name        string // like "iPhone", or "SMS to (•••) •••-••12"
+	PhoneNumber string // the phone number, obfuscated like (•••) •••-••12, empty for devices
+	SMS         bool   // the code is sent by SMS, else by a phone call or to the device
+
+	phoneID int            // the id of the trusted phone number, for two-factor authentication
+	raw     map[string]any // the device listDevices returns, sent back for two-step authentication
+}
+
+// TwoFactorRequest is the code a sign-in asks for, it's given to the TrustedDeviceSelector of the client.
+type TwoFactorRequest struct {
+	AppleID string
+	// HSAVersion is 2 for two-factor authentication, 1 for the older two-step authentication
+	HSAVersion int
+	// Devices are where the code can be sent to, the trusted phone numbers for two-factor authentication,
+	// the trusted devices and phone numbers for two-step authentication
+	Devices []*TrustedDevice
+}
+
+// TrustedDeviceSelector picks where the code of a sign-in is sent, from req.Devices.
+// Nil keeps the code two-factor authentication pushes to the trusted Apple devices,
+// and fails two-step authentication with ErrTwoStepRequired.
+type TrustedDeviceSelector func(req *TwoFactorRequest) (*TrustedDevice, error)
+
+// selectTrustedDevice asks the TrustedDeviceSelector of the client, nil when there is none, or it picks none.
+func (r *Client) selectTrustedDevice(hsaVersion int, devices []*TrustedDevice) (*TrustedDevice, error) {
+	if r.trustedDeviceSelector == nil || len(devices) == 0 {
+		return nil, nil
+	}
+	device, err := r.trustedDeviceSelector(&TwoFactorRequest{AppleID: r.appleID, HSAVersion: hsaVersion, Devices: devices})
+	if err != nil {
+		return nil, fmt.Errorf("select trusted device failed, err: %w", err)
+	}
+	return device, nil
+}
+
 // Returns devices trusted for two-step authentication.
-func (r *Client) trustedDevices() ([]*device, error) {
+func (r *Client) trustedDevices(ctx context.Context) ([]*TrustedDevice, error) {
 	text, err := r.request(&rawReq{
+		Context: ctx,
 		Method:  http.MethodPost,
 		URL:     r.setupEndpoint + "/listDevices",
 		Headers: r.getCommonHeaders(map[string]string{}),
@@ -20,21 +61,192 @@ func (r *Client) trustedDevices() ([]*device, error) {
 	if err = json.Unmarshal([]byte(text), res); err != nil {
 		return nil, fmt.Errorf("listDevices unmarshal failed, text: %s", text)
 	}
-	return res.Devices, nil
+	devices := make([]*TrustedDevice, 0, len(res.Devices))
+	for _, raw := range res.Devices {
+		device := &TrustedDevice{raw: raw}
+		device.ID, _ = raw["deviceId"].(string)
+		device.PhoneNumber, _ = raw["phoneNumber"].(string)
+		deviceType, _ := raw["deviceType"].(string)
+		device.SMS = deviceType == "SMS"
+		if device.Name, _ = raw["deviceName"].(string); device.Name == "" {
+			device.Name = fmt.Sprintf("SMS to %s", device.PhoneNumber)
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
 }
 
 type trustedDevicesResp struct {
-	Devices []*device `json:"devices"`
+	Devices []map[string]any `json:"devices"`
+}
+
+// sendVerificationCode sends the code of two-step authentication to the device.
+func (r *Client) sendVerificationCode(ctx context.Context, device *TrustedDevice) error {
+	text, err := r.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.setupEndpoint + "/sendVerificationCode",
+		Headers: r.getCommonHeaders(map[string]string{}),
+		Body:    device.raw,
+	})
+	if err != nil {
+		return fmt.Errorf("sendVerificationCode failed, err: %w", err)
+	}
+	res := new(struct {
+		Success bool `json:"success"`
+	})
+	if err = json.Unmarshal([]byte(text), res); err != nil || !res.Success {
+		return fmt.Errorf("sendVerificationCode failed, text: %s", text)
+	}
+	return nil
+}
+
+// validateVerificationCode validates the code of two-step authentication sent to the device, and trusts the browser.
+func (r *Client) validateVerificationCode(ctx context.Context, device *TrustedDevice, code string) error {
+	body := make(map[string]any, len(device.raw)+2)
+	for k, v := range device.raw {
+		body[k] = v
+	}
+	body["verificationCode"] = code
+	body["trustBrowser"] = true
+
+	if _, err := r.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.setupEndpoint + "/validateVerificationCode",
+		Headers: r.getCommonHeaders(map[string]string{}),
+		Body:    body,
+	}); err != nil {
+		if IsErrorCode(err, ErrValidateCodeWrong.Code) {
+			return ErrValidateCodeWrong
+		}
+		return fmt.Errorf("validateVerificationCode failed: %w", err)
+	}
+
+	if err := r.authWithToken(ctx); err != nil {
+		return err
+	}
+	if r.isRequires2SA() {
+		return fmt.Errorf("2SA is still required after validateVerificationCode")
+	}
+	return nil
+}
+
+// authOptions are how the code of two-factor authentication can be sent.
+type authOptions struct {
+	TrustedPhoneNumbers []struct {
+		ID                 int    `json:"id"`
+		NumberWithDialCode string `json:"numberWithDialCode"`
+		ObfuscatedNumber   string `json:"obfuscatedNumber"`
+		PushMode           string `json:"pushMode"`
+	} `json:"trustedPhoneNumbers"`
+	NoTrustedDevices bool            `json:"noTrustedDevices"`
+	FsaChallenge     json.RawMessage `json:"fsaChallenge"`
+}
+
+// getAuthOptions returns how the code of two-factor authentication can be sent, as the sign-in page asks.
+func (r *Client) getAuthOptions(ctx context.Context) (*authOptions, error) {
+	session := r.session()
+	headers := r.getAuthHeaders(map[string]string{"Accept": "application/json"})
+	headers = setIfNotEmpty(headers, "scnt", session.Scnt)
+	headers = setIfNotEmpty(headers, "X-Apple-ID-Session-Id", session.SessionID)
+
+	text, err := r.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodGet,
+		URL:     r.authEndpoint,
+		Headers: headers,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get auth options failed, err: %w", err)
+	}
+	res := new(authOptions)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, fmt.Errorf("get auth options unmarshal failed, text: %s", text)
+	}
+	return res, nil
+}
+
+// trustedPhones returns the trusted phone numbers of the auth options.
+func (r *authOptions) trustedPhones() []*TrustedDevice {
+	devices := make([]*TrustedDevice, 0, len(r.TrustedPhoneNumbers))
+	for _, phone := range r.TrustedPhoneNumbers {
+		number := phone.NumberWithDialCode
+		if number == "" {
+			number = phone.ObfuscatedNumber
+		}
+		mode := "SMS"
+		if phone.PushMode == "voice" {
+			mode = "Call"
+		}
+		devices = append(devices, &TrustedDevice{
+			ID:          strconv.Itoa(phone.ID),
+			Name:        fmt.Sprintf("%s to %s", mode, number),
+			PhoneNumber: number,
+			SMS:         phone.PushMode != "voice",
+			phoneID:     phone.ID,
+		})
+	}
+	return devices
+}
+
+// requestPhoneCode sends the code of two-factor authentication to the trusted phone number, by SMS or a call.
+func (r *Client) requestPhoneCode(ctx context.Context, device *TrustedDevice) error {
+	session := r.session()
+	headers := r.getAuthHeaders(map[string]string{"Accept": "application/json"})
+	headers = setIfNotEmpty(headers, "scnt", session.Scnt)
+	headers = setIfNotEmpty(headers, "X-Apple-ID-Session-Id", session.SessionID)
+
+	if _, err := r.request(&rawReq{
+		Context:      ctx,
+		Method:       http.MethodPut,
+		URL:          r.authEndpoint + "/verify/phone",
+		Headers:      headers,
+		Body:         map[string]any{"phoneNumber": map[string]any{"id": device.phoneID}, "mode": phoneMode(device)},
+		ExpectStatus: newSet[int](http.StatusOK, http.StatusAccepted),
+	}); err != nil {
+		return fmt.Errorf("request phone code failed: %w", err)
+	}
+	return nil
 }
 
-type device struct {
-	DeviceName  string `json:"deviceName"`
-	PhoneNumber string `json:"phoneNumber"`
+// validatePhoneCode is like validate2FACode, for a code sent to a trusted phone number.
+func (r *Client) validatePhoneCode(ctx context.Context, device *TrustedDevice, code string) error {
+	session := r.session()
+	headers := r.getAuthHeaders(map[string]string{"Accept": "application/json"})
+	headers = setIfNotEmpty(headers, "scnt", session.Scnt)
+	headers = setIfNotEmpty(headers, "X-Apple-ID-Session-Id", session.SessionID)
+
+	if _, err := r.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.authEndpoint + "/verify/phone/securitycode",
+		Headers: headers,
+		Body: map[string]any{
+			"phoneNumber":  map[string]any{"id": device.phoneID},
+			"securityCode": map[string]string{"code": code},
+			"mode":         phoneMode(device),
+		},
+		ExpectStatus: newSet[int](http.StatusOK, http.StatusNoContent),
+	}); err != nil {
+		if IsErrorCode(err, ErrValidateCodeWrong.Code) {
+			return ErrValidateCodeWrong
+		}
+		return fmt.Errorf("validatePhoneCode failed: %w", err)
+	}
+
+	if err := r.trustSession(ctx); err != nil {
+		return err
+	}
+	if r.isRequires2FA() {
+		return fmt.Errorf("2FA is still required after validatePhoneCode")
+	}
+	return nil
 }
 
-func (r *device) GetName() string {
-	if r.DeviceName != "" {
-		return r.DeviceName
+func phoneMode(device *TrustedDevice) string {
+	if device.SMS {
+		return "sms"
 	}
-	return fmt.Sprintf("SMS to %s", r.PhoneNumber)
+	return "voice"
 }
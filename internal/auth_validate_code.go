@@ -1,18 +1,21 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
 
-func (r *Client) validate2FACode(code string) error {
+func (r *Client) validate2FACode(ctx context.Context, code string) error {
+	session := r.session()
 	body := map[string]interface{}{"securityCode": map[string]string{"code": code}}
 
 	headers := r.getAuthHeaders(map[string]string{"Accept": "application/json"})
-	headers = setIfNotEmpty(headers, "scnt", r.sessionData.Scnt)
-	headers = setIfNotEmpty(headers, "X-Apple-ID-Session-Id", r.sessionData.SessionID)
+	headers = setIfNotEmpty(headers, "scnt", session.Scnt)
+	headers = setIfNotEmpty(headers, "X-Apple-ID-Session-Id", session.SessionID)
 
 	if _, err := r.request(&rawReq{
+		Context:      ctx,
 		Method:       http.MethodPost,
 		URL:          r.authEndpoint + "/verify/trusteddevice/securitycode",
 		Headers:      headers,
@@ -25,7 +28,7 @@ func (r *Client) validate2FACode(code string) error {
 		return fmt.Errorf("validate2FACode failed: %w", err)
 	}
 
-	if err := r.trustSession(); err != nil {
+	if err := r.trustSession(ctx); err != nil {
 		return err
 	}
 
@@ -1,15 +1,17 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 )
 
-func (r *Client) validateToken() error {
-	fmt.Printf("Checking session token validity\n")
+func (r *Client) validateToken(ctx context.Context) error {
+	r.log(LogLevelInfo, "Checking session token validity")
 
 	text, err := r.request(&rawReq{
+		Context: ctx,
 		Method:  http.MethodPost,
 		URL:     r.setupEndpoint + "/validate",
 		Headers: r.getCommonHeaders(map[string]string{}),
@@ -22,7 +24,7 @@ func (r *Client) validateToken() error {
 	if err = json.Unmarshal([]byte(text), res); err != nil {
 		return fmt.Errorf("validateToken unmarshal failed, err: %w, text: %s", err, text)
 	}
-	r.Data = res
+	r.setData(res)
 
 	return nil
 }
@@ -1,49 +1,108 @@
 package internal
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"strings"
 )
 
-func (r *Client) verify2Fa() error {
-	if r.Data == nil || r.Data.DsInfo == nil {
+func (r *Client) verify2Fa(ctx context.Context) error {
+	data := r.data()
+	if data == nil || data.DsInfo == nil {
 		return fmt.Errorf("not authenticated validate data")
 	}
 
 	if r.isRequires2FA() {
-		code, err := r.twoFACodeGetter(r.appleID)
-		if err != nil {
-			return fmt.Errorf("get 2fa code failed, err: %w", err)
+		return r.verifyTwoFactor(ctx, data)
+	} else if r.isRequires2SA() {
+		return r.verifyTwoStep(ctx)
+	}
+	return nil
+}
+
+// verifyTwoFactor validates the code of two-factor authentication (HSA2), pushed to the trusted Apple devices,
+// or sent to the trusted phone number the TrustedDeviceSelector picks.
+func (r *Client) verifyTwoFactor(ctx context.Context, data *ValidateData) error {
+	var phone *TrustedDevice
+	options, err := r.getAuthOptions(ctx)
+	if err != nil {
+		// the code pushed to the devices works without the options
+		r.log(LogLevelWarn, "Get auth options failed, using the code of the trusted devices", "err", err)
+	} else {
+		if len(options.FsaChallenge) > 0 && string(options.FsaChallenge) != "null" {
+			return ErrSecurityKeyRequired
 		}
-		if err := r.validate2FACode(code); err != nil {
+		phones := options.trustedPhones()
+		if phone, err = r.selectTrustedDevice(2, phones); err != nil {
 			return err
 		}
-
-		if !r.Data.HsaTrustedBrowser {
-			if err := r.trustSession(); err != nil {
-				return err
-			}
+		if phone == nil && options.NoTrustedDevices && len(phones) > 0 {
+			// there is no device to push the code to, it can only be sent to a phone number
+			phone = phones[0]
 		}
-	} else if r.isRequires2SA() {
-		fmt.Printf("Two-step authentication required. Your trusted devices are:\n")
-		devices, err := r.trustedDevices()
-		if err != nil {
+	}
+	if phone != nil {
+		if err := r.requestPhoneCode(ctx, phone); err != nil {
 			return err
 		}
+		r.log(LogLevelInfo, "2FA code sent", "to", phone.Name)
+	}
+
+	code, err := r.twoFACodeGetter(r.appleID)
+	if err != nil {
+		return fmt.Errorf("get 2fa code failed, err: %w", err)
+	}
+	if phone != nil {
+		return r.validatePhoneCode(ctx, phone, code)
+	}
+	if err := r.validate2FACode(ctx, code); err != nil {
+		return err
+	}
+
+	if !data.HsaTrustedBrowser {
+		if err := r.trustSession(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyTwoStep validates the code of two-step authentication (HSA1), sent to the trusted device the TrustedDeviceSelector picks.
+func (r *Client) verifyTwoStep(ctx context.Context) error {
+	devices, err := r.trustedDevices(ctx)
+	if err != nil {
+		return err
+	}
+	device, err := r.selectTrustedDevice(1, devices)
+	if err != nil {
+		return err
+	}
+	if device == nil {
+		names := make([]string, 0, len(devices))
 		for i, device := range devices {
-			fmt.Printf("  %d: %s\n", i, device.GetName())
+			names = append(names, fmt.Sprintf("%d: %s", i, device.Name))
 		}
+		r.log(LogLevelWarn, "Two-step authentication required", "trusted_devices", strings.Join(names, ", "))
+		return ErrTwoStepRequired
+	}
 
-		fmt.Printf("not impl")
-		os.Exit(1)
+	if err := r.sendVerificationCode(ctx, device); err != nil {
+		return err
 	}
-	return nil
+	r.log(LogLevelInfo, "2SA code sent", "to", device.Name)
+	code, err := r.twoFACodeGetter(r.appleID)
+	if err != nil {
+		return fmt.Errorf("get 2fa code failed, err: %w", err)
+	}
+	return r.validateVerificationCode(ctx, device, code)
 }
 
 func (r *Client) isRequires2FA() bool {
-	return r.Data.DsInfo.HsaVersion == 2 && (r.Data.HsaChallengeRequired || !r.Data.HsaTrustedBrowser)
+	data := r.data()
+	return data.DsInfo.HsaVersion == 2 && (data.HsaChallengeRequired || !data.HsaTrustedBrowser)
 }
 
 func (r *Client) isRequires2SA() bool {
-	return r.Data.DsInfo.HsaVersion >= 1 && (r.Data.HsaChallengeRequired || !r.Data.HsaTrustedBrowser)
+	data := r.data()
+	return data.DsInfo.HsaVersion >= 1 && (data.HsaChallengeRequired || !data.HsaTrustedBrowser)
 }
@@ -1,12 +1,14 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 )
 
-func (r *Client) authWithCredentialsService(service, password string) error {
+func (r *Client) authWithCredentialsService(ctx context.Context, service, password string) error {
 	_, err := r.request(&rawReq{
+		Context: ctx,
 		Method:  http.MethodPost,
 		URL:     r.setupEndpoint + "/accountLogin",
 		Headers: r.getCommonHeaders(map[string]string{}),
@@ -20,5 +22,5 @@ func (r *Client) authWithCredentialsService(service, password string) error {
 		return fmt.Errorf("authWithCredentialsService failed, err: %w", err)
 	}
 
-	return r.validateToken()
+	return r.validateToken(ctx)
 }
@@ -1,22 +1,25 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 )
 
 // auth using session token
-func (r *Client) authWithToken() error {
+func (r *Client) authWithToken(ctx context.Context) error {
+	session := r.session()
 	text, err := r.request(&rawReq{
+		Context: ctx,
 		Method:  http.MethodPost,
 		URL:     r.setupEndpoint + "/accountLogin",
 		Headers: r.getCommonHeaders(map[string]string{}),
 		Body: map[string]any{
-			"accountCountryCode": r.sessionData.AccountCountry,
-			"dsWebAuthToken":     r.sessionData.SessionToken,
+			"accountCountryCode": session.AccountCountry,
+			"dsWebAuthToken":     session.SessionToken,
 			"extended_login":     true,
-			"trustToken":         r.sessionData.TrustToken,
+			"trustToken":         session.TrustToken,
 		},
 		ExpectStatus: newSet[int](200),
 	})
@@ -28,6 +31,6 @@ func (r *Client) authWithToken() error {
 	if err = json.Unmarshal([]byte(text), data); err != nil {
 		return fmt.Errorf("authWithToken unmarshal failed, text: %s", text)
 	}
-	r.Data = data
+	r.setData(data)
 	return nil
 }
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CalendarService is the calendars of the account, get it with Client.CalendarCli.
+type CalendarService struct {
+	icloud      *Client
+	serviceRoot string
+}
+
+// CalendarCli returns the calendar service of the account, it's created once and cached by the client.
+func (r *Client) CalendarCli() (*CalendarService, error) {
+	r.calendarLock.Lock()
+	defer r.calendarLock.Unlock()
+
+	if r.calendar == nil {
+		serviceRoot, err := r.getWebServiceURL("calendar")
+		if err != nil {
+			return nil, err
+		}
+		r.calendar = &CalendarService{icloud: r, serviceRoot: serviceRoot}
+	}
+	return r.calendar, nil
+}
+
+// Calendar is a calendar of the account, or shared with it.
+type Calendar struct {
+	ID       string
+	Title    string
+	Color    string // like #1badf8
+	Enabled  bool
+	ReadOnly bool
+}
+
+// CalendarEvent is an event of a calendar, a recurring event is an event per occurrence.
+type CalendarEvent struct {
+	ID         string
+	CalendarID string
+	Title      string
+	Location   string
+	// Start and End are in UTC, for all-day events, the dates are the days of the event, End excluded
+	Start  time.Time
+	End    time.Time
+	AllDay bool
+	// TimeZone is the time zone the event was created in, like Europe/Paris, empty for floating events
+	TimeZone string
+	// Recurring is set for the occurrences of a recurring event
+	Recurring bool
+}
+
+// calendarEventsWindow is the date range of an events request, longer ranges are fetched in several requests.
+const calendarEventsWindow = 90 * 24 * time.Hour
+
+// Calendars returns the calendars of the account.
+func (r *CalendarService) Calendars() ([]*Calendar, error) {
+	return r.CalendarsContext(context.Background())
+}
+
+// CalendarsContext is like Calendars, the request is given up when ctx is done.
+func (r *CalendarService) CalendarsContext(ctx context.Context) ([]*Calendar, error) {
+	now := time.Now().UTC()
+	text, err := r.request(ctx, "startup", now, now)
+	if err != nil {
+		return nil, fmt.Errorf("get calendars failed, err: %w", err)
+	}
+	res := new(calendarStartupResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, fmt.Errorf("get calendars unmarshal failed, err: %w, text: %s", err, text)
+	}
+
+	calendars := make([]*Calendar, 0, len(res.Collection))
+	for _, v := range res.Collection {
+		calendars = append(calendars, &Calendar{
+			ID:       v.GUID,
+			Title:    v.Title,
+			Color:    v.Color,
+			Enabled:  v.Enabled,
+			ReadOnly: v.ReadOnly,
+		})
+	}
+	return calendars, nil
+}
+
+// Events returns the events of every calendar between from and to, by day, to included.
+func (r *CalendarService) Events(from, to time.Time) ([]*CalendarEvent, error) {
+	return r.EventsContext(context.Background(), from, to)
+}
+
+// EventsContext is like Events, the requests are given up when ctx is done.
+func (r *CalendarService) EventsContext(ctx context.Context, from, to time.Time) ([]*CalendarEvent, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("get calendar events failed, err: the end %s is before the start %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+
+	// an event spanning two windows is returned twice
+	seen := map[string]bool{}
+	var events []*CalendarEvent
+	for start := from; !start.After(to); start = start.Add(calendarEventsWindow) {
+		end := start.Add(calendarEventsWindow - 24*time.Hour)
+		if end.After(to) {
+			end = to
+		}
+		text, err := r.request(ctx, "events", start, end)
+		if err != nil {
+			return nil, fmt.Errorf("get calendar events failed, err: %w", err)
+		}
+		res := new(calendarEventsResp)
+		if err = json.Unmarshal([]byte(text), res); err != nil {
+			return nil, fmt.Errorf("get calendar events unmarshal failed, err: %w, text: %s", err, text)
+		}
+		for _, v := range res.Event {
+			event := &CalendarEvent{
+				ID:         v.GUID,
+				CalendarID: v.PGUID,
+				Title:      v.Title,
+				Location:   v.Location,
+				Start:      calendarDate(v.StartDate),
+				End:        calendarDate(v.EndDate),
+				AllDay:     v.AllDay,
+				Recurring:  v.RecurrenceMaster || v.RecurrenceException || v.Recurrence != "",
+			}
+			if v.TZ != "floating" {
+				event.TimeZone = v.TZ
+			}
+			if event.AllDay && !event.End.After(event.Start) {
+				event.End = event.Start.AddDate(0, 0, 1)
+			}
+			key := event.ID + "/" + event.Start.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// calendarDate parses the dates of iCloud, like [20230105, 2023, 1, 5, 10, 30, 630],
+// the day as a number, year, month, day, hour, minute, and the minutes of the day.
+func calendarDate(v []int) time.Time {
+	if len(v) < 6 {
+		return time.Time{}
+	}
+	return time.Date(v[1], time.Month(v[2]), v[3], v[4], v[5], 0, 0, time.UTC)
+}
+
+// request GETs the calendar endpoint for the date range, the dates of the response are in UTC.
+func (r *CalendarService) request(ctx context.Context, path string, from, to time.Time) (string, error) {
+	return r.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodGet,
+		URL:     r.serviceRoot + "/ca/" + path,
+		Querys: map[string]string{
+			"dsid":      r.icloud.DSID(),
+			"lang":      "en-us",
+			"usertz":    "UTC",
+			"startDate": from.Format("2006-01-02"),
+			"endDate":   to.Format("2006-01-02"),
+		},
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+	})
+}
+
+type calendarStartupResp struct {
+	Collection []struct {
+		GUID     string `json:"guid"`
+		Title    string `json:"title"`
+		Color    string `json:"color"`
+		Enabled  bool   `json:"enabled"`
+		ReadOnly bool   `json:"readOnly"`
+	} `json:"Collection"`
+}
+
+type calendarEventsResp struct {
+	Event []struct {
+		GUID                string `json:"guid"`
+		PGUID               string `json:"pGuid"`
+		Title               string `json:"title"`
+		Location            string `json:"location"`
+		StartDate           []int  `json:"startDate"`
+		EndDate             []int  `json:"endDate"`
+		AllDay              bool   `json:"allDay"`
+		TZ                  string `json:"tz"`
+		Recurrence          string `json:"recurrence"`
+		RecurrenceMaster    bool   `json:"recurrenceMaster"`
+		RecurrenceException bool   `json:"recurrenceException"`
+	} `json:"Event"`
+}
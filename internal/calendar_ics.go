@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteICS writes the events to w as an iCalendar file, RFC 5545, named after calendar, which may be nil.
+// The times are in UTC, and the occurrences of a recurring event are separate events.
+func WriteICS(w io.Writer, calendar *Calendar, events []*CalendarEvent) error {
+	ics := new(contentLines)
+	ics.line("BEGIN", "VCALENDAR")
+	ics.line("VERSION", "2.0")
+	ics.line("PRODID", "-//chyroc//icloudgo//EN")
+	ics.line("CALSCALE", "GREGORIAN")
+	if calendar != nil {
+		ics.optional("X-WR-CALNAME", escapeText(calendar.Title))
+	}
+	stamp := time.Now().UTC().Format(icsDateTime)
+	for _, event := range events {
+		uid := event.ID
+		if event.Recurring {
+			// the occurrences share the id of the event
+			uid += "-" + event.Start.Format(icsDateTime)
+		}
+		ics.line("BEGIN", "VEVENT")
+		ics.line("UID", escapeText(uid))
+		ics.line("DTSTAMP", stamp)
+		if event.AllDay {
+			ics.line("DTSTART;VALUE=DATE", event.Start.Format(icsDate))
+			ics.line("DTEND;VALUE=DATE", event.End.Format(icsDate))
+		} else {
+			ics.line("DTSTART", event.Start.Format(icsDateTime))
+			ics.line("DTEND", event.End.Format(icsDateTime))
+		}
+		ics.optional("SUMMARY", escapeText(event.Title))
+		ics.optional("LOCATION", escapeText(event.Location))
+		ics.line("END", "VEVENT")
+	}
+	ics.line("END", "VCALENDAR")
+
+	if _, err := io.WriteString(w, ics.String()); err != nil {
+		return fmt.Errorf("write ics failed, err: %w", err)
+	}
+	return nil
+}
+
+// Filename is the file name the calendar is exported as, like Work.ics.
+func (r *Calendar) Filename() string {
+	return cleanName(r.Title) + ".ics"
+}
+
+const (
+	icsDate     = "20060102"
+	icsDateTime = "20060102T150405Z"
+)
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/chyroc/gorequests"
 	uuid "github.com/satori/go.uuid"
@@ -12,11 +14,17 @@ import (
 
 type TextGetter func(appleID string) (string, error)
 
+// Client is safe for concurrent use by multiple goroutines, once created:
+// the session is guarded by a lock, concurrent Authenticate calls run one at a time,
+// and the PhotoService, albums and iterators it returns can be shared too.
 type Client struct {
 	// param
 	appleID         string
 	passwordGetter  TextGetter
 	twoFACodeGetter TextGetter
+	consentHandler  ConsentHandler
+
+	trustedDeviceSelector TrustedDeviceSelector
 
 	// storage
 	cookieDir       string
@@ -25,18 +33,58 @@ type Client struct {
 	sessionDataPath string
 
 	// user data
-	clientID    string
-	sessionData *SessionData
-	Data        *ValidateData
-	httpCli     *gorequests.Session
+	clientID     string
+	sessionData  *SessionData
+	validateData *ValidateData
+	httpCli      *gorequests.Session
+
+	// Data is the account data of the last login or validation, it's replaced, not modified, on re-authentication.
+	//
+	// Deprecated: reading Data races with a re-authentication of another goroutine, use AccountData.
+	Data *ValidateData
 
 	// server
 	setupEndpoint string
 	homeEndpoint  string
 	authEndpoint  string
 
+	ckDatabaseEndpoint string
+	downloadEndpoint   string
+
+	// lock guards sessionData and validateData, authLock serializes logins, flushLock the session files
+	lock         sync.RWMutex
+	authLock     sync.Mutex
+	flushLock    sync.Mutex
+	photoLock    sync.Mutex
+	driveLock    sync.Mutex
+	findMyLock   sync.Mutex
+	contactsLock sync.Mutex
+	calendarLock sync.Mutex
+
+	// reauthLock serializes the re-authentications of expired sessions, reauthedAt is when the last one finished
+	reauthLock sync.Mutex
+	reauthedAt time.Time
+
+	// download
+	downloadRetry    *DownloadRetryPolicy
+	downloadHosts    downloadHosts
+	filenameTemplate *FilenameTemplate
+
+	logger Logger
+
+	// rate limit, requestInterval is the min time between two requests, nextRequestAt when the next one is allowed
+	rateLimitLock    sync.Mutex
+	pauseUntil       time.Time
+	rateLimitedCount int64
+	requestInterval  time.Duration
+	nextRequestAt    time.Time
+
 	// service
-	photo *PhotoService
+	photo    *PhotoService
+	drive    *DriveService
+	findMy   *FindMyService
+	contacts *ContactsService
+	calendar *CalendarService
 }
 
 type ClientOption struct {
@@ -44,7 +92,23 @@ type ClientOption struct {
 	CookieDir       string
 	PasswordGetter  TextGetter
 	TwoFACodeGetter TextGetter
-	Domain          string // com,cn
+	ConsentHandler  ConsentHandler // asked for the terms and consent steps of a sign-in, nil fails them with ConsentRequiredError
+	Domain          string         // com,cn
+	Endpoints       *Endpoints
+	DownloadRetry   *DownloadRetryPolicy // nil is DefaultDownloadRetryPolicy
+	Logger          Logger               // nil is DefaultLogger, NopLogger silences the client
+
+	// MaxRequestsPerSecond caps the requests of the client, the downloads included, shared by all its goroutines,
+	// so many download threads don't get throttled, 0 is unlimited
+	MaxRequestsPerSecond float64
+
+	// FilenameTemplate lays out PhotoAsset.LocalPath, like `{{.Date.Format "2006/01"}}/{{.Filename}}`, see FilenameTemplate,
+	// empty is the filename in the output dir
+	FilenameTemplate string
+
+	// TrustedDeviceSelector picks the trusted phone number or device the code of a sign-in is sent to,
+	// like an SMS for a server without an Apple device nearby, nil is the code pushed to the trusted Apple devices
+	TrustedDeviceSelector TrustedDeviceSelector
 }
 
 func NewClient(option *ClientOption) (*Client, error) {
@@ -55,7 +119,16 @@ func newClient(option *ClientOption) (*Client, error) {
 	cli := &Client{
 		twoFACodeGetter: option.TwoFACodeGetter,
 		passwordGetter:  option.PasswordGetter,
+		consentHandler:  option.ConsentHandler,
+		downloadRetry:   option.DownloadRetry,
+		logger:          option.Logger,
+
+		trustedDeviceSelector: option.TrustedDeviceSelector,
+	}
+	if cli.logger == nil {
+		cli.logger = DefaultLogger
 	}
+	cli.SetMaxRequestsPerSecond(option.MaxRequestsPerSecond)
 
 	// domain
 	if option.Domain == "cn" {
@@ -69,6 +142,15 @@ func newClient(option *ClientOption) (*Client, error) {
 	} else {
 		return nil, fmt.Errorf("invalid domain: %s", option.Domain)
 	}
+	cli.applyEndpoints(option.Endpoints)
+
+	if option.FilenameTemplate != "" {
+		tmpl, err := ParseFilenameTemplate(option.FilenameTemplate)
+		if err != nil {
+			return nil, err
+		}
+		cli.filenameTemplate = tmpl
+	}
 
 	// storage
 	{
@@ -105,7 +187,8 @@ func newClient(option *ClientOption) (*Client, error) {
 		}
 
 		// data
-		cli.Data = new(ValidateData)
+		cli.validateData = new(ValidateData)
+		cli.Data = cli.validateData
 	}
 
 	cli.appleID = option.AppID
@@ -124,8 +207,41 @@ func readFile(path string) []byte {
 }
 
 func (r *Client) getWebServiceURL(key string) (string, error) {
-	if _, ok := r.Data.Webservices[key]; !ok {
+	if key == "ckdatabasews" && r.ckDatabaseEndpoint != "" {
+		return r.ckDatabaseEndpoint, nil
+	}
+	data := r.data()
+	if data == nil {
+		return "", fmt.Errorf("webservice not available: %s, not authenticated", key)
+	}
+	if _, ok := data.Webservices[key]; !ok {
 		return "", fmt.Errorf("webservice not available: %s", key)
 	}
-	return r.Data.Webservices[key].URL, nil
+	return data.Webservices[key].URL, nil
+}
+
+// data returns the account data of the last login or validation, nil before authentication.
+func (r *Client) data() *ValidateData {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.validateData
+}
+
+func (r *Client) setData(data *ValidateData) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.validateData, r.Data = data, data
+}
+
+// session returns a copy of the session data.
+func (r *Client) session() SessionData {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return *r.sessionData
+}
+
+func (r *Client) updateSession(update func(d *SessionData)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	update(r.sessionData)
 }
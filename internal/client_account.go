@@ -0,0 +1,64 @@
+package internal
+
+// AccountData returns the account data of the last login or validation, like the dsid and the webservices.
+// It's replaced, not modified, on re-authentication, so it can be read from any goroutine, but don't modify it.
+func (r *Client) AccountData() *ValidateData {
+	return r.data()
+}
+
+// DSID returns the directory services id of the account, empty before authentication.
+func (r *Client) DSID() string {
+	data := r.data()
+	if data == nil || data.DsInfo == nil {
+		return ""
+	}
+	return data.DsInfo.Dsid
+}
+
+// AccountCountry returns the country code of the account, like "USA".
+func (r *Client) AccountCountry() string {
+	data := r.data()
+	session := r.session()
+	if session.AccountCountry != "" {
+		return session.AccountCountry
+	}
+	if data == nil || data.DsInfo == nil {
+		return ""
+	}
+	return data.DsInfo.CountryCode
+}
+
+// PrimaryEmail returns the primary email address of the apple id.
+func (r *Client) PrimaryEmail() string {
+	data := r.data()
+	if data == nil || data.DsInfo == nil {
+		return ""
+	}
+	return data.DsInfo.PrimaryEmail
+}
+
+// AlternateEmails returns the other email addresses of the apple id, like the icloud.com alias.
+func (r *Client) AlternateEmails() []string {
+	data := r.data()
+	if data == nil || data.DsInfo == nil {
+		return nil
+	}
+	primary := data.DsInfo.PrimaryEmail
+	seen := newSet(primary)
+	var res []string
+	for _, entry := range data.DsInfo.AppleIdEntries {
+		if entry.IsPrimary || entry.Type != "EMAIL" || seen.Has(entry.Value) {
+			continue
+		}
+		seen.Add(entry.Value)
+		res = append(res, entry.Value)
+	}
+	for _, alias := range []string{data.DsInfo.ICloudAppleIdAlias, data.DsInfo.AppleIdAlias} {
+		if alias == "" || seen.Has(alias) {
+			continue
+		}
+		seen.Add(alias)
+		res = append(res, alias)
+	}
+	return res
+}
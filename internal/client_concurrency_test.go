@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// TestClientConcurrentUse shares one client, service and iterator between goroutines, run it with -race.
+func TestClientConcurrentUse(t *testing.T) {
+	cli, server := newMockClient(t)
+	want := map[string][]byte{}
+	for i := 0; i < 50; i++ {
+		asset := server.AddAsset(&icloudmock.Asset{Data: []byte(fmt.Sprintf("photo %d", i))})
+		want[asset.Filename] = asset.Data
+	}
+	server.AddAlbum("Trips")
+
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+	album, err := photoCli.GetAlbum(AlbumNameAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter := album.PhotosIterWithOption(&PhotosIterOption{PageSize: 7})
+
+	var lock sync.Mutex
+	got := map[string]int{}
+	work := func(i int) error {
+		service, err := cli.PhotoCli()
+		if err != nil {
+			return err
+		} else if service != photoCli {
+			return errors.New("PhotoCli returned another service")
+		}
+		albums, err := service.Albums()
+		if err != nil {
+			return err
+		} else if albums["Trips"] == nil {
+			return errors.New("album Trips not found")
+		}
+		if i%4 == 0 {
+			if err := cli.Authenticate(false, nil); err != nil {
+				return err
+			}
+		}
+		if dsid := cli.DSID(); dsid != icloudmock.Dsid {
+			return fmt.Errorf("DSID = %q, want %q", dsid, icloudmock.Dsid)
+		}
+		if data := cli.AccountData(); data == nil || data.Webservices["ckdatabasews"] == nil {
+			return errors.New("Data has no ckdatabasews")
+		}
+
+		for {
+			asset, err := iter.Next()
+			if errors.Is(err, ErrPhotosIterateEnd) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			body, err := asset.Download(PhotoVersionOriginal)
+			if err != nil {
+				return err
+			}
+			data, err := io.ReadAll(body)
+			body.Close()
+			if err != nil {
+				return err
+			} else if !bytes.Equal(data, want[asset.Filename()]) {
+				return fmt.Errorf("downloaded %q for %s, want %q", data, asset.Filename(), want[asset.Filename()])
+			}
+			lock.Lock()
+			got[asset.Filename()]++
+			lock.Unlock()
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- work(i)
+		}(i)
+	}
+	// the session expires while the goroutines run, their requests renew it
+	server.ExpireSession()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("iterated %d assets, want %d", len(got), len(want))
+	}
+	for name, count := range got {
+		if count != 1 {
+			t.Errorf("%s iterated %d times, want once", name, count)
+		}
+	}
+}
+
+// TestClientConcurrentReauthenticate expires the session under many goroutines, they renew it together, not each on its own.
+func TestClientConcurrentReauthenticate(t *testing.T) {
+	cli, server := newMockClient(t)
+	server.AddAsset(&icloudmock.Asset{Data: []byte("photo")})
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+	album, err := photoCli.GetAlbum(AlbumNameAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.ExpireSession()
+	logins := server.Requests("accountLogin")
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := album.GetPhotosByOffset(0, 10)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if renewed := server.Requests("accountLogin") - logins; renewed != 1 {
+		t.Errorf("the session was renewed %d times, want once", renewed)
+	}
+}
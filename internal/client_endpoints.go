@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Endpoints overrides the base URLs the client talks to, empty fields keep the defaults of ClientOption.Domain.
+//
+// It's meant for mock servers, proxy gateways and regional endpoints,
+// each field can also be set by an env, like ICLOUD_SETUP_ENDPOINT.
+type Endpoints struct {
+	Auth       string // ICLOUD_AUTH_ENDPOINT, like https://idmsa.apple.com/appleauth/auth
+	Setup      string // ICLOUD_SETUP_ENDPOINT, like https://setup.icloud.com/setup/ws/1
+	Home       string // ICLOUD_HOME_ENDPOINT, like https://www.icloud.com
+	CKDatabase string // ICLOUD_CKDATABASE_ENDPOINT, replaces the ckdatabasews service url of the account
+	Download   string // ICLOUD_DOWNLOAD_ENDPOINT, replaces the scheme and host of asset download urls
+}
+
+func (r *Client) applyEndpoints(endpoints *Endpoints) {
+	if endpoints == nil {
+		endpoints = new(Endpoints)
+	}
+	pick := func(value, env string) string {
+		if value == "" {
+			value = os.Getenv(env)
+		}
+		return strings.TrimSuffix(value, "/")
+	}
+
+	if v := pick(endpoints.Auth, "ICLOUD_AUTH_ENDPOINT"); v != "" {
+		r.authEndpoint = v
+	}
+	if v := pick(endpoints.Setup, "ICLOUD_SETUP_ENDPOINT"); v != "" {
+		r.setupEndpoint = v
+	}
+	if v := pick(endpoints.Home, "ICLOUD_HOME_ENDPOINT"); v != "" {
+		r.homeEndpoint = v
+	}
+	r.ckDatabaseEndpoint = pick(endpoints.CKDatabase, "ICLOUD_CKDATABASE_ENDPOINT")
+	r.downloadEndpoint = pick(endpoints.Download, "ICLOUD_DOWNLOAD_ENDPOINT")
+}
+
+// rewriteDownloadURL points an asset download url to the download endpoint override, if any.
+func (r *Client) rewriteDownloadURL(s string) string {
+	if r.downloadEndpoint == "" {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	endpoint, err := url.Parse(r.downloadEndpoint)
+	if err != nil {
+		return s
+	}
+	u.Scheme, u.Host = endpoint.Scheme, endpoint.Host
+	u.Path = strings.TrimSuffix(endpoint.Path, "/") + u.Path
+	return u.String()
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// KeepAlive validates the session every interval in the background, which renews the session cookies
+// before they expire, so a long-running daemon does not need a brand new login with 2fa.
+//
+// Call the returned function to stop it, it returns once the background validation is done,
+// a validation in flight is given up.
+func (r *Client) KeepAlive(interval time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.keepAlive(ctx); err != nil && ctx.Err() == nil {
+					r.log(LogLevelWarn, "KeepAlive: session validate failed", "err", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-finished
+		})
+	}
+}
+
+// keepAlive only touches the session, the renewed cookies are saved by the cookie jar.
+func (r *Client) keepAlive(ctx context.Context) error {
+	_, err := r.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.setupEndpoint + "/validate",
+		Headers: r.getCommonHeaders(map[string]string{}),
+	})
+	if err != nil {
+		return err
+	}
+	return r.flush()
+}
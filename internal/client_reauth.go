@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrSessionExpired is returned by ValidateSession when the session can't be used without a new login.
+var ErrSessionExpired = NewError("session_expired", "the session expired, a new login is needed")
+
+// isSessionExpiredStatus reports whether a response with the status means the web session expired,
+// iCloud answers 421 or 450 when the cookies of the web services expired, and 401 when the session token did.
+func isSessionExpiredStatus(status int) bool {
+	return status == http.StatusUnauthorized || status == http.StatusMisdirectedRequest || status == 450
+}
+
+// shouldReauthenticate reports whether the request is retried after re-authenticating when it answers status,
+// the login requests themselves are never, a wrong password is not an expired session,
+// nor are the downloads, their urls are signed, and don't use the session.
+func (r *Client) shouldReauthenticate(req *rawReq, status int) bool {
+	if !isSessionExpiredStatus(status) || r.session().SessionToken == "" {
+		return false
+	}
+	if strings.HasSuffix(urlHost(req.URL), "icloud-content.com") {
+		return false
+	}
+	return !strings.HasPrefix(req.URL, r.authEndpoint) && !strings.HasPrefix(req.URL, r.setupEndpoint)
+}
+
+// reauthenticate renews the expired session of a request sent at since, the requests failing together renew it once.
+// It logs in with the session token first, then with the password, and 2fa only if the trust of the session is gone,
+// so the PasswordGetter and TwoFACodeGetter are only asked when there is no other way.
+func (r *Client) reauthenticate(ctx context.Context, since time.Time) error {
+	r.reauthLock.Lock()
+	defer r.reauthLock.Unlock()
+	if r.reauthedAt.After(since) {
+		return nil
+	}
+
+	r.log(LogLevelInfo, "Session expired, re-authenticating", "apple_id", r.appleID)
+	r.authLock.Lock()
+	err := r.authWithToken(ctx)
+	r.authLock.Unlock()
+	if err == nil {
+		err = r.flush()
+	} else {
+		r.log(LogLevelInfo, "Re-authenticate with the session token failed, logging in", "err", err)
+		err = r.AuthenticateContext(ctx, true, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("re-authenticate failed, err: %w", err)
+	}
+	r.reauthedAt = time.Now()
+	return nil
+}
+
+// ValidateSession checks the session is still valid, without logging in again, for a daemon to detect
+// an expired session early, like to ask for a new 2fa code before a run needs it.
+// It returns an error matching ErrSessionExpired with errors.Is when a new login is needed.
+func (r *Client) ValidateSession() error {
+	return r.ValidateSessionContext(context.Background())
+}
+
+// ValidateSessionContext is like ValidateSession, the request is given up when ctx is done.
+func (r *Client) ValidateSessionContext(ctx context.Context) error {
+	if r.session().SessionToken == "" {
+		return ErrSessionExpired
+	}
+	_, _, status, err := r.doRequest(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.setupEndpoint + "/validate",
+		Headers: r.getCommonHeaders(map[string]string{}),
+	})
+	if err != nil {
+		if isSessionExpiredStatus(status) {
+			return fmt.Errorf("validate session failed, err: %w", ErrSessionExpired)
+		}
+		return fmt.Errorf("validate session failed, err: %w", err)
+	}
+	if data := r.data(); data != nil && data.DsInfo != nil && (r.isRequires2FA() || r.isRequires2SA()) {
+		return fmt.Errorf("validate session failed, err: %w", ErrSessionExpired)
+	}
+	return r.flush()
+}
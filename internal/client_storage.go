@@ -0,0 +1,89 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// StorageStatus is the iCloud storage of the account.
+type StorageStatus struct {
+	Used  int64 // bytes
+	Total int64 // bytes
+	// Full is set when the storage is full, the devices of the account stop uploading to iCloud Photos then,
+	// so the library in iCloud may miss the newest photos of the devices
+	Full bool
+	// AlmostFull is set when the storage is close to full
+	AlmostFull bool
+	// Paid is set when the account pays for more storage than the free tier
+	Paid bool
+	// ByMedia is what the storage is used by, like photos, backups or documents
+	ByMedia []*StorageUsage
+}
+
+// StorageUsage is the storage a kind of media uses.
+type StorageUsage struct {
+	Media string // like photos
+	Label string // like Photos and Videos
+	Used  int64  // bytes
+}
+
+// Free returns the bytes left in the storage, 0 when it's full.
+func (r *StorageStatus) Free() int64 {
+	if r.Used >= r.Total {
+		return 0
+	}
+	return r.Total - r.Used
+}
+
+// StorageStatus returns the iCloud storage of the account, like whether it's full, which pauses the uploads of the devices.
+func (r *Client) StorageStatus() (*StorageStatus, error) {
+	return r.StorageStatusContext(context.Background())
+}
+
+// StorageStatusContext is like StorageStatus, the request is given up when ctx is done.
+func (r *Client) StorageStatusContext(ctx context.Context) (*StorageStatus, error) {
+	text, err := r.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.setupEndpoint + "/storageUsageInfo",
+		Headers: r.getCommonHeaders(map[string]string{}),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get storage status failed, err: %w", err)
+	}
+	res := new(storageUsageResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, fmt.Errorf("get storage status unmarshal failed, err: %w, text: %s", err, text)
+	}
+
+	status := &StorageStatus{
+		Used:       res.StorageUsageInfo.UsedStorageInBytes,
+		Total:      res.StorageUsageInfo.TotalStorageInBytes,
+		Full:       res.QuotaStatus.OverQuota,
+		AlmostFull: res.QuotaStatus.AlmostFull,
+		Paid:       res.QuotaStatus.PaidQuota,
+	}
+	for _, v := range res.StorageUsageByMedia {
+		status.ByMedia = append(status.ByMedia, &StorageUsage{Media: v.MediaKey, Label: v.DisplayLabel, Used: v.UsageInBytes})
+	}
+	return status, nil
+}
+
+type storageUsageResp struct {
+	StorageUsageByMedia []struct {
+		MediaKey     string `json:"mediaKey"`
+		DisplayLabel string `json:"displayLabel"`
+		UsageInBytes int64  `json:"usageInBytes"`
+	} `json:"storageUsageByMedia"`
+	StorageUsageInfo struct {
+		UsedStorageInBytes  int64 `json:"usedStorageInBytes"`
+		TotalStorageInBytes int64 `json:"totalStorageInBytes"`
+	} `json:"storageUsageInfo"`
+	QuotaStatus struct {
+		OverQuota  bool `json:"overQuota"`
+		AlmostFull bool `json:"almost-full"`
+		PaidQuota  bool `json:"paidQuota"`
+	} `json:"quotaStatus"`
+}
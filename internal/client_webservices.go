@@ -0,0 +1,27 @@
+package internal
+
+// WebServices returns the iCloud service endpoints of the account, keyed by service name like "ckdatabasews",
+// it's empty before authentication.
+//
+// Services disabled for the account have a Status other than "active".
+func (r *Client) WebServices() map[string]*WebService {
+	data := r.data()
+	res := map[string]*WebService{}
+	if data == nil {
+		return res
+	}
+	for name, service := range data.Webservices {
+		if service == nil {
+			continue
+		}
+		copied := *service
+		res[name] = &copied
+	}
+	return res
+}
+
+// IsWebServiceActive reports whether the service is enabled for the account.
+func (r *Client) IsWebServiceActive(name string) bool {
+	service, ok := r.WebServices()[name]
+	return ok && service.Status == "active"
+}
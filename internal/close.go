@@ -14,14 +14,17 @@ func (r *Client) Close() error {
 }
 
 func (r *Client) flush() error {
+	r.flushLock.Lock()
+	defer r.flushLock.Unlock()
+
 	if r.clientID != "" {
 		if err := os.WriteFile(r.clientIDPath, []byte(r.clientID), 0o644); err != nil {
 			return err
 		}
 	}
 
-	if r.sessionData.SessionToken != "" {
-		if bs, _ := json.Marshal(r.sessionData); len(bs) > 0 {
+	if session := r.session(); session.SessionToken != "" {
+		if bs, _ := json.Marshal(session); len(bs) > 0 {
 			if err := os.WriteFile(r.sessionDataPath, bs, 0o644); err != nil {
 				return err
 			}
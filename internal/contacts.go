@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ContactsService is the contacts of the account, get it with Client.ContactsCli.
+type ContactsService struct {
+	icloud      *Client
+	serviceRoot string
+}
+
+// ContactsCli returns the contacts service of the account, it's created once and cached by the client.
+func (r *Client) ContactsCli() (*ContactsService, error) {
+	r.contactsLock.Lock()
+	defer r.contactsLock.Unlock()
+
+	if r.contacts == nil {
+		serviceRoot, err := r.getWebServiceURL("contacts")
+		if err != nil {
+			return nil, err
+		}
+		r.contacts = &ContactsService{icloud: r, serviceRoot: serviceRoot}
+	}
+	return r.contacts, nil
+}
+
+// Contact is a card of the contacts, the fields are as iCloud.com shows them.
+type Contact struct {
+	ID         string
+	Prefix     string
+	FirstName  string
+	MiddleName string
+	LastName   string
+	Suffix     string
+	Nickname   string
+	Company    string
+	Department string
+	JobTitle   string
+	// IsCompany is set for the cards of companies, which show Company as their name
+	IsCompany bool
+	// Birthday is like 1990-01-31, empty when unset
+	Birthday string
+	Notes    string
+
+	Phones    []*ContactField
+	Emails    []*ContactField
+	URLs      []*ContactField
+	Addresses []*ContactAddress
+}
+
+// ContactField is a labeled value of a contact, like a phone number, the label is like MOBILE, HOME or WORK.
+type ContactField struct {
+	Label string
+	Value string
+}
+
+// ContactAddress is a labeled postal address of a contact.
+type ContactAddress struct {
+	Label       string
+	Street      string
+	City        string
+	State       string
+	PostalCode  string
+	Country     string
+	CountryCode string
+}
+
+// Contacts returns every contact of the account.
+func (r *ContactsService) Contacts() ([]*Contact, error) {
+	return r.ContactsContext(context.Background())
+}
+
+// ContactsContext is like Contacts, the requests are given up when ctx is done.
+func (r *ContactsService) ContactsContext(ctx context.Context) ([]*Contact, error) {
+	query := map[string]string{
+		"dsid":          r.icloud.DSID(),
+		"clientVersion": "2.1",
+		"locale":        "en_US",
+		"order":         "last,first",
+	}
+
+	// startup returns the tokens of the address book, contacts lists it
+	text, err := r.request(ctx, "startup", query)
+	if err != nil {
+		return nil, fmt.Errorf("get contacts failed, err: %w", err)
+	}
+	startup := new(contactsStartupResp)
+	if err = json.Unmarshal([]byte(text), startup); err != nil {
+		return nil, fmt.Errorf("get contacts unmarshal failed, err: %w, text: %s", err, text)
+	}
+
+	query["prefToken"] = startup.PrefToken
+	query["syncToken"] = startup.SyncToken
+	query["limit"] = "0"
+	query["offset"] = "0"
+	text, err = r.request(ctx, "contacts", query)
+	if err != nil {
+		return nil, fmt.Errorf("get contacts failed, err: %w", err)
+	}
+	res := new(contactsResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, fmt.Errorf("get contacts unmarshal failed, err: %w, text: %s", err, text)
+	}
+
+	contacts := make([]*Contact, 0, len(res.Contacts))
+	for _, v := range res.Contacts {
+		contact := &Contact{
+			ID:         v.ContactID,
+			Prefix:     v.Prefix,
+			FirstName:  v.FirstName,
+			MiddleName: v.MiddleName,
+			LastName:   v.LastName,
+			Suffix:     v.Suffix,
+			Nickname:   v.NickName,
+			Company:    v.CompanyName,
+			Department: v.Department,
+			JobTitle:   v.JobTitle,
+			IsCompany:  v.IsCompany,
+			Birthday:   v.Birthday,
+			Notes:      v.Notes,
+			Phones:     contactFields(v.Phones),
+			Emails:     contactFields(v.EmailAddresses),
+			URLs:       contactFields(v.URLs),
+		}
+		for _, address := range v.StreetAddresses {
+			contact.Addresses = append(contact.Addresses, &ContactAddress{
+				Label:       address.Label,
+				Street:      address.Field.Street,
+				City:        address.Field.City,
+				State:       address.Field.State,
+				PostalCode:  address.Field.PostalCode,
+				Country:     address.Field.Country,
+				CountryCode: address.Field.CountryCode,
+			})
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts, nil
+}
+
+func contactFields(fields []*contactFieldResp) []*ContactField {
+	res := make([]*ContactField, 0, len(fields))
+	for _, v := range fields {
+		res = append(res, &ContactField{Label: v.Label, Value: v.Field})
+	}
+	return res
+}
+
+func (r *ContactsService) request(ctx context.Context, path string, query map[string]string) (string, error) {
+	return r.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodGet,
+		URL:     r.serviceRoot + "/co/" + path,
+		Querys:  query,
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+	})
+}
+
+type contactsStartupResp struct {
+	PrefToken string `json:"prefToken"`
+	SyncToken string `json:"syncToken"`
+}
+
+type contactsResp struct {
+	Contacts []struct {
+		ContactID       string              `json:"contactId"`
+		Prefix          string              `json:"prefix"`
+		FirstName       string              `json:"firstName"`
+		MiddleName      string              `json:"middleName"`
+		LastName        string              `json:"lastName"`
+		Suffix          string              `json:"suffix"`
+		NickName        string              `json:"nickName"`
+		CompanyName     string              `json:"companyName"`
+		Department      string              `json:"department"`
+		JobTitle        string              `json:"jobTitle"`
+		IsCompany       bool                `json:"isCompany"`
+		Birthday        string              `json:"birthday"`
+		Notes           string              `json:"notes"`
+		Phones          []*contactFieldResp `json:"phones"`
+		EmailAddresses  []*contactFieldResp `json:"emailAddresses"`
+		URLs            []*contactFieldResp `json:"urls"`
+		StreetAddresses []struct {
+			Label string `json:"label"`
+			Field struct {
+				Street      string `json:"street"`
+				City        string `json:"city"`
+				State       string `json:"state"`
+				PostalCode  string `json:"postalCode"`
+				Country     string `json:"country"`
+				CountryCode string `json:"countryCode"`
+			} `json:"field"`
+		} `json:"streetAddresses"`
+	} `json:"contacts"`
+}
+
+type contactFieldResp struct {
+	Label string `json:"label"`
+	Field string `json:"field"`
+}
@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// VCardVersion is the vCard version contacts are written as.
+type VCardVersion string
+
+const (
+	// VCard30 is vCard 3.0, RFC 2426, what most address books import
+	VCard30 VCardVersion = "3.0"
+	// VCard40 is vCard 4.0, RFC 6350
+	VCard40 VCardVersion = "4.0"
+)
+
+// ParseVCardVersion parses 3.0 or 4.0.
+func ParseVCardVersion(s string) (VCardVersion, error) {
+	switch version := VCardVersion(s); version {
+	case VCard30, VCard40:
+		return version, nil
+	default:
+		return "", fmt.Errorf("invalid vcard version: %s, must be 3.0 or 4.0", s)
+	}
+}
+
+// WriteVCards writes the contacts to w as a vcf file, one vCard after the other.
+func WriteVCards(w io.Writer, contacts []*Contact, version VCardVersion) error {
+	for _, contact := range contacts {
+		if _, err := io.WriteString(w, contact.VCard(version)); err != nil {
+			return fmt.Errorf("write vcard of %s failed, err: %w", contact.ID, err)
+		}
+	}
+	return nil
+}
+
+// FullName is the name the contact is shown with, the company for the cards of companies.
+func (r *Contact) FullName() string {
+	if r.IsCompany && r.Company != "" {
+		return r.Company
+	}
+	var parts []string
+	for _, v := range []string{r.Prefix, r.FirstName, r.MiddleName, r.LastName, r.Suffix} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	if len(parts) == 0 {
+		return r.Company
+	}
+	return strings.Join(parts, " ")
+}
+
+// VCard returns the contact as a vCard of version, with CRLF line ends and long lines folded, the photo is not included.
+func (r *Contact) VCard(version VCardVersion) string {
+	card := &vCardWriter{version: version}
+	card.line("BEGIN", "VCARD")
+	card.line("VERSION", string(version))
+	if version == VCard40 {
+		if r.IsCompany {
+			card.line("KIND", "org")
+		}
+	} else if r.IsCompany {
+		card.line("X-ABShowAs", "COMPANY")
+	}
+	card.line("FN", escapeText(r.FullName()))
+	card.line("N", vCardJoin(r.LastName, r.FirstName, r.MiddleName, r.Prefix, r.Suffix))
+	card.optional("NICKNAME", escapeText(r.Nickname))
+	if r.Company != "" || r.Department != "" {
+		card.line("ORG", vCardJoin(r.Company, r.Department))
+	}
+	card.optional("TITLE", escapeText(r.JobTitle))
+	if r.Birthday != "" {
+		birthday := r.Birthday
+		if version == VCard40 {
+			// Apple stores the birthdays without year in 1604, 4.0 writes them as --MMDD
+			birthday = strings.ReplaceAll(birthday, "-", "")
+			if strings.HasPrefix(birthday, "1604") {
+				birthday = "--" + birthday[4:]
+			}
+		}
+		card.line("BDAY", birthday)
+	}
+	for _, v := range r.Phones {
+		card.line("TEL"+card.types(v.Label, "voice")+card.textValue(), escapeText(v.Value))
+	}
+	for _, v := range r.Emails {
+		types := card.types(v.Label, "")
+		if version == VCard30 {
+			types = card.types(v.Label, "internet")
+		}
+		card.line("EMAIL"+types, escapeText(v.Value))
+	}
+	for _, v := range r.Addresses {
+		card.line("ADR"+card.types(v.Label, ""), vCardJoin("", "", v.Street, v.City, v.State, v.PostalCode, v.Country))
+	}
+	for _, v := range r.URLs {
+		card.line("URL"+card.types(v.Label, ""), v.Value)
+	}
+	card.optional("NOTE", escapeText(r.Notes))
+	card.optional("UID", escapeText(r.ID))
+	card.line("END", "VCARD")
+	return card.String()
+}
+
+// vCardTypes maps the labels of iCloud to vCard types, the labels missing are written without type.
+var vCardTypes = map[string][]string{
+	"HOME":     {"home"},
+	"WORK":     {"work"},
+	"MOBILE":   {"cell"},
+	"IPHONE":   {"cell"},
+	"HOME FAX": {"home", "fax"},
+	"WORK FAX": {"work", "fax"},
+	"PAGER":    {"pager"},
+}
+
+type vCardWriter struct {
+	contentLines
+	version VCardVersion
+}
+
+// types returns the TYPE parameter of label, with extra first, upper case in vCard 3.0, lower case in 4.0.
+func (r *vCardWriter) types(label, extra string) string {
+	var types []string
+	if extra != "" {
+		types = append(types, extra)
+	}
+	types = append(types, vCardTypes[strings.ToUpper(label)]...)
+	if len(types) == 0 {
+		return ""
+	}
+	value := strings.Join(types, ",")
+	if r.version == VCard30 {
+		value = strings.ToUpper(value)
+	}
+	return ";TYPE=" + value
+}
+
+// textValue marks a phone number as text in vCard 4.0, where a TEL is a tel: uri by default.
+func (r *vCardWriter) textValue() string {
+	if r.version == VCard40 {
+		return ";VALUE=text"
+	}
+	return ""
+}
+
+// vCardJoin escapes the components of a structured value, like N, and joins them with ;.
+func vCardJoin(components ...string) string {
+	for i, v := range components {
+		components[i] = escapeText(v)
+	}
+	return strings.Join(components, ";")
+}
@@ -0,0 +1,41 @@
+package internal
+
+import "strings"
+
+// contentLines writes the content lines of vCard and iCalendar files, which share their line format,
+// RFC 6350 and RFC 5545: CRLF line ends, and long lines folded.
+type contentLines struct {
+	b strings.Builder
+}
+
+// line writes a content line, folded at 75 octets, the leading space of the folded lines included,
+// without splitting a UTF-8 character.
+func (r *contentLines) line(name, value string) {
+	line, limit := name+":"+value, 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		r.b.WriteString(line[:cut] + "\r\n ")
+		line, limit = line[cut:], 74
+	}
+	r.b.WriteString(line + "\r\n")
+}
+
+func (r *contentLines) optional(name, value string) {
+	if value != "" {
+		r.line(name, value)
+	}
+}
+
+func (r *contentLines) String() string {
+	return r.b.String()
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`)
+
+// escapeText escapes a text value of a content line.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// downloadHostCooldown is how long a download host which failed is tried after the others.
+const downloadHostCooldown = 5 * time.Minute
+
+// downloadHosts are the health of the hosts the asset downloads fail over between, shared by the downloads of the client.
+type downloadHosts struct {
+	lock sync.Mutex
+	down map[string]time.Time // host -> when it failed
+}
+
+// partitionRegexp matches the partition of the account in a webservice host, like p63 in p63-ckdatabasews.icloud.com.
+var partitionRegexp = regexp.MustCompile(`^(p\d+)-`)
+
+// downloadMirrors returns the urls the asset download url can be fetched from, in the order to try them:
+// the icloud-content.com host of the partition of the account, like p63-cvws.icloud-content.com, then the host listed,
+// the hosts which failed recently last. With the Endpoints.Download override, it's only the override.
+func (r *Client) downloadMirrors(rawURL string) []string {
+	if r.downloadEndpoint != "" {
+		return []string{r.rewriteDownloadURL(rawURL)}
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return []string{rawURL}
+	}
+
+	mirrors := []string{rawURL}
+	if partition := r.partition(); partition != "" && strings.HasSuffix(u.Hostname(), ".icloud-content.com") && !partitionRegexp.MatchString(u.Host) {
+		// replace the host in the url as listed, re-encoding it could change the path
+		mirror := strings.Replace(rawURL, "//"+u.Host, "//"+partition+"-"+u.Host, 1)
+		mirrors = []string{mirror, rawURL}
+	}
+
+	r.downloadHosts.lock.Lock()
+	defer r.downloadHosts.lock.Unlock()
+	sort.SliceStable(mirrors, func(i, j int) bool {
+		return !r.downloadHosts.isDown(mirrors[i]) && r.downloadHosts.isDown(mirrors[j])
+	})
+	return mirrors
+}
+
+// partition returns the partition of the account, like p63, empty before authentication.
+func (r *Client) partition() string {
+	serviceURL, err := r.getWebServiceURL("ckdatabasews")
+	if err != nil {
+		return ""
+	}
+	u, err := url.Parse(serviceURL)
+	if err != nil {
+		return ""
+	}
+	if match := partitionRegexp.FindStringSubmatch(u.Hostname()); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// isDown reports whether the host of the url failed recently, the lock must be held.
+func (r *downloadHosts) isDown(rawURL string) bool {
+	at, ok := r.down[urlHost(rawURL)]
+	return ok && time.Since(at) < downloadHostCooldown
+}
+
+// fail records the host of the url failed.
+func (r *downloadHosts) fail(rawURL string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.down == nil {
+		r.down = map[string]time.Time{}
+	}
+	r.down[urlHost(rawURL)] = time.Now()
+}
+
+// ok records the host of the url works.
+func (r *downloadHosts) ok(rawURL string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.down, urlHost(rawURL))
+}
+
+// isFailoverStatus reports whether a download failing with the status is tried on the next host,
+// like an unreachable host, a 5xx, or a host rejecting the url, but not a rate limit, which is the same on every host.
+func isFailoverStatus(status int) bool {
+	return !isRateLimitedStatus(status)
+}
+
+func urlHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return strings.ToLower(u.Host)
+	}
+	return rawURL
+}
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/chyroc/icloudgo/internal/pool"
+)
+
+// ParallelDownloadOption is how a PhotoService downloads a large asset in parallel ranges,
+// per-connection throughput to the iCloud CDN is often what limits the download of a multi-GB video.
+type ParallelDownloadOption struct {
+	// Concurrency is the ranges downloaded at once, 1 or less downloads an asset in one request
+	Concurrency int
+	// ChunkSize is the size of a range, 0 is 16MB
+	ChunkSize int64
+	// MinSize is the size from which an asset is downloaded in ranges, 0 is two chunks
+	MinSize int64
+}
+
+const defaultChunkSize = 16 << 20
+
+// parallelPartSuffix is appended to the target of a parallel download while it's in progress,
+// the ranges are written out of order, so the file can't be resumed like the .part file of a download in one request.
+const parallelPartSuffix = ".parallel" + PartialFileSuffix
+
+// errRangeIgnored is returned when the server answers a range request with the whole file.
+var errRangeIgnored = errors.New("range request ignored")
+
+// SetParallelDownload makes the downloads of the service fetch the assets of option.MinSize or more in
+// option.Concurrency parallel ranges of option.ChunkSize, nil downloads every asset in one request, the default.
+//
+// It applies to the downloads to a single target whose storage implements StorageWriteAt, like FileStorage,
+// without a .part file of a previous download to resume. A download falls back to one request when the server ignores the ranges.
+// An interrupted parallel download starts over.
+func (r *PhotoService) SetParallelDownload(option *ParallelDownloadOption) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.parallel = option
+}
+
+func (r *PhotoService) parallelDownload() *ParallelDownloadOption {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.parallel
+}
+
+func (r *ParallelDownloadOption) chunkSize() int64 {
+	if r.ChunkSize <= 0 {
+		return defaultChunkSize
+	}
+	return r.ChunkSize
+}
+
+// usable reports whether the download of size bytes to target of storage is downloaded in ranges.
+func (r *ParallelDownloadOption) usable(storage Storage, target string, size int64) bool {
+	if r == nil || r.Concurrency <= 1 || size <= 0 {
+		return false
+	}
+	minSize := r.MinSize
+	if minSize <= 0 {
+		minSize = 2 * r.chunkSize()
+	}
+	if size < minSize {
+		return false
+	}
+	if _, ok := storage.(StorageWriteAt); !ok {
+		return false
+	}
+	if _, ok := storage.(StorageAppend); ok {
+		// a .part file of a download in one request is resumed instead
+		if f, err := storage.Stat(target + PartialFileSuffix); err == nil && f.Size() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// chunkOpener opens the bytes from start to end, inclusive, of a download.
+type chunkOpener func(ctx context.Context, start, end int64) (io.ReadCloser, error)
+
+// openStreamRange GETs the bytes from start to end, inclusive, of url,
+// it fails with errRangeIgnored when the server answers with the whole file.
+func (r *Client) openStreamRange(ctx context.Context, url string, start, end int64) (io.ReadCloser, error) {
+	body, _, err := r.openMirrors(ctx, url, func(mirror string) (io.ReadCloser, int64, int, error) {
+		body, status, err := r.requestStreamStatus(&rawReq{
+			Context:      ctx,
+			Method:       http.MethodGet,
+			URL:          mirror,
+			Headers:      r.getCommonHeaders(map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", start, end)}),
+			ExpectStatus: newSet(http.StatusOK, http.StatusPartialContent),
+		})
+		if err != nil {
+			return nil, 0, status, err
+		}
+		if status != http.StatusPartialContent {
+			body.Close()
+			return nil, 0, status, errRangeIgnored
+		}
+		return body, start, status, nil
+	})
+	return body, err
+}
+
+// downloadToStorageParallel is like downloadToStorage, but downloads the file in parallel ranges,
+// to a .parallel.part file written at offsets, see ParallelDownloadOption.
+func (r *Client) downloadToStorageParallel(ctx context.Context, storage Storage, target string, size int64, option *ParallelDownloadOption, open chunkOpener) error {
+	progress := newDownloadProgress(ctx, target, size)
+	err := r.downloadChunks(ctx, storage, target, size, option, open, progress)
+	progress.done(err)
+	return err
+}
+
+func (r *Client) downloadChunks(ctx context.Context, storage Storage, target string, size int64, option *ParallelDownloadOption, open chunkOpener, progress *downloadProgress) error {
+	partTarget := target + parallelPartSuffix
+	f, err := storage.(StorageWriteAt).CreateWriteAt(partTarget)
+	if err != nil {
+		return &storageError{fmt.Errorf("open file error: %w", err)}
+	}
+
+	progress.start(0)
+	chunkSize := option.chunkSize()
+	workers := pool.New(ctx, &pool.Option{Workers: option.Concurrency})
+	for start := int64(0); start < size && workers.Err() == nil; start += chunkSize {
+		start, end := start, start+chunkSize-1
+		if end >= size {
+			end = size - 1
+		}
+		_ = workers.Submit(func(ctx context.Context, _ int) error {
+			return r.downloadChunk(ctx, f, start, end, open, progress)
+		})
+	}
+	err = workers.Wait()
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = &storageError{fmt.Errorf("copy file error: %w", closeErr)}
+	}
+	if err != nil {
+		_ = storage.Remove(partTarget)
+		return err
+	}
+
+	if err := storage.Rename(partTarget, target); err != nil {
+		return fmt.Errorf("rename file error: %w", err)
+	}
+	r.log(LogLevelDebug, "download done", "file", target, "size", size, "concurrency", option.Concurrency)
+	return nil
+}
+
+// downloadChunk downloads the bytes from start to end, inclusive, to f,
+// a range failing midway is retried with the download retry policy of the client, from where it failed.
+func (r *Client) downloadChunk(ctx context.Context, f io.WriterAt, start, end int64, open chunkOpener, progress *downloadProgress) error {
+	policy := r.downloadRetryPolicy()
+	offset := start
+	for attempt := 1; ; attempt++ {
+		n, err := copyChunk(ctx, f, offset, end, open, progress)
+		offset += n
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts || !isRetryableDownloadError(ctx, err) || errors.Is(err, errRangeIgnored) {
+			return err
+		}
+		r.log(LogLevelWarn, "download range failed, retry", "range", fmt.Sprintf("%d-%d", offset, end), "retry", fmt.Sprintf("%d/%d", attempt, policy.MaxAttempts-1), "delay", policy.delay(attempt), "err", err)
+		if err := policy.wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// copyChunk copies the bytes from start to end, inclusive, to f, and returns how many it copied.
+func copyChunk(ctx context.Context, f io.WriterAt, start, end int64, open chunkOpener, progress *downloadProgress) (int64, error) {
+	body, err := open(ctx, start, end)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	want := end - start + 1
+	n, err := io.Copy(&storageWriter{w: &offsetWriter{w: f, offset: start}}, progress.reader(io.LimitReader(body, want)))
+	if err != nil {
+		var storageErr *storageError
+		if errors.As(err, &storageErr) {
+			return n, &storageError{fmt.Errorf("copy file error: %w", err)}
+		}
+		return n, fmt.Errorf("copy file error: %w", err)
+	}
+	if n < want {
+		return n, fmt.Errorf("copy file error: %w", io.ErrUnexpectedEOF)
+	}
+	return n, nil
+}
+
+// offsetWriter writes to w from offset on.
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (r *offsetWriter) Write(p []byte) (int, error) {
+	n, err := r.w.WriteAt(p, r.offset)
+	r.offset += int64(n)
+	return n, err
+}
+
+// downloadInRanges downloads the version in parallel ranges when the service is set to, see SetParallelDownload,
+// downloaded is false when it's left to a download in one request.
+func (r *PhotoAsset) downloadInRanges(ctx context.Context, version PhotoVersion, storage Storage, target string, size int64) (downloaded bool, err error) {
+	parallel := r.service.parallelDownload()
+	if !parallel.usable(storage, target, size) {
+		return false, nil
+	}
+	err = r.service.icloud.downloadToStorageParallel(ctx, storage, target, size, parallel, func(ctx context.Context, start, end int64) (io.ReadCloser, error) {
+		return r.openDownloadRange(ctx, version, start, end)
+	})
+	if errors.Is(err, errRangeIgnored) {
+		r.service.icloud.log(LogLevelDebug, "range request ignored, download in one request", "file", target)
+		return false, nil
+	}
+	return true, err
+}
+
+// openDownloadRange is the chunkOpener of the version, like openDownload.
+func (r *PhotoAsset) openDownloadRange(ctx context.Context, version PhotoVersion, start, end int64) (io.ReadCloser, error) {
+	for attempt := 0; ; attempt++ {
+		url, err := r.downloadURL(ctx, version)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := r.service.icloud.openStreamRange(ctx, url, start, end)
+		if err != nil && isExpiredURLError(err) && attempt == 0 {
+			if err := r.refreshURLs(ctx); err != nil {
+				return nil, err
+			}
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("download %s failed: %w", r.Filename(), err)
+		}
+		return body, nil
+	}
+}
@@ -0,0 +1,232 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DownloadRetryPolicy is how the DownloadTo methods retry a download failing midway,
+// a retry resumes from the .part file with a Range request when the storage implements StorageAppend.
+type DownloadRetryPolicy struct {
+	// MaxAttempts is the attempts of a download, 1 disables retries
+	MaxAttempts int
+	// Backoff is the pause before the first retry, doubled before each next retry, up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultDownloadRetryPolicy is used when ClientOption.DownloadRetry is nil.
+var DefaultDownloadRetryPolicy = &DownloadRetryPolicy{
+	MaxAttempts: 3,
+	Backoff:     2 * time.Second,
+	MaxBackoff:  time.Minute,
+}
+
+// delay returns the pause before the retry-th retry, from 1.
+func (r *DownloadRetryPolicy) delay(retry int) time.Duration {
+	delay := r.Backoff
+	for i := 1; i < retry && (r.MaxBackoff <= 0 || delay < r.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	return delay
+}
+
+// wait sleeps the pause before the retry-th retry, it returns ctx.Err() when ctx is done first.
+func (r *DownloadRetryPolicy) wait(ctx context.Context, retry int) error {
+	timer := time.NewTimer(r.delay(retry))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (r *Client) downloadRetryPolicy() *DownloadRetryPolicy {
+	if r.downloadRetry == nil {
+		return DefaultDownloadRetryPolicy
+	}
+	return r.downloadRetry
+}
+
+// storageError is a failure of the storage, not of the download, retrying doesn't help.
+type storageError struct {
+	err error
+}
+
+func (r *storageError) Error() string {
+	return r.err.Error()
+}
+
+func (r *storageError) Unwrap() error {
+	return r.err
+}
+
+func isRetryableDownloadError(ctx context.Context, err error) bool {
+	var storageErr *storageError
+	return ctx.Err() == nil && !errors.As(err, &storageErr)
+}
+
+// rangeOpener opens a download from offset, and returns the offset the body starts at,
+// 0 when the server ignored the range.
+type rangeOpener func(ctx context.Context, offset int64) (io.ReadCloser, int64, error)
+
+// openStream GETs url from offset with a Range request, see rangeOpener,
+// it fails over between the download hosts of the url, see downloadMirrors.
+func (r *Client) openStream(ctx context.Context, url string, offset int64) (io.ReadCloser, int64, error) {
+	return r.openMirrors(ctx, url, func(mirror string) (io.ReadCloser, int64, int, error) {
+		return r.openStreamFrom(ctx, mirror, offset)
+	})
+}
+
+// openMirrors opens the first of the download hosts of the url which works, see downloadMirrors.
+func (r *Client) openMirrors(ctx context.Context, url string, open func(mirror string) (io.ReadCloser, int64, int, error)) (io.ReadCloser, int64, error) {
+	mirrors := r.downloadMirrors(url)
+	var err error
+	for i, mirror := range mirrors {
+		var body io.ReadCloser
+		var start int64
+		var status int
+		body, start, status, err = open(mirror)
+		if err == nil {
+			r.downloadHosts.ok(mirror)
+			return body, start, nil
+		}
+		if ctx.Err() != nil || !isFailoverStatus(status) || errors.Is(err, errRangeIgnored) || i == len(mirrors)-1 {
+			break
+		}
+		r.downloadHosts.fail(mirror)
+		r.log(LogLevelWarn, "download host failed, fail over", "host", urlHost(mirror), "next", urlHost(mirrors[i+1]), "err", err)
+	}
+	return nil, 0, err
+}
+
+// openStreamFrom GETs url from offset, and also returns the status of the response.
+func (r *Client) openStreamFrom(ctx context.Context, url string, offset int64) (io.ReadCloser, int64, int, error) {
+	headers := map[string]string{}
+	expect := newSet(http.StatusOK)
+	if offset > 0 {
+		headers["Range"] = fmt.Sprintf("bytes=%d-", offset)
+		expect.Add(http.StatusPartialContent)
+	}
+	body, status, err := r.requestStreamStatus(&rawReq{
+		Context:      ctx,
+		Method:       http.MethodGet,
+		URL:          url,
+		Headers:      r.getCommonHeaders(headers),
+		ExpectStatus: expect,
+	})
+	if err != nil && offset > 0 && status == http.StatusRequestedRangeNotSatisfiable {
+		// the file changed since the .part file was written, start over
+		return r.openStreamFrom(ctx, url, 0)
+	} else if err != nil {
+		return nil, 0, status, err
+	}
+	if status != http.StatusPartialContent {
+		return body, 0, status, nil
+	}
+	return body, offset, status, nil
+}
+
+// downloadToStorage downloads to the .part file of target, and renames it to target once complete,
+// failed attempts are retried with the download retry policy of the client,
+// the progress is reported to the DownloadProgressFunc of ctx.
+//
+// size is the expected size of the file, the .part file left by a failed attempt or an interrupted run
+// is resumed when it's known and storage implements StorageAppend.
+func (r *Client) downloadToStorage(ctx context.Context, storage Storage, target string, size int64, open rangeOpener) error {
+	progress := newDownloadProgress(ctx, target, size)
+	err := r.downloadToStorageWithRetry(ctx, storage, target, size, open, progress)
+	progress.done(err)
+	return err
+}
+
+func (r *Client) downloadToStorageWithRetry(ctx context.Context, storage Storage, target string, size int64, open rangeOpener, progress *downloadProgress) error {
+	partTarget := target + PartialFileSuffix
+	policy := r.downloadRetryPolicy()
+	for attempt := 1; ; attempt++ {
+		err := downloadPart(ctx, storage, partTarget, size, open, progress)
+		if err == nil {
+			break
+		}
+		if attempt >= policy.MaxAttempts || !isRetryableDownloadError(ctx, err) {
+			return err
+		}
+		r.log(LogLevelWarn, "download failed, retry", "file", target, "retry", fmt.Sprintf("%d/%d", attempt, policy.MaxAttempts-1), "delay", policy.delay(attempt), "err", err)
+		if err := policy.wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+
+	if err := storage.Rename(partTarget, target); err != nil {
+		return fmt.Errorf("rename file error: %w", err)
+	}
+	r.log(LogLevelDebug, "download done", "file", target, "size", size)
+	return nil
+}
+
+func downloadPart(ctx context.Context, storage Storage, partTarget string, size int64, open rangeOpener, progress *downloadProgress) error {
+	var offset int64
+	appender, canAppend := storage.(StorageAppend)
+	if canAppend && size > 0 {
+		if f, err := storage.Stat(partTarget); err == nil && f.Size() <= size {
+			offset = f.Size()
+		}
+	}
+	if offset > 0 && offset == size {
+		// complete, only the rename is missing
+		progress.start(offset)
+		return nil
+	}
+
+	body, start, err := open(ctx, offset)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var f io.WriteCloser
+	if start > 0 {
+		f, err = appender.Append(partTarget)
+	} else {
+		f, err = storage.Create(partTarget)
+	}
+	if err != nil {
+		return &storageError{fmt.Errorf("open file error: %w", err)}
+	}
+
+	progress.start(start)
+	_, err = io.Copy(&storageWriter{w: f}, progress.reader(body))
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = &storageError{closeErr}
+	}
+	if err != nil {
+		var storageErr *storageError
+		if errors.As(err, &storageErr) {
+			return &storageError{fmt.Errorf("copy file error: %w", err)}
+		}
+		return fmt.Errorf("copy file error: %w", err)
+	}
+	return nil
+}
+
+// storageWriter marks the write errors of the storage, to tell them from the read errors of the download.
+type storageWriter struct {
+	w io.Writer
+}
+
+func (r *storageWriter) Write(p []byte) (int, error) {
+	n, err := r.w.Write(p)
+	if err != nil {
+		return n, &storageError{err}
+	}
+	return n, nil
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DriveService is the iCloud Drive of the account, get it with Client.DriveCli.
+type DriveService struct {
+	icloud      *Client
+	serviceRoot string
+	docsRoot    string
+}
+
+// driveRootID is the drivewsid of the root folder of iCloud Drive.
+const driveRootID = "FOLDER::com.apple.CloudDocs::root"
+
+// DriveCli returns the iCloud Drive service of the account, it's created once and cached by the client.
+func (r *Client) DriveCli() (*DriveService, error) {
+	r.driveLock.Lock()
+	defer r.driveLock.Unlock()
+
+	if r.drive == nil {
+		serviceRoot, err := r.getWebServiceURL("drivews")
+		if err != nil {
+			return nil, err
+		}
+		docsRoot, err := r.getWebServiceURL("docws")
+		if err != nil {
+			return nil, err
+		}
+		r.drive = &DriveService{icloud: r, serviceRoot: serviceRoot, docsRoot: docsRoot}
+	}
+	return r.drive, nil
+}
+
+// Root returns the root folder of iCloud Drive.
+func (r *DriveService) Root() (*DriveNode, error) {
+	return r.RootContext(context.Background())
+}
+
+// RootContext is like Root, the request is given up when ctx is done.
+func (r *DriveService) RootContext(ctx context.Context) (*DriveNode, error) {
+	return r.getNodeByID(ctx, driveRootID)
+}
+
+// GetNode finds a file or folder by its slash separated path from the root, like "Documents/notes.txt",
+// an empty path is the root.
+func (r *DriveService) GetNode(path string) (*DriveNode, error) {
+	return r.GetNodeContext(context.Background(), path)
+}
+
+// GetNodeContext is like GetNode, the requests are given up when ctx is done.
+func (r *DriveService) GetNodeContext(ctx context.Context, path string) (*DriveNode, error) {
+	node, err := r.RootContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		if node, err = node.ChildContext(ctx, name); err != nil {
+			return nil, fmt.Errorf("get drive node %s failed, err: %w", path, err)
+		}
+	}
+	return node, nil
+}
+
+func (r *DriveService) querys() map[string]string {
+	return map[string]string{"dsid": r.icloud.DSID()}
+}
+
+func (r *DriveService) getNodeByID(ctx context.Context, drivewsid string) (*DriveNode, error) {
+	text, err := r.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.serviceRoot + "/retrieveItemDetailsInFolders",
+		Querys:  r.querys(),
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		Body:    []any{map[string]any{"drivewsid": drivewsid, "partialData": false}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get drive folder failed, err: %w", err)
+	}
+	var res []*driveNodeData
+	if err = json.Unmarshal([]byte(text), &res); err != nil {
+		return nil, fmt.Errorf("get drive folder unmarshal failed, err: %w, text: %s", err, text)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("get drive folder failed, err: %s not found", drivewsid)
+	}
+	return r.newDriveNode(res[0]), nil
+}
@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	DriveNodeTypeFolder     = "FOLDER"
+	DriveNodeTypeFile       = "FILE"
+	DriveNodeTypeAppLibrary = "APP_LIBRARY"
+)
+
+// DriveNode is a file or folder of iCloud Drive.
+type DriveNode struct {
+	service *DriveService
+
+	Name     string
+	Type     string
+	Size     int64
+	Modified time.Time
+
+	drivewsid string
+	docwsid   string
+	zone      string
+	etag      string
+
+	// cache, the items of a folder
+	_children []*DriveNode
+	lock      *sync.Mutex
+}
+
+func (r *DriveService) newDriveNode(data *driveNodeData) *DriveNode {
+	name := data.Name
+	if data.Extension != "" {
+		name += "." + data.Extension
+	}
+	modified, _ := time.Parse(time.RFC3339, data.DateModified)
+	node := &DriveNode{
+		service:   r,
+		Name:      name,
+		Type:      data.Type,
+		Size:      data.Size,
+		Modified:  modified,
+		drivewsid: data.Drivewsid,
+		docwsid:   data.Docwsid,
+		zone:      data.Zone,
+		etag:      data.Etag,
+		lock:      new(sync.Mutex),
+	}
+	if data.Items != nil {
+		node._children = make([]*DriveNode, 0, len(data.Items))
+		for _, item := range data.Items {
+			node._children = append(node._children, r.newDriveNode(item))
+		}
+		sort.Slice(node._children, func(i, j int) bool { return node._children[i].Name < node._children[j].Name })
+	}
+	return node
+}
+
+// ID returns the drivewsid of the node, stable across renames.
+func (r *DriveNode) ID() string {
+	return r.drivewsid
+}
+
+// IsDir reports whether the node is a folder, or the folder of an app.
+func (r *DriveNode) IsDir() bool {
+	return r.Type != DriveNodeTypeFile
+}
+
+// Children lists the items of a folder, sorted by name, the list is cached by the node.
+func (r *DriveNode) Children() ([]*DriveNode, error) {
+	return r.ChildrenContext(context.Background())
+}
+
+// ChildrenContext is like Children, the request is given up when ctx is done.
+func (r *DriveNode) ChildrenContext(ctx context.Context) ([]*DriveNode, error) {
+	if !r.IsDir() {
+		return nil, fmt.Errorf("list %s failed, err: not a folder", r.Name)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r._children == nil {
+		node, err := r.service.getNodeByID(ctx, r.drivewsid)
+		if err != nil {
+			return nil, err
+		}
+		r._children = node._children
+		if r._children == nil {
+			r._children = []*DriveNode{}
+		}
+	}
+	return r._children, nil
+}
+
+// Child finds an item of a folder by name.
+func (r *DriveNode) Child(name string) (*DriveNode, error) {
+	return r.ChildContext(context.Background(), name)
+}
+
+// ChildContext is like Child, the request is given up when ctx is done.
+func (r *DriveNode) ChildContext(ctx context.Context, name string) (*DriveNode, error) {
+	children, err := r.ChildrenContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		if child.Name == name {
+			return child, nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found in %s", name, r.Name)
+}
+
+// Walk calls fn for the node and every node below it, depth first,
+// with the slash separated path relative to the node, "" for the node itself.
+// fn returning an error stops the walk with it.
+func (r *DriveNode) Walk(fn func(path string, node *DriveNode) error) error {
+	return r.WalkContext(context.Background(), fn)
+}
+
+// WalkContext is like Walk, the requests are given up when ctx is done.
+func (r *DriveNode) WalkContext(ctx context.Context, fn func(path string, node *DriveNode) error) error {
+	return r.walk(ctx, "", fn)
+}
+
+func (r *DriveNode) walk(ctx context.Context, nodePath string, fn func(path string, node *DriveNode) error) error {
+	if err := fn(nodePath, r); err != nil {
+		return err
+	}
+	if !r.IsDir() {
+		return nil
+	}
+	children, err := r.ChildrenContext(ctx)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := child.walk(ctx, path.Join(nodePath, child.Name), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Download returns the content of a file, the caller must close it.
+func (r *DriveNode) Download() (io.ReadCloser, error) {
+	return r.DownloadContext(context.Background())
+}
+
+// DownloadContext is like Download, reading the body fails with ctx.Err() once ctx is done.
+func (r *DriveNode) DownloadContext(ctx context.Context) (io.ReadCloser, error) {
+	body, _, err := r.openDownload(ctx, 0)
+	return body, err
+}
+
+// openDownload is the rangeOpener of the file, the download url is resolved on every call.
+func (r *DriveNode) openDownload(ctx context.Context, offset int64) (io.ReadCloser, int64, error) {
+	if r.IsDir() {
+		return nil, 0, fmt.Errorf("download %s failed: not a file", r.Name)
+	}
+	querys := r.service.querys()
+	querys["document_id"] = r.docwsid
+	text, err := r.service.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodGet,
+		URL:     fmt.Sprintf("%s/ws/%s/download/by_id", r.service.docsRoot, r.zone),
+		Querys:  querys,
+		Headers: r.service.icloud.getCommonHeaders(map[string]string{}),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("download %s failed: %w", r.Name, err)
+	}
+	res := new(driveDownloadResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, 0, fmt.Errorf("download %s unmarshal failed, err: %w, text: %s", r.Name, err, text)
+	}
+	url := res.DataToken.URL
+	if url == "" {
+		url = res.PackageToken.URL
+	}
+	if url == "" {
+		return nil, 0, fmt.Errorf("download %s failed: no download url", r.Name)
+	}
+
+	body, start, err := r.service.icloud.openStream(ctx, url, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("download %s failed: %w", r.Name, err)
+	}
+	return body, start, nil
+}
+
+// DownloadTo downloads a file to target, through a .part file like PhotoAsset.DownloadTo,
+// and is retried and resumed the same way.
+func (r *DriveNode) DownloadTo(target string) error {
+	return r.DownloadToContext(context.Background(), target)
+}
+
+// DownloadToContext is like DownloadTo, the download is given up when ctx is done.
+func (r *DriveNode) DownloadToContext(ctx context.Context, target string) error {
+	storage := NewFileStorage()
+	if err := r.service.icloud.downloadToStorage(ctx, storage, target, r.Size, r.openDownload); err != nil {
+		return err
+	}
+	if !r.Modified.IsZero() {
+		if err := storage.Chtimes(target, r.Modified, r.Modified); err != nil {
+			return fmt.Errorf("change file time error: %v", err)
+		}
+	}
+	return nil
+}
+
+// Mkdir creates a folder named name in the folder, and returns it.
+func (r *DriveNode) Mkdir(name string) (*DriveNode, error) {
+	return r.MkdirContext(context.Background(), name)
+}
+
+// MkdirContext is like Mkdir, the request is given up when ctx is done.
+func (r *DriveNode) MkdirContext(ctx context.Context, name string) (*DriveNode, error) {
+	if !r.IsDir() {
+		return nil, fmt.Errorf("create folder %s failed, err: %s is not a folder", name, r.Name)
+	}
+	text, err := r.service.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.service.serviceRoot + "/createFolders",
+		Querys:  r.service.querys(),
+		Headers: r.service.icloud.getCommonHeaders(map[string]string{}),
+		Body: map[string]any{
+			"destinationDrivewsId": r.drivewsid,
+			"folders": []any{map[string]any{
+				"clientId": "FOLDER::UNKNOWN_ZONE::TempId-" + uuid.NewV4().String(),
+				"name":     name,
+			}},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create folder %s failed, err: %w", name, err)
+	}
+	res := new(driveCreateFoldersResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, fmt.Errorf("create folder %s unmarshal failed, err: %w, text: %s", name, err, text)
+	}
+	if len(res.Folders) == 0 {
+		return nil, fmt.Errorf("create folder %s failed, err: no folder in response, text: %s", name, text)
+	}
+	r.resetChildren()
+	return r.service.newDriveNode(res.Folders[0]), nil
+}
+
+// Trash moves the file or folder to Recently Deleted.
+func (r *DriveNode) Trash() error {
+	return r.TrashContext(context.Background())
+}
+
+// TrashContext is like Trash, the request is given up when ctx is done.
+func (r *DriveNode) TrashContext(ctx context.Context) error {
+	text, err := r.service.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.service.serviceRoot + "/moveItemsToTrash",
+		Querys:  r.service.querys(),
+		Headers: r.service.icloud.getCommonHeaders(map[string]string{}),
+		Body: map[string]any{
+			"items": []any{map[string]any{
+				"drivewsid": r.drivewsid,
+				"etag":      r.etag,
+				"clientId":  r.drivewsid,
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("trash %s failed, err: %w", r.Name, err)
+	}
+	res := new(driveTrashResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return fmt.Errorf("trash %s unmarshal failed, err: %w, text: %s", r.Name, err, text)
+	}
+	for _, item := range res.Items {
+		if item.Status != "OK" {
+			return fmt.Errorf("trash %s failed, status: %s", r.Name, item.Status)
+		}
+	}
+	return nil
+}
+
+func (r *DriveNode) resetChildren() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r._children = nil
+}
+
+type driveNodeData struct {
+	Drivewsid    string           `json:"drivewsid"`
+	Docwsid      string           `json:"docwsid"`
+	Zone         string           `json:"zone"`
+	Name         string           `json:"name"`
+	Extension    string           `json:"extension"`
+	Etag         string           `json:"etag"`
+	Type         string           `json:"type"`
+	Size         int64            `json:"size"`
+	DateModified string           `json:"dateModified"`
+	Items        []*driveNodeData `json:"items"`
+}
+
+type driveDownloadResp struct {
+	DataToken struct {
+		URL string `json:"url"`
+	} `json:"data_token"`
+	PackageToken struct {
+		URL string `json:"url"`
+	} `json:"package_token"`
+}
+
+type driveCreateFoldersResp struct {
+	Folders []*driveNodeData `json:"folders"`
+}
+
+type driveTrashResp struct {
+	Items []*struct {
+		Drivewsid string `json:"drivewsid"`
+		Status    string `json:"status"`
+	} `json:"items"`
+}
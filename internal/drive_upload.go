@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Upload uploads a file of size bytes into the folder, as filename, a file with the same name is kept,
+// iCloud renames the new one.
+//
+// file is streamed, not buffered in memory.
+func (r *DriveNode) Upload(filename string, file io.Reader, size int64) error {
+	return r.UploadContext(context.Background(), filename, file, size)
+}
+
+// UploadContext is like Upload, the upload is given up when ctx is done.
+func (r *DriveNode) UploadContext(ctx context.Context, filename string, file io.Reader, size int64) error {
+	if !r.IsDir() {
+		return fmt.Errorf("upload %s failed, err: %s is not a folder", filename, r.Name)
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// 1. ask for an upload url
+	querys := r.service.querys()
+	querys["filename"] = filename
+	querys["type"] = "FILE"
+	querys["content_type"] = contentType
+	querys["size"] = strconv.FormatInt(size, 10)
+	text, err := r.service.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     fmt.Sprintf("%s/ws/%s/upload/web", r.service.docsRoot, r.zone),
+		Querys:  querys,
+		Headers: r.service.icloud.getCommonHeaders(map[string]string{}),
+	})
+	if err != nil {
+		return fmt.Errorf("upload %s failed, err: %w", filename, err)
+	}
+	var tokens []*driveUploadToken
+	if err = json.Unmarshal([]byte(text), &tokens); err != nil || len(tokens) == 0 {
+		return fmt.Errorf("upload %s failed, err: no upload url, text: %s", filename, text)
+	}
+	token := tokens[0]
+
+	// 2. send the content
+	body, contentTypeHeader := multipartFileBody(filename, file)
+	text, err = r.service.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     token.URL,
+		Headers: r.service.icloud.getCommonHeaders(map[string]string{"Content-Type": contentTypeHeader}),
+		Body:    body,
+	})
+	body.Close()
+	if err != nil {
+		return fmt.Errorf("upload %s failed, err: %w", filename, err)
+	}
+	content := new(driveUploadContentResp)
+	if err = json.Unmarshal([]byte(text), content); err != nil {
+		return fmt.Errorf("upload %s unmarshal failed, err: %w, text: %s", filename, err, text)
+	}
+
+	// 3. add the file to the folder
+	now := time.Now().UnixMilli()
+	_, err = r.service.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     fmt.Sprintf("%s/ws/%s/update/documents", r.service.docsRoot, r.zone),
+		Querys:  r.service.querys(),
+		Headers: r.service.icloud.getCommonHeaders(map[string]string{}),
+		Body: map[string]any{
+			"data": map[string]any{
+				"signature":           content.SingleFile.FileChecksum,
+				"wrapping_key":        content.SingleFile.WrappingKey,
+				"reference_signature": content.SingleFile.ReferenceChecksum,
+				"receipt":             content.SingleFile.Receipt,
+				"size":                content.SingleFile.Size,
+			},
+			"command":           "add_file",
+			"create_short_guid": true,
+			"document_id":       token.DocumentID,
+			"path": map[string]any{
+				"starting_document_id": r.docwsid,
+				"path":                 filename,
+			},
+			"allow_conflict": true,
+			"file_flags": map[string]any{
+				"is_writable":   true,
+				"is_executable": false,
+				"is_hidden":     false,
+			},
+			"mtime": now,
+			"btime": now,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("upload %s failed, err: %w", filename, err)
+	}
+	r.resetChildren()
+	return nil
+}
+
+// multipartFileBody streams file as the "files" part of a multipart body,
+// close the body once the request is done, which stops the copy if it's not read to the end.
+func multipartFileBody(filename string, file io.Reader) (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		part, err := writer.CreateFormFile("files", filename)
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, writer.FormDataContentType()
+}
+
+type driveUploadToken struct {
+	DocumentID string `json:"document_id"`
+	URL        string `json:"url"`
+}
+
+type driveUploadContentResp struct {
+	SingleFile struct {
+		FileChecksum      string `json:"fileChecksum"`
+		WrappingKey       string `json:"wrappingKey"`
+		ReferenceChecksum string `json:"referenceChecksum"`
+		Receipt           string `json:"receipt"`
+		Size              int64  `json:"size"`
+	} `json:"singleFile"`
+}
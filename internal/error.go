@@ -10,6 +10,15 @@ import (
 var (
 	ErrValidateCodeWrong = NewError("-21669", "validate code wrong")
 	ErrPhotosIterateEnd  = NewError("photos_iterate_end", "photos iterate end")
+
+	// ErrPasswordRequired and ErrTwoFACodeRequired are returned by non-interactive getters
+	// when they have no value, Authenticate keeps them in its error chain.
+	ErrPasswordRequired  = NewError("password_required", "password required, but no password is provided")
+	ErrTwoFACodeRequired = NewError("2fa_code_required", "2fa code required, but no 2fa code is provided")
+	ErrTwoStepRequired   = NewError("2sa_required", "two-step authentication required, but no trusted device is selected to send the code to")
+
+	// ErrSecurityKeyRequired is returned when the account signs in with security keys, which need a browser
+	ErrSecurityKeyRequired = NewError("security_key_required", "the account signs in with security keys, which need a browser")
 )
 
 type Error struct {
@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FindMyService is the Find My service of the account, the devices of the account and of its family,
+// get it with Client.FindMyCli.
+type FindMyService struct {
+	icloud      *Client
+	serviceRoot string
+}
+
+// FindMyCli returns the Find My service of the account, it's created once and cached by the client.
+func (r *Client) FindMyCli() (*FindMyService, error) {
+	r.findMyLock.Lock()
+	defer r.findMyLock.Unlock()
+
+	if r.findMy == nil {
+		serviceRoot, err := r.getWebServiceURL("findme")
+		if err != nil {
+			return nil, err
+		}
+		r.findMy = &FindMyService{icloud: r, serviceRoot: serviceRoot}
+	}
+	return r.findMy, nil
+}
+
+// FindMyDevice is a device of Find My, as of the last Devices call.
+type FindMyDevice struct {
+	service *FindMyService
+
+	ID          string
+	Name        string // the name the owner gave it, like "John's iPhone"
+	DisplayName string // like "iPhone 14 Pro"
+	Class       string // like iPhone, iPad, Mac, Watch
+	Model       string
+	// Status is the Find My status code, like 200 for online, 201 for offline, 203 for pending
+	Status string
+	// BatteryLevel is between 0 and 1, BatteryStatus like Charging, NotCharging or Charged
+	BatteryLevel    float64
+	BatteryStatus   string
+	LostModeCapable bool
+	// Location is nil when the device couldn't be located
+	Location *FindMyLocation
+}
+
+// FindMyLocation is where a device was last seen.
+type FindMyLocation struct {
+	Latitude  float64
+	Longitude float64
+	// HorizontalAccuracy is the radius of the location in meters
+	HorizontalAccuracy float64
+	Timestamp          time.Time
+	PositionType       string // like GPS or Wifi
+	IsOld              bool
+	IsInaccurate       bool
+}
+
+// Devices locates the devices, and returns them.
+func (r *FindMyService) Devices() ([]*FindMyDevice, error) {
+	return r.DevicesContext(context.Background())
+}
+
+// DevicesContext is like Devices, the request is given up when ctx is done.
+func (r *FindMyService) DevicesContext(ctx context.Context) ([]*FindMyDevice, error) {
+	text, err := r.request(ctx, "refreshClient", map[string]any{
+		"clientContext": map[string]any{
+			"fmly":              true,
+			"shouldLocate":      true,
+			"selectedDevice":    "all",
+			"deviceListVersion": 1,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get find my devices failed, err: %w", err)
+	}
+	res := new(findMyRefreshResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, fmt.Errorf("get find my devices unmarshal failed, err: %w, text: %s", err, text)
+	}
+
+	devices := make([]*FindMyDevice, 0, len(res.Content))
+	for _, v := range res.Content {
+		device := &FindMyDevice{
+			service:         r,
+			ID:              v.ID,
+			Name:            v.Name,
+			DisplayName:     v.DeviceDisplayName,
+			Class:           v.DeviceClass,
+			Model:           v.DeviceModel,
+			Status:          v.DeviceStatus,
+			BatteryLevel:    v.BatteryLevel,
+			BatteryStatus:   v.BatteryStatus,
+			LostModeCapable: v.LostModeCapable,
+		}
+		if v.Location != nil {
+			device.Location = &FindMyLocation{
+				Latitude:           v.Location.Latitude,
+				Longitude:          v.Location.Longitude,
+				HorizontalAccuracy: v.Location.HorizontalAccuracy,
+				Timestamp:          time.UnixMilli(v.Location.TimeStamp),
+				PositionType:       v.Location.PositionType,
+				IsOld:              v.Location.IsOld,
+				IsInaccurate:       v.Location.IsInaccurate,
+			}
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// GetDevice finds a device by id or name, names are matched case insensitively.
+func (r *FindMyService) GetDevice(idOrName string) (*FindMyDevice, error) {
+	return r.GetDeviceContext(context.Background(), idOrName)
+}
+
+// GetDeviceContext is like GetDevice, the request is given up when ctx is done.
+func (r *FindMyService) GetDeviceContext(ctx context.Context, idOrName string) (*FindMyDevice, error) {
+	devices, err := r.DevicesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		if device.ID == idOrName || strings.EqualFold(device.Name, idOrName) {
+			return device, nil
+		}
+	}
+	return nil, fmt.Errorf("find my device %s not found", idOrName)
+}
+
+// PlaySound plays a sound on the device, subject is the title of the alert shown with it.
+func (r *FindMyDevice) PlaySound(subject string) error {
+	return r.PlaySoundContext(context.Background(), subject)
+}
+
+// PlaySoundContext is like PlaySound, the request is given up when ctx is done.
+func (r *FindMyDevice) PlaySoundContext(ctx context.Context, subject string) error {
+	if subject == "" {
+		subject = "Find My iPhone Alert"
+	}
+	_, err := r.service.request(ctx, "playSound", map[string]any{
+		"device":        r.ID,
+		"subject":       subject,
+		"clientContext": map[string]any{"fmly": true},
+	})
+	if err != nil {
+		return fmt.Errorf("play sound on %s failed, err: %w", r.Name, err)
+	}
+	return nil
+}
+
+// DisplayMessage shows a message on the device, with a sound if sound is set.
+func (r *FindMyDevice) DisplayMessage(subject, message string, sound bool) error {
+	return r.DisplayMessageContext(context.Background(), subject, message, sound)
+}
+
+// DisplayMessageContext is like DisplayMessage, the request is given up when ctx is done.
+func (r *FindMyDevice) DisplayMessageContext(ctx context.Context, subject, message string, sound bool) error {
+	_, err := r.service.request(ctx, "sendMessage", map[string]any{
+		"device":   r.ID,
+		"subject":  subject,
+		"sound":    sound,
+		"userText": true,
+		"text":     message,
+	})
+	if err != nil {
+		return fmt.Errorf("display message on %s failed, err: %w", r.Name, err)
+	}
+	return nil
+}
+
+// LostMode puts the device in Lost Mode: it's locked with passcode, if the device has none,
+// and shows message and the phone number to call the owner.
+func (r *FindMyDevice) LostMode(phoneNumber, message, passcode string) error {
+	return r.LostModeContext(context.Background(), phoneNumber, message, passcode)
+}
+
+// LostModeContext is like LostMode, the request is given up when ctx is done.
+func (r *FindMyDevice) LostModeContext(ctx context.Context, phoneNumber, message, passcode string) error {
+	if !r.LostModeCapable {
+		return fmt.Errorf("lost mode on %s failed, err: the device doesn't support lost mode", r.Name)
+	}
+	_, err := r.service.request(ctx, "lostDevice", map[string]any{
+		"device":          r.ID,
+		"text":            message,
+		"userText":        true,
+		"ownerNbr":        phoneNumber,
+		"lostModeEnabled": true,
+		"trackingEnabled": true,
+		"passcode":        passcode,
+	})
+	if err != nil {
+		return fmt.Errorf("lost mode on %s failed, err: %w", r.Name, err)
+	}
+	return nil
+}
+
+func (r *FindMyService) request(ctx context.Context, action string, body map[string]any) (string, error) {
+	return r.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     r.serviceRoot + "/fmipservice/client/web/" + action,
+		Querys:  map[string]string{"dsid": r.icloud.DSID()},
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		Body:    body,
+	})
+}
+
+type findMyRefreshResp struct {
+	Content []struct {
+		ID                string  `json:"id"`
+		Name              string  `json:"name"`
+		DeviceDisplayName string  `json:"deviceDisplayName"`
+		DeviceClass       string  `json:"deviceClass"`
+		DeviceModel       string  `json:"deviceModel"`
+		DeviceStatus      string  `json:"deviceStatus"`
+		BatteryLevel      float64 `json:"batteryLevel"`
+		BatteryStatus     string  `json:"batteryStatus"`
+		LostModeCapable   bool    `json:"lostModeCapable"`
+		Location          *struct {
+			Latitude           float64 `json:"latitude"`
+			Longitude          float64 `json:"longitude"`
+			HorizontalAccuracy float64 `json:"horizontalAccuracy"`
+			TimeStamp          int64   `json:"timeStamp"`
+			PositionType       string  `json:"positionType"`
+			IsOld              bool    `json:"isOld"`
+			IsInaccurate       bool    `json:"isInaccurate"`
+		} `json:"location"`
+	} `json:"content"`
+}
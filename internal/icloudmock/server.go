@@ -0,0 +1,921 @@
+// Package icloudmock is a fake iCloud for the tests, it serves the sign-in, setup, photos database,
+// upload and download endpoints the client talks to, from an in-memory photo library.
+//
+// Point the client to it with ClientOption.Endpoints, Auth and Setup are enough,
+// the photos database and the uploads are found in the webservices of the account it answers.
+package icloudmock
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AppleID and Password are the credentials the server accepts, unless changed on the Server.
+	AppleID  = "user@example.com"
+	Password = "password"
+
+	// Dsid is the dsid of the account.
+	Dsid = "1000000001"
+
+	databasePrefix = "/database/1/com.apple.photos.cloud/production/private"
+)
+
+// Asset is a photo of the library.
+type Asset struct {
+	// ID is the record name of the CPLMaster, what PhotoAsset.ID returns,
+	// the CPLAsset record is named by RecordName.
+	ID        string
+	Filename  string
+	Data      []byte
+	AssetDate time.Time
+	AddedDate time.Time
+	Modified  time.Time
+	Hidden    bool
+	Deleted   bool
+	Favorite  bool
+	// Albums are the ids of the user albums the asset is filed into
+	Albums []string
+
+	changeTag int
+	seq       int // the change of the library which last changed the asset
+}
+
+// RecordName is the record name of the CPLAsset of the asset, what Upload returns as PhotoID.
+func (r *Asset) RecordName() string {
+	return r.ID + "-ASSET"
+}
+
+// Checksum is the fileChecksum of the original.
+func (r *Asset) Checksum() string {
+	sum := sha1.Sum(r.Data)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Album is a user album of the library.
+type Album struct {
+	ID      string
+	Name    string
+	Deleted bool
+
+	changeTag int
+}
+
+// Server is the fake iCloud, it's safe for concurrent use, like the client.
+type Server struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	appleID  string
+	password string
+	token    string // the session token of the last sign-in, empty before it
+	expired  bool   // the session must be renewed with accountLogin
+	assets   []*Asset
+	albums   []*Album
+	failures map[string]int
+	requests map[string]int
+	nextID   int
+
+	seq         int              // the last change of the library, the sync tokens are seq-<n>
+	expunged    []*expungedAsset // the assets expunged, for the change feed
+	changesPage int
+}
+
+type expungedAsset struct {
+	id  string
+	seq int
+}
+
+// New starts a server with an empty library, Close it when done.
+func New() *Server {
+	s := &Server{
+		appleID:  AppleID,
+		password: Password,
+		failures: map[string]int{},
+		requests: map[string]int{},
+
+		changesPage: 200,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// AuthEndpoint is the Endpoints.Auth of the server.
+func (s *Server) AuthEndpoint() string {
+	return s.URL + "/appleauth/auth"
+}
+
+// SetupEndpoint is the Endpoints.Setup of the server.
+func (s *Server) SetupEndpoint() string {
+	return s.URL + "/setup/ws/1"
+}
+
+// AddAsset adds the asset to the library, the missing ID and dates are filled in, and returns it.
+func (s *Server) AddAsset(asset *Asset) *Asset {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.addAsset(asset)
+	return asset
+}
+
+func (s *Server) addAsset(asset *Asset) {
+	s.nextID++
+	if asset.ID == "" {
+		asset.ID = fmt.Sprintf("MASTER%04d", s.nextID)
+	}
+	if asset.Filename == "" {
+		asset.Filename = fmt.Sprintf("IMG_%04d.JPG", s.nextID)
+	}
+	if asset.AssetDate.IsZero() {
+		asset.AssetDate = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(s.nextID) * time.Hour)
+	}
+	if asset.AddedDate.IsZero() {
+		asset.AddedDate = asset.AssetDate
+	}
+	if asset.Modified.IsZero() {
+		asset.Modified = asset.AddedDate
+	}
+	asset.changeTag = 1
+	s.seq++
+	asset.seq = s.seq
+	s.assets = append(s.assets, asset)
+}
+
+// AddAlbum adds a user album to the library, and returns it.
+func (s *Server) AddAlbum(name string) *Album {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.nextID++
+	album := &Album{ID: fmt.Sprintf("ALBUM%04d", s.nextID), Name: name, changeTag: 1}
+	s.albums = append(s.albums, album)
+	return album
+}
+
+// Asset returns a copy of the asset of the master record name id, nil if there is none, like after it's expunged.
+func (s *Server) Asset(id string) *Asset {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, asset := range s.assets {
+		if asset.ID == id {
+			res := *asset
+			res.Albums = append([]string{}, asset.Albums...)
+			return &res
+		}
+	}
+	return nil
+}
+
+// UpdateAsset changes the asset of the master record name id with fn, like an edit on another device,
+// its modification date is now unless fn sets it, and it's in the next changes of the change feed.
+func (s *Server) UpdateAsset(id string, fn func(asset *Asset)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	asset := s.findByID(id)
+	if asset == nil {
+		return
+	}
+	modified := asset.Modified
+	fn(asset)
+	if asset.Modified.Equal(modified) {
+		asset.Modified = time.Now()
+	}
+	s.changed(asset)
+}
+
+// SetChangesPageSize sets how many assets a page of the change feed has at most, 200 by default.
+func (s *Server) SetChangesPageSize(n int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.changesPage = n
+}
+
+// Fail makes the requests of name fail with status, 0 lets them succeed again.
+//
+// name is the record type of a records/query, like CPLAlbumByPositionLive or CPLAssetAndMasterHiddenByAssetDate,
+// or the last element of the path for the others, like signin, accountLogin, validate, batch, modify, lookup, zone (the change feed), upload or download.
+func (s *Server) Fail(name string, status int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if status == 0 {
+		delete(s.failures, name)
+	} else {
+		s.failures[name] = status
+	}
+}
+
+// ExpireSession makes the photos requests fail with 421 until the session is renewed by an accountLogin.
+func (s *Server) ExpireSession() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.expired = true
+}
+
+// Requests returns how many requests of name were received, name is like the one of Fail.
+func (s *Server) Requests(name string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests[name]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	name := path.Base(req.URL.Path)
+	var query *recordsQuery
+	if name == "query" && strings.HasSuffix(req.URL.Path, "/records/query") {
+		query = new(recordsQuery)
+		if err := json.Unmarshal(body, query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name = query.Query.RecordType
+	}
+	if strings.HasPrefix(req.URL.Path, "/download/") {
+		name = "download"
+	}
+	s.requests[name]++
+	if status := s.failures[name]; status != 0 {
+		writeJSON(w, status, map[string]any{"serverErrorCode": "INTERNAL_ERROR", "reason": "injected failure of " + name})
+		return
+	}
+
+	switch {
+	case req.URL.Path == "/appleauth/auth/signin":
+		s.signIn(w, body)
+	case req.URL.Path == "/setup/ws/1/accountLogin":
+		s.accountLogin(w, body)
+	case req.URL.Path == "/setup/ws/1/validate":
+		if s.token == "" || s.expired {
+			writeJSON(w, 421, map[string]any{"error": "Missing X-APPLE-WEBAUTH-TOKEN cookie"})
+			return
+		}
+		writeJSON(w, http.StatusOK, s.validateData())
+	case req.URL.Path == "/upload":
+		s.upload(w, req, body)
+	case strings.HasPrefix(req.URL.Path, "/download/"):
+		s.download(w, req)
+	case strings.HasPrefix(req.URL.Path, databasePrefix+"/"):
+		if s.token == "" || s.expired {
+			writeJSON(w, 421, map[string]any{"error": "Authentication required"})
+			return
+		}
+		switch strings.TrimPrefix(req.URL.Path, databasePrefix) {
+		case "/records/query":
+			writeJSON(w, http.StatusOK, s.query(query))
+		case "/internal/records/query/batch":
+			s.batch(w, body)
+		case "/records/modify":
+			s.modify(w, body)
+		case "/records/lookup":
+			s.lookup(w, body)
+		case "/changes/zone":
+			s.changes(w, body)
+		default:
+			http.NotFound(w, req)
+		}
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func (s *Server) signIn(w http.ResponseWriter, body []byte) {
+	var req struct {
+		AccountName string `json:"accountName"`
+		Password    string `json:"password"`
+	}
+	_ = json.Unmarshal(body, &req)
+	if req.AccountName != s.appleID || req.Password != s.password {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{
+			"serviceErrors": []any{map[string]any{"code": "-20101", "message": "Your Apple ID or password was incorrect."}},
+		})
+		return
+	}
+	s.nextID++
+	s.token = fmt.Sprintf("session-token-%d", s.nextID)
+	w.Header().Set("X-Apple-Session-Token", s.token)
+	w.Header().Set("X-Apple-ID-Session-Id", "session-id")
+	w.Header().Set("X-Apple-ID-Account-Country", "USA")
+	w.Header().Set("scnt", "scnt")
+	writeJSON(w, http.StatusOK, map[string]any{"authType": "hsa2"})
+}
+
+func (s *Server) accountLogin(w http.ResponseWriter, body []byte) {
+	var req struct {
+		DsWebAuthToken string `json:"dsWebAuthToken"`
+	}
+	_ = json.Unmarshal(body, &req)
+	if s.token == "" || req.DsWebAuthToken != s.token {
+		writeJSON(w, 421, map[string]any{"error": "Invalid session token"})
+		return
+	}
+	s.expired = false
+	writeJSON(w, http.StatusOK, s.validateData())
+}
+
+func (s *Server) validateData() map[string]any {
+	return map[string]any{
+		"dsInfo": map[string]any{
+			"dsid":               Dsid,
+			"appleId":            s.appleID,
+			"fullName":           "Test User",
+			"locale":             "en_US",
+			"hsaVersion":         2,
+			"isWebAccessAllowed": true,
+		},
+		"hsaTrustedBrowser":    true,
+		"hsaChallengeRequired": false,
+		"webservices": map[string]any{
+			"ckdatabasews":  map[string]any{"url": s.URL, "status": "active"},
+			"uploadimagews": map[string]any{"url": s.URL, "status": "active"},
+		},
+	}
+}
+
+type queryFilter struct {
+	FieldName  string `json:"fieldName"`
+	Comparator string `json:"comparator"`
+	FieldValue struct {
+		Value json.RawMessage `json:"value"`
+		Type  string          `json:"type"`
+	} `json:"fieldValue"`
+}
+
+type recordsQuery struct {
+	Query struct {
+		RecordType string         `json:"recordType"`
+		FilterBy   []*queryFilter `json:"filterBy"`
+	} `json:"query"`
+	ResultsLimit int `json:"resultsLimit"`
+}
+
+func (r *recordsQuery) filter(fieldName string) *queryFilter {
+	for _, filter := range r.Query.FilterBy {
+		if filter.FieldName == fieldName {
+			return filter
+		}
+	}
+	return nil
+}
+
+func (r *recordsQuery) stringFilter(fieldName string) string {
+	var s string
+	if filter := r.filter(fieldName); filter != nil {
+		_ = json.Unmarshal(filter.FieldValue.Value, &s)
+	}
+	return s
+}
+
+func (s *Server) query(query *recordsQuery) map[string]any {
+	switch query.Query.RecordType {
+	case "CheckIndexingState":
+		return map[string]any{"records": []any{map[string]any{
+			"recordName": "_INDEXING_STATE", "recordType": "CheckIndexingState",
+			"fields": map[string]any{"state": map[string]any{"value": "FINISHED", "type": "STRING"}},
+		}}}
+	case "HyperionIndexCountLookup":
+		return map[string]any{"records": []any{}}
+	case "CPLAlbumByPositionLive":
+		records := []any{}
+		for _, album := range s.albums {
+			if !album.Deleted {
+				records = append(records, album.record())
+			}
+		}
+		return map[string]any{"records": records}
+	}
+
+	assets := s.list(query.Query.RecordType, query)
+	start, limit := 0, query.ResultsLimit
+	if filter := query.filter("startRank"); filter != nil {
+		_ = json.Unmarshal(filter.FieldValue.Value, &start)
+	}
+	if limit <= 0 {
+		limit = 200
+	}
+	var page []*Asset
+	if query.stringFilter("direction") == "DESCENDING" {
+		for i := start; i >= 0 && i < len(assets) && len(page) < limit; i-- {
+			page = append(page, assets[i])
+		}
+	} else {
+		for i := start; i >= 0 && i < len(assets) && len(page) < limit; i++ {
+			page = append(page, assets[i])
+		}
+	}
+
+	records := []any{}
+	for _, asset := range page {
+		records = append(records, s.masterRecord(asset), s.assetRecord(asset))
+	}
+	return map[string]any{"records": records, "syncToken": "sync-token"}
+}
+
+// list returns the assets of the list type, the newest first, they are sorted by the date of the name of the list.
+func (s *Server) list(listType string, query *recordsQuery) []*Asset {
+	var since, until int64
+	for _, filter := range query.Query.FilterBy {
+		if filter.FieldName != "assetDate" {
+			continue
+		}
+		var value int64
+		_ = json.Unmarshal(filter.FieldValue.Value, &value)
+		switch filter.Comparator {
+		case "GREATER_THAN_OR_EQUALS":
+			since = value
+		case "LESS_THAN":
+			until = value
+		}
+	}
+
+	var match func(asset *Asset) bool
+	switch listType {
+	case "CPLAssetAndMasterByAddedDate":
+		// like iCloud, the hidden and the deleted assets are listed too, the client filters them
+		match = func(asset *Asset) bool { return true }
+	case "CPLAssetAndMasterHiddenByAssetDate":
+		match = func(asset *Asset) bool { return asset.Hidden && !asset.Deleted }
+	case "CPLAssetAndMasterDeletedByExpungedDate":
+		match = func(asset *Asset) bool { return asset.Deleted }
+	case "CPLAssetAndMasterInSmartAlbumByAssetDate":
+		smartAlbum := query.stringFilter("smartAlbum")
+		match = func(asset *Asset) bool {
+			if asset.Hidden || asset.Deleted {
+				return false
+			}
+			switch smartAlbum {
+			case "FAVORITE":
+				return asset.Favorite
+			case "VIDEO":
+				return isVideo(asset.Filename)
+			}
+			return false
+		}
+	case "CPLContainerRelationLiveByAssetDate":
+		parentID := query.stringFilter("parentId")
+		match = func(asset *Asset) bool {
+			return !asset.Deleted && contains(asset.Albums, parentID)
+		}
+	default:
+		return nil
+	}
+
+	var res []*Asset
+	for _, asset := range s.assets {
+		date := asset.AssetDate.UnixMilli()
+		if match(asset) && (since == 0 || date >= since) && (until == 0 || date < until) {
+			res = append(res, asset)
+		}
+	}
+	byAdded := strings.Contains(listType, "ByAddedDate") || strings.Contains(listType, "ByExpungedDate")
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i].AssetDate, res[j].AssetDate
+		if byAdded {
+			a, b = res[i].AddedDate, res[j].AddedDate
+		}
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return res[i].ID < res[j].ID
+	})
+	return res
+}
+
+// count is the itemCount of the HyperionIndexCountLookup of objType.
+func (s *Server) count(objType string) int {
+	count := 0
+	for _, asset := range s.assets {
+		var match bool
+		switch {
+		case objType == "CPLAssetByAddedDate":
+			match = !asset.Hidden && !asset.Deleted
+		case objType == "CPLAssetHiddenByAssetDate":
+			match = asset.Hidden && !asset.Deleted
+		case objType == "CPLAssetDeletedByExpungedDate":
+			match = asset.Deleted
+		case objType == "CPLAssetInSmartAlbumByAssetDate:Favorite":
+			match = asset.Favorite && !asset.Hidden && !asset.Deleted
+		case objType == "CPLAssetInSmartAlbumByAssetDate:Video":
+			match = isVideo(asset.Filename) && !asset.Hidden && !asset.Deleted
+		case strings.HasPrefix(objType, "CPLContainerRelationNotDeletedByAssetDate:"):
+			match = !asset.Deleted && contains(asset.Albums, strings.TrimPrefix(objType, "CPLContainerRelationNotDeletedByAssetDate:"))
+		}
+		if match {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Server) batch(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Batch []*recordsQuery `json:"batch"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	batch := []any{}
+	for _, query := range req.Batch {
+		var objTypes []string
+		if filter := query.filter("indexCountID"); filter != nil {
+			_ = json.Unmarshal(filter.FieldValue.Value, &objTypes)
+		}
+		records := []any{}
+		for _, objType := range objTypes {
+			records = append(records, map[string]any{
+				"recordName": objType, "recordType": "IndexCountResult",
+				"fields": map[string]any{"itemCount": map[string]any{"value": s.count(objType), "type": "INT64"}},
+			})
+		}
+		batch = append(batch, map[string]any{"records": records})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"batch": batch})
+}
+
+type modifyOperation struct {
+	OperationType string `json:"operationType"`
+	Record        struct {
+		RecordName      string                     `json:"recordName"`
+		RecordType      string                     `json:"recordType"`
+		RecordChangeTag string                     `json:"recordChangeTag"`
+		Fields          map[string]json.RawMessage `json:"fields"`
+	} `json:"record"`
+}
+
+func (s *Server) modify(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Operations []*modifyOperation `json:"operations"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	records := []any{}
+	for _, op := range req.Operations {
+		records = append(records, s.modifyRecord(op))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"records": records})
+}
+
+func (s *Server) modifyRecord(op *modifyOperation) map[string]any {
+	name := op.Record.RecordName
+	field := func(key string) (json.RawMessage, bool) {
+		raw, ok := op.Record.Fields[key]
+		if !ok {
+			return nil, false
+		}
+		var value struct {
+			Value json.RawMessage `json:"value"`
+		}
+		_ = json.Unmarshal(raw, &value)
+		return value.Value, true
+	}
+	isOne := func(raw json.RawMessage) bool {
+		return string(raw) == "1" || string(raw) == "true"
+	}
+	stringOf := func(raw json.RawMessage) string {
+		var s string
+		_ = json.Unmarshal(raw, &s)
+		return s
+	}
+
+	switch op.Record.RecordType {
+	case "CPLAsset":
+		asset := s.findByRecordName(name)
+		if asset == nil {
+			return map[string]any{"recordName": name, "serverErrorCode": "NOT_FOUND", "reason": "Record not found"}
+		}
+		if op.Record.RecordChangeTag != "" && op.Record.RecordChangeTag != changeTag(asset.changeTag) {
+			return map[string]any{"recordName": name, "serverErrorCode": "CONFLICT", "reason": "record to insert already exists"}
+		}
+		if raw, ok := field("isExpunged"); ok && isOne(raw) {
+			s.expunge(asset)
+			return map[string]any{"recordName": name, "deleted": true}
+		}
+		if raw, ok := field("isDeleted"); ok {
+			asset.Deleted = isOne(raw)
+		}
+		if raw, ok := field("isHidden"); ok {
+			asset.Hidden = isOne(raw)
+		}
+		if raw, ok := field("isFavorite"); ok {
+			asset.Favorite = isOne(raw)
+		}
+		asset.Modified = time.Now()
+		s.changed(asset)
+		return s.assetRecord(asset)
+	case "CPLAlbum":
+		album := s.findAlbum(name)
+		if op.OperationType == "create" {
+			if album != nil {
+				return map[string]any{"recordName": name, "serverErrorCode": "CONFLICT", "reason": "record to insert already exists"}
+			}
+			raw, _ := field("albumNameEnc")
+			bs, _ := base64.StdEncoding.DecodeString(stringOf(raw))
+			album = &Album{ID: name, Name: string(bs), changeTag: 1}
+			s.albums = append(s.albums, album)
+			return album.record()
+		}
+		if album == nil {
+			return map[string]any{"recordName": name, "serverErrorCode": "NOT_FOUND", "reason": "Record not found"}
+		}
+		if raw, ok := field("albumNameEnc"); ok {
+			bs, _ := base64.StdEncoding.DecodeString(stringOf(raw))
+			album.Name = string(bs)
+		}
+		if raw, ok := field("isDeleted"); ok {
+			album.Deleted = isOne(raw)
+		}
+		album.changeTag++
+		return album.record()
+	case "CPLContainerRelation":
+		itemID, albumID, _ := strings.Cut(name, "-IN-")
+		if raw, ok := field("itemId"); ok {
+			itemID = stringOf(raw)
+		}
+		if raw, ok := field("containerId"); ok {
+			albumID = stringOf(raw)
+		}
+		asset := s.findByRecordName(itemID)
+		if asset == nil {
+			return map[string]any{"recordName": name, "serverErrorCode": "NOT_FOUND", "reason": "Record not found"}
+		}
+		if op.OperationType == "create" {
+			if !contains(asset.Albums, albumID) {
+				asset.Albums = append(asset.Albums, albumID)
+			}
+		} else {
+			albums := asset.Albums[:0]
+			for _, id := range asset.Albums {
+				if id != albumID {
+					albums = append(albums, id)
+				}
+			}
+			asset.Albums = albums
+		}
+		return map[string]any{"recordName": name, "recordType": "CPLContainerRelation", "recordChangeTag": "1"}
+	}
+	return map[string]any{"recordName": name, "serverErrorCode": "BAD_REQUEST", "reason": "unknown record type " + op.Record.RecordType}
+}
+
+func (s *Server) lookup(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Records []struct {
+			RecordName string `json:"recordName"`
+		} `json:"records"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	records := []any{}
+	for _, v := range req.Records {
+		if asset := s.findByRecordName(v.RecordName); asset != nil {
+			records = append(records, s.assetRecord(asset))
+		} else if asset := s.findByID(v.RecordName); asset != nil {
+			records = append(records, s.masterRecord(asset))
+		} else if album := s.findAlbum(v.RecordName); album != nil {
+			records = append(records, album.record())
+		} else {
+			records = append(records, map[string]any{"recordName": v.RecordName, "serverErrorCode": "NOT_FOUND", "reason": "Record not found"})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"records": records})
+}
+
+func (s *Server) upload(w http.ResponseWriter, req *http.Request, body []byte) {
+	if s.token == "" || s.expired {
+		writeJSON(w, 421, map[string]any{"error": "Authentication required"})
+		return
+	}
+	for _, asset := range s.assets {
+		if bytes.Equal(asset.Data, body) {
+			writeJSON(w, http.StatusOK, map[string]any{"isDuplicate": true, "photoId": asset.RecordName()})
+			return
+		}
+	}
+	now := time.Now()
+	asset := &Asset{Filename: req.URL.Query().Get("filename"), Data: body, AssetDate: now, AddedDate: now}
+	s.addAsset(asset)
+	writeJSON(w, http.StatusOK, map[string]any{"isDuplicate": false, "photoId": asset.RecordName()})
+}
+
+// download serves /download/<id>, with ranges, like the resumed downloads ask for.
+func (s *Server) download(w http.ResponseWriter, req *http.Request) {
+	asset := s.findByID(strings.TrimPrefix(req.URL.Path, "/download/"))
+	if asset == nil {
+		http.NotFound(w, req)
+		return
+	}
+	http.ServeContent(w, req, asset.Filename, asset.Modified, bytes.NewReader(asset.Data))
+}
+
+// changed records a change of the asset record.
+func (s *Server) changed(asset *Asset) {
+	asset.changeTag++
+	s.seq++
+	asset.seq = s.seq
+}
+
+// changes serves the change feed, the assets changed since the sync token, in the order of the changes.
+func (s *Server) changes(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Zones []struct {
+			SyncToken string `json:"syncToken"`
+		} `json:"zones"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || len(req.Zones) == 0 {
+		http.Error(w, "invalid changes request", http.StatusBadRequest)
+		return
+	}
+	since := 0
+	if token := req.Zones[0].SyncToken; token != "" {
+		if _, err := fmt.Sscanf(token, "seq-%d", &since); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"serverErrorCode": "BAD_REQUEST", "reason": "invalid sync token " + token})
+			return
+		}
+	}
+
+	type change struct {
+		seq   int
+		asset *Asset
+		id    string // the master record name of an expunged asset
+	}
+	var changes []*change
+	for _, asset := range s.assets {
+		if asset.seq > since {
+			changes = append(changes, &change{seq: asset.seq, asset: asset})
+		}
+	}
+	for _, expunged := range s.expunged {
+		if expunged.seq > since {
+			changes = append(changes, &change{seq: expunged.seq, id: expunged.id})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].seq < changes[j].seq })
+
+	token, moreComing := s.seq, len(changes) > s.changesPage
+	if moreComing {
+		changes = changes[:s.changesPage]
+		token = changes[len(changes)-1].seq
+	}
+	records := []any{}
+	for _, v := range changes {
+		if v.asset == nil {
+			records = append(records,
+				map[string]any{"recordName": v.id, "recordType": "CPLMaster", "deleted": true},
+				map[string]any{"recordName": v.id + "-ASSET", "recordType": "CPLAsset", "deleted": true})
+			continue
+		}
+		records = append(records, s.masterRecord(v.asset), s.assetRecord(v.asset))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"zones": []any{map[string]any{
+		"records":    records,
+		"syncToken":  fmt.Sprintf("seq-%d", token),
+		"moreComing": moreComing,
+	}}})
+}
+
+func (s *Server) expunge(asset *Asset) {
+	s.seq++
+	s.expunged = append(s.expunged, &expungedAsset{id: asset.ID, seq: s.seq})
+	assets := s.assets[:0]
+	for _, v := range s.assets {
+		if v != asset {
+			assets = append(assets, v)
+		}
+	}
+	s.assets = assets
+}
+
+func (s *Server) findByID(id string) *Asset {
+	for _, asset := range s.assets {
+		if asset.ID == id {
+			return asset
+		}
+	}
+	return nil
+}
+
+func (s *Server) findByRecordName(recordName string) *Asset {
+	for _, asset := range s.assets {
+		if asset.RecordName() == recordName {
+			return asset
+		}
+	}
+	return nil
+}
+
+func (s *Server) findAlbum(id string) *Album {
+	for _, album := range s.albums {
+		if album.ID == id {
+			return album
+		}
+	}
+	return nil
+}
+
+func (s *Server) masterRecord(asset *Asset) map[string]any {
+	fileType := "public.jpeg"
+	if isVideo(asset.Filename) {
+		fileType = "com.apple.quicktime-movie"
+	}
+	return map[string]any{
+		"recordName":      asset.ID,
+		"recordType":      "CPLMaster",
+		"recordChangeTag": "1",
+		"fields": map[string]any{
+			"filenameEnc":         map[string]any{"value": base64.StdEncoding.EncodeToString([]byte(asset.Filename)), "type": "ENCRYPTED_BYTES"},
+			"itemType":            map[string]any{"value": fileType, "type": "STRING"},
+			"resOriginalFileType": map[string]any{"value": fileType, "type": "STRING"},
+			"resOriginalWidth":    map[string]any{"value": 4032, "type": "INT64"},
+			"resOriginalHeight":   map[string]any{"value": 3024, "type": "INT64"},
+			"resOriginalRes": map[string]any{"type": "ASSETID", "value": map[string]any{
+				"size":         len(asset.Data),
+				"fileChecksum": asset.Checksum(),
+				"downloadURL":  s.URL + "/download/" + asset.ID,
+			}},
+		},
+		"created":  map[string]any{"timestamp": asset.AddedDate.UnixMilli()},
+		"modified": map[string]any{"timestamp": asset.AddedDate.UnixMilli()},
+	}
+}
+
+func (s *Server) assetRecord(asset *Asset) map[string]any {
+	flag := func(b bool) map[string]any {
+		if b {
+			return map[string]any{"value": 1, "type": "INT64"}
+		}
+		return map[string]any{"value": 0, "type": "INT64"}
+	}
+	return map[string]any{
+		"recordName":      asset.RecordName(),
+		"recordType":      "CPLAsset",
+		"recordChangeTag": changeTag(asset.changeTag),
+		"fields": map[string]any{
+			"masterRef":  map[string]any{"type": "REFERENCE", "value": map[string]any{"recordName": asset.ID, "action": "DELETE_SELF"}},
+			"assetDate":  map[string]any{"value": asset.AssetDate.UnixMilli(), "type": "TIMESTAMP"},
+			"addedDate":  map[string]any{"value": asset.AddedDate.UnixMilli(), "type": "TIMESTAMP"},
+			"isHidden":   flag(asset.Hidden),
+			"isDeleted":  flag(asset.Deleted),
+			"isFavorite": flag(asset.Favorite),
+		},
+		"created":  map[string]any{"timestamp": asset.AddedDate.UnixMilli()},
+		"modified": map[string]any{"timestamp": asset.Modified.UnixMilli()},
+	}
+}
+
+func (r *Album) record() map[string]any {
+	return map[string]any{
+		"recordName":      r.ID,
+		"recordType":      "CPLAlbum",
+		"recordChangeTag": changeTag(r.changeTag),
+		"fields": map[string]any{
+			"albumNameEnc": map[string]any{"value": base64.StdEncoding.EncodeToString([]byte(r.Name)), "type": "ENCRYPTED_BYTES"},
+			"albumType":    map[string]any{"value": 0, "type": "INT64"},
+			"parentId":     map[string]any{"value": "----Root-Folder----", "type": "STRING"},
+		},
+	}
+}
+
+func changeTag(tag int) string {
+	return fmt.Sprintf("tag%d", tag)
+}
+
+func isVideo(filename string) bool {
+	ext := strings.ToLower(path.Ext(filename))
+	return ext == ".mov" || ext == ".mp4"
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"io"
+)
+
+// AlbumLister finds albums, it's implemented by *PhotoService,
+// accept it instead of *PhotoService to fake the library in tests,
+// the PhotoService of a client of icloudtest.NewServer has albums and assets of a fake library.
+type AlbumLister interface {
+	Albums() (map[string]*PhotoAlbum, error)
+	GetAlbum(albumName string) (*PhotoAlbum, error)
+	GetAlbumByID(id AlbumID) (*PhotoAlbum, error)
+}
+
+// AssetIterator yields assets until it returns ErrPhotosIterateEnd,
+// it's what PhotoAlbum.PhotosIter returns.
+type AssetIterator interface {
+	Next() (*PhotoAsset, error)
+}
+
+// Downloader downloads one version of an asset, it's implemented by *PhotoAsset.
+type Downloader interface {
+	Download(version PhotoVersion) (io.ReadCloser, error)
+	DownloadTo(version PhotoVersion, target string) error
+	DownloadToStorage(version PhotoVersion, storage Storage, target string) error
+}
+
+var (
+	_ AlbumLister   = (*PhotoService)(nil)
+	_ AssetIterator = (*photosIterNextImpl)(nil)
+	_ AssetIterator = (*photosIterChain)(nil)
+	_ AssetIterator = (*photosIterErr)(nil)
+	_ Downloader    = (*PhotoAsset)(nil)
+)
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger receives what the client does, like the login steps, the retries and the downloads,
+// fields are key value pairs, like "file", "IMG_0001.HEIC", "err", err.
+//
+// It's called from the goroutines doing the work, concurrently.
+type Logger interface {
+	Log(level LogLevel, msg string, fields ...any)
+}
+
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+func (r LogLevel) String() string {
+	switch r {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(r))
+}
+
+// ParseLogLevel parses debug, info, warn or error.
+func ParseLogLevel(s string) (LogLevel, error) {
+	for level := LogLevelDebug; level <= LogLevelError; level++ {
+		if strings.EqualFold(s, level.String()) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid log level %q, want debug, info, warn or error", s)
+}
+
+// DefaultLogger is used when ClientOption.Logger is nil, it prints the info and above to stdout.
+var DefaultLogger Logger = NewTextLogger(os.Stdout, LogLevelInfo)
+
+// NopLogger discards everything, for embedders wanting the library silent.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Log(LogLevel, string, ...any) {}
+
+// NewTextLogger returns a Logger writing a line per entry at level and above to w, like `msg key=value ...`.
+func NewTextLogger(w io.Writer, level LogLevel) Logger {
+	return &writerLogger{w: w, level: level}
+}
+
+// NewJSONLogger returns a Logger writing a JSON object per entry at level and above to w,
+// with the time, level, msg and fields.
+func NewJSONLogger(w io.Writer, level LogLevel) Logger {
+	return &writerLogger{w: w, level: level, json: true}
+}
+
+type writerLogger struct {
+	lock  sync.Mutex
+	w     io.Writer
+	level LogLevel
+	json  bool
+}
+
+func (r *writerLogger) Log(level LogLevel, msg string, fields ...any) {
+	if level < r.level {
+		return
+	}
+	var line []byte
+	if r.json {
+		entry := map[string]any{"time": time.Now().Format(time.RFC3339), "level": level.String(), "msg": msg}
+		for i := 0; i+1 < len(fields); i += 2 {
+			entry[fmt.Sprint(fields[i])] = logValue(fields[i+1])
+		}
+		line, _ = json.Marshal(entry)
+	} else {
+		var sb strings.Builder
+		sb.WriteString(msg)
+		for i := 0; i+1 < len(fields); i += 2 {
+			fmt.Fprintf(&sb, " %v=%v", fields[i], logValue(fields[i+1]))
+		}
+		line = []byte(sb.String())
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, _ = r.w.Write(append(line, '\n'))
+}
+
+// logValue returns the loggable value of a field, errors and durations as their text.
+func logValue(v any) any {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+func (r *Client) log(level LogLevel, msg string, fields ...any) {
+	r.logger.Log(level, msg, fields...)
+}
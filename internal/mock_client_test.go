@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// newMockClient returns a client signed in to a fake iCloud, and the fake.
+func newMockClient(t *testing.T) (*Client, *icloudmock.Server) {
+	t.Helper()
+	server := icloudmock.New()
+	t.Cleanup(server.Close)
+
+	cli, err := NewClient(&ClientOption{
+		AppID:     icloudmock.AppleID,
+		CookieDir: t.TempDir(),
+		PasswordGetter: func(appleID string) (string, error) {
+			return icloudmock.Password, nil
+		},
+		TwoFACodeGetter: func(appleID string) (string, error) {
+			return "", errors.New("no 2fa code expected")
+		},
+		Domain:    "com",
+		Endpoints: &Endpoints{Auth: server.AuthEndpoint(), Setup: server.SetupEndpoint()},
+		Logger:    NopLogger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.Authenticate(false, nil); err != nil {
+		t.Fatal(err)
+	}
+	return cli, server
+}
+
+// iterFilenames returns the filenames of the assets of iter, in order.
+func iterFilenames(t *testing.T, iter AssetIterator) []string {
+	t.Helper()
+	var names []string
+	for {
+		asset, err := iter.Next()
+		if errors.Is(err, ErrPhotosIterateEnd) {
+			return names
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, asset.Filename())
+	}
+}
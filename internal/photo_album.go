@@ -1,8 +1,9 @@
 package internal
 
 import (
-	"encoding/base64"
+	"context"
 	"fmt"
+	"strings"
 	"sync"
 )
 
@@ -25,11 +26,13 @@ type PhotoAlbum struct {
 	service *PhotoService
 
 	// attr
+	id          AlbumID
 	Name        string
 	ListType    string
 	ObjType     string
 	Direction   string
 	QueryFilter []*folderMetaDataQueryFilter
+	path        string
 
 	// cache
 	_size *int
@@ -51,8 +54,15 @@ func (r *PhotoService) newPhotoAlbum(name, listType, objType, direction string,
 	}
 }
 
+// GetAlbum finds an album by name. Smart albums can also be found by AlbumID,
+// or by their localized name, like "Favoriten".
 func (r *PhotoService) GetAlbum(albumName string) (*PhotoAlbum, error) {
-	albums, err := r.Albums()
+	return r.GetAlbumContext(context.Background(), albumName)
+}
+
+// GetAlbumContext is like GetAlbum, the album list request is given up when ctx is done.
+func (r *PhotoService) GetAlbumContext(ctx context.Context, albumName string) (*PhotoAlbum, error) {
+	albums, err := r.AlbumsContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -62,31 +72,91 @@ func (r *PhotoService) GetAlbum(albumName string) (*PhotoAlbum, error) {
 		var ok bool
 		album, ok = albums[albumName]
 		if !ok {
+			if id, isSmartAlbum := resolveAlbumID(albumName); isSmartAlbum {
+				return r.GetAlbumByIDContext(ctx, id)
+			}
 			return nil, fmt.Errorf("album %s not found", albumName)
 		}
 	}
 	return album, nil
 }
 
+// GetAlbumByID finds a smart album by its stable id, independent of the account language.
+func (r *PhotoService) GetAlbumByID(id AlbumID) (*PhotoAlbum, error) {
+	return r.GetAlbumByIDContext(context.Background(), id)
+}
+
+// GetAlbumByIDContext is like GetAlbumByID, the album list request is given up when ctx is done.
+func (r *PhotoService) GetAlbumByIDContext(ctx context.Context, id AlbumID) (*PhotoAlbum, error) {
+	albums, err := r.AlbumsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, album := range albums {
+		if album.id == id {
+			return album, nil
+		}
+	}
+	return nil, fmt.Errorf("album %s not found", id)
+}
+
+// ID returns the AlbumID of a smart album, or the record name of a user album.
+func (r *PhotoAlbum) ID() AlbumID {
+	return r.id
+}
+
+// DisplayName returns the album name in the account language.
+func (r *PhotoAlbum) DisplayName() string {
+	if _, ok := albumIDToName[r.id]; ok {
+		return AlbumDisplayName(r.id, r.service.icloud.Locale())
+	}
+	return r.Name
+}
+
+// Albums returns the albums by name, the map is shared by all callers and must not be modified.
 func (r *PhotoService) Albums() (map[string]*PhotoAlbum, error) {
+	return r.AlbumsContext(context.Background())
+}
+
+// AlbumsContext is like Albums, the album list requests are given up when ctx is done.
+func (r *PhotoService) AlbumsContext(ctx context.Context) (map[string]*PhotoAlbum, error) {
 	r.lock.Lock()
-	albumIsNil := len(r._albums) == 0
+	albums := r._albums
 	r.lock.Unlock()
 
-	if !albumIsNil {
-		return r._albums, nil
+	if len(albums) > 0 {
+		return albums, nil
 	}
 
 	tmp := map[string]*PhotoAlbum{}
 
+	knownObjTypes := newSet[string]()
 	for name, props := range icloudPhotoFolderMeta {
 		tmp[name] = r.newPhotoAlbum(name, props.ListType, props.ObjType, props.Direction, props.QueryFilter)
+		tmp[name].id = albumNameToID[name]
+		knownObjTypes.Add(props.ObjType)
 	}
 
-	folders, err := r.getFolders()
+	// smart albums discovered at runtime never override the builtin ones,
+	// and discovery failure, which is reported to the logger and the event sink, falls back to the builtin list.
+	smartAlbums, err := r.getSmartAlbums(ctx)
+	if err != nil {
+		r.icloud.log(LogLevelWarn, "Smart album discovery failed, using the builtin smart albums", "err", err)
+		r.eventSink().Error(nil, err)
+	}
+	for name, props := range smartAlbums {
+		if _, ok := tmp[name]; ok || knownObjTypes.Has(props.ObjType) {
+			continue
+		}
+		tmp[name] = r.newPhotoAlbum(name, props.ListType, props.ObjType, props.Direction, props.QueryFilter)
+		tmp[name].id = AlbumID(strings.ToLower(name))
+	}
+
+	folders, err := r.getFolders(ctx)
 	if err != nil {
 		return nil, err
 	}
+	paths := resolveFolderPaths(folders)
 
 	for _, folder := range folders {
 		if folder.Fields.AlbumNameEnc == nil || folder.Fields.AlbumNameEnc.Value == "" {
@@ -95,28 +165,34 @@ func (r *PhotoService) Albums() (map[string]*PhotoAlbum, error) {
 		if folder.Fields.IsDeleted != nil && folder.Fields.IsDeleted.Value != "" {
 			continue
 		}
-		if folder.RecordName == "----Root-Folder----" {
+		if folder.RecordName == rootFolderRecordName {
 			continue
 		}
-		folderID := folder.RecordName
-		folderObjType := fmt.Sprintf("CPLContainerRelationNotDeletedByAssetDate:%s", folderID)
-		folderName, _ := base64.StdEncoding.DecodeString(folder.Fields.AlbumNameEnc.Value.(string))
+		folderName := folder.name()
 		if len(folderName) == 0 {
 			continue
 		}
-
-		tmp[string(folderName)] = r.newPhotoAlbum(string(folderName), "CPLContainerRelationLiveByAssetDate", folderObjType, "ASCENDING", []*folderMetaDataQueryFilter{{
-			FieldName:  "parentId",
-			Comparator: "EQUALS",
-			FieldValue: &folderTypeValue{Type: "STRING", Value: folderID},
-		}})
+		album, err := r.newUserAlbum(folder.RecordName, folderName, paths[folder.RecordName])
+		if err != nil {
+			continue
+		}
+		tmp[folderName] = album
 	}
 
 	r.lock.Lock()
 	r._albums = tmp
 	r.lock.Unlock()
 
-	return r._albums, nil
+	return tmp, nil
+}
+
+// Path returns the album's location in the iCloud folder hierarchy, like "Parent/Child/Album".
+// Smart albums and top-level albums return their name.
+func (r *PhotoAlbum) Path() string {
+	if r.path == "" {
+		return r.Name
+	}
+	return r.path
 }
 
 var icloudPhotoFolderMeta = map[string]*folderMetaData{
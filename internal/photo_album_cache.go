@@ -0,0 +1,39 @@
+package internal
+
+// adjustAlbumSizes keeps the cached album sizes in step with a mutation made through the library,
+// the cached sizes of the albums in deltas are adjusted by their delta, and the other cached sizes are dropped,
+// the mutation may change them too, like the Videos album for an uploaded video, or the user albums of a deleted photo.
+func (r *PhotoService) adjustAlbumSizes(deltas map[AlbumID]int) {
+	r.lock.Lock()
+	albums := r._albums
+	r.lock.Unlock()
+
+	for _, album := range albums {
+		album.adjustSize(deltas)
+	}
+}
+
+func (r *PhotoAlbum) adjustSize(deltas map[AlbumID]int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r._size == nil {
+		return
+	}
+	delta, ok := deltas[r.id]
+	if !ok {
+		r._size = nil
+		return
+	}
+	size := *r._size + delta
+	if size < 0 {
+		size = 0
+	}
+	r._size = &size
+}
+
+// invalidateSize drops the cached size of the album, the next Size call asks iCloud.
+func (r *PhotoAlbum) invalidateSize() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r._size = nil
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"strings"
+)
+
+// AlbumID is a stable, locale independent identifier of a smart album.
+//
+// User created albums use their record name as id.
+type AlbumID string
+
+const (
+	AlbumIDAll             AlbumID = "all"
+	AlbumIDTimeLapse       AlbumID = "time-lapse"
+	AlbumIDVideos          AlbumID = "videos"
+	AlbumIDSloMo           AlbumID = "slo-mo"
+	AlbumIDBursts          AlbumID = "bursts"
+	AlbumIDFavorites       AlbumID = "favorites"
+	AlbumIDPanoramas       AlbumID = "panoramas"
+	AlbumIDScreenshots     AlbumID = "screenshots"
+	AlbumIDLive            AlbumID = "live"
+	AlbumIDRecentlyDeleted AlbumID = "recently-deleted"
+	AlbumIDHidden          AlbumID = "hidden"
+)
+
+var albumIDToName = map[AlbumID]string{
+	AlbumIDAll:             AlbumNameAll,
+	AlbumIDTimeLapse:       AlbumNameTimeLapse,
+	AlbumIDVideos:          AlbumNameVideos,
+	AlbumIDSloMo:           AlbumNameSloMo,
+	AlbumIDBursts:          AlbumNameBursts,
+	AlbumIDFavorites:       AlbumNameFavorites,
+	AlbumIDPanoramas:       AlbumNamePanoramas,
+	AlbumIDScreenshots:     AlbumNameScreenshots,
+	AlbumIDLive:            AlbumNameLive,
+	AlbumIDRecentlyDeleted: AlbumNameRecentlyDeleted,
+	AlbumIDHidden:          AlbumNameHidden,
+}
+
+var albumNameToID = func() map[string]AlbumID {
+	res := map[string]AlbumID{}
+	for id, name := range albumIDToName {
+		res[name] = id
+	}
+	return res
+}()
+
+// localized smart album names, keyed by language code
+var albumLocalizedNames = map[string]map[AlbumID]string{
+	"zh": {
+		AlbumIDAll:             "所有照片",
+		AlbumIDTimeLapse:       "延时摄影",
+		AlbumIDVideos:          "视频",
+		AlbumIDSloMo:           "慢动作",
+		AlbumIDBursts:          "连拍快照",
+		AlbumIDFavorites:       "个人收藏",
+		AlbumIDPanoramas:       "全景照片",
+		AlbumIDScreenshots:     "截屏",
+		AlbumIDLive:            "实况照片",
+		AlbumIDRecentlyDeleted: "最近删除",
+		AlbumIDHidden:          "已隐藏",
+	},
+	"de": {
+		AlbumIDAll:             "Alle Fotos",
+		AlbumIDTimeLapse:       "Zeitraffer",
+		AlbumIDVideos:          "Videos",
+		AlbumIDSloMo:           "Slo-Mo",
+		AlbumIDBursts:          "Serien",
+		AlbumIDFavorites:       "Favoriten",
+		AlbumIDPanoramas:       "Panoramen",
+		AlbumIDScreenshots:     "Bildschirmfotos",
+		AlbumIDLive:            "Live Photos",
+		AlbumIDRecentlyDeleted: "Zuletzt gelöscht",
+		AlbumIDHidden:          "Ausgeblendet",
+	},
+	"fr": {
+		AlbumIDAll:             "Toutes les photos",
+		AlbumIDTimeLapse:       "Accéléré",
+		AlbumIDVideos:          "Vidéos",
+		AlbumIDSloMo:           "Ralenti",
+		AlbumIDBursts:          "Rafales",
+		AlbumIDFavorites:       "Favoris",
+		AlbumIDPanoramas:       "Panoramas",
+		AlbumIDScreenshots:     "Captures d’écran",
+		AlbumIDLive:            "Live Photos",
+		AlbumIDRecentlyDeleted: "Suppressions récentes",
+		AlbumIDHidden:          "Masqués",
+	},
+	"es": {
+		AlbumIDAll:             "Todas las fotos",
+		AlbumIDTimeLapse:       "Time-lapse",
+		AlbumIDVideos:          "Vídeos",
+		AlbumIDSloMo:           "Cámara lenta",
+		AlbumIDBursts:          "Ráfagas",
+		AlbumIDFavorites:       "Favoritos",
+		AlbumIDPanoramas:       "Panorámicas",
+		AlbumIDScreenshots:     "Capturas de pantalla",
+		AlbumIDLive:            "Live Photos",
+		AlbumIDRecentlyDeleted: "Eliminado",
+		AlbumIDHidden:          "Oculto",
+	},
+	"ja": {
+		AlbumIDAll:             "すべての写真",
+		AlbumIDTimeLapse:       "タイムラプス",
+		AlbumIDVideos:          "ビデオ",
+		AlbumIDSloMo:           "スローモーション",
+		AlbumIDBursts:          "バースト",
+		AlbumIDFavorites:       "お気に入り",
+		AlbumIDPanoramas:       "パノラマ",
+		AlbumIDScreenshots:     "スクリーンショット",
+		AlbumIDLive:            "Live Photos",
+		AlbumIDRecentlyDeleted: "最近削除した項目",
+		AlbumIDHidden:          "非表示",
+	},
+}
+
+// AlbumDisplayName returns the localized name of a smart album, like "Favoriten" for AlbumIDFavorites in "de_DE".
+//
+// It falls back to the english name for unknown locales.
+func AlbumDisplayName(id AlbumID, locale string) string {
+	if names, ok := albumLocalizedNames[localeLanguage(locale)]; ok && names[id] != "" {
+		return names[id]
+	}
+	return albumIDToName[id]
+}
+
+// resolveAlbumID maps an album id, or a smart album name in any supported language, to its AlbumID.
+func resolveAlbumID(name string) (AlbumID, bool) {
+	if _, ok := albumIDToName[AlbumID(strings.ToLower(name))]; ok {
+		return AlbumID(strings.ToLower(name)), true
+	}
+	if id, ok := albumNameToID[name]; ok {
+		return id, true
+	}
+	for _, names := range albumLocalizedNames {
+		for id, localized := range names {
+			if strings.EqualFold(localized, name) {
+				return id, true
+			}
+		}
+	}
+	return "", false
+}
+
+// localeLanguage turns "zh_CN" or "de-de" into "zh" / "de".
+func localeLanguage(locale string) string {
+	locale = strings.ToLower(locale)
+	if i := strings.IndexAny(locale, "_-"); i >= 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
+
+// Locale returns the account locale, like "en_US", empty before authentication.
+func (r *Client) Locale() string {
+	data := r.data()
+	if data == nil || data.DsInfo == nil {
+		return ""
+	}
+	return data.DsInfo.Locale
+}
@@ -0,0 +1,268 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// CreateAlbum creates a top level album, and returns it.
+func (r *PhotoService) CreateAlbum(name string) (*PhotoAlbum, error) {
+	return r.CreateAlbumContext(context.Background(), name)
+}
+
+// CreateAlbumContext is like CreateAlbum, the request is given up when ctx is done.
+func (r *PhotoService) CreateAlbumContext(ctx context.Context, name string) (*PhotoAlbum, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, fmt.Errorf("create album failed, err: empty name")
+	}
+
+	recordName := strings.ToUpper(uuid.NewV4().String())
+	_, err := r.modifyRecords(ctx, []any{
+		map[string]any{
+			"operationType": "create",
+			"record": map[string]any{
+				"recordName": recordName,
+				"recordType": "CPLAlbum",
+				"fields": map[string]any{
+					"albumNameEnc":  map[string]any{"value": base64.StdEncoding.EncodeToString([]byte(name))},
+					"albumType":     map[string]any{"value": 0},
+					"sortAscending": map[string]any{"value": 1},
+					"sortType":      map[string]any{"value": 0},
+					"parentId":      map[string]any{"value": rootFolderRecordName},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create album %s failed, err: %w", name, err)
+	}
+
+	album, err := r.newUserAlbum(recordName, name, name)
+	if err != nil {
+		return nil, err
+	}
+	r.updateAlbums(func(albums map[string]*PhotoAlbum) {
+		albums[name] = album
+	})
+	return album, nil
+}
+
+// Rename renames a user album, the album keeps its photos and its place in folders.
+func (r *PhotoAlbum) Rename(newName string) error {
+	return r.RenameContext(context.Background(), newName)
+}
+
+// RenameContext is like Rename, the requests are given up when ctx is done.
+func (r *PhotoAlbum) RenameContext(ctx context.Context, newName string) error {
+	if strings.TrimSpace(newName) == "" {
+		return fmt.Errorf("rename album %s failed, err: empty name", r.Name)
+	}
+	err := r.updateAlbumFields(ctx, map[string]any{
+		"albumNameEnc": map[string]any{"value": base64.StdEncoding.EncodeToString([]byte(newName))},
+	})
+	if err != nil {
+		return fmt.Errorf("rename album %s failed, err: %w", r.Name, err)
+	}
+
+	oldName := r.Name
+	r.service.updateAlbums(func(albums map[string]*PhotoAlbum) {
+		if albums[oldName] == r {
+			delete(albums, oldName)
+		}
+		albums[newName] = r
+	})
+	r.Name = newName
+	if i := strings.LastIndex(r.path, "/"); i >= 0 {
+		r.path = r.path[:i+1] + newName
+	} else {
+		r.path = newName
+	}
+	return nil
+}
+
+// Delete deletes a user album, its photos stay in the library.
+func (r *PhotoAlbum) Delete() error {
+	return r.DeleteContext(context.Background())
+}
+
+// DeleteContext is like Delete, the requests are given up when ctx is done.
+func (r *PhotoAlbum) DeleteContext(ctx context.Context) error {
+	if err := r.updateAlbumFields(ctx, map[string]any{"isDeleted": map[string]any{"value": 1}}); err != nil {
+		return fmt.Errorf("delete album %s failed, err: %w", r.Name, err)
+	}
+	r.service.updateAlbums(func(albums map[string]*PhotoAlbum) {
+		if albums[r.Name] == r {
+			delete(albums, r.Name)
+		}
+	})
+	return nil
+}
+
+// AddAssets files the assets into the album, it only works for user albums.
+func (r *PhotoAlbum) AddAssets(assets ...*PhotoAsset) error {
+	ids := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		if asset._assetRecord == nil {
+			return fmt.Errorf("add %s to album %s failed, err: no asset record", asset.Filename(), r.Name)
+		}
+		ids = append(ids, asset._assetRecord.RecordName)
+	}
+	return r.AddAssetIDs(ids...)
+}
+
+// AddAssetIDs is like AddAssets, but takes asset record names, like the id Upload returns.
+func (r *PhotoAlbum) AddAssetIDs(ids ...string) error {
+	if !r.isUserAlbum() {
+		return fmt.Errorf("add assets to album %s failed, err: not a user album", r.Name)
+	}
+
+	operations := make([]any, 0, len(ids))
+	for _, id := range ids {
+		if err := validateQueryIdentifier(id); err != nil {
+			return fmt.Errorf("add assets to album %s failed, err: %w", r.Name, err)
+		}
+		operations = append(operations, map[string]any{
+			"operationType": "create",
+			"record": map[string]any{
+				"recordName": id + "-IN-" + string(r.id),
+				"recordType": "CPLContainerRelation",
+				"fields": map[string]any{
+					"itemId":      map[string]any{"value": id},
+					"containerId": map[string]any{"value": string(r.id)},
+					"position":    map[string]any{"value": 1024},
+				},
+			},
+		})
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+	if _, err := r.service.modifyRecords(context.Background(), operations); err != nil {
+		return fmt.Errorf("add assets to album %s failed, err: %w", r.Name, err)
+	}
+
+	r.invalidateSize()
+	return nil
+}
+
+// RemoveAssets takes the assets out of the album, they stay in the library, it only works for user albums.
+func (r *PhotoAlbum) RemoveAssets(assets ...*PhotoAsset) error {
+	ids := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		if asset._assetRecord == nil {
+			return fmt.Errorf("remove %s from album %s failed, err: no asset record", asset.Filename(), r.Name)
+		}
+		ids = append(ids, asset._assetRecord.RecordName)
+	}
+	return r.RemoveAssetIDs(ids...)
+}
+
+// RemoveAssetIDs is like RemoveAssets, but takes asset record names, like AddAssetIDs.
+func (r *PhotoAlbum) RemoveAssetIDs(ids ...string) error {
+	if !r.isUserAlbum() {
+		return fmt.Errorf("remove assets from album %s failed, err: not a user album", r.Name)
+	}
+
+	operations := make([]any, 0, len(ids))
+	for _, id := range ids {
+		if err := validateQueryIdentifier(id); err != nil {
+			return fmt.Errorf("remove assets from album %s failed, err: %w", r.Name, err)
+		}
+		// the relation record AddAssetIDs created
+		operations = append(operations, map[string]any{
+			"operationType": "forceDelete",
+			"record": map[string]any{
+				"recordName": id + "-IN-" + string(r.id),
+				"recordType": "CPLContainerRelation",
+			},
+		})
+	}
+	if len(operations) == 0 {
+		return nil
+	}
+	if _, err := r.service.modifyRecords(context.Background(), operations); err != nil {
+		return fmt.Errorf("remove assets from album %s failed, err: %w", r.Name, err)
+	}
+
+	r.invalidateSize()
+	return nil
+}
+
+func (r *PhotoAlbum) isUserAlbum() bool {
+	return validateQueryIdentifier(string(r.id)) == nil && strings.HasPrefix(r.ObjType, "CPLContainerRelation")
+}
+
+// updateAlbumFields updates the CPLAlbum record of a user album, with the change tag of its current version.
+func (r *PhotoAlbum) updateAlbumFields(ctx context.Context, fields map[string]any) error {
+	if !r.isUserAlbum() {
+		return fmt.Errorf("not a user album")
+	}
+	record, err := r.service.lookupRecord(ctx, string(r.id))
+	if err != nil {
+		return err
+	}
+	_, err = r.service.modifyRecords(ctx, []any{
+		map[string]any{
+			"operationType": "update",
+			"record": map[string]any{
+				"recordName":      string(r.id),
+				"recordType":      "CPLAlbum",
+				"recordChangeTag": record.RecordChangeTag,
+				"fields":          fields,
+			},
+		},
+	})
+	return err
+}
+
+// updateAlbums applies update to a copy of the album list, if it's loaded,
+// copy on write, the published map may be read by other goroutines.
+func (r *PhotoService) updateAlbums(update func(albums map[string]*PhotoAlbum)) {
+	if _, err := r.Albums(); err != nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	albums := make(map[string]*PhotoAlbum, len(r._albums)+1)
+	for k, v := range r._albums {
+		albums[k] = v
+	}
+	update(albums)
+	r._albums = albums
+}
+
+// newUserAlbum builds the album of a CPLAlbum record.
+func (r *PhotoService) newUserAlbum(folderID, name, path string) (*PhotoAlbum, error) {
+	folderObjType, err := buildObjType("CPLContainerRelationNotDeletedByAssetDate", folderID)
+	if err != nil {
+		return nil, err
+	}
+	folderFilter, err := newQueryFilter("parentId", "EQUALS", "STRING", folderID)
+	if err != nil {
+		return nil, err
+	}
+	album := r.newPhotoAlbum(name, "CPLContainerRelationLiveByAssetDate", folderObjType, "ASCENDING", []*folderMetaDataQueryFilter{folderFilter})
+	album.id = AlbumID(folderID)
+	album.path = path
+	return album, nil
+}
+
+func (r *PhotoService) modifyRecords(ctx context.Context, operations []any) (string, error) {
+	return r.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     fmt.Sprintf("%s/records/modify", r.serviceEndpoint),
+		Querys:  r.querys,
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		Body: map[string]any{
+			"operations": operations,
+			"zoneID":     r.zoneID(),
+			"atomic":     true,
+		},
+	})
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// AlbumMembership is the user albums an asset is filed into.
+type AlbumMembership struct {
+	ID       string   `json:"id"`
+	Filename string   `json:"filename"`
+	Albums   []string `json:"albums"` // the paths of the albums, like "Trips/2024", sorted
+}
+
+// AlbumMemberships returns the user albums of every asset in one, by asset id, the assets in no user album are left out.
+// It lists every user album, the smart albums, like Favorites, are left out, they follow from the asset itself.
+func (r *PhotoService) AlbumMemberships() (map[string]*AlbumMembership, error) {
+	return r.AlbumMembershipsContext(context.Background())
+}
+
+// AlbumMembershipsContext is like AlbumMemberships, the listing is given up when ctx is done.
+func (r *PhotoService) AlbumMembershipsContext(ctx context.Context) (map[string]*AlbumMembership, error) {
+	albums, err := r.AlbumsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var userAlbums []*PhotoAlbum
+	for _, album := range albums {
+		if album.isUserAlbum() {
+			userAlbums = append(userAlbums, album)
+		}
+	}
+	sort.Slice(userAlbums, func(i, j int) bool { return userAlbums[i].Path() < userAlbums[j].Path() })
+
+	res := map[string]*AlbumMembership{}
+	for _, album := range userAlbums {
+		iter := album.PhotosIterWithOption(&PhotosIterOption{Context: ctx})
+		for {
+			asset, err := iter.Next()
+			if err != nil {
+				if errors.Is(err, ErrPhotosIterateEnd) {
+					break
+				}
+				return nil, fmt.Errorf("list album %s failed, err: %w", album.Path(), err)
+			}
+			membership := res[asset.ID()]
+			if membership == nil {
+				membership = &AlbumMembership{ID: asset.ID(), Filename: asset.Filename()}
+				res[asset.ID()] = membership
+			}
+			if n := len(membership.Albums); n == 0 || membership.Albums[n-1] != album.Path() {
+				membership.Albums = append(membership.Albums, album.Path())
+			}
+		}
+	}
+	return res, nil
+}
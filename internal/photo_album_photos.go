@@ -1,55 +1,186 @@
 package internal
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 )
 
 func (r *PhotoAlbum) PhotosIter() PhotosIterNext {
+	return r.PhotosIterWithOption(nil)
+}
+
+// PhotosIterWithOption is like PhotosIter, but lets the caller decide whether
+// Hidden and Recently Deleted assets show up when iterating All Photos.
+// When their album can't be found, the first Next returns the error.
+func (r *PhotoAlbum) PhotosIterWithOption(option *PhotosIterOption) PhotosIterNext {
+	if option == nil {
+		option = new(PhotosIterOption)
+	}
+
+	ctx := option.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	progress := newIterProgress(ctx, r, option.OnProgress)
+	iter := r.photosIter(ctx)
+	iter.applyOption(option)
+	iter.progress = progress
+	if r.Name != AlbumNameAll {
+		return iter
+	}
+
+	iter.addFilter(func(asset *PhotoAsset) bool {
+		return !asset.IsHidden() && !asset.IsDeleted()
+	})
+	chain := &photosIterChain{lock: new(sync.Mutex), iters: []PhotosIterNext{iter}}
+	var extraAlbumNames []string
+	if option.IncludeHidden {
+		extraAlbumNames = append(extraAlbumNames, AlbumNameHidden)
+	}
+	if option.IncludeRecentlyDeleted {
+		extraAlbumNames = append(extraAlbumNames, AlbumNameRecentlyDeleted)
+	}
+	for _, name := range extraAlbumNames {
+		album, err := r.service.GetAlbumContext(ctx, name)
+		if err != nil {
+			// the assets asked for can't be listed, fail before yielding any instead of silently skipping them
+			return &photosIterErr{err: fmt.Errorf("get album %s failed, err: %w", name, err)}
+		}
+		extraIter := album.photosIter(ctx)
+		extraIter.applyOption(option)
+		extraIter.progress = progress
+		chain.iters = append(chain.iters, extraIter)
+	}
+	return chain
+}
+
+// defaultPageSize is the records asked for per page of an album.
+const defaultPageSize = 200
+
+func (r *PhotoAlbum) photosIter(ctx context.Context) *photosIterNextImpl {
 	offset := 0
 	if r.Direction == "DESCENDING" {
-		offset = r.Size() - 1
+		size, _ := r.GetSizeContext(ctx)
+		offset = size - 1
 	}
 	return &photosIterNextImpl{
-		album:  r,
-		lock:   new(sync.Mutex),
-		offset: offset,
-		assets: nil,
-		index:  0,
-		end:    false,
+		ctx:      ctx,
+		album:    r,
+		lock:     new(sync.Mutex),
+		offset:   offset,
+		pageSize: defaultPageSize,
+		assets:   nil,
+		index:    0,
+		end:      false,
 	}
 }
 
-func (r *PhotoAlbum) GetPhotosByOffset(offset, limit int) ([]*PhotoAsset, error) {
-	var assets []*PhotoAsset
+func (r *photosIterNextImpl) applyOption(option *PhotosIterOption) {
+	r.applySince(option.Since)
+	r.applyUntil(option.Until)
+	if option.PageSize > 0 {
+		r.pageSize = option.PageSize
+	}
+	if option.Filter != nil {
+		r.addFilter(option.Filter)
+	}
+}
 
-	text, err := r.service.icloud.request(&rawReq{
-		Method:  "POST",
-		URL:     fmt.Sprintf("%s/records/query", r.service.serviceEndpoint),
-		Querys:  r.service.querys,
-		Headers: r.service.icloud.getCommonHeaders(map[string]string{}),
-		Body:    r.listQueryGenerate(offset, limit, r.ListType, r.Direction, r.QueryFilter),
+// addFilter makes the iterator skip assets filter returns false for, on top of the existing filter.
+func (r *photosIterNextImpl) addFilter(filter func(asset *PhotoAsset) bool) {
+	prev := r.filter
+	r.filter = func(asset *PhotoAsset) bool {
+		return (prev == nil || prev(asset)) && filter(asset)
+	}
+}
+
+// applySince limits the iterator to assets taken at or after since, a zero since means no limit.
+func (r *photosIterNextImpl) applySince(since time.Time) {
+	if since.IsZero() {
+		return
+	}
+	if filter, err := newQueryFilter("assetDate", "GREATER_THAN_OR_EQUALS", "TIMESTAMP", since.UnixMilli()); err == nil {
+		r.queryFilter = append(r.queryFilter, filter)
+	}
+	r.addFilter(func(asset *PhotoAsset) bool {
+		return !asset.AssetDate().Before(since)
+	})
+	if r.album.Direction != "ASCENDING" {
+		return
+	}
+	// ascending ranks of the lists sorted by date start at the newest asset, nothing after an older asset can match,
+	// an asset is never taken after it's added, so lists by added date can stop at the added date,
+	// the other lists, like Recently Deleted by expunged date, are iterated to the end
+	switch {
+	case strings.Contains(r.album.ListType, "ByAddedDate"):
+		r.stop = func(asset *PhotoAsset) bool {
+			return asset.AddedDate().Before(since)
+		}
+	case strings.Contains(r.album.ListType, "ByAssetDate"):
+		r.stop = func(asset *PhotoAsset) bool {
+			return asset.AssetDate().Before(since)
+		}
+	}
+}
+
+// applyUntil limits the iterator to assets taken before until, a zero until means no limit.
+func (r *photosIterNextImpl) applyUntil(until time.Time) {
+	if until.IsZero() {
+		return
+	}
+	if filter, err := newQueryFilter("assetDate", "LESS_THAN", "TIMESTAMP", until.UnixMilli()); err == nil {
+		r.queryFilter = append(r.queryFilter, filter)
+	}
+	r.addFilter(func(asset *PhotoAsset) bool {
+		return asset.AssetDate().Before(until)
 	})
+	if r.album.Direction == "DESCENDING" && strings.Contains(r.album.ListType, "ByAssetDate") {
+		// descending ranks of the lists by asset date start at the oldest asset, nothing after a newer asset can match,
+		// an asset added later may be taken earlier, so the other lists can't stop
+		r.stop = func(asset *PhotoAsset) bool {
+			return !asset.AssetDate().Before(until)
+		}
+	}
+}
+
+func (r *PhotoAlbum) GetPhotosByOffset(offset, limit int) ([]*PhotoAsset, error) {
+	return r.getPhotosByOffset(context.Background(), offset, limit, nil)
+}
+
+func (r *PhotoAlbum) getPhotosByOffset(ctx context.Context, offset, limit int, extraQueryFilter []*folderMetaDataQueryFilter) ([]*PhotoAsset, error) {
+	queryFilter := append(append([]*folderMetaDataQueryFilter{}, r.QueryFilter...), extraQueryFilter...)
+	body, err := r.listQueryGenerate(offset, limit, r.ListType, r.Direction, queryFilter)
 	if err != nil {
 		return nil, fmt.Errorf("get album photos failed, err: %w", err)
 	}
-	res := new(getPhotosResp)
-	if err = json.Unmarshal([]byte(text), res); err != nil {
-		return nil, fmt.Errorf("get album photos unmarshal failed, err: %w", err)
-	}
 
 	var masterRecords []*photoRecord
 	assetRecords := map[string]*photoRecord{}
-	for _, record := range res.Records {
+	_, err = requestRecords(r.service.icloud, &rawReq{
+		Context: ctx,
+		Method:  "POST",
+		URL:     fmt.Sprintf("%s/records/query", r.service.serviceEndpoint),
+		Querys:  r.service.querys,
+		Headers: r.service.icloud.getCommonHeaders(map[string]string{}),
+		Body:    body,
+	}, func(record *photoRecord) error {
 		if record.RecordType == "CPLAsset" {
 			masterID := record.Fields.MasterRef.Value.RecordName
 			assetRecords[masterID] = record
 		} else if record.RecordType == "CPLMaster" {
 			masterRecords = append(masterRecords, record)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get album photos failed, err: %w", err)
 	}
 
+	assets := make([]*PhotoAsset, 0, len(masterRecords))
 	for _, masterRecord := range masterRecords {
 		assets = append(assets,
 			r.service.newPhotoAsset(masterRecord, assetRecords[masterRecord.RecordName]),
@@ -67,7 +198,7 @@ func (r *PhotoAlbum) GetPhotosByCount(count int) ([]*PhotoAsset, error) {
 
 	var assets []*PhotoAsset
 	for {
-		tmp, err := r.GetPhotosByOffset(offset, 200)
+		tmp, err := r.GetPhotosByOffset(offset, defaultPageSize)
 		if err != nil {
 			return nil, err
 		}
@@ -95,7 +226,128 @@ func (r *PhotoAlbum) calOffset(offset, lastAssetLen int) int {
 	return offset
 }
 
-func (r *PhotoAlbum) listQueryGenerate(offset, limit int, listType string, direction string, queryFilter []*folderMetaDataQueryFilter) any {
+// photoDesiredKeys are the fields of CPLMaster and CPLAsset records the photo requests ask for.
+var photoDesiredKeys = []string{
+	"resJPEGFullWidth",
+	"resJPEGFullHeight",
+	"resJPEGFullFileType",
+	"resJPEGFullFingerprint",
+	"resJPEGFullRes",
+	"resJPEGLargeWidth",
+	"resJPEGLargeHeight",
+	"resJPEGLargeFileType",
+	"resJPEGLargeFingerprint",
+	"resJPEGLargeRes",
+	"resJPEGMedWidth",
+	"resJPEGMedHeight",
+	"resJPEGMedFileType",
+	"resJPEGMedFingerprint",
+	"resJPEGMedRes",
+	"resJPEGThumbWidth",
+	"resJPEGThumbHeight",
+	"resJPEGThumbFileType",
+	"resJPEGThumbFingerprint",
+	"resJPEGThumbRes",
+	"resVidFullWidth",
+	"resVidFullHeight",
+	"resVidFullFileType",
+	"resVidFullFingerprint",
+	"resVidFullRes",
+	"resVidMedWidth",
+	"resVidMedHeight",
+	"resVidMedFileType",
+	"resVidMedFingerprint",
+	"resVidMedRes",
+	"resVidSmallWidth",
+	"resVidSmallHeight",
+	"resVidSmallFileType",
+	"resVidSmallFingerprint",
+	"resVidSmallRes",
+	"resSidecarWidth",
+	"resSidecarHeight",
+	"resSidecarFileType",
+	"resSidecarFingerprint",
+	"resSidecarRes",
+	"itemType",
+	"dataClassType",
+	"filenameEnc",
+	"originalFilenameEnc",
+	"importedBy",
+	"importedByBundleIdentifierEnc",
+	"importedByDisplayNameEnc",
+	"originalOrientation",
+	"resOriginalWidth",
+	"resOriginalHeight",
+	"resOriginalFileType",
+	"resOriginalFingerprint",
+	"resOriginalRes",
+	"resOriginalAltWidth",
+	"resOriginalAltHeight",
+	"resOriginalAltFileType",
+	"resOriginalAltFingerprint",
+	"resOriginalAltRes",
+	"resOriginalVidComplWidth",
+	"resOriginalVidComplHeight",
+	"resOriginalVidComplFileType",
+	"resOriginalVidComplFingerprint",
+	"resOriginalVidComplRes",
+	"isDeleted",
+	"isExpunged",
+	"dateExpunged",
+	"remappedRef",
+	"recordName",
+	"recordType",
+	"recordChangeTag",
+	"masterRef",
+	"adjustmentRenderType",
+	"assetDate",
+	"addedDate",
+	"isFavorite",
+	"isHidden",
+	"orientation",
+	"duration",
+	"assetSubtype",
+	"assetSubtypeV2",
+	"assetHDRType",
+	"burstFlags",
+	"burstFlagsExt",
+	"burstId",
+	"captionEnc",
+	"locationEnc",
+	"locationV2Enc",
+	"locationLatitude",
+	"locationLongitude",
+	"adjustmentType",
+	"adjustmentCreatorCode",
+	"adjustmentCompoundVersion",
+	"adjustmentTimestamp",
+	"adjustmentSimpleDataEnc",
+	"timeZoneOffset",
+	"vidComplDurValue",
+	"vidComplDurScale",
+	"vidComplDispValue",
+	"vidComplDispScale",
+	"vidComplVisibilityState",
+	"customRenderedValue",
+	"containerId",
+	"itemId",
+	"position",
+	"isKeyAsset",
+	"videoFrameRate",
+	"codec",
+}
+
+func (r *PhotoAlbum) listQueryGenerate(offset, limit int, listType string, direction string, queryFilter []*folderMetaDataQueryFilter) (any, error) {
+	if err := validateQueryIdentifier(listType); err != nil {
+		return nil, err
+	}
+	if direction != "ASCENDING" && direction != "DESCENDING" {
+		return nil, fmt.Errorf("%w: unknown direction %q", ErrInvalidQueryFilter, direction)
+	}
+	if err := validateQueryFilters(queryFilter); err != nil {
+		return nil, err
+	}
+
 	res := map[string]any{
 		"query": map[string]any{
 			"filterBy": append([]*folderMetaDataQueryFilter{
@@ -113,109 +365,11 @@ func (r *PhotoAlbum) listQueryGenerate(offset, limit int, listType string, direc
 			"recordType": listType,
 		},
 		"resultsLimit": limit,
-		"desiredKeys": []string{
-			"resJPEGFullWidth",
-			"resJPEGFullHeight",
-			"resJPEGFullFileType",
-			"resJPEGFullFingerprint",
-			"resJPEGFullRes",
-			"resJPEGLargeWidth",
-			"resJPEGLargeHeight",
-			"resJPEGLargeFileType",
-			"resJPEGLargeFingerprint",
-			"resJPEGLargeRes",
-			"resJPEGMedWidth",
-			"resJPEGMedHeight",
-			"resJPEGMedFileType",
-			"resJPEGMedFingerprint",
-			"resJPEGMedRes",
-			"resJPEGThumbWidth",
-			"resJPEGThumbHeight",
-			"resJPEGThumbFileType",
-			"resJPEGThumbFingerprint",
-			"resJPEGThumbRes",
-			"resVidFullWidth",
-			"resVidFullHeight",
-			"resVidFullFileType",
-			"resVidFullFingerprint",
-			"resVidFullRes",
-			"resVidMedWidth",
-			"resVidMedHeight",
-			"resVidMedFileType",
-			"resVidMedFingerprint",
-			"resVidMedRes",
-			"resVidSmallWidth",
-			"resVidSmallHeight",
-			"resVidSmallFileType",
-			"resVidSmallFingerprint",
-			"resVidSmallRes",
-			"resSidecarWidth",
-			"resSidecarHeight",
-			"resSidecarFileType",
-			"resSidecarFingerprint",
-			"resSidecarRes",
-			"itemType",
-			"dataClassType",
-			"filenameEnc",
-			"originalOrientation",
-			"resOriginalWidth",
-			"resOriginalHeight",
-			"resOriginalFileType",
-			"resOriginalFingerprint",
-			"resOriginalRes",
-			"resOriginalAltWidth",
-			"resOriginalAltHeight",
-			"resOriginalAltFileType",
-			"resOriginalAltFingerprint",
-			"resOriginalAltRes",
-			"resOriginalVidComplWidth",
-			"resOriginalVidComplHeight",
-			"resOriginalVidComplFileType",
-			"resOriginalVidComplFingerprint",
-			"resOriginalVidComplRes",
-			"isDeleted",
-			"isExpunged",
-			"dateExpunged",
-			"remappedRef",
-			"recordName",
-			"recordType",
-			"recordChangeTag",
-			"masterRef",
-			"adjustmentRenderType",
-			"assetDate",
-			"addedDate",
-			"isFavorite",
-			"isHidden",
-			"orientation",
-			"duration",
-			"assetSubtype",
-			"assetSubtypeV2",
-			"assetHDRType",
-			"burstFlags",
-			"burstFlagsExt",
-			"burstId",
-			"captionEnc",
-			"locationEnc",
-			"locationV2Enc",
-			"locationLatitude",
-			"locationLongitude",
-			"adjustmentType",
-			"timeZoneOffset",
-			"vidComplDurValue",
-			"vidComplDurScale",
-			"vidComplDispValue",
-			"vidComplDispScale",
-			"vidComplVisibilityState",
-			"customRenderedValue",
-			"containerId",
-			"itemId",
-			"position",
-			"isKeyAsset",
-		},
-		"zoneID": map[string]any{"zoneName": "PrimarySync"},
+		"desiredKeys":  photoDesiredKeys,
+		"zoneID":       r.service.zoneID(),
 	}
 
-	return res
+	return res, nil
 }
 
 type getPhotosResp struct {
@@ -240,6 +394,22 @@ type photoRecord struct {
 			Value string `json:"value"`
 			Type  string `json:"type"`
 		} `json:"filenameEnc,omitempty"`
+		OriginalFilenameEnc struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"originalFilenameEnc,omitempty"`
+		ImportedBy struct {
+			Value int    `json:"value"`
+			Type  string `json:"type"`
+		} `json:"importedBy,omitempty"`
+		ImportedByBundleIdentifierEnc struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"importedByBundleIdentifierEnc,omitempty"`
+		ImportedByDisplayNameEnc struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"importedByDisplayNameEnc,omitempty"`
 		ResJPEGMedRes struct {
 			Value struct {
 				FileChecksum      string `json:"fileChecksum"`
@@ -448,6 +618,10 @@ type photoRecord struct {
 			Value int    `json:"value"`
 			Type  string `json:"type"`
 		} `json:"isHidden,omitempty"`
+		IsDeleted struct {
+			Value int    `json:"value"`
+			Type  string `json:"type"`
+		} `json:"isDeleted,omitempty"`
 		Duration struct {
 			Value int    `json:"value"`
 			Type  string `json:"type"`
@@ -488,6 +662,116 @@ type photoRecord struct {
 			Value string `json:"value"`
 			Type  string `json:"type"`
 		} `json:"locationEnc,omitempty"`
+		LocationLatitude struct {
+			Value float64 `json:"value"`
+			Type  string  `json:"type"`
+		} `json:"locationLatitude,omitempty"`
+		LocationLongitude struct {
+			Value float64 `json:"value"`
+			Type  string  `json:"type"`
+		} `json:"locationLongitude,omitempty"`
+		CaptionEnc struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"captionEnc,omitempty"`
+		AdjustmentType struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"adjustmentType,omitempty"`
+		AdjustmentCreatorCode struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"adjustmentCreatorCode,omitempty"`
+		AdjustmentCompoundVersion struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"adjustmentCompoundVersion,omitempty"`
+		AdjustmentTimestamp struct {
+			Value int64  `json:"value"`
+			Type  string `json:"type"`
+		} `json:"adjustmentTimestamp,omitempty"`
+		AdjustmentSimpleDataEnc struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"adjustmentSimpleDataEnc,omitempty"`
+		VideoFrameRate struct {
+			Value float64 `json:"value"`
+			Type  string  `json:"type"`
+		} `json:"videoFrameRate,omitempty"`
+		Codec struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"codec,omitempty"`
+		BurstID struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"burstId,omitempty"`
+		ResJPEGFullRes struct {
+			Value struct {
+				FileChecksum      string `json:"fileChecksum"`
+				Size              int    `json:"size"`
+				WrappingKey       string `json:"wrappingKey"`
+				ReferenceChecksum string `json:"referenceChecksum"`
+				DownloadURL       string `json:"downloadURL"`
+			} `json:"value"`
+			Type string `json:"type"`
+		} `json:"resJPEGFullRes,omitempty"`
+		ResJPEGFullWidth struct {
+			Value int    `json:"value"`
+			Type  string `json:"type"`
+		} `json:"resJPEGFullWidth,omitempty"`
+		ResJPEGFullHeight struct {
+			Value int    `json:"value"`
+			Type  string `json:"type"`
+		} `json:"resJPEGFullHeight,omitempty"`
+		ResJPEGFullFileType struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"resJPEGFullFileType,omitempty"`
+		ResVidFullRes struct {
+			Value struct {
+				FileChecksum      string `json:"fileChecksum"`
+				Size              int    `json:"size"`
+				WrappingKey       string `json:"wrappingKey"`
+				ReferenceChecksum string `json:"referenceChecksum"`
+				DownloadURL       string `json:"downloadURL"`
+			} `json:"value"`
+			Type string `json:"type"`
+		} `json:"resVidFullRes,omitempty"`
+		ResVidFullWidth struct {
+			Value int    `json:"value"`
+			Type  string `json:"type"`
+		} `json:"resVidFullWidth,omitempty"`
+		ResVidFullHeight struct {
+			Value int    `json:"value"`
+			Type  string `json:"type"`
+		} `json:"resVidFullHeight,omitempty"`
+		ResVidFullFileType struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"resVidFullFileType,omitempty"`
+		ResOriginalAltRes struct {
+			Value struct {
+				FileChecksum      string `json:"fileChecksum"`
+				Size              int    `json:"size"`
+				WrappingKey       string `json:"wrappingKey"`
+				ReferenceChecksum string `json:"referenceChecksum"`
+				DownloadURL       string `json:"downloadURL"`
+			} `json:"value"`
+			Type string `json:"type"`
+		} `json:"resOriginalAltRes,omitempty"`
+		ResOriginalAltWidth struct {
+			Value int    `json:"value"`
+			Type  string `json:"type"`
+		} `json:"resOriginalAltWidth,omitempty"`
+		ResOriginalAltHeight struct {
+			Value int    `json:"value"`
+			Type  string `json:"type"`
+		} `json:"resOriginalAltHeight,omitempty"`
+		ResOriginalAltFileType struct {
+			Value string `json:"value"`
+			Type  string `json:"type"`
+		} `json:"resOriginalAltFileType,omitempty"`
 	} `json:"fields"`
 	PluginFields    struct{} `json:"pluginFields"`
 	RecordChangeTag string   `json:"recordChangeTag"`
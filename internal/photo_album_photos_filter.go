@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// MediaType is the kind of asset a PhotosFilter keeps.
+type MediaType string
+
+const (
+	MediaTypeAny   MediaType = ""
+	MediaTypePhoto MediaType = "photo"
+	MediaTypeVideo MediaType = "video"
+	MediaTypeLive  MediaType = "live"
+)
+
+// PhotosFilter selects the assets of an album by date, media type and filename, the zero value keeps every asset.
+type PhotosFilter struct {
+	// From and To limit the assets to those taken at or after From, and before To, the dates are sent to the server
+	From time.Time
+	To   time.Time
+	// MediaType keeps only the photos, live photos included, the videos, or the live photos
+	MediaType MediaType
+	// Match is a filename glob, like IMG_*.HEIC, case insensitive, an invalid glob matches nothing
+	Match string
+}
+
+// PhotosIterWithFilter is like PhotosIter, but only yields the assets matching filter.
+func (r *PhotoAlbum) PhotosIterWithFilter(filter *PhotosFilter) PhotosIterNext {
+	return r.PhotosIterWithOption(filter.IterOption(nil))
+}
+
+// IterOption returns a copy of option, nil for the defaults, limited to the assets matching the filter.
+func (r *PhotosFilter) IterOption(option *PhotosIterOption) *PhotosIterOption {
+	res := new(PhotosIterOption)
+	if option != nil {
+		*res = *option
+	}
+	if r == nil {
+		return res
+	}
+	if r.From.After(res.Since) {
+		res.Since = r.From
+	}
+	if !r.To.IsZero() && (res.Until.IsZero() || r.To.Before(res.Until)) {
+		res.Until = r.To
+	}
+	if r.MediaType != MediaTypeAny || r.Match != "" {
+		prev := res.Filter
+		res.Filter = func(asset *PhotoAsset) bool {
+			return (prev == nil || prev(asset)) && r.Matches(asset)
+		}
+	}
+	return res
+}
+
+// Matches reports whether the asset matches every field of the filter.
+func (r *PhotosFilter) Matches(asset *PhotoAsset) bool {
+	if r == nil {
+		return true
+	}
+	date := asset.AssetDate()
+	if date.Before(r.From) || (!r.To.IsZero() && !date.Before(r.To)) {
+		return false
+	}
+	switch r.MediaType {
+	case MediaTypePhoto:
+		if asset.IsVideo() {
+			return false
+		}
+	case MediaTypeVideo:
+		if !asset.IsVideo() {
+			return false
+		}
+	case MediaTypeLive:
+		if !asset.IsLivePhoto() {
+			return false
+		}
+	}
+	if r.Match != "" {
+		ok, _ := path.Match(strings.ToLower(r.Match), strings.ToLower(asset.Filename()))
+		return ok
+	}
+	return true
+}
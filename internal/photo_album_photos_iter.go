@@ -1,23 +1,84 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"sync"
+	"time"
 )
 
-type PhotosIterNext interface {
-	Next() (*PhotoAsset, error)
+type PhotosIterNext = AssetIterator
+
+// PhotosIterOption controls which assets the album iterator yields.
+//
+// Hidden and Recently Deleted assets are never part of All Photos by default,
+// set IncludeHidden / IncludeRecentlyDeleted to append them after the album's own assets.
+//
+// Since limits the assets to those taken at or after it, and Until to those taken before it,
+// the date filters are sent to the server, and checked again locally in case the server ignores them.
+//
+// Filter, if set, skips the assets it returns false for.
+//
+// Context, if set, cancels the page requests of the iterator, Next then returns its error.
+//
+// OnProgress, if set, is called with the progress of the iterator for every asset listed, from the goroutine calling Next.
+//
+// PageSize is the records asked for per page, 0 is 200, the records of a page are decoded as they arrive,
+// so a bigger page, like 1000, takes fewer requests without buffering the whole response.
+type PhotosIterOption struct {
+	Context                context.Context
+	IncludeHidden          bool
+	IncludeRecentlyDeleted bool
+	Since                  time.Time
+	Until                  time.Time
+	Filter                 func(asset *PhotoAsset) bool
+	OnProgress             func(progress IterProgress)
+	PageSize               int
 }
 
 type photosIterNextImpl struct {
+	ctx    context.Context
 	album  *PhotoAlbum
 	lock   *sync.Mutex
 	offset int
 	assets []*PhotoAsset
 	index  int
 	end    bool
+	filter func(asset *PhotoAsset) bool
+
+	progress *iterProgress
+	pageSize int
+
+	queryFilter []*folderMetaDataQueryFilter
+	// stop ends the iteration at the first asset it returns true for
+	stop func(asset *PhotoAsset) bool
 }
 
 func (r *photosIterNextImpl) Next() (*PhotoAsset, error) {
+	for {
+		asset, err := r.next()
+		if err != nil {
+			if !errors.Is(err, ErrPhotosIterateEnd) {
+				r.album.service.eventSink().Error(nil, err)
+			}
+			return nil, err
+		}
+		if r.stop != nil && r.stop(asset) {
+			r.lock.Lock()
+			r.assets, r.end = nil, true
+			r.lock.Unlock()
+			return nil, ErrPhotosIterateEnd
+		}
+		if r.filter == nil || r.filter(asset) {
+			r.progress.scan(asset)
+			r.album.service.eventSink().AssetDiscovered(r.album, asset)
+			return asset, nil
+		}
+		r.progress.scan(nil)
+	}
+}
+
+func (r *photosIterNextImpl) next() (*PhotoAsset, error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
@@ -30,7 +91,7 @@ func (r *photosIterNextImpl) Next() (*PhotoAsset, error) {
 		return nil, ErrPhotosIterateEnd
 	}
 
-	assets, err := r.album.GetPhotosByOffset(r.offset, 200)
+	assets, err := r.album.getPhotosByOffset(r.ctx, r.offset, r.pageSize, r.queryFilter)
 	if err != nil {
 		return nil, err
 	}
@@ -45,3 +106,43 @@ func (r *photosIterNextImpl) Next() (*PhotoAsset, error) {
 
 	return r.assets[r.index-1], nil
 }
+
+// photosIterChain yields assets of each iterator in turn.
+type photosIterChain struct {
+	lock  *sync.Mutex
+	iters []PhotosIterNext
+}
+
+func (r *photosIterChain) Next() (*PhotoAsset, error) {
+	for {
+		r.lock.Lock()
+		if len(r.iters) == 0 {
+			r.lock.Unlock()
+			return nil, ErrPhotosIterateEnd
+		}
+		iter := r.iters[0]
+		r.lock.Unlock()
+
+		asset, err := iter.Next()
+		if err == nil {
+			return asset, nil
+		} else if err != ErrPhotosIterateEnd {
+			return nil, err
+		}
+
+		r.lock.Lock()
+		if len(r.iters) > 0 && r.iters[0] == iter {
+			r.iters = r.iters[1:]
+		}
+		r.lock.Unlock()
+	}
+}
+
+// photosIterErr is the iterator of assets which can't be listed, Next returns the error.
+type photosIterErr struct {
+	err error
+}
+
+func (r *photosIterErr) Next() (*PhotoAsset, error) {
+	return nil, r.err
+}
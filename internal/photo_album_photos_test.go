@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+func TestPhotosIterWithOptionIncludes(t *testing.T) {
+	cli, server := newMockClient(t)
+	server.AddAsset(&icloudmock.Asset{Filename: "visible.jpg", Data: []byte("visible")})
+	server.AddAsset(&icloudmock.Asset{Filename: "hidden.jpg", Data: []byte("hidden"), Hidden: true})
+	server.AddAsset(&icloudmock.Asset{Filename: "deleted.jpg", Data: []byte("deleted"), Deleted: true})
+
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+	album, err := photoCli.GetAlbum(AlbumNameAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		option *PhotosIterOption
+		want   []string
+	}{
+		{option: nil, want: []string{"visible.jpg"}},
+		{option: &PhotosIterOption{IncludeHidden: true}, want: []string{"visible.jpg", "hidden.jpg"}},
+		{option: &PhotosIterOption{IncludeRecentlyDeleted: true}, want: []string{"visible.jpg", "deleted.jpg"}},
+		{option: &PhotosIterOption{IncludeHidden: true, IncludeRecentlyDeleted: true}, want: []string{"visible.jpg", "hidden.jpg", "deleted.jpg"}},
+	} {
+		if got := iterFilenames(t, album.PhotosIterWithOption(tc.option)); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("PhotosIterWithOption(%+v) = %v, want %v", tc.option, got, tc.want)
+		}
+	}
+}
+
+func TestPhotosIterWithOptionAlbumLookupFails(t *testing.T) {
+	cli, server := newMockClient(t)
+	server.AddAsset(&icloudmock.Asset{Filename: "visible.jpg", Data: []byte("visible")})
+	server.AddAsset(&icloudmock.Asset{Filename: "hidden.jpg", Data: []byte("hidden"), Hidden: true})
+
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// All Photos without the album list loaded, so the Hidden album has to be looked up, and the lookup fails
+	props := icloudPhotoFolderMeta[AlbumNameAll]
+	album := photoCli.newPhotoAlbum(AlbumNameAll, props.ListType, props.ObjType, props.Direction, props.QueryFilter)
+	server.Fail("CPLAlbumByPositionLive", 500)
+
+	iter := album.PhotosIterWithOption(&PhotosIterOption{IncludeHidden: true})
+	asset, err := iter.Next()
+	if err == nil || errors.Is(err, ErrPhotosIterateEnd) {
+		t.Fatalf("first Next = %v, %v, want the error of the Hidden album lookup", asset, err)
+	}
+	if _, err := iter.Next(); err == nil || errors.Is(err, ErrPhotosIterateEnd) {
+		t.Fatalf("Next after the error = %v, want the error again", err)
+	}
+
+	server.Fail("CPLAlbumByPositionLive", 0)
+	if got, want := iterFilenames(t, album.PhotosIterWithOption(&PhotosIterOption{IncludeHidden: true})), []string{"visible.jpg", "hidden.jpg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PhotosIterWithOption once the lookup works = %v, want %v", got, want)
+	}
+}
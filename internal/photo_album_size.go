@@ -1,17 +1,25 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 )
 
+// Size returns the asset count of the album, it's cached after the first call,
+// and kept in step with the uploads, deletes and album changes made through the library.
 func (r *PhotoAlbum) Size() int {
 	size, _ := r.GetSize()
 	return size
 }
 
 func (r *PhotoAlbum) GetSize() (int, error) {
+	return r.GetSizeContext(context.Background())
+}
+
+// GetSizeContext is like GetSize, the size request is given up when ctx is done.
+func (r *PhotoAlbum) GetSizeContext(ctx context.Context) (int, error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 
@@ -19,7 +27,7 @@ func (r *PhotoAlbum) GetSize() (int, error) {
 		return *r._size, nil
 	}
 
-	size, err := r.getSize()
+	size, err := r.getSize(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -28,8 +36,9 @@ func (r *PhotoAlbum) GetSize() (int, error) {
 	return size, nil
 }
 
-func (r *PhotoAlbum) getSize() (int, error) {
+func (r *PhotoAlbum) getSize(ctx context.Context) (int, error) {
 	text, err := r.service.icloud.request(&rawReq{
+		Context: ctx,
 		Method:  http.MethodPost,
 		URL:     fmt.Sprintf("%s/internal/records/query/batch", r.service.serviceEndpoint),
 		Querys:  r.service.querys,
@@ -49,7 +58,7 @@ func (r *PhotoAlbum) getSize() (int, error) {
 						"recordType": "HyperionIndexCountLookup",
 					},
 					"zoneWide": true,
-					"zoneID":   map[string]string{"zoneName": "PrimarySync"},
+					"zoneID":   r.service.zoneID(),
 				},
 			},
 		},
@@ -13,6 +13,7 @@ type PhotoAsset struct {
 	_versions     map[PhotoVersion]*photoVersionDetail
 	_masterRecord *photoRecord
 	_assetRecord  *photoRecord
+	fetchedAt     time.Time
 	lock          *sync.Mutex
 }
 
@@ -22,10 +23,12 @@ func (r *PhotoService) newPhotoAsset(masterRecord, assetRecords *photoRecord) *P
 		_masterRecord: masterRecord,
 		_assetRecord:  assetRecords,
 		_versions:     nil,
+		fetchedAt:     time.Now(),
 		lock:          new(sync.Mutex),
 	}
 }
 
+// Filename returns the current filename of the asset, which changes when the asset is edited.
 func (r *PhotoAsset) Filename() string {
 	if v := r._masterRecord.Fields.FilenameEnc.Value; v != "" {
 		bs, _ := base64.StdEncoding.DecodeString(v)
@@ -37,12 +40,45 @@ func (r *PhotoAsset) Filename() string {
 	return cleanFilename(r.ID())
 }
 
+// OriginalFilename returns the filename the asset was imported with, like the camera name IMG_0001.HEIC,
+// it's the same as Filename for assets never edited.
+func (r *PhotoAsset) OriginalFilename() string {
+	if v := r._masterRecord.Fields.OriginalFilenameEnc.Value; v != "" {
+		bs, _ := base64.StdEncoding.DecodeString(v)
+		if len(bs) > 0 {
+			return cleanFilename(string(bs))
+		}
+	}
+
+	return r.Filename()
+}
+
+// LocalPath returns the path of the version in outputDir, versions other than the original get a _<version> suffix,
+// the live video versions the .MOV extension, so the original video of a live photo sits next to the photo,
+// the poster frame versions of a video the .JPG extension, and the adjusted and alternative versions the extension of their type.
+// The path of the original is ClientOption.FilenameTemplate when set, else the filename.
 func (r *PhotoAsset) LocalPath(outputDir string, size PhotoVersion) string {
-	filename := r.Filename()
+	if r.service != nil && r.service.icloud.filenameTemplate != nil {
+		if path, err := r.LocalPathWithTemplate(outputDir, size, r.service.icloud.filenameTemplate, ""); err == nil {
+			return path
+		}
+	}
+	return r.LocalPathWithFilename(outputDir, size, r.Filename())
+}
+
+// LocalPathWithFilename is like LocalPath, but uses filename, like OriginalFilename, instead of the current filename.
+func (r *PhotoAsset) LocalPathWithFilename(outputDir string, size PhotoVersion, filename string) string {
 	ext := filepath.Ext(filename)
 	filename = filename[:len(filename)-len(ext)]
+	if isLiveVideoVersion(size) {
+		ext = liveVideoExt
+	} else if isPosterFrameVersion(size) {
+		ext = posterFrameExt
+	} else {
+		ext = r.versionExt(size, ext)
+	}
 
-	if size == PhotoVersionOriginal || size == "" {
+	if size == PhotoVersionOriginal || size == "" || size == PhotoVersionLiveOriginal {
 		return filepath.Join(outputDir, filename+ext)
 	}
 
@@ -58,14 +94,80 @@ func (r *PhotoAsset) Size() int {
 }
 
 func (r *PhotoAsset) FormatSize() string {
-	return formatSize(r.Size())
+	return FormatSize(r.Size())
 }
 
 func (r *PhotoAsset) Created() time.Time {
 	return time.UnixMilli(r._masterRecord.Created.Timestamp)
 }
 
-func formatSize(size int) string {
+// AssetDate returns when the asset was taken, it falls back to Created if the date is unknown.
+func (r *PhotoAsset) AssetDate() time.Time {
+	if r._assetRecord != nil && r._assetRecord.Fields.AssetDate.Value > 0 {
+		return time.UnixMilli(r._assetRecord.Fields.AssetDate.Value)
+	}
+	return r.Created()
+}
+
+// AssetDateInZone returns AssetDate in the time zone the asset was taken in, which is the date shown in Photos,
+// including the adjustments made with Adjust Date & Time, it's in the local time zone if the zone is unknown.
+func (r *PhotoAsset) AssetDateInZone() time.Time {
+	date := r.AssetDate()
+	if r._assetRecord == nil || r._assetRecord.Fields.TimeZoneOffset.Type == "" {
+		return date.Local()
+	}
+	offset := r._assetRecord.Fields.TimeZoneOffset.Value
+	return date.In(time.FixedZone("", offset))
+}
+
+// AddedDate returns when the asset was added to the library, it falls back to Created if the date is unknown.
+func (r *PhotoAsset) AddedDate() time.Time {
+	if r._assetRecord != nil && r._assetRecord.Fields.AddedDate.Value > 0 {
+		return time.UnixMilli(r._assetRecord.Fields.AddedDate.Value)
+	}
+	return r.Created()
+}
+
+// Checksum returns the iCloud checksum of the original file, base64 encoded, which doesn't change unless the original changes.
+func (r *PhotoAsset) Checksum() string {
+	return r._masterRecord.Fields.ResOriginalRes.Value.FileChecksum
+}
+
+// Caption returns the caption the user gave the asset, empty if there is none.
+func (r *PhotoAsset) Caption() string {
+	if r._assetRecord == nil || r._assetRecord.Fields.CaptionEnc.Value == "" {
+		return ""
+	}
+	bs, _ := base64.StdEncoding.DecodeString(r._assetRecord.Fields.CaptionEnc.Value)
+	return string(bs)
+}
+
+// Modified returns the last time the asset or its master record was changed.
+func (r *PhotoAsset) Modified() time.Time {
+	modified := r._masterRecord.Modified.Timestamp
+	if r._assetRecord != nil && r._assetRecord.Modified.Timestamp > modified {
+		modified = r._assetRecord.Modified.Timestamp
+	}
+	return time.UnixMilli(modified)
+}
+
+// IsFavorite reports whether the asset is in the Favorites album.
+func (r *PhotoAsset) IsFavorite() bool {
+	return r._assetRecord != nil && r._assetRecord.Fields.IsFavorite.Value == 1
+}
+
+// IsHidden reports whether the asset is in the Hidden album.
+func (r *PhotoAsset) IsHidden() bool {
+	return r._assetRecord != nil && r._assetRecord.Fields.IsHidden.Value == 1
+}
+
+// IsDeleted reports whether the asset is in the Recently Deleted album.
+func (r *PhotoAsset) IsDeleted() bool {
+	return r._assetRecord != nil && r._assetRecord.Fields.IsDeleted.Value == 1
+}
+
+// FormatSize formats a byte count, like "1.50MB".
+func FormatSize(size int) string {
 	if size < 1024 {
 		return fmt.Sprintf("%dB", size)
 	} else if size < 1024*1024 {
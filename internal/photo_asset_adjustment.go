@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"encoding/base64"
+	"time"
+)
+
+// Adjustment is the non-destructive edit recipe of an edited asset, what Photos saves as an .AAE file.
+type Adjustment struct {
+	// FormatIdentifier is the app the edit was made with, like "com.apple.photo".
+	FormatIdentifier string
+	// FormatVersion is the version of the edit data format, like "1.5".
+	FormatVersion string
+	// CreatorCode is the code of the app which made the edit.
+	CreatorCode string
+	// Timestamp is when the asset was last edited.
+	Timestamp time.Time
+	// Data is the edit data, a compressed plist, empty when iCloud doesn't inline it.
+	Data []byte
+}
+
+// IsEdited reports whether the asset has edits on top of the original.
+func (r *PhotoAsset) IsEdited() bool {
+	return r._assetRecord != nil && r._assetRecord.Fields.AdjustmentType.Value != ""
+}
+
+// Adjustment returns the edit recipe of an edited asset, ok is false when the asset is not edited.
+func (r *PhotoAsset) Adjustment() (adjustment *Adjustment, ok bool) {
+	if !r.IsEdited() {
+		return nil, false
+	}
+	fields := r._assetRecord.Fields
+	adjustment = &Adjustment{
+		FormatIdentifier: fields.AdjustmentType.Value,
+		FormatVersion:    fields.AdjustmentCompoundVersion.Value,
+		CreatorCode:      fields.AdjustmentCreatorCode.Value,
+	}
+	if v := fields.AdjustmentTimestamp.Value; v > 0 {
+		adjustment.Timestamp = time.UnixMilli(v)
+	}
+	if v := fields.AdjustmentSimpleDataEnc.Value; v != "" {
+		adjustment.Data, _ = base64.StdEncoding.DecodeString(v)
+	}
+	return adjustment, true
+}
@@ -1,25 +1,132 @@
 package internal
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"net/http"
 )
 
+// Delete moves the asset to the Recently Deleted album, Restore moves it back.
 func (r *PhotoAsset) Delete() error {
-	body := fmt.Sprintf(`{"operations":[{"operationType":"update","record":{"recordName":"%s","recordType":"%s","recordChangeTag":"%s","fields":{"isDeleted":{"value":1}}}}],"zoneID":{"zoneName":"PrimarySync"},"atomic":true}`,
-		r._assetRecord.RecordName,
-		r._assetRecord.RecordType,
-		r._masterRecord.RecordChangeTag,
-	)
-	_, err := r.service.icloud.request(&rawReq{
-		Method:  http.MethodPost,
-		URL:     fmt.Sprintf("%s/records/modify", r.service.serviceEndpoint),
-		Querys:  r.service.querys,
-		Headers: r.service.icloud.getCommonHeaders(map[string]string{}),
-		Body:    body,
-	})
-	if err != nil {
+	return r.DeleteContext(context.Background())
+}
+
+// DeleteContext is like Delete, the request is given up when ctx is done.
+func (r *PhotoAsset) DeleteContext(ctx context.Context) error {
+	if err := r.updateAssetFields(ctx, map[string]any{"isDeleted": map[string]any{"value": 1}}); err != nil {
 		return fmt.Errorf("delete %s failed: %w", r.Filename(), err)
 	}
+	if !r.IsDeleted() {
+		r.service.adjustAlbumSizes(map[AlbumID]int{r.liveAlbumID(): -1, AlbumIDRecentlyDeleted: 1})
+		r._assetRecord.Fields.IsDeleted.Value = 1
+	}
+	return nil
+}
+
+// Restore moves the asset out of the Recently Deleted album, back to where it was deleted from.
+func (r *PhotoAsset) Restore() error {
+	return r.RestoreContext(context.Background())
+}
+
+// RestoreContext is like Restore, the request is given up when ctx is done.
+func (r *PhotoAsset) RestoreContext(ctx context.Context) error {
+	if err := r.updateAssetFields(ctx, map[string]any{"isDeleted": map[string]any{"value": 0}}); err != nil {
+		return fmt.Errorf("restore %s failed: %w", r.Filename(), err)
+	}
+	if r.IsDeleted() {
+		r.service.adjustAlbumSizes(map[AlbumID]int{AlbumIDRecentlyDeleted: -1, r.liveAlbumID(): 1})
+		r._assetRecord.Fields.IsDeleted.Value = 0
+	}
+	return nil
+}
+
+// Expunge deletes the asset permanently, like "Delete" in the Recently Deleted album, it can't be undone.
+func (r *PhotoAsset) Expunge() error {
+	if err := r.updateAssetFields(context.Background(), map[string]any{"isExpunged": map[string]any{"value": 1}}); err != nil {
+		return fmt.Errorf("expunge %s failed: %w", r.Filename(), err)
+	}
+	if r.IsDeleted() {
+		r.service.adjustAlbumSizes(map[AlbumID]int{AlbumIDRecentlyDeleted: -1})
+	} else {
+		r.service.adjustAlbumSizes(nil)
+	}
 	return nil
 }
+
+// liveAlbumID is the album of the asset when it's not deleted.
+func (r *PhotoAsset) liveAlbumID() AlbumID {
+	if r.IsHidden() {
+		return AlbumIDHidden
+	}
+	return AlbumIDAll
+}
+
+// updateAssetFields updates the CPLAsset record, with the change tag of the version listed,
+// or of its current version when it changed since, like on another device.
+//
+// The current version is only updated when it's still in or out of Recently Deleted like the version listed,
+// so a photo restored on another device is not deleted permanently, nor one deleted there restored,
+// else the CONFLICT is returned.
+func (r *PhotoAsset) updateAssetFields(ctx context.Context, fields map[string]any) error {
+	if r._assetRecord == nil {
+		return fmt.Errorf("no asset record")
+	}
+	if err := validateQueryIdentifier(r._assetRecord.RecordName); err != nil {
+		return err
+	}
+	changeTag := r._assetRecord.RecordChangeTag
+	for retried := false; ; retried = true {
+		text, err := r.service.modifyRecords(ctx, []any{
+			map[string]any{
+				"operationType": "update",
+				"record": map[string]any{
+					"recordName":      r._assetRecord.RecordName,
+					"recordType":      r._assetRecord.RecordType,
+					"recordChangeTag": changeTag,
+					"fields":          fields,
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		res := new(modifyRecordsResp)
+		if err := json.Unmarshal([]byte(text), res); err != nil {
+			return fmt.Errorf("modify records unmarshal failed, err: %w, text: %s", err, text)
+		}
+		if len(res.Records) == 0 {
+			return nil
+		}
+
+		record := res.Records[0]
+		if record.ServerErrorCode == "" {
+			if record.RecordChangeTag != "" {
+				r._assetRecord.RecordChangeTag = record.RecordChangeTag
+			}
+			return nil
+		}
+		conflict := fmt.Errorf("%s: %s", record.ServerErrorCode, record.Reason)
+		if record.ServerErrorCode != "CONFLICT" || retried {
+			return conflict
+		}
+		current, err := r.service.lookupRecord(ctx, r._assetRecord.RecordName)
+		if err != nil {
+			return err
+		}
+		if current.Fields.IsDeleted.Value != r._assetRecord.Fields.IsDeleted.Value {
+			return fmt.Errorf("%w, the asset was moved in or out of Recently Deleted since it was listed", conflict)
+		}
+		changeTag = current.RecordChangeTag
+	}
+}
+
+// modifyRecordsResp is the response of records/modify, a record which failed has a server error code,
+// like CONFLICT when its change tag is not the one of its current version.
+type modifyRecordsResp struct {
+	Records []struct {
+		RecordName      string `json:"recordName"`
+		RecordChangeTag string `json:"recordChangeTag"`
+		ServerErrorCode string `json:"serverErrorCode"`
+		Reason          string `json:"reason"`
+	} `json:"records"`
+}
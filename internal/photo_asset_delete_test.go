@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+// recentlyDeleted returns the assets of the Recently Deleted album.
+func recentlyDeleted(t *testing.T, cli *Client) []*PhotoAsset {
+	t.Helper()
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+	album, err := photoCli.GetAlbum(AlbumNameRecentlyDeleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []*PhotoAsset
+	iter := album.PhotosIterWithOption(nil)
+	for {
+		asset, err := iter.Next()
+		if errors.Is(err, ErrPhotosIterateEnd) {
+			return res
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, asset)
+	}
+}
+
+func TestExpungeConflict(t *testing.T) {
+	cli, server := newMockClient(t)
+	restored := server.AddAsset(&icloudmock.Asset{Filename: "restored.jpg", Data: []byte("restored"), Deleted: true})
+	edited := server.AddAsset(&icloudmock.Asset{Filename: "edited.jpg", Data: []byte("edited"), Deleted: true})
+	assets := recentlyDeleted(t, cli)
+	if len(assets) != 2 {
+		t.Fatalf("%d assets in Recently Deleted, want 2", len(assets))
+	}
+
+	// both change on another device after the listing, one is restored, the other only edited
+	server.UpdateAsset(restored.ID, func(asset *icloudmock.Asset) { asset.Deleted = false })
+	server.UpdateAsset(edited.ID, func(asset *icloudmock.Asset) { asset.Favorite = true })
+
+	for _, asset := range assets {
+		err := asset.Expunge()
+		switch asset.ID() {
+		case restored.ID:
+			if err == nil || !strings.Contains(err.Error(), "CONFLICT") {
+				t.Errorf("expunge of the restored asset: %v, want a CONFLICT", err)
+			}
+		case edited.ID:
+			if err != nil {
+				t.Errorf("expunge of the edited asset: %s", err)
+			}
+		}
+	}
+	if server.Asset(restored.ID) == nil {
+		t.Errorf("the restored asset was expunged")
+	}
+	if server.Asset(edited.ID) != nil {
+		t.Errorf("the edited asset was not expunged")
+	}
+}
@@ -1,66 +1,295 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"net/http"
-	"os"
 	"strings"
 )
 
+// PartialFileSuffix is appended to the target of DownloadTo while the download is in progress.
+const PartialFileSuffix = ".part"
+
 type PhotoVersion string
 
 const (
 	PhotoVersionOriginal PhotoVersion = "original"
 	PhotoVersionMedium   PhotoVersion = "medium"
 	PhotoVersionThumb    PhotoVersion = "thumb"
+
+	// the edited rendering of an edited asset, and the other original of a pair, like the RAW of a RAW+JPEG photo,
+	// see PhotoAsset.Versions
+	PhotoVersionAdjusted    PhotoVersion = "adjusted"
+	PhotoVersionAlternative PhotoVersion = "alternative"
+
+	// the versions of the paired video of a live photo, see PhotoAsset.LiveVideoVersions
+	PhotoVersionLiveOriginal PhotoVersion = "live_original"
+	PhotoVersionLiveMedium   PhotoVersion = "live_medium"
+	PhotoVersionLiveThumb    PhotoVersion = "live_thumb"
+
+	// the versions of the poster frame of a video, see PhotoAsset.PosterFrameVersions
+	PhotoVersionPoster      PhotoVersion = "poster"
+	PhotoVersionPosterThumb PhotoVersion = "poster_thumb"
 )
 
 func (r *PhotoAsset) DownloadTo(version PhotoVersion, target string) error {
-	body, err := r.Download(version)
+	return r.DownloadToContext(context.Background(), version, target)
+}
+
+// DownloadToContext is like DownloadTo, the download is given up when ctx is done,
+// and the .part file is left for the next run.
+func (r *PhotoAsset) DownloadToContext(ctx context.Context, version PhotoVersion, target string) error {
+	return r.DownloadToStorageContext(ctx, version, NewFileStorage(), target)
+}
+
+// DownloadToStorage is like DownloadTo, but writes to storage instead of the local filesystem.
+func (r *PhotoAsset) DownloadToStorage(version PhotoVersion, storage Storage, target string) error {
+	return r.DownloadToStorageContext(context.Background(), version, storage, target)
+}
+
+// DownloadToStorageContext is like DownloadToStorage, the download is given up when ctx is done.
+//
+// A download failing midway is retried with the DownloadRetryPolicy of the client, and resumed
+// from the .part file when storage implements StorageAppend, so is the .part file of an interrupted run.
+func (r *PhotoAsset) DownloadToStorageContext(ctx context.Context, version PhotoVersion, storage Storage, target string) error {
+	var size int64
+	if v, ok := r.getVersions()[version]; ok {
+		size = int64(v.Size)
+	}
+
+	events := r.service.eventSink()
+	events.DownloadStarted(r, version, target)
+
+	// write to a .part file first, so a crashed run never leaves a truncated file at target
+	downloaded, err := r.downloadInRanges(ctx, version, storage, target, size)
+	if !downloaded {
+		err = r.service.icloud.downloadToStorage(ctx, storage, target, size, func(ctx context.Context, offset int64) (io.ReadCloser, int64, error) {
+			return r.openDownload(ctx, version, offset)
+		})
+	}
 	if err != nil {
+		events.Error(r, err)
 		return err
 	}
-	defer body.Close()
 
-	f, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE, 0o644)
-	if err != nil {
-		return fmt.Errorf("open file error: %v", err)
+	// 1676381385791 to time.time
+	if chtimes, ok := storage.(StorageChtimes); ok {
+		created := r.Created()
+		if err := chtimes.Chtimes(target, created, created); err != nil {
+			err = fmt.Errorf("change file time error: %v", err)
+			events.Error(r, err)
+			return err
+		}
+	}
+
+	events.DownloadFinished(r, version, target)
+	return nil
+}
+
+// StorageTarget is one destination of DownloadToStorages.
+type StorageTarget struct {
+	Storage Storage
+	Path    string
+}
+
+// DownloadToStorages downloads the asset once, and streams it to every target,
+// it returns the error of each target, in the order of targets, a failing target doesn't stop the others.
+func (r *PhotoAsset) DownloadToStorages(version PhotoVersion, targets []*StorageTarget) []error {
+	return r.DownloadToStoragesContext(context.Background(), version, targets)
+}
+
+// DownloadToStoragesContext is like DownloadToStorages, the download is given up when ctx is done.
+// The progress is reported with the path of the first target.
+//
+// A single target is resumed like DownloadToStorage, with more targets a download failing midway
+// is retried from the start, for the targets without an error of their own.
+func (r *PhotoAsset) DownloadToStoragesContext(ctx context.Context, version PhotoVersion, targets []*StorageTarget) []error {
+	if len(targets) == 1 {
+		return []error{r.DownloadToStorageContext(ctx, version, targets[0].Storage, targets[0].Path)}
+	}
+
+	var size int64
+	if v, ok := r.getVersions()[version]; ok {
+		size = int64(v.Size)
+	}
+	events := r.service.eventSink()
+	for _, target := range targets {
+		events.DownloadStarted(r, version, target.Path)
+	}
+	progress := newDownloadProgress(ctx, targets[0].Path, size)
+	errs := r.downloadToStorages(ctx, version, targets, progress)
+	var err error
+	for i, targetErr := range errs {
+		if targetErr != nil {
+			events.Error(r, targetErr)
+			if err == nil {
+				err = targetErr
+			}
+		} else {
+			events.DownloadFinished(r, version, targets[i].Path)
+		}
+	}
+	progress.done(err)
+	return errs
+}
+
+func (r *PhotoAsset) downloadToStorages(ctx context.Context, version PhotoVersion, targets []*StorageTarget, progress *downloadProgress) []error {
+	errs := make([]error, len(targets))
+	policy := r.service.icloud.downloadRetryPolicy()
+	pending := targets
+	for attempt := 1; ; attempt++ {
+		targetErrs, err := r.downloadToStoragesOnce(ctx, version, pending, progress)
+		var failed []*StorageTarget
+		for i, target := range pending {
+			if targetErrs[i] != nil {
+				errs[indexOfTarget(targets, target)] = targetErrs[i]
+			} else if err != nil {
+				failed = append(failed, target)
+			}
+		}
+		if err == nil || len(failed) == 0 {
+			return errs
+		}
+		if attempt >= policy.MaxAttempts || !isRetryableDownloadError(ctx, err) {
+			for _, target := range failed {
+				errs[indexOfTarget(targets, target)] = err
+			}
+			return errs
+		}
+		r.service.icloud.log(LogLevelWarn, "download failed, retry", "file", r.Filename(), "retry", fmt.Sprintf("%d/%d", attempt, policy.MaxAttempts-1), "delay", policy.delay(attempt), "err", err)
+		if waitErr := policy.wait(ctx, attempt); waitErr != nil {
+			for _, target := range failed {
+				errs[indexOfTarget(targets, target)] = err
+			}
+			return errs
+		}
+		pending = failed
 	}
+}
 
-	_, err = io.Copy(f, body)
+// downloadToStoragesOnce downloads the asset once to every target, it returns the errors of the targets,
+// and the error of the download itself, which targets without an error of their own failed with.
+func (r *PhotoAsset) downloadToStoragesOnce(ctx context.Context, version PhotoVersion, targets []*StorageTarget, progress *downloadProgress) ([]error, error) {
+	errs := make([]error, len(targets))
+	body, err := r.DownloadContext(ctx, version)
 	if err != nil {
-		return fmt.Errorf("copy file error: %v", err)
+		return errs, err
+	}
+	defer body.Close()
+
+	files := make([]io.WriteCloser, len(targets))
+	writers := make([]io.Writer, 0, len(targets))
+	for i, target := range targets {
+		f, err := target.Storage.Create(target.Path + PartialFileSuffix)
+		if err != nil {
+			errs[i] = fmt.Errorf("open file error: %w", err)
+			continue
+		}
+		files[i] = f
+		writers = append(writers, &fanoutWriter{w: f, err: &errs[i]})
+	}
+	if len(writers) == 0 {
+		return errs, nil
+	}
+
+	progress.start(0)
+	var downloadErr error
+	if _, err := io.Copy(io.MultiWriter(writers...), progress.reader(body)); err != nil {
+		downloadErr = fmt.Errorf("copy file error: %w", err)
 	}
 
-	// 1676381385791 to time.time
 	created := r.Created()
-	if err := os.Chtimes(target, created, created); err != nil {
-		return fmt.Errorf("change file time error: %v", err)
+	for i, target := range targets {
+		if files[i] == nil {
+			continue
+		}
+		if err := files[i].Close(); err != nil && errs[i] == nil {
+			errs[i] = fmt.Errorf("copy file error: %w", err)
+		}
+		if errs[i] != nil || downloadErr != nil {
+			continue
+		}
+		if err := target.Storage.Rename(target.Path+PartialFileSuffix, target.Path); err != nil {
+			errs[i] = fmt.Errorf("rename file error: %w", err)
+			continue
+		}
+		if chtimes, ok := target.Storage.(StorageChtimes); ok {
+			if err := chtimes.Chtimes(target.Path, created, created); err != nil {
+				errs[i] = fmt.Errorf("change file time error: %v", err)
+			}
+		}
+	}
+	return errs, downloadErr
+}
+
+func indexOfTarget(targets []*StorageTarget, target *StorageTarget) int {
+	for i, v := range targets {
+		if v == target {
+			return i
+		}
 	}
+	return -1
+}
 
-	return nil
+// fanoutWriter records the first write error of a destination, and then drops its writes,
+// so io.MultiWriter keeps feeding the other destinations.
+type fanoutWriter struct {
+	w   io.Writer
+	err *error
+}
+
+func (r *fanoutWriter) Write(p []byte) (int, error) {
+	if *r.err != nil {
+		return len(p), nil
+	}
+	if _, err := r.w.Write(p); err != nil {
+		*r.err = fmt.Errorf("copy file error: %w", err)
+	}
+	return len(p), nil
 }
 
 func (r *PhotoAsset) Download(version PhotoVersion) (io.ReadCloser, error) {
-	versionDetail, ok := r.getVersions()[version]
-	if !ok {
-		var keys []string
-		for k := range r.getVersions() {
-			keys = append(keys, string(k))
+	return r.DownloadContext(context.Background(), version)
+}
+
+// DownloadContext is like Download, reading the body fails with ctx.Err() once ctx is done.
+//
+// Download URLs expire, so URLs listed long ago, or rejected as expired, are resolved again first.
+func (r *PhotoAsset) DownloadContext(ctx context.Context, version PhotoVersion) (io.ReadCloser, error) {
+	body, _, err := r.openDownload(ctx, version, 0)
+	return body, err
+}
+
+// openDownload is the rangeOpener of the version.
+func (r *PhotoAsset) openDownload(ctx context.Context, version PhotoVersion, offset int64) (io.ReadCloser, int64, error) {
+	for attempt := 0; ; attempt++ {
+		url, err := r.downloadURL(ctx, version)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		body, start, err := r.service.icloud.openStream(ctx, url, offset)
+		if err != nil && isExpiredURLError(err) && attempt == 0 {
+			if err := r.refreshURLs(ctx); err != nil {
+				return nil, 0, err
+			}
+			continue
+		} else if err != nil {
+			return nil, 0, fmt.Errorf("download %s failed: %w", r.Filename(), err)
 		}
-		return nil, fmt.Errorf("version %s not found, valid: %s", version, strings.Join(keys, ","))
+		return body, start, nil
 	}
+}
 
-	body, err := r.service.icloud.requestStream(&rawReq{
-		Method:  http.MethodGet,
-		URL:     versionDetail.URL,
-		Headers: r.service.icloud.getCommonHeaders(map[string]string{}),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("download %s failed: %w", r.Filename(), err)
+func (r *PhotoAsset) versionNotFound(version PhotoVersion) error {
+	var keys []string
+	for k := range r.getVersions() {
+		if k == photoVersionThumbnail {
+			continue
+		}
+		keys = append(keys, string(k))
 	}
-	return body, nil
+	return fmt.Errorf("version %s not found, valid: %s", version, strings.Join(keys, ","))
 }
 
 func (r *PhotoAsset) getVersions() map[PhotoVersion]*photoVersionDetail {
@@ -75,6 +304,23 @@ func (r *PhotoAsset) getVersions() map[PhotoVersion]*photoVersionDetail {
 }
 
 func (r *PhotoAsset) packVersion() map[PhotoVersion]*photoVersionDetail {
+	versions := r.packMediaVersion()
+	for version, detail := range r.packLiveVideoVersion() {
+		versions[version] = detail
+	}
+	for version, detail := range r.packPosterFrameVersion() {
+		versions[version] = detail
+	}
+	for version, detail := range r.packExtraVersion() {
+		versions[version] = detail
+	}
+	for version, detail := range r.packThumbnailVersion() {
+		versions[version] = detail
+	}
+	return versions
+}
+
+func (r *PhotoAsset) packMediaVersion() map[PhotoVersion]*photoVersionDetail {
 	fields := r._masterRecord.Fields
 
 	if fields.ResVidSmallRes.Type != "" || fields.ResVidSmallRes.Value.Size != 0 {
@@ -86,6 +332,7 @@ func (r *PhotoAsset) packVersion() map[PhotoVersion]*photoVersionDetail {
 				Size:     fields.ResOriginalRes.Value.Size,
 				URL:      fields.ResOriginalRes.Value.DownloadURL,
 				Type:     fields.ResOriginalFileType.Value,
+				Checksum: fields.ResOriginalRes.Value.FileChecksum,
 			},
 			PhotoVersionMedium: {
 				Filename: r.Filename(),
@@ -94,6 +341,7 @@ func (r *PhotoAsset) packVersion() map[PhotoVersion]*photoVersionDetail {
 				Size:     fields.ResJPEGMedRes.Value.Size,
 				URL:      fields.ResJPEGMedRes.Value.DownloadURL,
 				Type:     fields.ResJPEGMedFileType.Value,
+				Checksum: fields.ResJPEGMedRes.Value.FileChecksum,
 			},
 			PhotoVersionThumb: {
 				Filename: r.Filename(),
@@ -102,6 +350,7 @@ func (r *PhotoAsset) packVersion() map[PhotoVersion]*photoVersionDetail {
 				Size:     fields.ResJPEGThumbRes.Value.Size,
 				URL:      fields.ResJPEGThumbRes.Value.DownloadURL,
 				Type:     fields.ResJPEGThumbFileType.Value,
+				Checksum: fields.ResJPEGThumbRes.Value.FileChecksum,
 			},
 		}
 	} else {
@@ -113,6 +362,7 @@ func (r *PhotoAsset) packVersion() map[PhotoVersion]*photoVersionDetail {
 				Size:     fields.ResOriginalRes.Value.Size,
 				URL:      fields.ResOriginalRes.Value.DownloadURL,
 				Type:     fields.ResOriginalFileType.Value,
+				Checksum: fields.ResOriginalRes.Value.FileChecksum,
 			},
 			PhotoVersionMedium: {
 				Filename: r.Filename(),
@@ -121,6 +371,7 @@ func (r *PhotoAsset) packVersion() map[PhotoVersion]*photoVersionDetail {
 				Size:     fields.ResVidMedRes.Value.Size,
 				URL:      fields.ResVidMedRes.Value.DownloadURL,
 				Type:     fields.ResVidMedFileType.Value,
+				Checksum: fields.ResVidMedRes.Value.FileChecksum,
 			},
 			PhotoVersionThumb: {
 				Filename: r.Filename(),
@@ -129,6 +380,7 @@ func (r *PhotoAsset) packVersion() map[PhotoVersion]*photoVersionDetail {
 				Size:     fields.ResVidSmallRes.Value.Size,
 				URL:      fields.ResVidSmallRes.Value.DownloadURL,
 				Type:     fields.ResVidSmallFileType.Value,
+				Checksum: fields.ResVidSmallRes.Value.FileChecksum,
 			},
 		}
 	}
@@ -141,4 +393,5 @@ type photoVersionDetail struct {
 	Size     int    `json:"size"`
 	URL      string `json:"url"`
 	Type     string `json:"type"`
+	Checksum string `json:"checksum"`
 }
@@ -0,0 +1,49 @@
+package internal
+
+// asset subtype flags, the same bits as PHAssetMediaSubtype of the Photos framework
+const (
+	assetSubtypePanorama   = 1 << 0
+	assetSubtypeScreenshot = 1 << 2
+	assetSubtypeLive       = 1 << 3
+	assetSubtypeSloMo      = 1 << 17
+)
+
+func (r *PhotoAsset) assetSubtype() int {
+	if r._assetRecord == nil {
+		return 0
+	}
+	if v := r._assetRecord.Fields.AssetSubtypeV2.Value; v != 0 {
+		return v
+	}
+	return r._assetRecord.Fields.AssetSubtype.Value
+}
+
+// IsScreenshot reports whether the asset is in the Screenshots smart album.
+func (r *PhotoAsset) IsScreenshot() bool {
+	return r.assetSubtype()&assetSubtypeScreenshot != 0
+}
+
+// IsScreenRecording reports whether the asset is a screen recording, a video iOS marks like the screenshots.
+func (r *PhotoAsset) IsScreenRecording() bool {
+	return r.IsVideo() && r.IsScreenshot()
+}
+
+// IsPanorama reports whether the asset is in the Panoramas smart album.
+func (r *PhotoAsset) IsPanorama() bool {
+	return r.assetSubtype()&assetSubtypePanorama != 0
+}
+
+// IsSloMo reports whether the asset is in the Slo-mo smart album.
+func (r *PhotoAsset) IsSloMo() bool {
+	return r.assetSubtype()&assetSubtypeSloMo != 0
+}
+
+// IsBurst reports whether the asset is part of a burst.
+func (r *PhotoAsset) IsBurst() bool {
+	return r._assetRecord != nil && (r._assetRecord.Fields.BurstID.Value != "" || r._assetRecord.Fields.BurstFlags.Value != 0)
+}
+
+// IsLivePhoto reports whether the asset is a live photo, which has a paired video.
+func (r *PhotoAsset) IsLivePhoto() bool {
+	return r.assetSubtype()&assetSubtypeLive != 0 || r._masterRecord.Fields.ResOriginalVidComplRes.Value.Size > 0
+}
@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"path/filepath"
+)
+
+// liveVideoExt is the extension of the paired video of a live photo, always a QuickTime movie.
+const liveVideoExt = ".MOV"
+
+// LiveVideoVersion is a version of the paired video of a live photo, download it with DownloadTo.
+type LiveVideoVersion struct {
+	Version  PhotoVersion
+	Filename string
+	Width    int
+	Height   int
+	Size     int
+	Type     string // uniform type identifier, like com.apple.quicktime-movie
+}
+
+// LiveVideoVersions returns the versions of the paired video of a live photo, the original first,
+// nil for other assets.
+func (r *PhotoAsset) LiveVideoVersions() []*LiveVideoVersion {
+	versions := r.getVersions()
+	var res []*LiveVideoVersion
+	for _, version := range []PhotoVersion{PhotoVersionLiveOriginal, PhotoVersionLiveMedium, PhotoVersionLiveThumb} {
+		detail, ok := versions[version]
+		if !ok {
+			continue
+		}
+		res = append(res, &LiveVideoVersion{
+			Version:  version,
+			Filename: detail.Filename,
+			Width:    detail.Width,
+			Height:   detail.Height,
+			Size:     detail.Size,
+			Type:     detail.Type,
+		})
+	}
+	return res
+}
+
+func isLiveVideoVersion(version PhotoVersion) bool {
+	return version == PhotoVersionLiveOriginal || version == PhotoVersionLiveMedium || version == PhotoVersionLiveThumb
+}
+
+// packLiveVideoVersion returns the live video versions of the master record, videos have none,
+// their resVid fields are the versions of the video itself.
+func (r *PhotoAsset) packLiveVideoVersion() map[PhotoVersion]*photoVersionDetail {
+	fields := r._masterRecord.Fields
+	if r.IsVideo() || fields.ResOriginalVidComplRes.Value.DownloadURL == "" {
+		return nil
+	}
+
+	filename := r.Filename()
+	filename = filename[:len(filename)-len(filepath.Ext(filename))] + liveVideoExt
+	versions := map[PhotoVersion]*photoVersionDetail{
+		PhotoVersionLiveOriginal: {
+			Filename: filename,
+			Width:    fields.ResOriginalVidComplWidth.Value,
+			Height:   fields.ResOriginalVidComplHeight.Value,
+			Size:     fields.ResOriginalVidComplRes.Value.Size,
+			URL:      fields.ResOriginalVidComplRes.Value.DownloadURL,
+			Type:     fields.ResOriginalVidComplFileType.Value,
+		},
+	}
+	if fields.ResVidMedRes.Value.DownloadURL != "" {
+		versions[PhotoVersionLiveMedium] = &photoVersionDetail{
+			Filename: filename,
+			Width:    fields.ResVidMedWidth.Value,
+			Height:   fields.ResVidMedHeight.Value,
+			Size:     fields.ResVidMedRes.Value.Size,
+			URL:      fields.ResVidMedRes.Value.DownloadURL,
+			Type:     fields.ResVidMedFileType.Value,
+		}
+	}
+	if fields.ResVidSmallRes.Value.DownloadURL != "" {
+		versions[PhotoVersionLiveThumb] = &photoVersionDetail{
+			Filename: filename,
+			Width:    fields.ResVidSmallWidth.Value,
+			Height:   fields.ResVidSmallHeight.Value,
+			Size:     fields.ResVidSmallRes.Value.Size,
+			URL:      fields.ResVidSmallRes.Value.DownloadURL,
+			Type:     fields.ResVidSmallFileType.Value,
+		}
+	}
+	return versions
+}
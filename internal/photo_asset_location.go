@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"unicode/utf16"
+)
+
+// Location returns where the asset was taken, ok is false if the asset has no location.
+func (r *PhotoAsset) Location() (latitude, longitude float64, ok bool) {
+	if r._assetRecord == nil {
+		return 0, 0, false
+	}
+	fields := r._assetRecord.Fields
+	if fields.LocationLatitude.Value != 0 || fields.LocationLongitude.Value != 0 {
+		return fields.LocationLatitude.Value, fields.LocationLongitude.Value, true
+	}
+	if fields.LocationEnc.Value == "" {
+		return 0, 0, false
+	}
+
+	// locationEnc is a base64 binary plist dict, like {"lat": 1.1, "lon": 2.2, "alt": 3.3, ...}
+	bs, err := base64.StdEncoding.DecodeString(fields.LocationEnc.Value)
+	if err != nil {
+		return 0, 0, false
+	}
+	dict := decodeBplistNumberDict(bs)
+	latitude, latOK := dict["lat"]
+	longitude, lonOK := dict["lon"]
+	return latitude, longitude, latOK && lonOK
+}
+
+// decodeBplistNumberDict decodes a binary plist whose top object is a dict,
+// keeping only the string keys with int or real values, nil if bs is not such a plist.
+func decodeBplistNumberDict(bs []byte) map[string]float64 {
+	if len(bs) < 40 || string(bs[:8]) != "bplist00" {
+		return nil
+	}
+	trailer := bs[len(bs)-32:]
+	offsetSize, refSize := int(trailer[6]), int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:16])
+	topObject := binary.BigEndian.Uint64(trailer[16:24])
+	offsetTable := binary.BigEndian.Uint64(trailer[24:32])
+	if offsetSize == 0 || refSize == 0 || numObjects > uint64(len(bs)) || offsetTable > uint64(len(bs)) {
+		return nil
+	}
+
+	readUint := func(b []byte) uint64 {
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v
+	}
+	offsetOf := func(ref uint64) (int, bool) {
+		start := offsetTable + ref*uint64(offsetSize)
+		if ref >= numObjects || start+uint64(offsetSize) > uint64(len(bs)) {
+			return 0, false
+		}
+		offset := readUint(bs[start : start+uint64(offsetSize)])
+		return int(offset), offset < uint64(len(bs))
+	}
+	// length returns the object count of the marker at offset, and where the object data starts.
+	length := func(offset int) (int, int, bool) {
+		n := int(bs[offset] & 0x0f)
+		if n != 0x0f {
+			return n, offset + 1, true
+		}
+		if offset+2 > len(bs) || bs[offset+1]&0xf0 != 0x10 {
+			return 0, 0, false
+		}
+		size := 1 << (bs[offset+1] & 0x0f)
+		if offset+2+size > len(bs) {
+			return 0, 0, false
+		}
+		return int(readUint(bs[offset+2 : offset+2+size])), offset + 2 + size, true
+	}
+	readString := func(ref uint64) (string, bool) {
+		offset, ok := offsetOf(ref)
+		if !ok {
+			return "", false
+		}
+		n, start, ok := length(offset)
+		if !ok {
+			return "", false
+		}
+		switch bs[offset] & 0xf0 {
+		case 0x50:
+			if start+n > len(bs) {
+				return "", false
+			}
+			return string(bs[start : start+n]), true
+		case 0x60:
+			if start+2*n > len(bs) {
+				return "", false
+			}
+			u := make([]uint16, n)
+			for i := range u {
+				u[i] = binary.BigEndian.Uint16(bs[start+2*i:])
+			}
+			return string(utf16.Decode(u)), true
+		}
+		return "", false
+	}
+	readNumber := func(ref uint64) (float64, bool) {
+		offset, ok := offsetOf(ref)
+		if !ok {
+			return 0, false
+		}
+		size := 1 << (bs[offset] & 0x0f)
+		if offset+1+size > len(bs) {
+			return 0, false
+		}
+		data := bs[offset+1 : offset+1+size]
+		switch {
+		case bs[offset]&0xf0 == 0x10:
+			return float64(int64(readUint(data))), true
+		case bs[offset] == 0x22:
+			return float64(math.Float32frombits(uint32(readUint(data)))), true
+		case bs[offset] == 0x23:
+			return math.Float64frombits(readUint(data)), true
+		}
+		return 0, false
+	}
+
+	offset, ok := offsetOf(topObject)
+	if !ok || bs[offset]&0xf0 != 0xd0 {
+		return nil
+	}
+	n, start, ok := length(offset)
+	if !ok || start+2*n*refSize > len(bs) {
+		return nil
+	}
+	res := map[string]float64{}
+	for i := 0; i < n; i++ {
+		keyRef := readUint(bs[start+i*refSize : start+(i+1)*refSize])
+		valueRef := readUint(bs[start+(n+i)*refSize : start+(n+i+1)*refSize])
+		key, ok := readString(keyRef)
+		if !ok {
+			continue
+		}
+		if value, ok := readNumber(valueRef); ok {
+			res[key] = value
+		}
+	}
+	return res
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetAssetByID finds an asset by its asset record name, like the PhotoID Upload returns.
+func (r *PhotoService) GetAssetByID(id string) (*PhotoAsset, error) {
+	return r.GetAssetByIDContext(context.Background(), id)
+}
+
+// GetAssetByIDContext is like GetAssetByID, the lookup is given up when ctx is done.
+func (r *PhotoService) GetAssetByIDContext(ctx context.Context, id string) (*PhotoAsset, error) {
+	assetRecord, err := r.lookupRecord(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get asset %s failed, err: %w", id, err)
+	} else if assetRecord.RecordType != "CPLAsset" {
+		return nil, fmt.Errorf("get asset %s failed, err: record type is %s", id, assetRecord.RecordType)
+	}
+
+	masterRecord, err := r.lookupRecord(ctx, assetRecord.Fields.MasterRef.Value.RecordName)
+	if err != nil {
+		return nil, fmt.Errorf("get asset %s failed, err: %w", id, err)
+	}
+	return r.newPhotoAsset(masterRecord, assetRecord), nil
+}
+
+func (r *PhotoService) lookupRecord(ctx context.Context, recordName string) (*photoRecord, error) {
+	if recordName == "" {
+		return nil, fmt.Errorf("record name is empty")
+	}
+	text, err := r.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     fmt.Sprintf("%s/records/lookup", r.serviceEndpoint),
+		Querys:  r.querys,
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		Body: map[string]any{
+			"records": []any{map[string]any{"recordName": recordName}},
+			"zoneID":  r.zoneID(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := new(getPhotosResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, fmt.Errorf("lookup record unmarshal failed, err: %w, text: %s", err, text)
+	}
+	if len(res.Records) == 0 || res.Records[0].RecordType == "" {
+		return nil, fmt.Errorf("record %s not found", recordName)
+	}
+	return res.Records[0], nil
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"path/filepath"
+)
+
+// posterFrameExt is the extension of the poster frame of a video, always a JPEG.
+const posterFrameExt = ".JPG"
+
+// PosterFrameVersion is a version of the poster frame of a video, the still shown before it plays,
+// download it with DownloadTo.
+type PosterFrameVersion struct {
+	Version  PhotoVersion
+	Filename string
+	Width    int
+	Height   int
+	Size     int
+	Type     string // uniform type identifier, like public.jpeg
+}
+
+// PosterFrameVersions returns the versions of the poster frame of a video, the largest first,
+// nil for other assets.
+func (r *PhotoAsset) PosterFrameVersions() []*PosterFrameVersion {
+	versions := r.getVersions()
+	var res []*PosterFrameVersion
+	for _, version := range []PhotoVersion{PhotoVersionPoster, PhotoVersionPosterThumb} {
+		detail, ok := versions[version]
+		if !ok {
+			continue
+		}
+		res = append(res, &PosterFrameVersion{
+			Version:  version,
+			Filename: detail.Filename,
+			Width:    detail.Width,
+			Height:   detail.Height,
+			Size:     detail.Size,
+			Type:     detail.Type,
+		})
+	}
+	return res
+}
+
+func isPosterFrameVersion(version PhotoVersion) bool {
+	return version == PhotoVersionPoster || version == PhotoVersionPosterThumb
+}
+
+// packPosterFrameVersion returns the poster frame versions of the master record, photos have none,
+// their resJPEG fields are the versions of the photo itself.
+func (r *PhotoAsset) packPosterFrameVersion() map[PhotoVersion]*photoVersionDetail {
+	if !r.IsVideo() {
+		return nil
+	}
+
+	fields := r._masterRecord.Fields
+	filename := r.Filename()
+	filename = filename[:len(filename)-len(filepath.Ext(filename))] + posterFrameExt
+	versions := map[PhotoVersion]*photoVersionDetail{}
+	if fields.ResJPEGMedRes.Value.DownloadURL != "" {
+		versions[PhotoVersionPoster] = &photoVersionDetail{
+			Filename: filename,
+			Width:    fields.ResJPEGMedWidth.Value,
+			Height:   fields.ResJPEGMedHeight.Value,
+			Size:     fields.ResJPEGMedRes.Value.Size,
+			URL:      fields.ResJPEGMedRes.Value.DownloadURL,
+			Type:     fields.ResJPEGMedFileType.Value,
+		}
+	}
+	if fields.ResJPEGThumbRes.Value.DownloadURL != "" {
+		versions[PhotoVersionPosterThumb] = &photoVersionDetail{
+			Filename: filename,
+			Width:    fields.ResJPEGThumbWidth.Value,
+			Height:   fields.ResJPEGThumbHeight.Value,
+			Size:     fields.ResJPEGThumbRes.Value.Size,
+			URL:      fields.ResJPEGThumbRes.Value.DownloadURL,
+			Type:     fields.ResJPEGThumbFileType.Value,
+		}
+	}
+	return versions
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrDownloadURLExpired is returned when the download URL of an asset is rejected
+// and looking the asset up again doesn't give a working one.
+var ErrDownloadURLExpired = NewError("download_url_expired", "download url expired")
+
+// downloadURLMaxAge is how long the download URLs of an asset are used as listed,
+// older URLs are resolved again before the download starts.
+const downloadURLMaxAge = 30 * time.Minute
+
+// isExpiredURLStatus reports whether a download failed because its signed URL expired.
+func isExpiredURLStatus(status int) bool {
+	return status == http.StatusForbidden || status == http.StatusGone
+}
+
+// downloadURL returns the download URL of the version, resolved again once it's older than downloadURLMaxAge.
+func (r *PhotoAsset) downloadURL(ctx context.Context, version PhotoVersion) (string, error) {
+	r.lock.Lock()
+	stale := time.Since(r.fetchedAt) > downloadURLMaxAge
+	r.lock.Unlock()
+	if stale {
+		if err := r.refreshURLs(ctx); err != nil {
+			return "", err
+		}
+	}
+	versionDetail, ok := r.getVersions()[version]
+	if !ok {
+		return "", r.versionNotFound(version)
+	}
+	return versionDetail.URL, nil
+}
+
+// refreshURLs looks the asset up again, for download URLs signed now.
+//
+// Only the versions are replaced, the records other methods read are kept as listed.
+func (r *PhotoAsset) refreshURLs(ctx context.Context) error {
+	masterRecord, err := r.service.lookupRecord(ctx, r._masterRecord.RecordName)
+	if err != nil {
+		return fmt.Errorf("refresh download url of %s failed, err: %w", r.Filename(), err)
+	}
+	var assetRecord *photoRecord
+	if r._assetRecord != nil {
+		if assetRecord, err = r.service.lookupRecord(ctx, r._assetRecord.RecordName); err != nil {
+			return fmt.Errorf("refresh download url of %s failed, err: %w", r.Filename(), err)
+		}
+	}
+	versions := r.service.newPhotoAsset(masterRecord, assetRecord).packVersion()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r._versions = versions
+	r.fetchedAt = time.Now()
+	return nil
+}
+
+// isExpiredURLError reports whether the download failed because its URL expired.
+func isExpiredURLError(err error) bool {
+	return errors.Is(err, ErrDownloadURLExpired)
+}
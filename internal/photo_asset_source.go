@@ -0,0 +1,29 @@
+package internal
+
+import "encoding/base64"
+
+// ImportedBy returns how the asset got in the library, the ZIMPORTEDBY value of the Photos database,
+// like 1 for the camera, 0 if iCloud doesn't tell.
+func (r *PhotoAsset) ImportedBy() int {
+	return r._masterRecord.Fields.ImportedBy.Value
+}
+
+// SourceBundleID returns the bundle identifier of the app which saved the asset, like net.whatsapp.WhatsApp,
+// empty if iCloud doesn't tell.
+func (r *PhotoAsset) SourceBundleID() string {
+	return decodeEncField(r._masterRecord.Fields.ImportedByBundleIdentifierEnc.Value)
+}
+
+// SourceAppName returns the name of the app which saved the asset, like WhatsApp, empty if iCloud doesn't tell.
+func (r *PhotoAsset) SourceAppName() string {
+	return decodeEncField(r._masterRecord.Fields.ImportedByDisplayNameEnc.Value)
+}
+
+// decodeEncField decodes the base64 value of an *Enc string field, empty if it's not base64.
+func decodeEncField(v string) string {
+	bs, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return ""
+	}
+	return string(bs)
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// FilenameTemplate lays out the assets in the output dir, it's a text/template of the path of the original,
+// executed with a FilenameTemplateData, like `{{.Date.Format "2006/01"}}/{{.ID}}_{{.Filename}}`,
+// '/' separates the dirs, and the characters not allowed in paths are replaced by '_'.
+type FilenameTemplate struct {
+	text string
+	tmpl *template.Template
+}
+
+// FilenameTemplateData is the asset a FilenameTemplate is executed with.
+type FilenameTemplateData struct {
+	ID               string
+	Filename         string // like IMG_0001.HEIC, it changes when the asset is edited
+	OriginalFilename string
+	Name             string // Filename without the extension
+	Ext              string // like .HEIC
+	Album            string // the album the asset is downloaded from, empty for all photos
+	Date             time.Time
+	CreatedAt        time.Time
+	AddedAt          time.Time
+	Favorite         bool
+	Video            bool
+	Source           string // the app which saved the asset, like WhatsApp, empty if unknown
+}
+
+// ParseFilenameTemplate parses the template, and checks it lays out an asset.
+func ParseFilenameTemplate(text string) (*FilenameTemplate, error) {
+	tmpl, err := template.New("filename").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse filename template failed, err: %w", err)
+	}
+	r := &FilenameTemplate{text: text, tmpl: tmpl}
+	if _, err := r.execute(&FilenameTemplateData{ID: "id", Filename: "IMG_0001.HEIC", Name: "IMG_0001", Ext: ".HEIC"}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *FilenameTemplate) String() string {
+	return r.text
+}
+
+// Path returns the path of the original of the asset downloaded from album, relative to the output dir.
+func (r *FilenameTemplate) Path(asset *PhotoAsset, album string) (string, error) {
+	filename := asset.Filename()
+	ext := filepath.Ext(filename)
+	return r.execute(&FilenameTemplateData{
+		ID:               asset.ID(),
+		Filename:         filename,
+		OriginalFilename: asset.OriginalFilename(),
+		Name:             filename[:len(filename)-len(ext)],
+		Ext:              ext,
+		Album:            album,
+		Date:             asset.AssetDateInZone(),
+		CreatedAt:        asset.Created(),
+		AddedAt:          asset.AddedDate(),
+		Favorite:         asset.IsFavorite(),
+		Video:            asset.IsVideo(),
+		Source:           asset.SourceAppName(),
+	})
+}
+
+func (r *FilenameTemplate) execute(data *FilenameTemplateData) (string, error) {
+	var sb strings.Builder
+	if err := r.tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("execute filename template failed, err: %w", err)
+	}
+
+	// the path can't leave the output dir, whatever the names are
+	var parts []string
+	for _, part := range strings.Split(sb.String(), "/") {
+		part = strings.TrimSpace(cleanPathPart(part))
+		if part != "" && part != "." && part != ".." {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("execute filename template failed, err: empty path")
+	}
+	return path.Join(parts...), nil
+}
+
+// LocalPathWithTemplate is like LocalPath, but lays out the asset downloaded from album with the template.
+func (r *PhotoAsset) LocalPathWithTemplate(outputDir string, size PhotoVersion, tmpl *FilenameTemplate, album string) (string, error) {
+	rel, err := tmpl.Path(r, album)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.FromSlash(rel)
+	return r.LocalPathWithFilename(filepath.Join(outputDir, filepath.Dir(rel)), size, filepath.Base(rel)), nil
+}
+
+// cleanPathPart replaces the characters not allowed in a file name on any platform.
+func cleanPathPart(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+)
+
+// photoVersionThumbnail is the small JPEG of an asset Thumbnail returns, of a photo or of the poster frame of a video,
+// it's not listed by Versions.
+const photoVersionThumbnail PhotoVersion = "thumbnail"
+
+const (
+	defaultThumbnailCacheSize = 32 << 20
+	// maxThumbnailSize guards the memory of a thumbnail, they are some KB
+	maxThumbnailSize = 4 << 20
+)
+
+// Thumbnail returns a small JPEG of the asset, of the poster frame for a video, for a GUI listing many assets.
+//
+// The thumbnails are kept in a LRU cache of the service, see PhotoService.SetThumbnailCacheSize,
+// the bytes returned can be shared with other callers, don't modify them.
+func (r *PhotoAsset) Thumbnail() ([]byte, error) {
+	return r.ThumbnailContext(context.Background())
+}
+
+// ThumbnailContext is like Thumbnail, the download is given up when ctx is done.
+func (r *PhotoAsset) ThumbnailContext(ctx context.Context) ([]byte, error) {
+	detail, ok := r.getVersions()[photoVersionThumbnail]
+	if !ok || detail.URL == "" {
+		return nil, fmt.Errorf("asset %s has no thumbnail", r.Filename())
+	}
+	key := r.ID() + "/" + detail.Checksum
+	if data, ok := r.service.thumbnails.get(key); ok {
+		return data, nil
+	}
+
+	body, _, err := r.openDownload(ctx, photoVersionThumbnail, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxThumbnailSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download thumbnail of %s failed, err: %w", r.Filename(), err)
+	}
+	if len(data) > maxThumbnailSize {
+		return nil, fmt.Errorf("download thumbnail of %s failed, err: larger than %d bytes", r.Filename(), maxThumbnailSize)
+	}
+	r.service.thumbnails.add(key, data)
+	return data, nil
+}
+
+// SetThumbnailCacheSize caps the thumbnails kept in memory by PhotoAsset.Thumbnail to size bytes,
+// the least recently used are dropped first, 0 disables the cache, the default is 32MB.
+func (r *PhotoService) SetThumbnailCacheSize(size int64) {
+	r.thumbnails.setMaxSize(size)
+}
+
+// packThumbnailVersion returns the thumbnail version of the master record, the JPEG thumbnail,
+// or the medium JPEG when there is no thumbnail.
+func (r *PhotoAsset) packThumbnailVersion() map[PhotoVersion]*photoVersionDetail {
+	fields := r._masterRecord.Fields
+	filename := r.Filename()
+	filename = filename[:len(filename)-len(filepath.Ext(filename))] + posterFrameExt
+	if fields.ResJPEGThumbRes.Value.DownloadURL != "" {
+		return map[PhotoVersion]*photoVersionDetail{photoVersionThumbnail: {
+			Filename: filename,
+			Width:    fields.ResJPEGThumbWidth.Value,
+			Height:   fields.ResJPEGThumbHeight.Value,
+			Size:     fields.ResJPEGThumbRes.Value.Size,
+			URL:      fields.ResJPEGThumbRes.Value.DownloadURL,
+			Type:     fields.ResJPEGThumbFileType.Value,
+			Checksum: fields.ResJPEGThumbRes.Value.FileChecksum,
+		}}
+	}
+	if fields.ResJPEGMedRes.Value.DownloadURL != "" {
+		return map[PhotoVersion]*photoVersionDetail{photoVersionThumbnail: {
+			Filename: filename,
+			Width:    fields.ResJPEGMedWidth.Value,
+			Height:   fields.ResJPEGMedHeight.Value,
+			Size:     fields.ResJPEGMedRes.Value.Size,
+			URL:      fields.ResJPEGMedRes.Value.DownloadURL,
+			Type:     fields.ResJPEGMedFileType.Value,
+			Checksum: fields.ResJPEGMedRes.Value.FileChecksum,
+		}}
+	}
+	return nil
+}
+
+// thumbnailCache is a LRU cache of thumbnails, bounded by their total size.
+type thumbnailCache struct {
+	lock    sync.Mutex
+	maxSize int64
+	size    int64
+	order   *list.List // of *thumbnailEntry, the most recently used first
+	entries map[string]*list.Element
+}
+
+type thumbnailEntry struct {
+	key  string
+	data []byte
+}
+
+func newThumbnailCache(maxSize int64) *thumbnailCache {
+	return &thumbnailCache{maxSize: maxSize, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (r *thumbnailCache) get(key string) ([]byte, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	elem, ok := r.entries[key]
+	if !ok {
+		return nil, false
+	}
+	r.order.MoveToFront(elem)
+	return elem.Value.(*thumbnailEntry).data, true
+}
+
+func (r *thumbnailCache) add(key string, data []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if int64(len(data)) > r.maxSize {
+		return
+	}
+	if elem, ok := r.entries[key]; ok {
+		r.size -= int64(len(elem.Value.(*thumbnailEntry).data))
+		r.order.Remove(elem)
+	}
+	r.entries[key] = r.order.PushFront(&thumbnailEntry{key: key, data: data})
+	r.size += int64(len(data))
+	r.evict()
+}
+
+func (r *thumbnailCache) setMaxSize(maxSize int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.maxSize = maxSize
+	r.evict()
+}
+
+// evict drops the least recently used thumbnails until they fit in maxSize, the lock must be held.
+func (r *thumbnailCache) evict() {
+	for r.size > r.maxSize && r.order.Len() > 0 {
+		entry := r.order.Remove(r.order.Back()).(*thumbnailEntry)
+		delete(r.entries, entry.key)
+		r.size -= int64(len(entry.data))
+	}
+}
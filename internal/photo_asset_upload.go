@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,13 +9,35 @@ import (
 )
 
 func (r *PhotoService) Upload(filename string, file io.Reader) (bool, error) {
-	webServiceURL, err := r.icloud.getWebServiceURL("uploadimagews")
+	res, err := r.UploadWithResult(filename, file)
 	if err != nil {
 		return false, err
 	}
+	return res.IsDuplicate, nil
+}
+
+// UploadResult is the result of an upload, PhotoID is the asset record name of the uploaded photo,
+// which can be filed into albums with PhotoAlbum.AddAssetIDs.
+type UploadResult struct {
+	IsDuplicate bool   `json:"isDuplicate"`
+	PhotoID     string `json:"photoId"`
+}
+
+// UploadWithResult is like Upload, but also returns the id of the uploaded photo.
+func (r *PhotoService) UploadWithResult(filename string, file io.Reader) (*UploadResult, error) {
+	return r.UploadWithResultContext(context.Background(), filename, file)
+}
+
+// UploadWithResultContext is like UploadWithResult, the upload is given up when ctx is done.
+func (r *PhotoService) UploadWithResultContext(ctx context.Context, filename string, file io.Reader) (*UploadResult, error) {
+	webServiceURL, err := r.icloud.getWebServiceURL("uploadimagews")
+	if err != nil {
+		return nil, err
+	}
 
-	resp := new(uploadPhotoResp)
+	resp := new(UploadResult)
 	body, err := r.icloud.request(&rawReq{
+		Context: ctx,
 		Method:  http.MethodPost,
 		URL:     webServiceURL + "/upload",
 		Headers: r.icloud.getCommonHeaders(map[string]string{"Content-Type": "text/plain"}),
@@ -22,14 +45,50 @@ func (r *PhotoService) Upload(filename string, file io.Reader) (bool, error) {
 		Body:    file,
 	})
 	if err != nil {
-		return false, fmt.Errorf("upload %s failed: %w", filename, err)
+		return nil, fmt.Errorf("upload %s failed: %w", filename, err)
 	}
 	if err := json.Unmarshal([]byte(body), resp); err != nil {
-		return false, fmt.Errorf("upload %s unmarshal failed: %w", filename, err)
+		return nil, fmt.Errorf("upload %s unmarshal failed: %w", filename, err)
+	}
+	if !resp.IsDuplicate {
+		r.adjustAlbumSizes(map[AlbumID]int{AlbumIDAll: 1})
 	}
-	return resp.IsDuplicate, nil
+	return resp, nil
 }
 
-type uploadPhotoResp struct {
-	IsDuplicate bool `json:"isDuplicate"`
+// UploadOption controls UploadAsset.
+//
+// Album, if set, is the user album the uploaded photo is filed into.
+type UploadOption struct {
+	Album *PhotoAlbum
+}
+
+// UploadAsset uploads the file, and returns the new asset.
+//
+// iCloud takes the whole file in one request, file is streamed, not buffered in memory.
+// A duplicate of an existing photo returns the existing asset.
+func (r *PhotoService) UploadAsset(ctx context.Context, file io.Reader, filename string, option *UploadOption) (*PhotoAsset, error) {
+	if option == nil {
+		option = new(UploadOption)
+	}
+
+	res, err := r.UploadWithResultContext(ctx, filename, file)
+	if err != nil {
+		return nil, err
+	}
+	if res.PhotoID == "" {
+		return nil, fmt.Errorf("upload %s failed: no photo id in the response", filename)
+	}
+
+	if option.Album != nil {
+		if err := option.Album.AddAssetIDs(res.PhotoID); err != nil {
+			return nil, err
+		}
+	}
+
+	asset, err := r.GetAssetByIDContext(ctx, res.PhotoID)
+	if err != nil {
+		return nil, fmt.Errorf("upload %s succeed as %s, but: %w", filename, res.PhotoID, err)
+	}
+	return asset, nil
 }
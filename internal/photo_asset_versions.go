@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"path/filepath"
+)
+
+// AssetVersion is a version of an asset, like the original, the edited rendering or a thumbnail,
+// download it with DownloadTo.
+type AssetVersion struct {
+	Version  PhotoVersion
+	Filename string
+	Width    int
+	Height   int
+	Size     int
+	Type     string // uniform type identifier, like public.heic
+	Checksum string // the file checksum of iCloud, not a hash of the content
+}
+
+// versionOrder is the order of Versions, the full size versions first.
+var versionOrder = []PhotoVersion{
+	PhotoVersionOriginal,
+	PhotoVersionAdjusted,
+	PhotoVersionAlternative,
+	PhotoVersionMedium,
+	PhotoVersionThumb,
+	PhotoVersionLiveOriginal,
+	PhotoVersionLiveMedium,
+	PhotoVersionLiveThumb,
+	PhotoVersionPoster,
+	PhotoVersionPosterThumb,
+}
+
+// Versions returns the versions of the asset which can be downloaded, the original first,
+// then the edited rendering of an edited asset, the other original of a pair, the previews,
+// the paired video of a live photo and the poster frame of a video.
+func (r *PhotoAsset) Versions() []*AssetVersion {
+	var res []*AssetVersion
+	for _, version := range versionOrder {
+		if v, ok := r.Version(version); ok {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// Version returns the version of the asset, ok is false when the asset has no such version,
+// like PhotoVersionAdjusted of an asset which is not edited.
+func (r *PhotoAsset) Version(version PhotoVersion) (v *AssetVersion, ok bool) {
+	detail, ok := r.getVersions()[version]
+	if !ok || detail.URL == "" {
+		return nil, false
+	}
+	return &AssetVersion{
+		Version:  version,
+		Filename: detail.Filename,
+		Width:    detail.Width,
+		Height:   detail.Height,
+		Size:     detail.Size,
+		Type:     detail.Type,
+		Checksum: detail.Checksum,
+	}, true
+}
+
+// typeExts are the extensions of the file types of the versions whose type can differ from the original,
+// like the JPEG rendering of an edited HEIC photo.
+var typeExts = map[string]string{
+	"public.jpeg":               ".JPG",
+	"public.heic":               ".HEIC",
+	"public.png":                ".PNG",
+	"public.tiff":               ".TIF",
+	"com.adobe.raw-image":       ".DNG",
+	"com.apple.quicktime-movie": ".MOV",
+	"public.mpeg-4":             ".MP4",
+}
+
+// versionExt returns the extension of the file of the version, ext when its type is unknown.
+func (r *PhotoAsset) versionExt(version PhotoVersion, ext string) string {
+	if version != PhotoVersionAdjusted && version != PhotoVersionAlternative {
+		return ext
+	}
+	if detail, ok := r.getVersions()[version]; ok {
+		if typeExt, ok := typeExts[detail.Type]; ok {
+			return typeExt
+		}
+	}
+	return ext
+}
+
+// packExtraVersion returns the adjusted version of the asset record, and the alternative version of the master record.
+func (r *PhotoAsset) packExtraVersion() map[PhotoVersion]*photoVersionDetail {
+	versions := map[PhotoVersion]*photoVersionDetail{}
+	filename := r.Filename()
+	base := filename[:len(filename)-len(filepath.Ext(filename))]
+	withExt := func(fileType string) string {
+		if ext, ok := typeExts[fileType]; ok {
+			return base + ext
+		}
+		return filename
+	}
+
+	if r._assetRecord != nil {
+		fields := r._assetRecord.Fields
+		if r.IsVideo() && fields.ResVidFullRes.Value.DownloadURL != "" {
+			versions[PhotoVersionAdjusted] = &photoVersionDetail{
+				Filename: withExt(fields.ResVidFullFileType.Value),
+				Width:    fields.ResVidFullWidth.Value,
+				Height:   fields.ResVidFullHeight.Value,
+				Size:     fields.ResVidFullRes.Value.Size,
+				URL:      fields.ResVidFullRes.Value.DownloadURL,
+				Type:     fields.ResVidFullFileType.Value,
+				Checksum: fields.ResVidFullRes.Value.FileChecksum,
+			}
+		} else if !r.IsVideo() && fields.ResJPEGFullRes.Value.DownloadURL != "" {
+			versions[PhotoVersionAdjusted] = &photoVersionDetail{
+				Filename: withExt(fields.ResJPEGFullFileType.Value),
+				Width:    fields.ResJPEGFullWidth.Value,
+				Height:   fields.ResJPEGFullHeight.Value,
+				Size:     fields.ResJPEGFullRes.Value.Size,
+				URL:      fields.ResJPEGFullRes.Value.DownloadURL,
+				Type:     fields.ResJPEGFullFileType.Value,
+				Checksum: fields.ResJPEGFullRes.Value.FileChecksum,
+			}
+		}
+	}
+
+	fields := r._masterRecord.Fields
+	if fields.ResOriginalAltRes.Value.DownloadURL != "" {
+		versions[PhotoVersionAlternative] = &photoVersionDetail{
+			Filename: withExt(fields.ResOriginalAltFileType.Value),
+			Width:    fields.ResOriginalAltWidth.Value,
+			Height:   fields.ResOriginalAltHeight.Value,
+			Size:     fields.ResOriginalAltRes.Value.Size,
+			URL:      fields.ResOriginalAltRes.Value.DownloadURL,
+			Type:     fields.ResOriginalAltFileType.Value,
+			Checksum: fields.ResOriginalAltRes.Value.FileChecksum,
+		}
+	}
+	return versions
+}
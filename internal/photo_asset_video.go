@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"strings"
+	"time"
+)
+
+// IsVideo reports whether the asset is a video, live photos are not videos.
+func (r *PhotoAsset) IsVideo() bool {
+	itemType := strings.ToLower(r._masterRecord.Fields.ItemType.Value)
+	return strings.Contains(itemType, "movie") || strings.Contains(itemType, "mpeg") || strings.Contains(itemType, "video")
+}
+
+// VideoDuration returns the duration of a video asset, 0 for photos.
+func (r *PhotoAsset) VideoDuration() time.Duration {
+	for _, record := range []*photoRecord{r._assetRecord, r._masterRecord} {
+		if record != nil && record.Fields.Duration.Value > 0 {
+			return time.Duration(record.Fields.Duration.Value) * time.Second
+		}
+	}
+	return 0
+}
+
+// VideoFrameRate returns the frames per second of a video asset, 0 if unknown.
+func (r *PhotoAsset) VideoFrameRate() float64 {
+	for _, record := range []*photoRecord{r._masterRecord, r._assetRecord} {
+		if record != nil && record.Fields.VideoFrameRate.Value > 0 {
+			return record.Fields.VideoFrameRate.Value
+		}
+	}
+	return 0
+}
+
+// VideoCodec returns the codec of a video asset, like "hvc1" or "avc1", empty if unknown.
+func (r *PhotoAsset) VideoCodec() string {
+	for _, record := range []*photoRecord{r._masterRecord, r._assetRecord} {
+		if record != nil && record.Fields.Codec.Value != "" {
+			return record.Fields.Codec.Value
+		}
+	}
+	return ""
+}
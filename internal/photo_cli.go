@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"sync"
 )
@@ -10,18 +11,28 @@ type PhotoService struct {
 	serviceRoot     string
 	serviceEndpoint string
 	querys          map[string]string
+	zone            *PhotoZone
 
-	_albums map[string]*PhotoAlbum
-	lock    *sync.Mutex
+	_albums    map[string]*PhotoAlbum
+	events     EventSink
+	parallel   *ParallelDownloadOption
+	thumbnails *thumbnailCache
+	lock       *sync.Mutex
 }
 
 func (r *Client) PhotoCli() (*PhotoService, error) {
+	r.photoLock.Lock()
+	defer r.photoLock.Unlock()
+
 	if r.photo == nil {
+		if err := r.checkWebAccess(); err != nil {
+			return nil, err
+		}
 		ckDatabaseWS, err := r.getWebServiceURL("ckdatabasews")
 		if err != nil {
 			return nil, err
 		}
-		r.photo, err = newPhotoService(r, ckDatabaseWS)
+		r.photo, err = newPhotoService(r, ckDatabaseWS, PhotoZonePrimary)
 		if err != nil {
 			return nil, err
 		}
@@ -29,18 +40,40 @@ func (r *Client) PhotoCli() (*PhotoService, error) {
 	return r.photo, nil
 }
 
-func newPhotoService(icloud *Client, serviceRoot string) (*PhotoService, error) {
+// PhotoCliWithZone returns a PhotoService bound to zone, like a shared library listed by PhotoService.Zones.
+//
+// Unlike PhotoCli, the service is not cached by the client.
+func (r *Client) PhotoCliWithZone(zone *PhotoZone) (*PhotoService, error) {
+	if zone == nil || zone.key() == PhotoZonePrimary.key() {
+		return r.PhotoCli()
+	}
+	if err := validateQueryIdentifier(zone.Name); err != nil {
+		return nil, err
+	}
+	if err := r.checkWebAccess(); err != nil {
+		return nil, err
+	}
+	ckDatabaseWS, err := r.getWebServiceURL("ckdatabasews")
+	if err != nil {
+		return nil, err
+	}
+	return newPhotoService(r, ckDatabaseWS, zone)
+}
+
+func newPhotoService(icloud *Client, serviceRoot string, zone *PhotoZone) (*PhotoService, error) {
 	photoCli := &PhotoService{
 		icloud:          icloud,
 		serviceRoot:     serviceRoot,
-		serviceEndpoint: fmt.Sprintf("%s/database/1/com.apple.photos.cloud/production/private", serviceRoot),
+		serviceEndpoint: fmt.Sprintf("%s/database/1/com.apple.photos.cloud/production/%s", serviceRoot, zone.database()),
 		querys:          map[string]string{"remapEnums": "true", "getCurrentSyncToken": "true"},
+		zone:            zone,
 
-		_albums: map[string]*PhotoAlbum{},
-		lock:    new(sync.Mutex),
+		_albums:    map[string]*PhotoAlbum{},
+		thumbnails: newThumbnailCache(defaultThumbnailCacheSize),
+		lock:       new(sync.Mutex),
 	}
 
-	if err := photoCli.checkPhotoServiceState(); err != nil {
+	if err := photoCli.checkPhotoServiceState(context.Background()); err != nil {
 		return nil, err
 	}
 
@@ -1,20 +1,28 @@
 package internal
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 )
 
-func (r *PhotoService) checkPhotoServiceState() error {
+func (r *PhotoService) checkPhotoServiceState(ctx context.Context) error {
 	text, err := r.icloud.request(&rawReq{
+		Context: ctx,
 		Method:  http.MethodPost,
 		URL:     fmt.Sprintf("%s/records/query", r.serviceEndpoint),
-		Body:    `{"query":{"recordType":"CheckIndexingState"},"zoneID":{"zoneName":"PrimarySync"}}`,
-		Headers: r.icloud.getCommonHeaders(map[string]string{"Content-type": "text/plain"}),
+		Body: map[string]any{
+			"query":  map[string]any{"recordType": "CheckIndexingState"},
+			"zoneID": r.zoneID(),
+		},
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
 		Querys:  r.querys,
 	})
 	if err != nil {
+		if webErr := r.icloud.webAccessError(err); webErr != nil {
+			return webErr
+		}
 		return fmt.Errorf("checkPhotoServiceState failed, err: %w", err)
 	}
 	res := new(getPhotoDatabaseResp)
@@ -0,0 +1,41 @@
+package internal
+
+// EventSink is told what a PhotoService does, for a GUI or web frontend to drive its own progress UI,
+// set it with PhotoService.SetEventSink. The methods are called from the goroutines iterating and downloading,
+// they should return quickly, and be safe for concurrent use. Embed NopEventSink to implement only some of them.
+type EventSink interface {
+	// AssetDiscovered is called for every asset an album iterator returns, after its filters
+	AssetDiscovered(album *PhotoAlbum, asset *PhotoAsset)
+	// DownloadStarted is called when a version of an asset starts downloading to target, by the DownloadTo methods
+	DownloadStarted(asset *PhotoAsset, version PhotoVersion, target string)
+	// DownloadFinished is called when the version of the asset is saved to target
+	DownloadFinished(asset *PhotoAsset, version PhotoVersion, target string)
+	// Error is called when listing an album or discovering the smart albums, or downloading an asset, fails, asset is nil for listing
+	Error(asset *PhotoAsset, err error)
+}
+
+// NopEventSink ignores every event.
+type NopEventSink struct{}
+
+func (NopEventSink) AssetDiscovered(*PhotoAlbum, *PhotoAsset)           {}
+func (NopEventSink) DownloadStarted(*PhotoAsset, PhotoVersion, string)  {}
+func (NopEventSink) DownloadFinished(*PhotoAsset, PhotoVersion, string) {}
+func (NopEventSink) Error(*PhotoAsset, error)                           {}
+
+// SetEventSink sets the sink of the events of the service, nil removes it.
+// The services of PhotoCliWithZone have their own sink.
+func (r *PhotoService) SetEventSink(sink EventSink) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events = sink
+}
+
+// eventSink returns the sink of the events of the service, NopEventSink without one.
+func (r *PhotoService) eventSink() EventSink {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.events == nil {
+		return NopEventSink{}
+	}
+	return r.events
+}
@@ -1,17 +1,25 @@
 package internal
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 )
 
-func (r *PhotoService) getFolders() ([]*folderRecord, error) {
+func (r *PhotoService) getFolders(ctx context.Context) ([]*folderRecord, error) {
 	text, err := r.icloud.request(&rawReq{
+		Context: ctx,
 		Method:  http.MethodPost,
 		URL:     r.serviceEndpoint + "/records/query",
-		Headers: r.icloud.getCommonHeaders(map[string]string{"Content-type": "text/plain"}),
-		Body:    `{"query":{"recordType":"CPLAlbumByPositionLive"},"zoneID":{"zoneName":"PrimarySync"}}`,
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		Body: map[string]any{
+			"query":  map[string]any{"recordType": "CPLAlbumByPositionLive"},
+			"zoneID": r.zoneID(),
+		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("getFolders failed, err: %w", err)
@@ -63,6 +71,7 @@ type folderRecord struct {
 		} `json:"importedByBundleIdentifierEnc,omitempty"`
 		AlbumNameEnc *folderTypeValue `json:"albumNameEnc,omitempty"`
 		IsDeleted    *folderTypeValue `json:"isDeleted,omitempty"`
+		ParentID     *folderTypeValue `json:"parentId,omitempty"`
 	} `json:"fields"`
 	PluginFields    struct{} `json:"pluginFields"`
 	RecordChangeTag string   `json:"recordChangeTag"`
@@ -84,7 +93,144 @@ type folderRecord struct {
 	} `json:"zoneID"`
 }
 
+const rootFolderRecordName = "----Root-Folder----"
+
+func (r *folderRecord) name() string {
+	if r.Fields.AlbumNameEnc == nil {
+		return ""
+	}
+	s, _ := r.Fields.AlbumNameEnc.Value.(string)
+	bs, _ := base64.StdEncoding.DecodeString(s)
+	return string(bs)
+}
+
+func (r *folderRecord) parentID() string {
+	if r.Fields.ParentID == nil {
+		return ""
+	}
+	s, _ := r.Fields.ParentID.Value.(string)
+	return s
+}
+
+// resolveFolderPaths walks the parentId links of every folder, and returns the
+// "Parent/Child/Album" path of each folder, keyed by record name.
+func resolveFolderPaths(folders []*folderRecord) map[string]string {
+	byID := map[string]*folderRecord{}
+	for _, folder := range folders {
+		byID[folder.RecordName] = folder
+	}
+
+	res := map[string]string{}
+	for _, folder := range folders {
+		var names []string
+		visited := newSet[string]()
+		for cur := folder; cur != nil && cur.RecordName != rootFolderRecordName; cur = byID[cur.parentID()] {
+			if visited.Has(cur.RecordName) {
+				break
+			}
+			visited.Add(cur.RecordName)
+			names = append([]string{cur.name()}, names...)
+		}
+		res[folder.RecordName] = strings.Join(names, "/")
+	}
+	return res
+}
+
 type folderTypeValue struct {
 	Value any    `json:"value"`
 	Type  string `json:"type"`
 }
+
+// folderAlbumType is the albumType of a folder record, a folder holds albums and folders, not assets.
+const folderAlbumType = 3
+
+// AlbumNode is an album or a folder of the album hierarchy of the Photos app, see PhotoService.AlbumTree.
+type AlbumNode struct {
+	ID       AlbumID
+	Name     string
+	Album    *PhotoAlbum  // nil for a folder and the root
+	Children []*AlbumNode // the albums and folders in the folder, in the order of the Photos app
+}
+
+// IsFolder reports whether the node is a folder, or the root, not an album.
+func (r *AlbumNode) IsFolder() bool {
+	return r.Album == nil
+}
+
+// Walk calls fn with each node under the node, parents before their children, and the names of the folders above it.
+func (r *AlbumNode) Walk(fn func(node *AlbumNode, folders []string)) {
+	r.walk(nil, fn)
+}
+
+func (r *AlbumNode) walk(folders []string, fn func(node *AlbumNode, folders []string)) {
+	for _, child := range r.Children {
+		fn(child, folders)
+		if child.IsFolder() {
+			child.walk(append(folders[:len(folders):len(folders)], child.Name), fn)
+		}
+	}
+}
+
+// AlbumTree returns the user albums nested in their folders, unlike Albums, which is keyed by name,
+// it keeps the albums of the same name in different folders. The root is a folder without a name,
+// the smart albums, like Favorites, are not in the tree.
+func (r *PhotoService) AlbumTree() (*AlbumNode, error) {
+	return r.AlbumTreeContext(context.Background())
+}
+
+// AlbumTreeContext is like AlbumTree, the album list requests are given up when ctx is done.
+func (r *PhotoService) AlbumTreeContext(ctx context.Context) (*AlbumNode, error) {
+	albums, err := r.AlbumsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := r.getFolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	paths := resolveFolderPaths(folders)
+
+	root := &AlbumNode{}
+	nodes := map[string]*AlbumNode{rootFolderRecordName: root}
+	positions := map[*AlbumNode]int{}
+	for _, folder := range folders {
+		if folder.RecordName == rootFolderRecordName || folder.name() == "" {
+			continue
+		}
+		if folder.Fields.IsDeleted != nil && folder.Fields.IsDeleted.Value != "" {
+			continue
+		}
+		node := &AlbumNode{ID: AlbumID(folder.RecordName), Name: folder.name()}
+		if folder.Fields.AlbumType.Value != folderAlbumType {
+			// the album of Albums, unless another album of the same name took its place there
+			album := albums[node.Name]
+			if album == nil || album.ID() != node.ID {
+				if album, err = r.newUserAlbum(folder.RecordName, node.Name, paths[folder.RecordName]); err != nil {
+					continue
+				}
+			}
+			node.Album = album
+		}
+		nodes[folder.RecordName] = node
+		positions[node] = folder.Fields.Position.Value
+	}
+
+	for _, folder := range folders {
+		node, ok := nodes[folder.RecordName]
+		if !ok || node == root {
+			continue
+		}
+		// an album whose folder is gone is shown at the top, like the Photos app does
+		parent, ok := nodes[folder.parentID()]
+		if !ok || !parent.IsFolder() {
+			parent = root
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	for _, node := range nodes {
+		sort.SliceStable(node.Children, func(i, j int) bool {
+			return positions[node.Children[i]] < positions[node.Children[j]]
+		})
+	}
+	return root, nil
+}
@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Moment is a group of assets taken close in time and place, like the moments of the Photos app,
+// iCloud.com doesn't list the moments of Photos, they are grouped on the client.
+type Moment struct {
+	// Start and End are the asset dates of the first and last asset
+	Start time.Time
+	End   time.Time
+	// Assets are in the order of the iterator
+	Assets []*PhotoAsset
+}
+
+// MomentOption is when the next asset starts a new moment.
+type MomentOption struct {
+	// MaxGap is the longest time between two assets of a moment
+	MaxGap time.Duration
+	// MaxDistance is the longest distance in meters between two assets of a moment, when both have a location,
+	// 0 ignores the locations
+	MaxDistance float64
+}
+
+// DefaultMomentOption is used when the option of NewMomentIter is nil.
+var DefaultMomentOption = &MomentOption{
+	MaxGap:      3 * time.Hour,
+	MaxDistance: 5000,
+}
+
+// MomentIter groups the assets of an iterator into moments.
+type MomentIter struct {
+	iter   AssetIterator
+	option *MomentOption
+	next   *PhotoAsset
+	end    bool
+}
+
+// NewMomentIter returns an iterator of the moments of the assets of iter, which must list them by asset date,
+// newest or oldest first, like PhotosIter of the albums of the library does.
+func NewMomentIter(iter AssetIterator, option *MomentOption) *MomentIter {
+	if option == nil {
+		option = DefaultMomentOption
+	}
+	return &MomentIter{iter: iter, option: option}
+}
+
+// Next returns the next moment, once all its assets are listed, or ErrPhotosIterateEnd after the last moment.
+func (r *MomentIter) Next() (*Moment, error) {
+	if r.end {
+		return nil, ErrPhotosIterateEnd
+	}
+	var moment *Moment
+	for {
+		asset := r.next
+		r.next = nil
+		if asset == nil {
+			var err error
+			asset, err = r.iter.Next()
+			if errors.Is(err, ErrPhotosIterateEnd) {
+				r.end = true
+				if moment == nil {
+					return nil, ErrPhotosIterateEnd
+				}
+				return moment, nil
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		if moment == nil {
+			moment = &Moment{Start: asset.AssetDate(), End: asset.AssetDate(), Assets: []*PhotoAsset{asset}}
+			continue
+		}
+		if !r.isSameMoment(moment.Assets[len(moment.Assets)-1], asset) {
+			r.next = asset
+			return moment, nil
+		}
+		moment.Assets = append(moment.Assets, asset)
+		if date := asset.AssetDate(); date.Before(moment.Start) {
+			moment.Start = date
+		} else if date.After(moment.End) {
+			moment.End = date
+		}
+	}
+}
+
+func (r *MomentIter) isSameMoment(prev, asset *PhotoAsset) bool {
+	gap := asset.AssetDate().Sub(prev.AssetDate())
+	if gap < 0 {
+		gap = -gap
+	}
+	if gap > r.option.MaxGap {
+		return false
+	}
+	if r.option.MaxDistance <= 0 {
+		return true
+	}
+	lat1, lon1, ok1 := prev.Location()
+	lat2, lon2, ok2 := asset.Location()
+	return !ok1 || !ok2 || distanceMeters(lat1, lon1, lat2, lon2) <= r.option.MaxDistance
+}
+
+// distanceMeters returns the great-circle distance between two points.
+func distanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000
+	rad := math.Pi / 180
+	dLat, dLon := (lat2-lat1)*rad, (lon2-lon1)*rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
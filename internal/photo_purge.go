@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PurgeOption selects which assets PurgeRecentlyDeleted expunges,
+// an asset is expunged when it matches OlderThan or Verified.
+type PurgeOption struct {
+	// OlderThan expunges assets deleted at least this long ago, 0 disables it.
+	// The deletion time is the last time the asset was modified.
+	OlderThan time.Duration
+	// Verified expunges assets it returns true for, like assets already kept in a local archive.
+	Verified func(asset *PhotoAsset) bool
+	// DryRun only reports the assets, without expunging them.
+	DryRun bool
+	// OnPurge is called for every asset expunged, or would be expunged with DryRun.
+	OnPurge func(asset *PhotoAsset)
+}
+
+// PurgeRecentlyDeleted permanently deletes assets of the Recently Deleted album selected by option,
+// and returns how many assets are expunged.
+func (r *PhotoService) PurgeRecentlyDeleted(option *PurgeOption) (int, error) {
+	if option == nil || (option.OlderThan <= 0 && option.Verified == nil) {
+		return 0, fmt.Errorf("purge recently deleted failed, err: no OlderThan or Verified set")
+	}
+
+	album, err := r.GetAlbumByID(AlbumIDRecentlyDeleted)
+	if err != nil {
+		return 0, err
+	}
+
+	// collect first, expunging while iterating shifts the offsets of the album
+	var assets []*PhotoAsset
+	iter := album.PhotosIter()
+	for {
+		asset, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, ErrPhotosIterateEnd) {
+				break
+			}
+			return 0, err
+		}
+		olderThan := option.OlderThan > 0 && time.Since(asset.Modified()) >= option.OlderThan
+		if olderThan || (option.Verified != nil && option.Verified(asset)) {
+			assets = append(assets, asset)
+		}
+	}
+
+	count := 0
+	for _, asset := range assets {
+		if !option.DryRun {
+			if err := asset.Expunge(); err != nil {
+				return count, err
+			}
+		}
+		count++
+		if option.OnPurge != nil {
+			option.OnPurge(asset)
+		}
+	}
+	return count, nil
+}
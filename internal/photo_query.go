@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	ErrInvalidQueryIdentifier = NewError("invalid_query_identifier", "invalid query identifier")
+	ErrInvalidQueryFilter     = NewError("invalid_query_filter", "invalid query filter")
+)
+
+var queryIdentifierRegexp = regexp.MustCompile(`^[A-Za-z0-9_\-.]+$`)
+
+var (
+	queryComparators = newSet("EQUALS", "NOT_EQUALS", "IN", "NOT_IN", "LESS_THAN", "LESS_THAN_OR_EQUALS", "GREATER_THAN", "GREATER_THAN_OR_EQUALS", "BEGINS_WITH")
+	queryValueTypes  = newSet("STRING", "STRING_LIST", "INT64", "INT64_LIST", "TIMESTAMP", "REFERENCE")
+)
+
+// validateQueryIdentifier checks a server-provided record name, zone name or field name
+// before it is embedded in a query.
+func validateQueryIdentifier(s string) error {
+	if !queryIdentifierRegexp.MatchString(s) {
+		return fmt.Errorf("%w: %q", ErrInvalidQueryIdentifier, s)
+	}
+	return nil
+}
+
+// buildObjType joins an index prefix and a record name, like "CPLContainerRelationNotDeletedByAssetDate:<folder id>".
+func buildObjType(prefix, id string) (string, error) {
+	if err := validateQueryIdentifier(prefix); err != nil {
+		return "", err
+	}
+	if err := validateQueryIdentifier(id); err != nil {
+		return "", err
+	}
+	return prefix + ":" + id, nil
+}
+
+func newQueryFilter(fieldName, comparator, valueType string, value any) (*folderMetaDataQueryFilter, error) {
+	filter := &folderMetaDataQueryFilter{
+		FieldName:  fieldName,
+		Comparator: comparator,
+		FieldValue: &folderTypeValue{Type: valueType, Value: value},
+	}
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func (r *folderMetaDataQueryFilter) validate() error {
+	if r == nil {
+		return fmt.Errorf("%w: nil filter", ErrInvalidQueryFilter)
+	}
+	if err := validateQueryIdentifier(r.FieldName); err != nil {
+		return fmt.Errorf("%w: field name: %s", ErrInvalidQueryFilter, err)
+	}
+	if !queryComparators.Has(r.Comparator) {
+		return fmt.Errorf("%w: %s: unknown comparator %q", ErrInvalidQueryFilter, r.FieldName, r.Comparator)
+	}
+	if r.FieldValue == nil || !queryValueTypes.Has(r.FieldValue.Type) {
+		return fmt.Errorf("%w: %s: unknown value type", ErrInvalidQueryFilter, r.FieldName)
+	}
+	if s, ok := r.FieldValue.Value.(string); ok && r.FieldValue.Type == "STRING" && s == "" {
+		return fmt.Errorf("%w: %s: empty value", ErrInvalidQueryFilter, r.FieldName)
+	}
+	return nil
+}
+
+func validateQueryFilters(filters []*folderMetaDataQueryFilter) error {
+	for _, filter := range filters {
+		if err := filter.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
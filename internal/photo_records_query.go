@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// QueryCursor is the position of a records query, pass the cursor RecordsQuery returns
+// as RecordsQueryRequest.Cursor for the next page, an empty cursor means there is no more.
+type QueryCursor string
+
+// RecordsQueryRequest is a raw records/query request against the photo library of the service,
+// for queries the high-level helpers don't cover.
+//
+// Filters and field names are validated like those of the helpers.
+// DesiredKeys nil asks for the fields the photo helpers use, ResultsLimit 0 lets the server decide.
+type RecordsQueryRequest struct {
+	RecordType   string
+	Filters      []*QueryFilter
+	SortBy       []*QuerySort
+	DesiredKeys  []string
+	ResultsLimit int
+	Cursor       QueryCursor
+}
+
+// QueryFilter is one filterBy item, like {FieldName: "startRank", Comparator: "EQUALS", Type: "INT64", Value: 0}.
+type QueryFilter struct {
+	FieldName  string
+	Comparator string
+	Type       string
+	Value      any
+}
+
+// QuerySort is one sortBy item.
+type QuerySort struct {
+	FieldName string
+	Ascending bool
+}
+
+// RecordsQueryResponse is one page of records.
+type RecordsQueryResponse struct {
+	Records   []*Record
+	SyncToken string
+}
+
+// Record is a raw CloudKit record.
+type Record struct {
+	RecordName      string                  `json:"recordName"`
+	RecordType      string                  `json:"recordType"`
+	RecordChangeTag string                  `json:"recordChangeTag"`
+	Fields          map[string]*RecordField `json:"fields"`
+	Created         RecordTimestamp         `json:"created"`
+	Modified        RecordTimestamp         `json:"modified"`
+	Deleted         bool                    `json:"deleted"`
+}
+
+// RecordField is a raw field of a record, decode Value with Decode.
+type RecordField struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// RecordTimestamp is when and by which device a record was created or modified.
+type RecordTimestamp struct {
+	Timestamp int64  `json:"timestamp"`
+	DeviceID  string `json:"deviceID"`
+}
+
+// Time returns the timestamp as a time.Time.
+func (r RecordTimestamp) Time() time.Time {
+	return time.UnixMilli(r.Timestamp)
+}
+
+// Field returns the field of the record, nil if the record doesn't have it.
+func (r *Record) Field(name string) *RecordField {
+	return r.Fields[name]
+}
+
+// Decode unmarshals the field value into v, like a string for STRING, or an int64 for INT64 and TIMESTAMP.
+func (r *RecordField) Decode(v any) error {
+	if r == nil {
+		return fmt.Errorf("decode field failed, err: field not found")
+	}
+	if err := json.Unmarshal(r.Value, v); err != nil {
+		return fmt.Errorf("decode %s field failed, err: %w", r.Type, err)
+	}
+	return nil
+}
+
+// RecordsQuery runs a raw records/query request, it returns the page of records,
+// and the cursor of the next page, empty on the last page.
+func (r *PhotoService) RecordsQuery(ctx context.Context, req *RecordsQueryRequest) (*RecordsQueryResponse, QueryCursor, error) {
+	body, err := req.body(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("records query failed, err: %w", err)
+	}
+	var records []*Record
+	page, err := requestRecords(r.icloud, &rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     fmt.Sprintf("%s/records/query", r.serviceEndpoint),
+		Querys:  r.querys,
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		Body:    body,
+	}, func(record *Record) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("records query failed, err: %w", err)
+	}
+	return &RecordsQueryResponse{Records: records, SyncToken: page.SyncToken}, QueryCursor(page.ContinuationMarker), nil
+}
+
+func (r *RecordsQueryRequest) body(service *PhotoService) (map[string]any, error) {
+	if r == nil {
+		return nil, fmt.Errorf("%w: nil request", ErrInvalidQueryFilter)
+	}
+	if err := validateQueryIdentifier(r.RecordType); err != nil {
+		return nil, err
+	}
+	filters := make([]*folderMetaDataQueryFilter, 0, len(r.Filters))
+	for _, filter := range r.Filters {
+		if filter == nil {
+			return nil, fmt.Errorf("%w: nil filter", ErrInvalidQueryFilter)
+		}
+		queryFilter, err := newQueryFilter(filter.FieldName, filter.Comparator, filter.Type, filter.Value)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, queryFilter)
+	}
+	sortBy := make([]map[string]any, 0, len(r.SortBy))
+	for _, sort := range r.SortBy {
+		if err := validateQueryIdentifier(sort.FieldName); err != nil {
+			return nil, err
+		}
+		sortBy = append(sortBy, map[string]any{"fieldName": sort.FieldName, "ascending": sort.Ascending})
+	}
+	desiredKeys := r.DesiredKeys
+	if desiredKeys == nil {
+		desiredKeys = photoDesiredKeys
+	}
+	for _, key := range desiredKeys {
+		if err := validateQueryIdentifier(key); err != nil {
+			return nil, err
+		}
+	}
+
+	query := map[string]any{"recordType": r.RecordType}
+	if len(filters) > 0 {
+		query["filterBy"] = filters
+	}
+	if len(sortBy) > 0 {
+		query["sortBy"] = sortBy
+	}
+	body := map[string]any{
+		"query":       query,
+		"desiredKeys": desiredKeys,
+		"zoneID":      service.zoneID(),
+	}
+	if r.ResultsLimit > 0 {
+		body["resultsLimit"] = r.ResultsLimit
+	}
+	if r.Cursor != "" {
+		body["continuationMarker"] = string(r.Cursor)
+	}
+	return body, nil
+}
@@ -0,0 +1,353 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SharedAlbum is a shared album (a CPLSharedStream), listed by the sharedstreams web service,
+// apart from the albums of the private library.
+type SharedAlbum struct {
+	service *PhotoService
+	root    string
+
+	ID        string
+	Name      string
+	OwnerName string
+	IsOwned   bool
+	Members   []*SharedAlbumMember
+}
+
+// SharedAlbumMember is a subscriber of a shared album.
+type SharedAlbumMember struct {
+	FirstName string
+	LastName  string
+	Email     string
+	// Accepted is false while the invitation is pending
+	Accepted bool
+}
+
+// SharedAsset is a photo or video of a shared album, it's a resized copy, not the original of the owner.
+type SharedAsset struct {
+	album *SharedAlbum
+
+	ID              string
+	Caption         string
+	Created         time.Time
+	ContributorName string
+	IsVideo         bool
+	derivatives     map[string]*sharedAssetDerivative
+}
+
+// SharedAssetComment is a comment on a shared asset.
+type SharedAssetComment struct {
+	AuthorName string
+	Text       string
+	Created    time.Time
+}
+
+// SharedAlbums lists the shared albums the account owns or subscribes to.
+func (r *PhotoService) SharedAlbums() ([]*SharedAlbum, error) {
+	return r.SharedAlbumsContext(context.Background())
+}
+
+// SharedAlbumsContext is like SharedAlbums, the request is given up when ctx is done.
+func (r *PhotoService) SharedAlbumsContext(ctx context.Context) ([]*SharedAlbum, error) {
+	root, err := r.sharedStreamsRoot()
+	if err != nil {
+		return nil, fmt.Errorf("get shared albums failed, err: %w", err)
+	}
+	res := new(sharedStreamsResp)
+	if err := r.sharedStreamsRequest(ctx, root, "webgetalldata", map[string]any{}, res); err != nil {
+		return nil, fmt.Errorf("get shared albums failed, err: %w", err)
+	}
+
+	albums := make([]*SharedAlbum, 0, len(res.Streams))
+	for _, stream := range res.Streams {
+		album := &SharedAlbum{
+			service:   r,
+			root:      root,
+			ID:        stream.StreamGUID,
+			Name:      stream.Name,
+			OwnerName: strings.TrimSpace(stream.OwnerFirstName + " " + stream.OwnerLastName),
+			IsOwned:   stream.IsOwned,
+		}
+		for _, subscriber := range stream.Subscribers {
+			album.Members = append(album.Members, &SharedAlbumMember{
+				FirstName: subscriber.FirstName,
+				LastName:  subscriber.LastName,
+				Email:     subscriber.Email,
+				Accepted:  subscriber.SubscriptionState == "accepted",
+			})
+		}
+		albums = append(albums, album)
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i].Name < albums[j].Name })
+	return albums, nil
+}
+
+// GetSharedAlbum finds a shared album by name.
+func (r *PhotoService) GetSharedAlbum(albumName string) (*SharedAlbum, error) {
+	albums, err := r.SharedAlbums()
+	if err != nil {
+		return nil, err
+	}
+	for _, album := range albums {
+		if album.Name == albumName {
+			return album, nil
+		}
+	}
+	return nil, fmt.Errorf("shared album %s not found", albumName)
+}
+
+// Assets lists the assets of the shared album, oldest first.
+func (r *SharedAlbum) Assets() ([]*SharedAsset, error) {
+	return r.AssetsContext(context.Background())
+}
+
+// AssetsContext is like Assets, the request is given up when ctx is done.
+func (r *SharedAlbum) AssetsContext(ctx context.Context) ([]*SharedAsset, error) {
+	res := new(sharedStreamResp)
+	body := map[string]any{"streamGuid": r.ID, "streamCtag": nil}
+	if err := r.service.sharedStreamsRequest(ctx, r.root, "webstream", body, res); err != nil {
+		return nil, fmt.Errorf("get shared album %s assets failed, err: %w", r.Name, err)
+	}
+
+	assets := make([]*SharedAsset, 0, len(res.Photos))
+	for _, photo := range res.Photos {
+		created, _ := time.Parse(time.RFC3339, photo.DateCreated)
+		assets = append(assets, &SharedAsset{
+			album:           r,
+			ID:              photo.PhotoGUID,
+			Caption:         photo.Caption,
+			Created:         created,
+			ContributorName: strings.TrimSpace(photo.ContributorFirstName + " " + photo.ContributorLastName),
+			IsVideo:         photo.MediaAssetType == "video",
+			derivatives:     photo.Derivatives,
+		})
+	}
+	sort.SliceStable(assets, func(i, j int) bool { return assets[i].Created.Before(assets[j].Created) })
+	return assets, nil
+}
+
+// Album returns the shared album of the asset.
+func (r *SharedAsset) Album() *SharedAlbum {
+	return r.album
+}
+
+// Filename returns a filename for the asset, shared assets don't keep the original filename.
+func (r *SharedAsset) Filename() string {
+	ext := ".jpg"
+	if r.IsVideo {
+		ext = ".mp4"
+	}
+	return cleanName(r.ID) + ext
+}
+
+// LocalPath returns the path of the asset in outputDir.
+func (r *SharedAsset) LocalPath(outputDir string) string {
+	return filepath.Join(outputDir, r.Filename())
+}
+
+// Size returns the size in bytes of the largest derivative, the one Download fetches.
+func (r *SharedAsset) Size() int {
+	if derivative := r.largestDerivative(); derivative != nil {
+		return derivative.size()
+	}
+	return 0
+}
+
+// largestDerivative returns the largest version of the asset the service has,
+// the poster frame of a video is skipped.
+func (r *SharedAsset) largestDerivative() *sharedAssetDerivative {
+	var res *sharedAssetDerivative
+	for key, derivative := range r.derivatives {
+		if r.IsVideo && key == "PosterFrame" {
+			continue
+		}
+		if res == nil || derivative.size() > res.size() {
+			res = derivative
+		}
+	}
+	return res
+}
+
+// Comments lists the comments on the asset, oldest first.
+func (r *SharedAsset) Comments() ([]*SharedAssetComment, error) {
+	return r.CommentsContext(context.Background())
+}
+
+// CommentsContext is like Comments, the request is given up when ctx is done.
+func (r *SharedAsset) CommentsContext(ctx context.Context) ([]*SharedAssetComment, error) {
+	res := new(sharedCommentsResp)
+	body := map[string]any{"streamGuid": r.album.ID, "photoGuid": r.ID}
+	if err := r.album.service.sharedStreamsRequest(ctx, r.album.root, "webgetcomments", body, res); err != nil {
+		return nil, fmt.Errorf("get shared asset %s comments failed, err: %w", r.ID, err)
+	}
+
+	comments := make([]*SharedAssetComment, 0, len(res.Comments))
+	for _, comment := range res.Comments {
+		created, _ := time.Parse(time.RFC3339, comment.DateCreated)
+		comments = append(comments, &SharedAssetComment{
+			AuthorName: strings.TrimSpace(comment.AuthorFirstName + " " + comment.AuthorLastName),
+			Text:       comment.Content,
+			Created:    created,
+		})
+	}
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].Created.Before(comments[j].Created) })
+	return comments, nil
+}
+
+// Download returns the largest version of the asset, the caller must close it.
+func (r *SharedAsset) Download() (io.ReadCloser, error) {
+	return r.DownloadContext(context.Background())
+}
+
+// DownloadContext is like Download, reading the body fails with ctx.Err() once ctx is done.
+func (r *SharedAsset) DownloadContext(ctx context.Context) (io.ReadCloser, error) {
+	body, _, err := r.openDownload(ctx, 0)
+	return body, err
+}
+
+// openDownload is the rangeOpener of the asset, the download url is resolved on every call.
+func (r *SharedAsset) openDownload(ctx context.Context, offset int64) (io.ReadCloser, int64, error) {
+	derivative := r.largestDerivative()
+	if derivative == nil {
+		return nil, 0, fmt.Errorf("download %s failed: no derivative", r.ID)
+	}
+
+	res := new(sharedAssetURLsResp)
+	body := map[string]any{"streamGuid": r.album.ID, "photoGuids": []string{r.ID}}
+	if err := r.album.service.sharedStreamsRequest(ctx, r.album.root, "webasseturls", body, res); err != nil {
+		return nil, 0, fmt.Errorf("download %s failed: %w", r.ID, err)
+	}
+	item, ok := res.Items[derivative.Checksum]
+	if !ok {
+		return nil, 0, fmt.Errorf("download %s failed: no url for checksum %s", r.ID, derivative.Checksum)
+	}
+
+	stream, start, err := r.album.service.icloud.openStream(ctx, "https://"+item.URLLocation+item.URLPath, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("download %s failed: %w", r.ID, err)
+	}
+	return stream, start, nil
+}
+
+// DownloadTo downloads the asset to target, through a .part file like PhotoAsset.DownloadTo,
+// and is retried and resumed the same way.
+func (r *SharedAsset) DownloadTo(target string) error {
+	return r.DownloadToContext(context.Background(), target)
+}
+
+// DownloadToContext is like DownloadTo, the download is given up when ctx is done.
+func (r *SharedAsset) DownloadToContext(ctx context.Context, target string) error {
+	return r.DownloadToStorageContext(ctx, NewFileStorage(), target)
+}
+
+// DownloadToStorage is like DownloadTo, but writes to storage instead of the local filesystem.
+func (r *SharedAsset) DownloadToStorage(storage Storage, target string) error {
+	return r.DownloadToStorageContext(context.Background(), storage, target)
+}
+
+// DownloadToStorageContext is like DownloadToStorage, the download is given up when ctx is done.
+func (r *SharedAsset) DownloadToStorageContext(ctx context.Context, storage Storage, target string) error {
+	if err := r.album.service.icloud.downloadToStorage(ctx, storage, target, int64(r.Size()), r.openDownload); err != nil {
+		return err
+	}
+	if chtimes, ok := storage.(StorageChtimes); ok && !r.Created.IsZero() {
+		if err := chtimes.Chtimes(target, r.Created, r.Created); err != nil {
+			return fmt.Errorf("change file time error: %v", err)
+		}
+	}
+	return nil
+}
+
+func (r *PhotoService) sharedStreamsRoot() (string, error) {
+	root, err := r.icloud.getWebServiceURL("sharedstreams")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/sharedstreams", root, r.icloud.DSID()), nil
+}
+
+func (r *PhotoService) sharedStreamsRequest(ctx context.Context, root, action string, body, res any) error {
+	text, err := r.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     fmt.Sprintf("%s/%s", root, action),
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		Body:    body,
+	})
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return fmt.Errorf("%s unmarshal failed, err: %w, text: %s", action, err, text)
+	}
+	return nil
+}
+
+type sharedStreamsResp struct {
+	Streams []*struct {
+		StreamGUID     string `json:"streamGuid"`
+		Name           string `json:"name"`
+		OwnerFirstName string `json:"ownerFirstName"`
+		OwnerLastName  string `json:"ownerLastName"`
+		IsOwned        bool   `json:"isOwned"`
+		Subscribers    []*struct {
+			FirstName         string `json:"firstName"`
+			LastName          string `json:"lastName"`
+			Email             string `json:"email"`
+			SubscriptionState string `json:"subscriptionState"`
+		} `json:"subscribers"`
+	} `json:"streams"`
+}
+
+type sharedStreamResp struct {
+	StreamName string `json:"streamName"`
+	Photos     []*struct {
+		PhotoGUID            string                            `json:"photoGuid"`
+		Caption              string                            `json:"caption"`
+		DateCreated          string                            `json:"dateCreated"`
+		ContributorFirstName string                            `json:"contributorFirstName"`
+		ContributorLastName  string                            `json:"contributorLastName"`
+		MediaAssetType       string                            `json:"mediaAssetType"`
+		Derivatives          map[string]*sharedAssetDerivative `json:"derivatives"`
+	} `json:"photos"`
+}
+
+type sharedAssetDerivative struct {
+	Checksum string `json:"checksum"`
+	FileSize string `json:"fileSize"`
+	Width    string `json:"width"`
+	Height   string `json:"height"`
+}
+
+func (r *sharedAssetDerivative) size() int {
+	var size int
+	_, _ = fmt.Sscan(r.FileSize, &size)
+	return size
+}
+
+type sharedCommentsResp struct {
+	Comments []*struct {
+		Content         string `json:"content"`
+		DateCreated     string `json:"dateCreated"`
+		AuthorFirstName string `json:"authorFirstName"`
+		AuthorLastName  string `json:"authorLastName"`
+	} `json:"comments"`
+}
+
+type sharedAssetURLsResp struct {
+	Items map[string]*struct {
+		URLLocation string `json:"url_location"`
+		URLPath     string `json:"url_path"`
+	} `json:"items"`
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const smartAlbumObjTypePrefix = "CPLAssetInSmartAlbumByAssetDate"
+
+// getSmartAlbums asks the server which smart album indexes exist in the zone,
+// so smart albums added by Apple show up without a hardcoded entry in icloudPhotoFolderMeta.
+func (r *PhotoService) getSmartAlbums(ctx context.Context) (map[string]*folderMetaData, error) {
+	text, err := r.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     fmt.Sprintf("%s/records/query", r.serviceEndpoint),
+		Querys:  r.querys,
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		Body: map[string]any{
+			"query":    map[string]any{"recordType": "HyperionIndexCountLookup"},
+			"zoneWide": true,
+			"zoneID":   r.zoneID(),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getSmartAlbums failed, err: %w", err)
+	}
+
+	res := new(getSmartAlbumsResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, fmt.Errorf("getSmartAlbums unmarshal failed, err: %w, text: %s", err, text)
+	}
+
+	albums := map[string]*folderMetaData{}
+	for _, record := range res.Records {
+		prefix, smartAlbum, ok := strings.Cut(record.RecordName, ":")
+		if !ok || prefix != smartAlbumObjTypePrefix {
+			continue
+		}
+		objType, err := buildObjType(prefix, smartAlbum)
+		if err != nil {
+			continue
+		}
+		filter, err := newQueryFilter("smartAlbum", "EQUALS", "STRING", strings.ToUpper(smartAlbum))
+		if err != nil {
+			continue
+		}
+		albums[smartAlbum] = &folderMetaData{
+			ObjType:     objType,
+			ListType:    "CPLAssetAndMasterInSmartAlbumByAssetDate",
+			Direction:   "ASCENDING",
+			QueryFilter: []*folderMetaDataQueryFilter{filter},
+		}
+	}
+	return albums, nil
+}
+
+type getSmartAlbumsResp struct {
+	Records []struct {
+		RecordName string `json:"recordName"`
+		RecordType string `json:"recordType"`
+	} `json:"records"`
+}
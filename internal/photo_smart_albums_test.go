@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"sync"
+	"testing"
+)
+
+type errorSink struct {
+	NopEventSink
+	lock sync.Mutex
+	errs []error
+}
+
+func (r *errorSink) Error(asset *PhotoAsset, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+// A failed smart album discovery is reported to the event sink, and the album list falls back to the builtin albums.
+func TestSmartAlbumsDiscoveryFails(t *testing.T) {
+	cli, server := newMockClient(t)
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := new(errorSink)
+	photoCli.SetEventSink(sink)
+	server.Fail("HyperionIndexCountLookup", 500)
+
+	albums, err := photoCli.Albums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if albums[AlbumNameAll] == nil {
+		t.Errorf("albums %v, want the builtin %s", albums, AlbumNameAll)
+	}
+	if len(sink.errs) != 1 {
+		t.Errorf("event sink errors %v, want the error of the discovery", sink.errs)
+	}
+}
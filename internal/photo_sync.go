@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SyncChanges is one batch of the asset changes of the library since a sync token.
+//
+// Assets are the added or changed assets, including those moved to Recently Deleted or hidden,
+// check IsDeleted and IsHidden. Deleted are the record names of the assets deleted for good.
+// Call SyncChanges with SyncToken for the next batch, while MoreComing is true.
+type SyncChanges struct {
+	Assets     []*PhotoAsset
+	Deleted    []string
+	SyncToken  string
+	MoreComing bool
+}
+
+// SyncChanges returns the asset changes of the library since syncToken,
+// an empty syncToken returns the whole library, in batches.
+func (r *PhotoService) SyncChanges(syncToken string) (*SyncChanges, error) {
+	return r.SyncChangesContext(context.Background(), syncToken)
+}
+
+// SyncChangesContext is like SyncChanges, the requests are given up when ctx is done.
+func (r *PhotoService) SyncChangesContext(ctx context.Context, syncToken string) (*SyncChanges, error) {
+	body := map[string]any{
+		"zoneID":             r.zoneID(),
+		"desiredKeys":        photoDesiredKeys,
+		"desiredRecordTypes": []string{"CPLMaster", "CPLAsset"},
+		"reverse":            false,
+	}
+	if syncToken != "" {
+		body["syncToken"] = syncToken
+	}
+	text, err := r.icloud.request(&rawReq{
+		Context: ctx,
+		Method:  http.MethodPost,
+		URL:     fmt.Sprintf("%s/changes/zone", r.serviceEndpoint),
+		Querys:  r.querys,
+		Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		Body:    map[string]any{"zones": []any{body}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sync changes failed, err: %w", err)
+	}
+	res := new(syncChangesResp)
+	if err = json.Unmarshal([]byte(text), res); err != nil {
+		return nil, fmt.Errorf("sync changes unmarshal failed, err: %w, text: %s", err, text)
+	}
+	if len(res.Zones) == 0 {
+		return nil, fmt.Errorf("sync changes failed, err: no zone in response, text: %s", text)
+	}
+	zone := res.Zones[0]
+
+	changes := &SyncChanges{SyncToken: zone.SyncToken, MoreComing: zone.MoreComing}
+	masterRecords := map[string]*photoRecord{}
+	var assetRecords []*photoRecord
+	for _, record := range zone.Records {
+		if record.Deleted {
+			changes.Deleted = append(changes.Deleted, record.RecordName)
+		} else if record.RecordType == "CPLMaster" {
+			masterRecords[record.RecordName] = record
+		} else if record.RecordType == "CPLAsset" {
+			assetRecords = append(assetRecords, record)
+		}
+	}
+
+	// the original of a master never changes, edits and flags change the asset record,
+	// so only asset records are yielded, with their master looked up when it's not in the batch.
+	for _, assetRecord := range assetRecords {
+		masterID := assetRecord.Fields.MasterRef.Value.RecordName
+		masterRecord := masterRecords[masterID]
+		if masterRecord == nil {
+			masterRecord, err = r.lookupRecord(ctx, masterID)
+			if err != nil {
+				return nil, fmt.Errorf("sync changes failed, err: %w", err)
+			}
+		}
+		changes.Assets = append(changes.Assets, r.newPhotoAsset(masterRecord, assetRecord))
+	}
+	return changes, nil
+}
+
+type syncChangesResp struct {
+	Zones []*struct {
+		Records    []*photoRecord `json:"records"`
+		SyncToken  string         `json:"syncToken"`
+		MoreComing bool           `json:"moreComing"`
+	} `json:"zones"`
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// WatchOption controls how a Watcher polls the library.
+type WatchOption struct {
+	// SyncToken is where the watch starts, like the SyncToken of a previous watch, empty starts with the whole library
+	SyncToken string
+	// Interval is the time between two polls, 0 is 5 minutes
+	Interval time.Duration
+	// MaxInterval caps the backoff of the polls after failures, like rate limits, 0 is 1 hour
+	MaxInterval time.Duration
+	// Retry reports whether an error of the fn of Watch is retried, with the same changes, after the backoff of a failed poll,
+	// nil stops the watch on any error of fn
+	Retry func(err error) bool
+}
+
+// Watcher polls the changes of a library, get it with PhotoService.NewWatcher.
+type Watcher struct {
+	service     *PhotoService
+	interval    time.Duration
+	maxInterval time.Duration
+	retry       func(err error) bool
+
+	lock  sync.Mutex
+	token string
+}
+
+// NewWatcher returns a watcher of the changes of the library, option nil is the defaults.
+func (r *PhotoService) NewWatcher(option *WatchOption) *Watcher {
+	if option == nil {
+		option = new(WatchOption)
+	}
+	res := &Watcher{service: r, interval: option.Interval, maxInterval: option.MaxInterval, retry: option.Retry, token: option.SyncToken}
+	if res.interval <= 0 {
+		res.interval = 5 * time.Minute
+	}
+	if res.maxInterval <= 0 {
+		res.maxInterval = time.Hour
+	}
+	if res.maxInterval < res.interval {
+		res.maxInterval = res.interval
+	}
+	return res
+}
+
+// SyncToken returns the token of the changes fn handled, save it to watch from there later.
+func (r *Watcher) SyncToken() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.token
+}
+
+// Watch calls fn with each batch of changes of the library, polling it every interval, until ctx is done.
+//
+// The token only moves past a batch once fn returns nil, an error of fn stops the watch with it, unless Retry retries it.
+// A failed poll, like a rate limit or a network error, is retried with a doubled interval, up to MaxInterval,
+// or after the Retry-After of a rate limit when it's longer.
+func (r *Watcher) Watch(ctx context.Context, fn func(changes *SyncChanges) error) error {
+	wait := r.interval
+	for {
+		err := r.poll(ctx, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var fnErr *watchFuncError
+		if errors.As(err, &fnErr) && (r.retry == nil || !r.retry(fnErr.err)) {
+			return fnErr.err
+		}
+		if err != nil {
+			wait *= 2
+			if wait > r.maxInterval {
+				wait = r.maxInterval
+			}
+			if retryAfter := RetryAfter(err); retryAfter > wait {
+				wait = retryAfter
+			}
+			r.service.icloud.log(LogLevelWarn, "watch failed, retry", "delay", wait, "err", err)
+		} else {
+			wait = r.interval
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// poll hands the changes since the token to fn, batch by batch.
+func (r *Watcher) poll(ctx context.Context, fn func(changes *SyncChanges) error) error {
+	for {
+		changes, err := r.service.SyncChangesContext(ctx, r.SyncToken())
+		if err != nil {
+			return err
+		}
+		if len(changes.Assets) > 0 || len(changes.Deleted) > 0 {
+			if err := fn(changes); err != nil {
+				return &watchFuncError{err: err}
+			}
+		}
+		r.lock.Lock()
+		r.token = changes.SyncToken
+		r.lock.Unlock()
+		if !changes.MoreComing {
+			return nil
+		}
+	}
+}
+
+// watchFuncError is an error of the fn of Watch, which stops the watch.
+type watchFuncError struct {
+	err error
+}
+
+func (r *watchFuncError) Error() string {
+	return r.err.Error()
+}
+
+func (r *watchFuncError) Unwrap() error {
+	return r.err
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chyroc/icloudgo/internal/icloudmock"
+)
+
+func TestWatcher(t *testing.T) {
+	cli, server := newMockClient(t)
+	server.AddAsset(&icloudmock.Asset{Filename: "first.jpg", Data: []byte("first")})
+	photoCli, err := cli.PhotoCli()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errFailed := errors.New("failed")
+	var names []string
+	failed := false
+	watcher := photoCli.NewWatcher(&WatchOption{
+		Interval: 10 * time.Millisecond,
+		Retry:    func(err error) bool { return errors.Is(err, errFailed) },
+	})
+	err = watcher.Watch(ctx, func(changes *SyncChanges) error {
+		for _, asset := range changes.Assets {
+			names = append(names, asset.Filename())
+		}
+		switch len(names) {
+		case 1:
+			server.AddAsset(&icloudmock.Asset{Filename: "second.jpg", Data: []byte("second")})
+		case 2:
+			// a retried error hands the same changes again
+			if !failed {
+				failed = true
+				return errFailed
+			}
+		case 3:
+			return fmt.Errorf("stop: %w", context.Canceled)
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("watch = %v, want the error of fn", err)
+	}
+	if want := []string{"first.jpg", "second.jpg", "second.jpg"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("changes %v, want %v", names, want)
+	}
+	if watcher.SyncToken() == "" {
+		t.Errorf("no sync token after the changes")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	err := fmt.Errorf("POST /records/query failed, status 429, err: %w", &rateLimitedError{retryAfter: time.Minute})
+	if !errors.Is(err, ErrRateLimited) || !IsErrorCode(err, ErrRateLimited.Code) {
+		t.Errorf("%v is not ErrRateLimited", err)
+	}
+	if got := RetryAfter(err); got != time.Minute {
+		t.Errorf("RetryAfter = %s, want 1m", got)
+	}
+	if got := RetryAfter(errors.New("other")); got != 0 {
+		t.Errorf("RetryAfter of another error = %s, want 0", got)
+	}
+}
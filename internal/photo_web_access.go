@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrWebAccessDisabled is the error WebAccessError matches with errors.Is.
+var ErrWebAccessDisabled = NewError("web_access_disabled", "iCloud web access is disabled for the account")
+
+// WebAccessError is returned when the account doesn't let iCloud.com read its photos,
+// like when "Access iCloud Data on the Web" is off, or Advanced Data Protection keeps the web out.
+type WebAccessError struct {
+	// Reason is what iCloud answered
+	Reason string
+	// AdvancedDataProtection reports whether the photo service of the account is end-to-end encrypted
+	AdvancedDataProtection bool
+}
+
+func (e *WebAccessError) Error() string {
+	guidance := "turn on Settings > [your name] > iCloud > Access iCloud Data on the Web, on an iPhone or iPad"
+	if e.AdvancedDataProtection {
+		guidance = "Advanced Data Protection is on, " + guidance + ", and approve the request on a trusted device when signing in, or turn Advanced Data Protection off"
+	}
+	return fmt.Sprintf("%s: iCloud web access to photos is disabled for the account (%s), %s", ErrWebAccessDisabled.Code, e.Reason, guidance)
+}
+
+func (e *WebAccessError) Is(target error) bool {
+	return target == ErrWebAccessDisabled
+}
+
+// webAccessErrorMarkers are the reasons CloudKit gives when the web can't read the private database,
+// {"serverErrorCode":"ACCESS_DENIED","reason":"private db access disabled for this account"}
+var webAccessErrorMarkers = []string{"private db access disabled", "ACCESS_DENIED"}
+
+// webAccessError returns a WebAccessError for err when it tells the web access is disabled, nil for other errors.
+func (r *Client) webAccessError(err error) error {
+	if err == nil || errors.Is(err, ErrWebAccessDisabled) {
+		return nil
+	}
+	for _, marker := range webAccessErrorMarkers {
+		if strings.Contains(err.Error(), marker) {
+			return &WebAccessError{Reason: marker, AdvancedDataProtection: r.isPhotoServiceEncrypted()}
+		}
+	}
+	return nil
+}
+
+// checkWebAccess returns a WebAccessError when the account reports web access is not allowed,
+// it's only trusted when the photo service is missing too, the flag is not sent to every account.
+func (r *Client) checkWebAccess() error {
+	data := r.data()
+	if data == nil || data.DsInfo == nil || data.DsInfo.IsWebAccessAllowed {
+		return nil
+	}
+	if _, ok := data.Webservices["ckdatabasews"]; ok {
+		return nil
+	}
+	return &WebAccessError{Reason: "isWebAccessAllowed is false", AdvancedDataProtection: r.isPhotoServiceEncrypted()}
+}
+
+// isPhotoServiceEncrypted reports whether the photo service needs the PCS keys of the devices,
+// which is what Advanced Data Protection turns on.
+func (r *Client) isPhotoServiceEncrypted() bool {
+	data := r.data()
+	if data == nil {
+		return false
+	}
+	service, ok := data.Webservices["ckdatabasews"]
+	return ok && service.PcsRequired
+}
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PhotoZone is a photo library the PhotoService works on.
+//
+// The default is the PrimarySync zone of the private database, an iCloud Shared Photo Library,
+// or content shared by another family member, lives in a SharedSync-* zone of the shared database.
+type PhotoZone struct {
+	Name            string
+	OwnerRecordName string
+	Shared          bool
+}
+
+const primaryZoneName = "PrimarySync"
+
+var PhotoZonePrimary = &PhotoZone{Name: primaryZoneName}
+
+// IsSharedLibrary reports whether the zone is an iCloud Shared Photo Library.
+func (r *PhotoZone) IsSharedLibrary() bool {
+	return strings.HasPrefix(r.Name, "SharedSync-")
+}
+
+func (r *PhotoZone) database() string {
+	if r.Shared {
+		return "shared"
+	}
+	return "private"
+}
+
+func (r *PhotoZone) key() string {
+	return r.database() + "/" + r.Name + "/" + r.OwnerRecordName
+}
+
+func (r *PhotoService) zoneID() map[string]any {
+	res := map[string]any{"zoneName": r.zone.Name}
+	if r.zone.OwnerRecordName != "" {
+		res["ownerRecordName"] = r.zone.OwnerRecordName
+	}
+	return res
+}
+
+// Zone returns the photo library the service is bound to.
+func (r *PhotoService) Zone() *PhotoZone {
+	return r.zone
+}
+
+// Zones lists the photo libraries the account can access, the primary library and any shared ones.
+func (r *PhotoService) Zones() ([]*PhotoZone, error) {
+	return r.ZonesContext(context.Background())
+}
+
+// ZonesContext is like Zones, the requests are given up when ctx is done.
+func (r *PhotoService) ZonesContext(ctx context.Context) ([]*PhotoZone, error) {
+	var res []*PhotoZone
+	for _, shared := range []bool{false, true} {
+		database := (&PhotoZone{Shared: shared}).database()
+		text, err := r.icloud.request(&rawReq{
+			Context: ctx,
+			Method:  http.MethodGet,
+			URL:     fmt.Sprintf("%s/database/1/com.apple.photos.cloud/production/%s/zones/list", r.serviceRoot, database),
+			Querys:  r.querys,
+			Headers: r.icloud.getCommonHeaders(map[string]string{}),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list %s zones failed, err: %w", database, err)
+		}
+		resp := new(listZonesResp)
+		if err = json.Unmarshal([]byte(text), resp); err != nil {
+			return nil, fmt.Errorf("list %s zones unmarshal failed, err: %w, text: %s", database, err, text)
+		}
+		for _, zone := range resp.Zones {
+			if zone.ZoneID.ZoneName != primaryZoneName && !strings.HasPrefix(zone.ZoneID.ZoneName, "SharedSync-") {
+				continue
+			}
+			res = append(res, &PhotoZone{
+				Name:            zone.ZoneID.ZoneName,
+				OwnerRecordName: zone.ZoneID.OwnerRecordName,
+				Shared:          shared,
+			})
+		}
+	}
+	return res, nil
+}
+
+type listZonesResp struct {
+	Zones []struct {
+		ZoneID struct {
+			ZoneName        string `json:"zoneName"`
+			OwnerRecordName string `json:"ownerRecordName"`
+			ZoneType        string `json:"zoneType"`
+		} `json:"zoneID"`
+	} `json:"zones"`
+}
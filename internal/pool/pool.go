@@ -0,0 +1,192 @@
+// Package pool runs tasks on a fixed number of workers, fed through a bounded queue,
+// it's shared by the commands working on many photos at once.
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Task is a unit of work, worker is the index of the worker running it, from 0.
+// ctx is done once the pool stops.
+type Task func(ctx context.Context, worker int) error
+
+type Option struct {
+	// Workers is how many tasks run at once, at least 1
+	Workers int
+	// QueueSize is how many tasks wait for a worker, Submit blocks while the queue is full, default Workers
+	QueueSize int
+	// Retries is how many times a failed task is retried, RetryDelay apart
+	Retries    int
+	RetryDelay time.Duration
+	// Retryable reports whether a task error is worth a retry, nil retries every error
+	Retryable func(err error) bool
+	// OnError is called with the error of a task once its retries are spent, and stops the pool with the error it returns,
+	// nil OnError stops the pool on the first error, it's called from the workers, concurrently
+	OnError func(err error) error
+}
+
+// Pool is safe for concurrent use, Submit may be called from several goroutines.
+type Pool struct {
+	option *Option
+	ctx    context.Context
+	cancel context.CancelFunc
+	queue  chan Task
+	wait   sync.WaitGroup
+
+	lock sync.Mutex
+	err  error
+
+	submitted int64
+	succeeded int64
+	failed    int64
+	retried   int64
+	running   int64
+}
+
+// Metrics is a snapshot of the tasks of a pool.
+type Metrics struct {
+	Submitted int64
+	Succeeded int64
+	Failed    int64
+	Retried   int64
+	Running   int64
+	Queued    int
+}
+
+func (r Metrics) String() string {
+	return fmt.Sprintf("%d tasks, %d succeeded, %d failed, %d retries", r.Submitted, r.Succeeded, r.Failed, r.Retried)
+}
+
+// New starts the workers of the pool, the pool stops when ctx is done.
+func New(ctx context.Context, option *Option) *Pool {
+	if option == nil {
+		option = &Option{}
+	}
+	if option.Workers < 1 {
+		option.Workers = 1
+	}
+	if option.QueueSize < 1 {
+		option.QueueSize = option.Workers
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Pool{
+		option: option,
+		ctx:    ctx,
+		cancel: cancel,
+		queue:  make(chan Task, option.QueueSize),
+	}
+	for worker := 0; worker < option.Workers; worker++ {
+		r.wait.Add(1)
+		go r.work(worker)
+	}
+	return r
+}
+
+// Submit queues the task, it blocks while the queue is full,
+// and returns the error the pool stopped with, without queueing the task, once it stopped.
+func (r *Pool) Submit(task Task) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	select {
+	case r.queue <- task:
+		atomic.AddInt64(&r.submitted, 1)
+		return nil
+	case <-r.ctx.Done():
+		return r.Err()
+	}
+}
+
+// Wait waits for the queued tasks, once nothing is submitted anymore,
+// and returns the error the pool stopped with, nil when every task succeeded or OnError let it go on.
+func (r *Pool) Wait() error {
+	close(r.queue)
+	r.wait.Wait()
+	err := r.Err()
+	r.cancel()
+	return err
+}
+
+// Stop stops the pool with err, the running tasks see their ctx done, the queued tasks are dropped.
+func (r *Pool) Stop(err error) {
+	r.lock.Lock()
+	if r.err == nil {
+		r.err = err
+	}
+	r.lock.Unlock()
+	r.cancel()
+}
+
+// Err returns the error the pool stopped with, or the error of its ctx, nil while it runs.
+func (r *Pool) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return r.ctx.Err()
+}
+
+// Metrics returns a snapshot of the tasks of the pool.
+func (r *Pool) Metrics() Metrics {
+	return Metrics{
+		Submitted: atomic.LoadInt64(&r.submitted),
+		Succeeded: atomic.LoadInt64(&r.succeeded),
+		Failed:    atomic.LoadInt64(&r.failed),
+		Retried:   atomic.LoadInt64(&r.retried),
+		Running:   atomic.LoadInt64(&r.running),
+		Queued:    len(r.queue),
+	}
+}
+
+func (r *Pool) work(worker int) {
+	defer r.wait.Done()
+	for task := range r.queue {
+		if r.ctx.Err() != nil {
+			// stopped, drop the queued tasks
+			continue
+		}
+		atomic.AddInt64(&r.running, 1)
+		err := r.run(task, worker)
+		atomic.AddInt64(&r.running, -1)
+		if err == nil {
+			atomic.AddInt64(&r.succeeded, 1)
+			continue
+		}
+		if r.ctx.Err() != nil && errors.Is(err, r.ctx.Err()) {
+			continue
+		}
+		atomic.AddInt64(&r.failed, 1)
+		if r.option.OnError != nil {
+			err = r.option.OnError(err)
+		}
+		if err != nil {
+			r.Stop(err)
+		}
+	}
+}
+
+func (r *Pool) run(task Task, worker int) error {
+	for attempt := 0; ; attempt++ {
+		err := task(r.ctx, worker)
+		if err == nil || attempt >= r.option.Retries || r.ctx.Err() != nil {
+			return err
+		}
+		if r.option.Retryable != nil && !r.option.Retryable(err) {
+			return err
+		}
+		atomic.AddInt64(&r.retried, 1)
+		timer := time.NewTimer(r.option.RetryDelay)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// downloadProgressInterval is how often a download reports its progress, on top of its last report.
+const downloadProgressInterval = 200 * time.Millisecond
+
+// DownloadProgress is the progress of a download to a target, reported to the DownloadProgressFunc
+// of the context of DownloadToContext, DownloadToStorageContext and the like.
+type DownloadProgress struct {
+	// Target is the path the download is saved to
+	Target string
+	// Written is the bytes saved so far, including the ones resumed from the .part file
+	Written int64
+	// Resumed is the bytes resumed from the .part file of a previous attempt or run
+	Resumed int64
+	// Total is the size of the file, 0 if unknown
+	Total   int64
+	Started time.Time
+	// Done is set on the last report of the download, Err is why it failed, nil on success
+	Done bool
+	Err  error
+}
+
+// ETA returns how long the rest of the download takes at the speed so far, 0 if unknown.
+func (r DownloadProgress) ETA() time.Duration {
+	return estimateRemaining(r.Written-r.Resumed, r.Total-r.Written, time.Since(r.Started))
+}
+
+// DownloadProgressFunc is called with the progress of a download, from the goroutine downloading,
+// it should return quickly, the download waits for it.
+type DownloadProgressFunc func(progress DownloadProgress)
+
+type downloadProgressKey struct{}
+
+// WithDownloadProgress returns a copy of ctx, the downloads given the copy report their progress to fn.
+func WithDownloadProgress(ctx context.Context, fn DownloadProgressFunc) context.Context {
+	return context.WithValue(ctx, downloadProgressKey{}, fn)
+}
+
+// DownloadProgressChan returns a DownloadProgressFunc sending the progress to ch, for a GUI or daemon
+// reading it from another goroutine, reports are dropped while ch is full, except the last one of each download.
+func DownloadProgressChan(ch chan<- DownloadProgress) DownloadProgressFunc {
+	return func(progress DownloadProgress) {
+		if progress.Done {
+			ch <- progress
+			return
+		}
+		select {
+		case ch <- progress:
+		default:
+		}
+	}
+}
+
+// downloadProgress reports the progress of a download to the DownloadProgressFunc of its context, it's nil without one.
+type downloadProgress struct {
+	lock     sync.Mutex // the ranges of a parallel download report at once
+	fn       DownloadProgressFunc
+	progress DownloadProgress
+	reported time.Time
+}
+
+func newDownloadProgress(ctx context.Context, target string, size int64) *downloadProgress {
+	fn, _ := ctx.Value(downloadProgressKey{}).(DownloadProgressFunc)
+	if fn == nil {
+		return nil
+	}
+	return &downloadProgress{fn: fn, progress: DownloadProgress{Target: target, Total: size, Started: time.Now()}}
+}
+
+// start resets the progress to offset, when an attempt starts writing.
+func (r *downloadProgress) start(offset int64) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.progress.Written, r.progress.Resumed = offset, offset
+	r.report(true)
+}
+
+// reader returns body, counting what's read from it to the progress.
+func (r *downloadProgress) reader(body io.Reader) io.Reader {
+	if r == nil {
+		return body
+	}
+	return &progressReader{r: body, progress: r}
+}
+
+func (r *downloadProgress) done(err error) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.progress.Done, r.progress.Err = true, err
+	r.report(true)
+}
+
+// report calls fn with the progress, the lock must be held.
+func (r *downloadProgress) report(force bool) {
+	if !force && time.Since(r.reported) < downloadProgressInterval {
+		return
+	}
+	r.reported = time.Now()
+	r.fn(r.progress)
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress *downloadProgress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.progress.lock.Lock()
+	r.progress.progress.Written += int64(n)
+	r.progress.report(false)
+	r.progress.lock.Unlock()
+	return n, err
+}
+
+// IterProgress is the progress of an album iterator, reported to PhotosIterOption.OnProgress.
+type IterProgress struct {
+	Album string
+	// Scanned is the assets listed so far, Yielded the ones Next returned, the others are skipped by the filters
+	Scanned int
+	Yielded int
+	// Total is the size of the album, 0 if unknown
+	Total int
+	// Current is the asset Next is returning, nil for the reports of skipped assets
+	Current *PhotoAsset
+	Started time.Time
+}
+
+// ETA returns how long listing the rest of the album takes at the speed so far, 0 if unknown.
+func (r IterProgress) ETA() time.Duration {
+	return estimateRemaining(int64(r.Scanned), int64(r.Total-r.Scanned), time.Since(r.Started))
+}
+
+// iterProgress is shared by the iterators of an album, like All Photos and the Hidden album chained after it,
+// it's nil without PhotosIterOption.OnProgress.
+type iterProgress struct {
+	lock     sync.Mutex
+	fn       func(progress IterProgress)
+	progress IterProgress
+}
+
+func newIterProgress(ctx context.Context, album *PhotoAlbum, fn func(progress IterProgress)) *iterProgress {
+	if fn == nil {
+		return nil
+	}
+	total, _ := album.GetSizeContext(ctx)
+	return &iterProgress{fn: fn, progress: IterProgress{Album: album.Name, Total: total, Started: time.Now()}}
+}
+
+// scan reports an asset listed, current is nil when the asset is skipped.
+func (r *iterProgress) scan(current *PhotoAsset) {
+	if r == nil {
+		return
+	}
+	r.lock.Lock()
+	r.progress.Scanned++
+	if current != nil {
+		r.progress.Yielded++
+	}
+	r.progress.Current = current
+	progress := r.progress
+	r.lock.Unlock()
+	r.fn(progress)
+}
+
+// estimateRemaining returns how long the remaining units take, at done units per elapsed, 0 if unknown.
+func estimateRemaining(done, remaining int64, elapsed time.Duration) time.Duration {
+	if done <= 0 || remaining <= 0 || elapsed <= 0 {
+		return 0
+	}
+	return time.Duration(float64(elapsed) / float64(done) * float64(remaining))
+}
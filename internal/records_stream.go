@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// recordsPage is a records/query response without its records, which are handed out one by one as they're decoded.
+type recordsPage struct {
+	ContinuationMarker string
+	SyncToken          string
+}
+
+// requestRecords sends a records/query request, and decodes the records of the response as it streams,
+// so a page of a thousand records doesn't sit in memory as text, then as records, then as assets.
+func requestRecords[T any](client *Client, req *rawReq, fn func(record *T) error) (*recordsPage, error) {
+	body, status, err := client.requestStreamStatus(req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	page, err := decodeRecords(body, fn)
+	if err != nil {
+		var recordErr *recordFuncError
+		if errors.As(err, &recordErr) {
+			return nil, recordErr.err
+		}
+		return nil, fmt.Errorf("%s %s failed, status %d, err: %w", req.Method, req.URL, status, err)
+	}
+	return page, nil
+}
+
+// decodeRecords decodes the records array of a records/query response one record at a time,
+// the other fields are kept to find the error of the response, like the ones of mayErr.
+func decodeRecords[T any](reader io.Reader, fn func(record *T) error) (*recordsPage, error) {
+	dec := json.NewDecoder(reader)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	page := new(recordsPage)
+	others := map[string]json.RawMessage{}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		switch key {
+		case "records":
+			if err := decodeRecordsArray(dec, fn); err != nil {
+				return nil, err
+			}
+		case "continuationMarker":
+			if err := dec.Decode(&page.ContinuationMarker); err != nil {
+				return nil, err
+			}
+		case "syncToken":
+			if err := dec.Decode(&page.SyncToken); err != nil {
+				return nil, err
+			}
+		default:
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			others[key] = raw
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	if len(others) > 0 {
+		bs, _ := json.Marshal(others)
+		if err := mayErr(bs); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+func decodeRecordsArray[T any](dec *json.Decoder, fn func(record *T) error) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil // "records": null
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("unexpected records %v", token)
+	}
+	for dec.More() {
+		record := new(T)
+		if err := dec.Decode(record); err != nil {
+			return err
+		}
+		if err := fn(record); err != nil {
+			return &recordFuncError{err: err}
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("expect %v, but got %v", want, token)
+	}
+	return nil
+}
+
+// recordFuncError is an error of the fn of requestRecords, returned as is.
+type recordFuncError struct {
+	err error
+}
+
+func (r *recordFuncError) Error() string {
+	return r.err.Error()
+}
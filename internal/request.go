@@ -1,11 +1,18 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
+	"time"
+
+	"github.com/chyroc/gorequests"
 )
 
 type rawReq struct {
+	Context      context.Context
 	Method       string
 	URL          string
 	Headers      map[string]string
@@ -16,19 +23,72 @@ type rawReq struct {
 }
 
 func (r *Client) request(req *rawReq) (string, error) {
-	text, _, err := r.doRequest(req)
+	text, _, _, err := r.doRequest(req)
 	return text, err
 }
 
 func (r *Client) requestStream(req *rawReq) (io.ReadCloser, error) {
-	req.Stream = true
-	_, body, err := r.doRequest(req)
+	body, _, err := r.requestStreamStatus(req)
 	return body, err
 }
 
-func (r *Client) doRequest(req *rawReq) (string, io.ReadCloser, error) {
-	status := 0
+// requestStreamStatus is like requestStream, and also returns the status of the response.
+func (r *Client) requestStreamStatus(req *rawReq) (io.ReadCloser, int, error) {
+	req.Stream = true
+	_, body, status, err := r.doRequest(req)
+	return body, status, err
+}
+
+func (r *Client) doRequest(req *rawReq) (string, io.ReadCloser, int, error) {
+	ctx := req.context()
+	// a streamed request body can only be sent once
+	_, isReader := req.Body.(io.Reader)
+	reauthenticated := false
+	for attempt := 0; ; attempt++ {
+		if err := r.waitRateLimit(ctx); err != nil {
+			return "", nil, 0, fmt.Errorf("%s %s failed, err: %w", req.Method, req.URL, err)
+		}
+
+		res := r.newHTTPRequest(req)
+		sentAt := time.Now()
+		resp, respErr := sendWithContext(ctx, res)
+		if respErr != nil && errors.Is(respErr, ctx.Err()) {
+			return "", nil, 0, fmt.Errorf("%s %s failed, err: %w", req.Method, req.URL, respErr)
+		}
+		if resp != nil {
+			for k, callback := range contextHeader {
+				if v := resp.Header.Get(k); v != "" {
+					r.updateSession(func(d *SessionData) { callback(d, v) })
+				}
+			}
+		}
 
+		status := res.MustResponseStatus()
+		if respErr == nil && r.isRateLimitedResponse(req, status, reauthenticated) {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			delay := parseRetryAfter(resp.Header, body, attempt)
+			r.onRateLimited(req.Method, req.URL, status, delay)
+			if isReader || attempt >= maxRateLimitRetries {
+				return string(body), nil, status, fmt.Errorf("%s %s failed, status %d, err: %w", req.Method, req.URL, status, &rateLimitedError{retryAfter: delay})
+			}
+			continue
+		}
+		if respErr == nil && !isReader && !reauthenticated && r.shouldReauthenticate(req, status) {
+			resp.Body.Close()
+			reauthenticated = true
+			if err := r.reauthenticate(ctx, sentAt); err != nil {
+				return "", nil, status, fmt.Errorf("%s %s failed, status %d, err: %w", req.Method, req.URL, status, err)
+			}
+			continue
+		}
+
+		text, body, err := r.readResponse(ctx, req, res, status, respErr)
+		return text, body, status, err
+	}
+}
+
+func (r *Client) newHTTPRequest(req *rawReq) *gorequests.Request {
 	res := r.httpCli.New(req.Method, req.URL).WithURLCookie("https://icloud.com.cn")
 	if len(req.Headers) > 0 {
 		res = res.WithHeaders(req.Headers)
@@ -37,36 +97,38 @@ func (r *Client) doRequest(req *rawReq) (string, io.ReadCloser, error) {
 		res = res.WithQuerys(req.Querys)
 	}
 	if req.Body != nil {
-		if len(req.Headers) > 0 && req.Headers["Content-Type"] == "text/plain" {
+		if contentType := req.Headers["Content-Type"]; contentType == "text/plain" || strings.HasPrefix(contentType, "multipart/") {
 			res = res.WithBody(req.Body)
 		} else {
 			res = res.WithJSON(req.Body)
 		}
 	}
+	return res
+}
 
-	resp, respErr := res.Response()
-	if resp != nil {
-		for k, callback := range contextHeader {
-			if resp.Header.Get(k) != "" {
-				callback(r.sessionData, resp.Header.Get(k))
-			}
-		}
-	}
-
-	status = res.MustResponseStatus()
-
+func (r *Client) readResponse(ctx context.Context, req *rawReq, res *gorequests.Request, status int, respErr error) (string, io.ReadCloser, error) {
 	if req.Stream {
 		if respErr != nil {
 			return "", nil, fmt.Errorf("%s %s failed, status %d, err: %s", req.Method, req.URL, status, respErr)
 		}
+		resp, _ := res.Response()
 		if req.ExpectStatus != nil && req.ExpectStatus.Len() > 0 && !req.ExpectStatus.Has(status) {
+			resp.Body.Close()
+			if isExpiredURLStatus(status) {
+				return "", nil, fmt.Errorf("%s %s failed, status %d, err: %w", req.Method, req.URL, status, ErrDownloadURLExpired)
+			}
 			return "", nil, fmt.Errorf("%s %s failed, expect status %v, but got %d", req.Method, req.URL, req.ExpectStatus.String(), status)
 		}
-		return "", resp.Body, nil
+		return "", newContextBody(ctx, resp.Body), nil
 	}
 
+	if resp, _ := res.Response(); resp != nil {
+		defer closeOnDone(ctx, resp.Body)()
+	}
 	text, err := res.Text()
-	if err != nil {
+	if err != nil && ctx.Err() != nil {
+		return text, nil, fmt.Errorf("%s %s failed, err: %w", req.Method, req.URL, ctx.Err())
+	} else if err != nil {
 		return text, nil, fmt.Errorf("%s %s failed, status %d, err: %s, response text: %s", req.Method, req.URL, status, err, text)
 	}
 
@@ -129,6 +191,9 @@ var contextHeader = map[string]func(d *SessionData, v string){
 	"X-Apple-TwoSV-Trust-Token": func(d *SessionData, v string) {
 		d.TrustToken = v
 	},
+	"X-Apple-Repair-Session-Token": func(d *SessionData, v string) {
+		d.RepairSessionToken = v
+	},
 	"scnt": func(d *SessionData, v string) {
 		d.Scnt = v
 	},
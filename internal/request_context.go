@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/chyroc/gorequests"
+)
+
+func (r *rawReq) context() context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+	return r.Context
+}
+
+// sendWithContext sends the request, and returns ctx.Err() once ctx is done.
+//
+// gorequests doesn't pass a context to net/http, so a cancelled request
+// is left to finish in the background, and its body is closed.
+func sendWithContext(ctx context.Context, res *gorequests.Request) (*http.Response, error) {
+	if ctx.Done() == nil {
+		return res.Response()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		resp, err := res.Response()
+		ch <- result{resp: resp, err: err}
+	}()
+
+	select {
+	case v := <-ch:
+		return v.resp, v.err
+	case <-ctx.Done():
+		go func() {
+			if v := <-ch; v.resp != nil {
+				v.resp.Body.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// closeOnDone closes body when ctx is done, which interrupts a blocked read,
+// call stop once the body is no longer read.
+func closeOnDone(ctx context.Context, body io.Closer) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			body.Close()
+		case <-done:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// contextBody is a streamed response body, which is closed when ctx is done,
+// reads then fail with ctx.Err().
+type contextBody struct {
+	ctx  context.Context
+	body io.ReadCloser
+	stop func()
+}
+
+func newContextBody(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	if ctx.Done() == nil {
+		return body
+	}
+	return &contextBody{ctx: ctx, body: body, stop: closeOnDone(ctx, body)}
+}
+
+func (r *contextBody) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if err != nil && err != io.EOF && r.ctx.Err() != nil {
+		return n, r.ctx.Err()
+	}
+	return n, err
+}
+
+func (r *contextBody) Close() error {
+	r.stop()
+	return r.body.Close()
+}
@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var ErrRateLimited = NewError("rate_limited", "rate limited by iCloud")
+
+const (
+	maxRateLimitRetries   = 5
+	defaultRateLimitPause = 30 * time.Second
+	maxRateLimitPause     = 10 * time.Minute
+)
+
+func isRateLimitedStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
+}
+
+// isRateLimitedResponse reports whether the request answering status was throttled, 429 and 503 always are.
+// 421 is an expired session first, it's a throttle once the session was renewed, and on the download hosts, which don't use the session.
+func (r *Client) isRateLimitedResponse(req *rawReq, status int, reauthenticated bool) bool {
+	if isRateLimitedStatus(status) {
+		return true
+	}
+	if status != http.StatusMisdirectedRequest {
+		return false
+	}
+	return reauthenticated || strings.HasSuffix(urlHost(req.URL), "icloud-content.com")
+}
+
+// waitRateLimit blocks while the client is paused by a rate limited response, then until the next request
+// is allowed by the max requests per second. Both are shared by all goroutines using the client.
+// It returns ctx.Err() when ctx is done first.
+func (r *Client) waitRateLimit(ctx context.Context) error {
+	for {
+		r.rateLimitLock.Lock()
+		now := time.Now()
+		wait := r.pauseUntil.Sub(now)
+		if wait <= 0 {
+			// reserve the next slot, so the requests waiting together are spread, not sent at once
+			slot := r.nextRequestAt
+			if slot.Before(now) {
+				slot = now
+			}
+			if r.requestInterval > 0 {
+				r.nextRequestAt = slot.Add(r.requestInterval)
+			}
+			r.rateLimitLock.Unlock()
+			return sleepContext(ctx, slot.Sub(now))
+		}
+		r.rateLimitLock.Unlock()
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext sleeps for d, it returns ctx.Err() when ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SetMaxRequestsPerSecond caps the requests of the client, the downloads included, to rps a second,
+// shared by all goroutines using the client, 0 is unlimited.
+func (r *Client) SetMaxRequestsPerSecond(rps float64) {
+	r.rateLimitLock.Lock()
+	defer r.rateLimitLock.Unlock()
+	r.requestInterval = 0
+	if rps > 0 {
+		r.requestInterval = time.Duration(float64(time.Second) / rps)
+	}
+}
+
+// rateLimitBackoff is the pause after the attempt-th rate limited response of a request without a Retry-After,
+// doubled with each attempt, up to maxRateLimitPause, with a jitter so the paused goroutines don't retry at once.
+func rateLimitBackoff(attempt int) time.Duration {
+	delay := defaultRateLimitPause
+	for i := 0; i < attempt && delay < maxRateLimitPause; i++ {
+		delay *= 2
+	}
+	if delay > maxRateLimitPause {
+		delay = maxRateLimitPause
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// onRateLimited pauses all requests of the client for delay.
+func (r *Client) onRateLimited(method, url string, status int, delay time.Duration) {
+	atomic.AddInt64(&r.rateLimitedCount, 1)
+
+	r.rateLimitLock.Lock()
+	defer r.rateLimitLock.Unlock()
+
+	if until := time.Now().Add(delay); until.After(r.pauseUntil) {
+		r.pauseUntil = until
+		r.log(LogLevelWarn, "RateLimited, pause all requests", "method", method, "url", url, "status", status, "delay", delay)
+	}
+}
+
+// RateLimitedCount returns how many responses were rate limited since the client was created.
+func (r *Client) RateLimitedCount() int64 {
+	return atomic.LoadInt64(&r.rateLimitedCount)
+}
+
+// parseRetryAfter reads the pause duration from the Retry-After header (seconds or http date),
+// the iCloud specific X-Apple-Retry-After header, or the CloudKit `retryAfter` response field,
+// without any of them it's the rateLimitBackoff of the attempt.
+func parseRetryAfter(header http.Header, body []byte, attempt int) time.Duration {
+	delay := time.Duration(0)
+	for _, key := range []string{"Retry-After", "X-Apple-Retry-After"} {
+		v := header.Get(key)
+		if v == "" {
+			continue
+		}
+		if seconds, err := strconv.Atoi(v); err == nil {
+			delay = time.Duration(seconds) * time.Second
+			break
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			delay = time.Until(t)
+			break
+		}
+	}
+
+	if delay <= 0 && len(body) > 0 {
+		resp := new(retryAfterResp)
+		if json.Unmarshal(body, resp) == nil {
+			if resp.RetryAfter > 0 {
+				delay = time.Duration(resp.RetryAfter) * time.Second
+			}
+			for _, v := range resp.Errors {
+				if v.RetryAfter > 0 {
+					delay = time.Duration(v.RetryAfter) * time.Second
+				}
+			}
+		}
+	}
+
+	if delay <= 0 {
+		delay = rateLimitBackoff(attempt)
+	}
+	if delay > maxRateLimitPause {
+		delay = maxRateLimitPause
+	}
+	return delay
+}
+
+// RetryAfter returns how long to wait before trying again what failed with err, a rate limit,
+// by the Retry-After iCloud answered, 0 when err is not a rate limit.
+func RetryAfter(err error) time.Duration {
+	var rateLimited *rateLimitedError
+	if errors.As(err, &rateLimited) {
+		return rateLimited.retryAfter
+	}
+	return 0
+}
+
+// rateLimitedError is ErrRateLimited with the pause of the last rate limited response.
+type rateLimitedError struct {
+	retryAfter time.Duration
+}
+
+func (r *rateLimitedError) Error() string {
+	return ErrRateLimited.Error()
+}
+
+func (r *rateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// {"serverErrorCode":"THROTTLED","retryAfter":30}
+type retryAfterResp struct {
+	RetryAfter int `json:"retryAfter"`
+	Errors     []struct {
+		RetryAfter int `json:"retryAfter"`
+	} `json:"errors"`
+}
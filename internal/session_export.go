@@ -0,0 +1,136 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sessionExportVersion is the version of the format of ExportSession.
+const sessionExportVersion = 1
+
+// sessionExport is the portable form of a session, ExportSession writes it, ImportSession reads it.
+type sessionExport struct {
+	Version    int              `json:"version"`
+	AppleID    string           `json:"apple_id"`
+	Home       string           `json:"home"` // like https://www.icloud.com, a session only works on its domain
+	ClientID   string           `json:"client_id"`
+	Session    SessionData      `json:"session"`
+	Cookies    []*sessionCookie `json:"cookies"`
+	ExportedAt time.Time        `json:"exported_at"`
+}
+
+type sessionCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"http_only"`
+}
+
+// ExportSession returns the session of the client, its cookies and tokens, as portable JSON,
+// for ImportSession on another machine, like logging in on a desktop with 2fa, and running on a headless NAS.
+// It's as good as the password and the 2fa code, keep it secret.
+func (r *Client) ExportSession() ([]byte, error) {
+	session := r.session()
+	if session.SessionToken == "" {
+		return nil, fmt.Errorf("export session failed, err: not authenticated")
+	}
+	jar, ok := r.httpCli.Jar().(interface{ AllCookies() []*http.Cookie })
+	if !ok {
+		return nil, fmt.Errorf("export session failed, err: the cookie jar can't be listed")
+	}
+
+	res := &sessionExport{
+		Version:    sessionExportVersion,
+		AppleID:    r.appleID,
+		Home:       r.homeEndpoint,
+		ClientID:   r.clientID,
+		Session:    session,
+		ExportedAt: time.Now(),
+	}
+	for _, cookie := range jar.AllCookies() {
+		if !isAppleCookieDomain(cookie.Domain) {
+			continue
+		}
+		res.Cookies = append(res.Cookies, &sessionCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		})
+	}
+	return json.MarshalIndent(res, "", "  ")
+}
+
+// ImportSession replaces the session of the client with one ExportSession returned, and saves it in the cookie dir,
+// Authenticate then reuses it, without the password or a 2fa code while it's valid.
+func (r *Client) ImportSession(bs []byte) error {
+	res := new(sessionExport)
+	if err := json.Unmarshal(bs, res); err != nil {
+		return fmt.Errorf("import session failed, err: %w", err)
+	}
+	if res.Version != sessionExportVersion || res.Session.SessionToken == "" {
+		return fmt.Errorf("import session failed, err: not an exported session")
+	}
+	return r.importSession(res)
+}
+
+func (r *Client) importSession(res *sessionExport) error {
+	if res.Home != r.homeEndpoint {
+		return fmt.Errorf("import session failed, err: the session is of %s, not %s", res.Home, r.homeEndpoint)
+	}
+	if r.appleID != "" && res.AppleID != "" && !strings.EqualFold(r.appleID, res.AppleID) {
+		return fmt.Errorf("import session failed, err: the session is of %s, not %s", res.AppleID, r.appleID)
+	}
+
+	r.authLock.Lock()
+	defer r.authLock.Unlock()
+
+	jar := r.httpCli.Jar()
+	for _, cookie := range res.Cookies {
+		if !isAppleCookieDomain(cookie.Domain) || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			continue
+		}
+		u := &url.URL{Scheme: "https", Host: strings.TrimPrefix(cookie.Domain, "."), Path: "/"}
+		jar.SetCookies(u, []*http.Cookie{{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			Expires:  cookie.Expires,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}})
+	}
+	if saver, ok := jar.(interface{ Save() error }); ok {
+		if err := saver.Save(); err != nil {
+			return fmt.Errorf("import session failed, err: %w", err)
+		}
+	}
+
+	if res.ClientID != "" {
+		r.clientID = res.ClientID
+	}
+	r.updateSession(func(d *SessionData) { *d = res.Session })
+	return r.flush()
+}
+
+// isAppleCookieDomain reports whether a cookie of the domain belongs to the session, the jar may have cookies of other hosts.
+func isAppleCookieDomain(domain string) bool {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	for _, v := range []string{"icloud.com", "icloud.com.cn", "apple.com", "icloud-content.com"} {
+		if domain == v || strings.HasSuffix(domain, "."+v) {
+			return true
+		}
+	}
+	return false
+}
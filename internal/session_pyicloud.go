@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ImportPyiCloudSession is like ImportSession, but imports the session pyicloud, and icloudpd, saved in dir,
+// like ~/.pyicloud, for the apple id of the client, so switching tools doesn't need a new 2fa login.
+func (r *Client) ImportPyiCloudSession(dir string) error {
+	if r.appleID == "" {
+		return fmt.Errorf("import pyicloud session failed, err: no apple id")
+	}
+	// pyicloud names the files after the word characters of the apple id
+	name := regexp.MustCompile(`\W`).ReplaceAllString(r.appleID, "")
+
+	bs, err := os.ReadFile(filepath.Join(dir, name+".session"))
+	if err != nil {
+		return fmt.Errorf("import pyicloud session failed, err: %w", err)
+	}
+	res := &sessionExport{Version: sessionExportVersion, AppleID: r.appleID, Home: r.homeEndpoint}
+	if err := json.Unmarshal(bs, &res.Session); err != nil {
+		return fmt.Errorf("import pyicloud session failed, err: %w", err)
+	}
+	var extra struct {
+		ClientID string `json:"client_id"`
+	}
+	_ = json.Unmarshal(bs, &extra)
+	res.ClientID = extra.ClientID
+	if res.Session.SessionToken == "" {
+		return fmt.Errorf("import pyicloud session failed, err: no session token in %s", name+".session")
+	}
+
+	if bs, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+		res.Cookies = parseLWPCookies(bs)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("import pyicloud session failed, err: %w", err)
+	}
+	return r.importSession(res)
+}
+
+// parseLWPCookies parses the cookies of a Set-Cookie3 file, the LWPCookieJar format of python,
+// like `Set-Cookie3: X-APPLE-WEBAUTH-TOKEN="v=2:t=..."; path="/"; domain=".icloud.com"; path_spec; secure; expires="2024-05-01 10:00:00Z"; version=0`.
+func parseLWPCookies(bs []byte) []*sessionCookie {
+	var cookies []*sessionCookie
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "Set-Cookie3:") {
+			continue
+		}
+		var cookie *sessionCookie
+		for i, attr := range strings.Split(strings.TrimPrefix(line, "Set-Cookie3:"), ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(attr), "=")
+			v = strings.Trim(v, `"`)
+			if i == 0 {
+				cookie = &sessionCookie{Name: k, Value: v, Path: "/"}
+				continue
+			}
+			switch strings.ToLower(k) {
+			case "path":
+				cookie.Path = v
+			case "domain":
+				cookie.Domain = v
+			case "secure":
+				cookie.Secure = true
+			case "httponly":
+				cookie.HttpOnly = true
+			case "expires":
+				cookie.Expires, _ = time.Parse("2006-01-02 15:04:05Z", v)
+			}
+		}
+		if cookie != nil && cookie.Name != "" && cookie.Domain != "" {
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies
+}
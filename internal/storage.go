@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Storage is the destination DownloadToStorage writes assets to.
+//
+// Names are slash or OS separated paths as the storage sees fit, Create must truncate an existing file,
+// and Stat must return an error satisfying os.IsNotExist for missing files.
+type Storage interface {
+	Stat(name string) (os.FileInfo, error)
+	Create(name string) (io.WriteCloser, error)
+	Rename(oldName, newName string) error
+	Remove(name string) error
+}
+
+// StorageChtimes is implemented by storages able to set the time of a file,
+// DownloadToStorage uses it to keep the asset's created time.
+type StorageChtimes interface {
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// StorageAppend is implemented by storages able to append to a file,
+// DownloadToStorage uses it to resume a download from its .part file.
+type StorageAppend interface {
+	// Append opens name for appending, it's created if missing.
+	Append(name string) (io.WriteCloser, error)
+}
+
+// StorageWriteAt is implemented by storages able to write a file at offsets,
+// DownloadToStorage uses it to download a large asset in parallel ranges, see PhotoService.SetParallelDownload.
+type StorageWriteAt interface {
+	// CreateWriteAt opens name for writing at offsets, it's created if missing, and truncated if it exists.
+	CreateWriteAt(name string) (WriteAtCloser, error)
+}
+
+// WriteAtCloser is the file of StorageWriteAt.
+type WriteAtCloser interface {
+	io.WriterAt
+	io.Closer
+}
+
+// FileStorage is the Storage of the local filesystem, the default of DownloadTo.
+type FileStorage struct{}
+
+func NewFileStorage() *FileStorage {
+	return &FileStorage{}
+}
+
+func (r *FileStorage) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (r *FileStorage) Create(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+}
+
+func (r *FileStorage) Append(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+}
+
+func (r *FileStorage) CreateWriteAt(name string) (WriteAtCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+}
+
+func (r *FileStorage) Rename(oldName, newName string) error {
+	return os.Rename(oldName, newName)
+}
+
+func (r *FileStorage) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (r *FileStorage) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// remoteFileInfo is the os.FileInfo of a file of a remote storage, like S3Storage.
+type remoteFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (r *remoteFileInfo) Name() string       { return path.Base(filepath.ToSlash(r.name)) }
+func (r *remoteFileInfo) Size() int64        { return r.size }
+func (r *remoteFileInfo) Mode() os.FileMode  { return 0o644 }
+func (r *remoteFileInfo) ModTime() time.Time { return r.modTime }
+func (r *remoteFileInfo) IsDir() bool        { return false }
+func (r *remoteFileInfo) Sys() any           { return nil }
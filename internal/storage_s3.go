@@ -0,0 +1,428 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3StorageOption is the bucket a S3Storage writes to, of AWS S3 or of a S3 compatible server, like MinIO.
+type S3StorageOption struct {
+	// Endpoint is the url of the server, like http://minio:9000, empty is AWS S3 of the region
+	Endpoint string
+	// Region of the bucket, empty is us-east-1
+	Region string
+	Bucket string
+	// Prefix is the dir of the files in the bucket, like backup/photos
+	Prefix string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // of temporary credentials, optional
+
+	// PathStyle addresses the bucket in the path of the urls, like MinIO needs, instead of in the host
+	PathStyle bool
+	// PartSize is the size of the parts a file is uploaded in, buffered in memory, 0 is 8MB, at least 5MB
+	PartSize int64
+	// HTTPClient sends the requests, nil is http.DefaultClient
+	HTTPClient *http.Client
+}
+
+const (
+	defaultS3PartSize = 8 << 20
+	minS3PartSize     = 5 << 20
+	// maxS3CopySize is the largest object copied in one request, larger ones are copied in parts
+	maxS3CopySize     = 5 << 30
+	s3CopyPartSize    = 1 << 30
+	s3EmptyPayloadSum = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Storage is the Storage of a S3 bucket, the assets are streamed to the bucket without touching the local disk.
+//
+// A file is uploaded in parts of PartSize as it's written, and is only visible once closed.
+// Rename is a copy and a delete, S3 can't move objects, and it doesn't implement StorageChtimes nor StorageAppend,
+// so an interrupted download starts over.
+type S3Storage struct {
+	option   S3StorageOption
+	endpoint *url.URL
+	httpCli  *http.Client
+}
+
+// NewS3Storage returns the storage of the bucket of option.
+func NewS3Storage(option *S3StorageOption) (*S3Storage, error) {
+	if option.Bucket == "" {
+		return nil, fmt.Errorf("new s3 storage failed, err: bucket is required")
+	}
+	if option.AccessKeyID == "" || option.SecretAccessKey == "" {
+		return nil, fmt.Errorf("new s3 storage failed, err: access key id and secret access key are required")
+	}
+	r := &S3Storage{option: *option, httpCli: option.HTTPClient}
+	if r.option.Region == "" {
+		r.option.Region = "us-east-1"
+	}
+	if r.option.PartSize == 0 {
+		r.option.PartSize = defaultS3PartSize
+	} else if r.option.PartSize < minS3PartSize {
+		r.option.PartSize = minS3PartSize
+	}
+	if r.httpCli == nil {
+		r.httpCli = http.DefaultClient
+	}
+	endpoint := r.option.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + r.option.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("new s3 storage failed, err: invalid endpoint %s", endpoint)
+	}
+	r.endpoint = u
+	return r, nil
+}
+
+func (r *S3Storage) Stat(name string) (os.FileInfo, error) {
+	resp, err := r.do(http.MethodHead, r.key(name), nil, nil, nil, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &remoteFileInfo{name: name, size: resp.ContentLength, modTime: modTime}, nil
+}
+
+func (r *S3Storage) Create(name string) (io.WriteCloser, error) {
+	return &s3Writer{storage: r, key: r.key(name)}, nil
+}
+
+func (r *S3Storage) Rename(oldName, newName string) error {
+	f, err := r.Stat(oldName)
+	if err != nil {
+		return err
+	}
+	if err := r.copy(r.key(oldName), r.key(newName), f.Size()); err != nil {
+		return err
+	}
+	return r.Remove(oldName)
+}
+
+func (r *S3Storage) Remove(name string) error {
+	_, err := r.do(http.MethodDelete, r.key(name), nil, nil, nil, http.StatusNoContent, http.StatusOK)
+	return err
+}
+
+// key returns the key of the file name in the bucket.
+func (r *S3Storage) key(name string) string {
+	return strings.TrimPrefix(path.Join(r.option.Prefix, filepath.ToSlash(name)), "/")
+}
+
+// copy copies the object src of size to dst, in parts when it's too large for one request.
+func (r *S3Storage) copy(src, dst string, size int64) error {
+	header := http.Header{"X-Amz-Copy-Source": {s3Escape("/"+r.option.Bucket+"/"+src, false)}}
+	if size <= maxS3CopySize {
+		resp, err := r.do(http.MethodPut, dst, nil, header, nil, http.StatusOK)
+		if err != nil {
+			return err
+		}
+		return s3ResultError(http.MethodPut, dst, resp)
+	}
+
+	upload, err := r.createMultipartUpload(dst)
+	if err != nil {
+		return err
+	}
+	for start := int64(0); start < size; start += s3CopyPartSize {
+		end := start + s3CopyPartSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		header.Set("X-Amz-Copy-Source-Range", fmt.Sprintf("bytes=%d-%d", start, end))
+		query := url.Values{"partNumber": {strconv.Itoa(len(upload.parts) + 1)}, "uploadId": {upload.id}}
+		resp, err := r.do(http.MethodPut, dst, query, header, nil, http.StatusOK)
+		if err != nil {
+			r.abortMultipartUpload(upload)
+			return err
+		}
+		result := new(struct {
+			ETag string `xml:"ETag"`
+		})
+		if err := xml.Unmarshal(resp.body, result); err != nil || result.ETag == "" {
+			r.abortMultipartUpload(upload)
+			return fmt.Errorf("copy %s to %s failed, err: %w", src, dst, s3ResultError(http.MethodPut, dst, resp))
+		}
+		upload.parts = append(upload.parts, s3Part{Number: len(upload.parts) + 1, ETag: result.ETag})
+	}
+	return r.completeMultipartUpload(upload)
+}
+
+type s3Upload struct {
+	key   string
+	id    string
+	parts []s3Part
+}
+
+type s3Part struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+func (r *S3Storage) createMultipartUpload(key string) (*s3Upload, error) {
+	resp, err := r.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, nil, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	result := new(struct {
+		UploadID string `xml:"UploadId"`
+	})
+	if err := xml.Unmarshal(resp.body, result); err != nil || result.UploadID == "" {
+		return nil, fmt.Errorf("create multipart upload of %s failed, response: %s", key, resp.body)
+	}
+	return &s3Upload{key: key, id: result.UploadID}, nil
+}
+
+func (r *S3Storage) uploadPart(upload *s3Upload, body []byte) error {
+	number := len(upload.parts) + 1
+	query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {upload.id}}
+	resp, err := r.do(http.MethodPut, upload.key, query, nil, body, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	upload.parts = append(upload.parts, s3Part{Number: number, ETag: resp.Header.Get("ETag")})
+	return nil
+}
+
+func (r *S3Storage) completeMultipartUpload(upload *s3Upload) error {
+	body, _ := xml.Marshal(&struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: upload.parts})
+	header := http.Header{"Content-Type": {"application/xml"}}
+	resp, err := r.do(http.MethodPost, upload.key, url.Values{"uploadId": {upload.id}}, header, body, http.StatusOK)
+	if err == nil {
+		err = s3ResultError(http.MethodPost, upload.key, resp)
+	}
+	if err != nil {
+		r.abortMultipartUpload(upload)
+	}
+	return err
+}
+
+// abortMultipartUpload drops the parts of a failed upload, so they aren't billed.
+func (r *S3Storage) abortMultipartUpload(upload *s3Upload) {
+	_, _ = r.do(http.MethodDelete, upload.key, url.Values{"uploadId": {upload.id}}, nil, nil, http.StatusNoContent)
+}
+
+type s3Response struct {
+	*http.Response
+	body []byte
+}
+
+// do sends the signed request of the object key, and fails when it doesn't answer one of the expect status.
+func (r *S3Storage) do(method, key string, query url.Values, header http.Header, body []byte, expect ...int) (*s3Response, error) {
+	u := *r.endpoint
+	p := "/" + key
+	if r.option.PathStyle {
+		p = "/" + r.option.Bucket + p
+	} else {
+		u.Host = r.option.Bucket + "." + u.Host
+	}
+	u.Path = p
+	u.RawPath = s3Escape(p, false)
+	u.RawQuery = s3CanonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed, err: %w", method, key, err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	r.sign(req, body, time.Now())
+
+	resp, err := r.httpCli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed, err: %w", method, key, err)
+	}
+	defer resp.Body.Close()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed, err: %w", method, key, err)
+	}
+	for _, status := range expect {
+		if resp.StatusCode == status {
+			return &s3Response{Response: resp, body: bs}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s failed, status %d, response: %s", method, key, resp.StatusCode, bs)
+}
+
+// s3ResultError returns the error of a copy or a completed upload, S3 can answer them with 200 and an error in the body.
+func s3ResultError(method, key string, resp *s3Response) error {
+	result := new(struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	})
+	if xml.Unmarshal(resp.body, result) == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("%s %s failed, err: %s: %s", method, key, result.Code, result.Message)
+	}
+	return nil
+}
+
+// sign signs the request with AWS Signature Version 4.
+func (r *S3Storage) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + r.option.Region + "/s3/aws4_request"
+	payloadSum := s3EmptyPayloadSum
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadSum = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadSum)
+	if r.option.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", r.option.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadSum,
+	}, "\n")
+	requestSum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestSum[:])
+
+	key := []byte("AWS4" + r.option.SecretAccessKey)
+	for _, v := range []string{now.Format("20060102"), r.option.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, v)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		r.option.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes s like AWS Signature Version 4 does, every byte but the unreserved characters, and '/' unless encodeSlash.
+func s3Escape(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' || (c == '/' && !encodeSlash) {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// s3CanonicalQuery encodes the query sorted by key, as signed.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Writer uploads a file in parts as it's written, a file smaller than a part is uploaded in one request on Close.
+type s3Writer struct {
+	storage *S3Storage
+	key     string
+	buf     []byte
+	upload  *s3Upload
+	err     error
+}
+
+func (r *s3Writer) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n := len(p)
+	partSize := int(r.storage.option.PartSize)
+	for len(p) > 0 {
+		size := partSize - len(r.buf)
+		if size > len(p) {
+			size = len(p)
+		}
+		r.buf = append(r.buf, p[:size]...)
+		p = p[size:]
+		if len(r.buf) < partSize {
+			continue
+		}
+		if r.upload == nil {
+			if r.upload, r.err = r.storage.createMultipartUpload(r.key); r.err != nil {
+				return 0, r.err
+			}
+		}
+		if r.err = r.storage.uploadPart(r.upload, r.buf); r.err != nil {
+			r.storage.abortMultipartUpload(r.upload)
+			return 0, r.err
+		}
+		r.buf = r.buf[:0]
+	}
+	return n, nil
+}
+
+func (r *s3Writer) Close() error {
+	if r.err != nil {
+		return r.err
+	}
+	r.err = fmt.Errorf("write %s failed, err: closed", r.key)
+	if r.upload == nil {
+		_, err := r.storage.do(http.MethodPut, r.key, nil, nil, r.buf, http.StatusOK)
+		return err
+	}
+	if len(r.buf) > 0 {
+		if err := r.storage.uploadPart(r.upload, r.buf); err != nil {
+			r.storage.abortMultipartUpload(r.upload)
+			return err
+		}
+	}
+	return r.storage.completeMultipartUpload(r.upload)
+}
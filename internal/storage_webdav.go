@@ -0,0 +1,182 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// WebDAVStorageOption is the dir of a WebDAV server a WebDAVStorage writes to.
+type WebDAVStorageOption struct {
+	// URL of the dir, like https://host/remote.php/dav/files/user/Photos
+	URL      string
+	Username string
+	Password string
+	// HTTPClient sends the requests, nil is http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// WebDAVStorage is the Storage of a dir of a WebDAV server, the assets are streamed to the server without touching the local disk.
+//
+// It doesn't implement StorageChtimes nor StorageAppend, so an interrupted download starts over.
+type WebDAVStorage struct {
+	base     *url.URL
+	username string
+	password string
+	httpCli  *http.Client
+
+	lock        sync.Mutex
+	createdDirs map[string]bool
+}
+
+// NewWebDAVStorage returns the storage of the dir of option.
+func NewWebDAVStorage(option *WebDAVStorageOption) (*WebDAVStorage, error) {
+	u, err := url.Parse(option.URL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("new webdav storage failed, err: invalid url %s", option.URL)
+	}
+	r := &WebDAVStorage{
+		base:        u,
+		username:    option.Username,
+		password:    option.Password,
+		httpCli:     option.HTTPClient,
+		createdDirs: map[string]bool{},
+	}
+	if r.httpCli == nil {
+		r.httpCli = http.DefaultClient
+	}
+	return r, nil
+}
+
+func (r *WebDAVStorage) Stat(name string) (os.FileInfo, error) {
+	resp, err := r.do(http.MethodHead, r.fileURL(name), nil, nil, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &remoteFileInfo{name: name, size: resp.ContentLength, modTime: modTime}, nil
+}
+
+// Create streams the file to the server as it's written, it's complete once closed.
+func (r *WebDAVStorage) Create(name string) (io.WriteCloser, error) {
+	if err := r.mkdirAll(path.Dir(r.rel(name))); err != nil {
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	res := &webdavWriter{PipeWriter: writer, done: make(chan error, 1)}
+	go func() {
+		_, err := r.do(http.MethodPut, r.fileURL(name), reader, nil, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+		// a failed upload fails the next writes
+		reader.CloseWithError(err)
+		res.done <- err
+	}()
+	return res, nil
+}
+
+func (r *WebDAVStorage) Rename(oldName, newName string) error {
+	if err := r.mkdirAll(path.Dir(r.rel(newName))); err != nil {
+		return err
+	}
+	header := http.Header{"Destination": {r.fileURL(newName)}, "Overwrite": {"T"}}
+	_, err := r.do("MOVE", r.fileURL(oldName), nil, header, http.StatusCreated, http.StatusNoContent)
+	return err
+}
+
+func (r *WebDAVStorage) Remove(name string) error {
+	resp, err := r.do(http.MethodDelete, r.fileURL(name), nil, nil, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	return nil
+}
+
+// rel returns the slash separated path of the file name in the dir.
+func (r *WebDAVStorage) rel(name string) string {
+	return path.Clean("/" + filepath.ToSlash(name))
+}
+
+func (r *WebDAVStorage) fileURL(name string) string {
+	u := *r.base
+	u.Path = path.Join(r.base.Path, r.rel(name))
+	u.RawPath = ""
+	return u.String()
+}
+
+// mkdirAll creates dir and its parents in the dir of the storage, the dirs created are remembered.
+func (r *WebDAVStorage) mkdirAll(dir string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	cur := "/"
+	for _, name := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		cur = path.Join(cur, name)
+		if r.createdDirs[cur] {
+			continue
+		}
+		// 405: it already exists
+		if _, err := r.do("MKCOL", r.fileURL(cur), nil, nil, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+			return err
+		}
+		r.createdDirs[cur] = true
+	}
+	return nil
+}
+
+// do sends the request, and fails when it doesn't answer one of the expect status.
+func (r *WebDAVStorage) do(method, rawURL string, body io.Reader, header http.Header, expect ...int) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed, err: %w", method, rawURL, err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := r.httpCli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed, err: %w", method, rawURL, err)
+	}
+	defer resp.Body.Close()
+	for _, status := range expect {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+	bs, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("%s %s failed, status %d, response: %s", method, rawURL, resp.StatusCode, bs)
+}
+
+// webdavWriter is the file of WebDAVStorage.Create, written to the body of the PUT request.
+type webdavWriter struct {
+	*io.PipeWriter
+	done chan error
+
+	once sync.Once
+	err  error
+}
+
+// Close finishes the body, and returns the error of the request.
+func (r *webdavWriter) Close() error {
+	r.once.Do(func() {
+		_ = r.PipeWriter.Close()
+		r.err = <-r.done
+	})
+	return r.err
+}
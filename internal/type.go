@@ -11,13 +11,15 @@ type SessionData struct {
 	SessionID      string `json:"session_id"`
 	AccountCountry string `json:"account_country"`
 	TrustToken     string `json:"trust_token"`
+	// RepairSessionToken is sent with a signin answered with 412, for the repair step, it's not saved
+	RepairSessionToken string `json:"-"`
 }
 
 type ValidateData struct {
 	DsInfo                       *ValidateDataDsInfo    `json:"dsInfo"`
 	HasMinimumDeviceForPhotosWeb bool                   `json:"hasMinimumDeviceForPhotosWeb"`
 	ICDPEnabled                  bool                   `json:"iCDPEnabled"`
-	Webservices                  map[string]*webService `json:"webservices"`
+	Webservices                  map[string]*WebService `json:"webservices"`
 	PcsEnabled                   bool                   `json:"pcsEnabled"`
 	TermsUpdateNeeded            bool                   `json:"termsUpdateNeeded"`
 	ConfigBag                    struct {
@@ -120,7 +122,8 @@ type ValidateDataApp struct {
 	IsQualifiedForBeta     bool `json:"isQualifiedForBeta"`     // Numbers
 }
 
-type webService struct {
+// WebService is an iCloud service endpoint of the account, like "ckdatabasews", from the validate response.
+type WebService struct {
 	PcsRequired bool   `json:"pcsRequired"`
 	URL         string `json:"url"`
 	UploadURL   string `json:"uploadUrl"`